@@ -3,21 +3,41 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"github.com/findosh/truenorth/internal/config"
 	"github.com/findosh/truenorth/internal/handlers"
+	"github.com/findosh/truenorth/internal/metrics"
 	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
 	"github.com/findosh/truenorth/internal/services/analytics"
 	"github.com/findosh/truenorth/internal/services/auth"
+	"github.com/findosh/truenorth/internal/services/digest"
+	"github.com/findosh/truenorth/internal/services/mail"
 	"github.com/findosh/truenorth/internal/services/marketdata"
+	"github.com/findosh/truenorth/internal/services/oauth"
+	"github.com/findosh/truenorth/internal/services/webhook"
 	"github.com/findosh/truenorth/internal/storage"
+	"github.com/findosh/truenorth/web"
+	"github.com/shopspring/decimal"
 )
 
+// shutdownTimeout bounds how long shutdown waits for in-flight requests and
+// webhook deliveries
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	rollback := flag.Int("rollback", 0, "roll back the N most recent database migrations and exit")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.Load()
 
@@ -28,6 +48,15 @@ func main() {
 	}
 	defer db.Close()
 
+	if *rollback > 0 {
+		if err := db.MigrateDown(*rollback); err != nil {
+			log.Fatalf("Failed to roll back migrations: %v", err)
+		}
+		version, _ := db.SchemaVersion()
+		log.Printf("Rolled back %d migration(s); schema is now at version %d", *rollback, version)
+		return
+	}
+
 	// Run migrations
 	if err := db.Migrate(); err != nil {
 		log.Fatalf("Failed to run migrations: %v", err)
@@ -35,48 +64,112 @@ func main() {
 
 	// Initialize repositories
 	userRepo := storage.NewUserRepository(db)
+	if cfg.EncryptSecrets {
+		if err := userRepo.EnableEncryption(cfg.EncryptionKey); err != nil {
+			log.Fatalf("Failed to enable encryption: %v", err)
+		}
+		n, err := userRepo.EncryptExisting()
+		if err != nil {
+			log.Fatalf("Failed to encrypt existing secrets: %v", err)
+		}
+		if n > 0 {
+			log.Printf("Encrypted MFA secrets for %d user(s)", n)
+		}
+	}
 	sessionRepo := storage.NewSessionRepository(db)
+	verificationRepo := storage.NewEmailVerificationRepository(db)
 	portfolioRepo := storage.NewPortfolioRepository(db)
 	holdingRepo := storage.NewHoldingRepository(db)
+	if cfg.HoldingHistory {
+		holdingRepo.EnableHistory()
+	}
+	lotRepo := storage.NewHoldingLotRepository(db)
+	shareRepo := storage.NewShareRepository(db)
 	scenarioRepo := storage.NewScenarioRepository(db)
+	overrideRepo := storage.NewTickerOverrideRepository(db)
+	webhookRepo := storage.NewWebhookRepository(db)
+	alertStateRepo := storage.NewAlertStateRepository(db)
+	transactionRepo := storage.NewTransactionRepository(db)
+	digestRepo := storage.NewDigestRepository(db)
 
 	// Initialize services
-	authService := auth.NewService(cfg, userRepo, sessionRepo)
+	var mailer mail.HTMLSender = mail.LogSender{}
+	if cfg.SMTPAddr != "" {
+		mailer = mail.NewSMTPSender(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+	authService := auth.NewService(cfg, userRepo, sessionRepo, verificationRepo, mailer)
+	models.CashYield = decimal.NewFromFloat(cfg.CashYield)
 	analyticsService := analytics.NewService()
 	marketDataService := marketdata.NewService(marketdata.Config{
 		Provider: marketdata.ProviderMock, // Use mock data for development
 		CacheTTL: 0,                        // Use default cache TTL
 	})
+	analyticsService.SetHistorySource(marketDataService)
+	analyticsService.SetTransactionSource(transactionRepo)
+	analyticsService.SetExpenseRatioSource(marketDataService)
+	webhookService := webhook.NewService(webhookRepo)
+	digestService, err := digest.NewService(digest.Config{
+		BaseURL:     cfg.BaseURL,
+		Secret:      cfg.SecretKey,
+		Users:       userRepo,
+		Portfolios:  portfolioRepo,
+		Scenarios:   scenarioRepo,
+		AlertStates: alertStateRepo,
+		Digests:     digestRepo,
+		Analytics:   analyticsService,
+		Prices:      marketDataService,
+		Mailer:      mailer,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize digests: %v", err)
+	}
+	var googleOAuth *oauth.Google
+	if cfg.GoogleLoginEnabled() {
+		googleOAuth = oauth.NewGoogle(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL)
+	}
 
-	// Get template directory
-	templateDir := getTemplateDir()
+	// Templates and static files are built in; a web directory on disk
+	// replaces them so edits show up without rebuilding
+	templates, static := web.Templates(), web.Static()
+	if cfg.WebDir != "" {
+		templates = os.DirFS(filepath.Join(cfg.WebDir, "templates"))
+		static = os.DirFS(filepath.Join(cfg.WebDir, "static"))
+		log.Printf("Serving templates and static files from %s", cfg.WebDir)
+	}
 
 	// Initialize handlers
 	h, err := handlers.New(
 		cfg,
-		templateDir,
+		templates,
 		authService,
 		analyticsService,
 		marketDataService,
 		userRepo,
 		portfolioRepo,
 		holdingRepo,
+		lotRepo,
+		shareRepo,
 		scenarioRepo,
+		overrideRepo,
+		webhookRepo,
+		alertStateRepo,
+		transactionRepo,
+		webhookService,
+		digestService,
+		googleOAuth,
 	)
 	if err != nil {
 		log.Fatalf("Failed to initialize handlers: %v", err)
 	}
 
 	// Initialize auth middleware
-	authMiddleware := middleware.NewAuth(authService)
+	authMiddleware := middleware.NewAuth(authService, cfg.IsProduction())
 
 	// Setup routes
 	mux := http.NewServeMux()
 
 	// Static files
-	staticDir := getStaticDir()
-	fs := http.FileServer(http.Dir(staticDir))
-	mux.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
 
 	// Public routes
 	mux.HandleFunc("/", h.Home)
@@ -95,6 +188,11 @@ func main() {
 		}
 	})
 	mux.HandleFunc("/logout", h.Logout)
+	mux.HandleFunc("/auth/refresh", h.Refresh)
+	mux.HandleFunc("/auth/verify", h.VerifyEmail)
+	mux.Handle("/auth/verify/resend", authMiddleware.RequireAuth(http.HandlerFunc(h.ResendVerification)))
+	mux.HandleFunc("/auth/google/login", h.GoogleLogin)
+	mux.HandleFunc("/auth/google/callback", h.GoogleCallback)
 
 	// Protected routes (require authentication)
 	mux.Handle("/dashboard", authMiddleware.RequireAuth(http.HandlerFunc(h.Dashboard)))
@@ -113,81 +211,201 @@ func main() {
 			h.ImportPage(w, r)
 		}
 	})))
+	mux.Handle("/api/import/preview", authMiddleware.RequireAuth(http.HandlerFunc(h.PreviewImport)))
+	mux.Handle("/api/import/confirm", authMiddleware.RequireAuth(http.HandlerFunc(h.ConfirmImport)))
+	mux.Handle("/holdings/edit", authMiddleware.RequireAuth(http.HandlerFunc(h.EditHolding)))
 	mux.Handle("/scenarios", authMiddleware.RequireAuth(http.HandlerFunc(h.ScenariosPage)))
 
 	// API routes - Scenarios
 	mux.Handle("/api/scenarios/simulate", authMiddleware.RequireAuth(http.HandlerFunc(h.SimulateScenario)))
+	mux.Handle("/api/scenarios/glidepath", authMiddleware.RequireAuth(http.HandlerFunc(h.SimulateGlidePath)))
 	mux.Handle("/api/scenarios", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodPost:
 			h.SaveScenario(w, r)
+		case http.MethodPut:
+			h.UpdateScenario(w, r)
 		case http.MethodDelete:
 			h.DeleteScenario(w, r)
 		default:
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
 	})))
+	// API routes - Webhooks
+	mux.Handle("/api/webhooks", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.ListWebhooks(w, r)
+		case http.MethodPost:
+			h.CreateWebhook(w, r)
+		case http.MethodDelete:
+			h.DeleteWebhook(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.Handle("/api/webhooks/test", authMiddleware.RequireAuth(http.HandlerFunc(h.TestWebhook)))
+	// API routes - Portfolio sharing
+	mux.Handle("/api/portfolios/shares", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.ListShares(w, r)
+		case http.MethodPost:
+			h.CreateShare(w, r)
+		case http.MethodDelete:
+			h.DeleteShare(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.Handle("/api/portfolios/", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.APIHoldings(w, r)
+		case http.MethodPost:
+			h.AddHolding(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	// API routes - Transactions
+	mux.Handle("/api/transactions", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.ListTransactions(w, r)
+		case http.MethodPost:
+			h.CreateTransaction(w, r)
+		case http.MethodDelete:
+			h.DeleteTransaction(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
 	mux.Handle("/api/template.csv", http.HandlerFunc(h.DownloadTemplate))
 
 	// API routes - Analytics (P1 features)
 	mux.Handle("/api/analytics/performance", authMiddleware.RequireAuth(http.HandlerFunc(h.APIPerformance)))
+	mux.Handle("/api/holdings/", authMiddleware.RequireAuth(http.HandlerFunc(h.APIHoldingPerformance)))
 	mux.Handle("/api/analytics/risk-reward", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRiskReward)))
 	mux.Handle("/api/analytics/expenses", authMiddleware.RequireAuth(http.HandlerFunc(h.APIExpenses)))
+	mux.Handle("/api/analytics/diversification", authMiddleware.RequireAuth(http.HandlerFunc(h.APIDiversification)))
+	mux.Handle("/api/analytics/frontier", authMiddleware.RequireAuth(http.HandlerFunc(h.APIFrontier)))
 	mux.Handle("/api/analytics/time-series", authMiddleware.RequireAuth(http.HandlerFunc(h.APITimeSeries)))
+	mux.Handle("/api/analytics/rolling", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRollingReturns)))
+	mux.Handle("/api/analytics/compare", authMiddleware.RequireAuth(http.HandlerFunc(h.APIComparePortfolios)))
+	mux.Handle("/api/analytics/deploy-cash", authMiddleware.RequireAuth(http.HandlerFunc(h.APIDeployCash)))
+	mux.Handle("/api/analytics/retirement", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRetirement)))
+	mux.Handle("/api/analytics/realized-gains", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRealizedGains)))
+	mux.Handle("/api/alerts", authMiddleware.RequireAuth(http.HandlerFunc(h.APIAlerts)))
+	mux.Handle("/api/alerts/state", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			h.SetAlertState(w, r)
+		case http.MethodDelete:
+			h.ClearAlertState(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	// API routes - Weekly digest
+	mux.Handle("/api/digest", authMiddleware.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			h.GetDigestSubscription(w, r)
+		case http.MethodPost:
+			h.SetDigestSubscription(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.Handle("/api/digest/preview", authMiddleware.RequireAuth(http.HandlerFunc(h.PreviewDigest)))
+	mux.HandleFunc("/digest/unsubscribe", h.UnsubscribeDigest)
 	mux.Handle("/api/market/status", http.HandlerFunc(h.APIMarketStatus))
 	mux.Handle("/api/market/quote", authMiddleware.RequireAuth(http.HandlerFunc(h.APIQuote)))
+	mux.Handle("/api/market/intraday", authMiddleware.RequireAuth(http.HandlerFunc(h.APIIntraday)))
+	mux.Handle("/api/portfolio/allocation", authMiddleware.RequireAuth(http.HandlerFunc(h.APIAllocation)))
+	mux.Handle("/api/portfolio/target", authMiddleware.RequireAuth(http.HandlerFunc(h.SetTargetScenario)))
 	mux.Handle("/api/portfolio/refresh", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRefreshPrices)))
+	mux.Handle("/api/portfolios/refresh-all", authMiddleware.RequireAuth(http.HandlerFunc(h.APIRefreshAllPrices)))
 
 	// Apply global middleware
 	handler := middleware.Chain(
 		mux,
 		middleware.Recover,
-		middleware.SecurityHeaders,
-		middleware.Logger,
+		middleware.SecurityHeaders(middleware.ContentSecurityPolicy{
+			ScriptSources: cfg.CSPScriptSources,
+			StyleSources:  cfg.CSPStyleSources,
+			ImageSources:  cfg.CSPImageSources,
+		}),
+		middleware.RequestLogger(cfg.LogFormat),
+		middleware.Metrics(mux),
+		middleware.NewCORS(cfg.CORSAllowedOrigins).Handler,
 	)
 
+	// Serve metrics on their own listener so they're never exposed on the
+	// public port
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{Addr: cfg.MetricsAddr, Handler: metricsMux}
+		go func() {
+			log.Printf("Metrics available on http://%s/metrics", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("Metrics server failed: %v", err)
+			}
+		}()
+	}
+
 	// Start server
 	addr := ":" + cfg.Port
+	server := &http.Server{Addr: addr, Handler: handler}
 	log.Printf("TrueNorth server starting on http://localhost%s", addr)
 	log.Printf("Environment: %s", cfg.Environment)
 
-	if err := http.ListenAndServe(addr, handler); err != nil {
-		log.Fatalf("Server failed: %v", err)
-	}
-}
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
 
-func getTemplateDir() string {
-	// Try relative path first
-	if _, err := os.Stat("web/templates"); err == nil {
-		return "web/templates"
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+
+	// Send weekly digests in the background until shutdown
+	digestsDone := make(chan struct{})
+	if cfg.DigestCheckInterval > 0 {
+		go func() {
+			defer close(digestsDone)
+			digestService.Run(signals, cfg.DigestCheckInterval)
+		}()
+	} else {
+		close(digestsDone)
 	}
 
-	// Try from executable location
-	exe, _ := os.Executable()
-	dir := filepath.Dir(exe)
-	templateDir := filepath.Join(dir, "web", "templates")
-	if _, err := os.Stat(templateDir); err == nil {
-		return templateDir
+	select {
+	case err := <-serveErr:
+		log.Fatalf("Server failed: %v", err)
+	case <-signals.Done():
 	}
 
-	// Fallback
-	return "web/templates"
-}
+	// Stop taking requests and let in-flight ones and background webhook
+	// deliveries finish before the database is closed
+	log.Printf("Shutting down")
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
 
-func getStaticDir() string {
-	// Try relative path first
-	if _, err := os.Stat("web/static"); err == nil {
-		return "web/static"
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown: %v", err)
 	}
-
-	// Try from executable location
-	exe, _ := os.Executable()
-	dir := filepath.Dir(exe)
-	staticDir := filepath.Join(dir, "web", "static")
-	if _, err := os.Stat(staticDir); err == nil {
-		return staticDir
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.Printf("Metrics server shutdown: %v", err)
+		}
+	}
+	if err := webhookService.Wait(ctx); err != nil {
+		log.Printf("Webhook deliveries still pending: %v", err)
+	}
+	select {
+	case <-digestsDone:
+	case <-ctx.Done():
+		log.Printf("Weekly digests still sending: %v", ctx.Err())
 	}
-
-	// Fallback
-	return "web/static"
 }
@@ -4,6 +4,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,6 +13,26 @@ type Config struct {
 	// Server settings
 	Port        string
 	Environment string // "development" or "production"
+	BaseURL     string // Public URL, for links in emails
+	WebDir      string // Templates and static files on disk, replacing the embedded ones
+
+	// Outgoing email; logged instead of sent when SMTPAddr is empty
+	SMTPAddr     string
+	SMTPFrom     string
+	SMTPUsername string
+	SMTPPassword string
+
+	// How often to look for users due a weekly digest; 0 stops sending them
+	DigestCheckInterval time.Duration
+
+	// Largest import upload accepted, in bytes
+	MaxImportSize int64
+
+	// Current money-market yield in percent, which cash is expected to earn
+	CashYield float64
+
+	// Default cost basis method for realized gains, "fifo" or "average"
+	CostBasisMethod string
 
 	// Database
 	DatabaseURL string
@@ -20,24 +41,71 @@ type Config struct {
 	SecretKey     string // For JWT signing
 	EncryptionKey string // For AES encryption
 
+	// Google sign-in; enabled when the client ID and secret are set
+	GoogleClientID     string
+	GoogleClientSecret string
+	GoogleRedirectURL  string
+
 	// Session settings
-	SessionDuration time.Duration
+	SessionDuration     time.Duration // Lifetime of a refresh token
+	AccessTokenDuration time.Duration // Lifetime of an access token
+
+	// Logging
+	LogFormat string // "text" or "json"
+
+	// CORS
+	CORSAllowedOrigins []string // Origins allowed to call /api/ routes
+
+	// Content Security Policy sources allowed beyond 'self'
+	CSPScriptSources []string
+	CSPStyleSources  []string
+	CSPImageSources  []string
+
+	// Metrics
+	MetricsAddr string // Listen address for /metrics; empty disables it
 
 	// Feature flags
-	EnableMFA bool
+	EnableMFA      bool
+	HoldingHistory bool // Record before/after snapshots when holdings change
+	EncryptSecrets bool // Encrypt sensitive columns with EncryptionKey
 }
 
 // Load reads configuration from environment variables with sensible defaults
 func Load() *Config {
-	return &Config{
-		Port:            getEnv("TRUENORTH_PORT", "8080"),
-		Environment:     getEnv("TRUENORTH_ENV", "development"),
-		DatabaseURL:     getEnv("TRUENORTH_DATABASE_URL", "truenorth.db"),
-		SecretKey:       getEnv("TRUENORTH_SECRET_KEY", "dev-secret-key-change-in-production"),
-		EncryptionKey:   getEnv("TRUENORTH_ENCRYPTION_KEY", "dev-encryption-key-32bytes!"),
-		SessionDuration: getDurationEnv("TRUENORTH_SESSION_DURATION", 24*time.Hour),
-		EnableMFA:       getBoolEnv("TRUENORTH_ENABLE_MFA", false),
+	cfg := &Config{
+		Port:                getEnv("TRUENORTH_PORT", "8080"),
+		Environment:         getEnv("TRUENORTH_ENV", "development"),
+		BaseURL:             strings.TrimRight(getEnv("TRUENORTH_BASE_URL", "http://localhost:8080"), "/"),
+		WebDir:              getEnv("TRUENORTH_WEB_DIR", ""),
+		SMTPAddr:            getEnv("TRUENORTH_SMTP_ADDR", ""),
+		SMTPFrom:            getEnv("TRUENORTH_SMTP_FROM", "TrueNorth <no-reply@localhost>"),
+		SMTPUsername:        getEnv("TRUENORTH_SMTP_USERNAME", ""),
+		SMTPPassword:        getEnv("TRUENORTH_SMTP_PASSWORD", ""),
+		DigestCheckInterval: getDurationEnv("TRUENORTH_DIGEST_CHECK_INTERVAL", time.Hour),
+		CostBasisMethod:     getEnv("TRUENORTH_COST_BASIS_METHOD", "fifo"),
+		CashYield:           getFloatEnv("TRUENORTH_CASH_YIELD", 4.5),
+		MaxImportSize:       getIntEnv("TRUENORTH_MAX_IMPORT_MB", 100) << 20,
+		DatabaseURL:         getEnv("TRUENORTH_DATABASE_URL", "truenorth.db"),
+		SecretKey:           getEnv("TRUENORTH_SECRET_KEY", "dev-secret-key-change-in-production"),
+		EncryptionKey:       getEnv("TRUENORTH_ENCRYPTION_KEY", "dev-encryption-key-32bytes!"),
+		SessionDuration:     getDurationEnv("TRUENORTH_SESSION_DURATION", 24*time.Hour),
+		AccessTokenDuration: getDurationEnv("TRUENORTH_ACCESS_TOKEN_DURATION", 15*time.Minute),
+		GoogleClientID:      getEnv("TRUENORTH_GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:  getEnv("TRUENORTH_GOOGLE_CLIENT_SECRET", ""),
+		GoogleRedirectURL:   getEnv("TRUENORTH_GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
+		LogFormat:           getEnv("TRUENORTH_LOG_FORMAT", "text"),
+		MetricsAddr:         getEnv("TRUENORTH_METRICS_ADDR", ""),
+		CORSAllowedOrigins:  getListEnv("TRUENORTH_CORS_ORIGINS"),
+		CSPScriptSources:    getListEnvDefault("TRUENORTH_CSP_SCRIPT_SRC", "https://cdn.jsdelivr.net"),
+		CSPStyleSources:     getListEnvDefault("TRUENORTH_CSP_STYLE_SRC", "'unsafe-inline'"),
+		CSPImageSources:     getListEnvDefault("TRUENORTH_CSP_IMG_SRC", "data:"),
+		EnableMFA:           getBoolEnv("TRUENORTH_ENABLE_MFA", false),
+		HoldingHistory:      getBoolEnv("TRUENORTH_HOLDING_HISTORY", false),
 	}
+
+	// Secrets are encrypted at rest in production unless turned off
+	cfg.EncryptSecrets = getBoolEnv("TRUENORTH_ENCRYPT_SECRETS", cfg.IsProduction())
+	return cfg
 }
 
 // IsDevelopment returns true if running in development mode
@@ -45,6 +113,11 @@ func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
 }
 
+// GoogleLoginEnabled returns true if Google sign-in is configured
+func (c *Config) GoogleLoginEnabled() bool {
+	return c.GoogleClientID != "" && c.GoogleClientSecret != ""
+}
+
 // IsProduction returns true if running in production mode
 func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
@@ -57,6 +130,25 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getListEnv splits a comma-separated variable, dropping empty entries
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getListEnvDefault is getListEnv with defaults for when the variable is unset
+func getListEnvDefault(key string, defaultValues ...string) []string {
+	if values := getListEnv(key); len(values) > 0 {
+		return values
+	}
+	return defaultValues
+}
+
 func getBoolEnv(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := strconv.ParseBool(value); err == nil {
@@ -66,6 +158,24 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getIntEnv(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if parsed, err := time.ParseDuration(value); err == nil {
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// alertView is an alert with the key used to acknowledge or dismiss it and
+// any decision already made about it
+type alertView struct {
+	models.Alert
+	Key        string             `json:"key"`
+	Status     models.AlertStatus `json:"status,omitempty"`
+	StatusAt   *time.Time         `json:"status_at,omitempty"`
+	Resurfaced bool               `json:"resurfaced,omitempty"` // Dismissed, but has since worsened
+}
+
+// applyAlertStates pairs alerts with their saved states, leaving out
+// dismissed ones unless includeDismissed is set
+func (h *Handler) applyAlertStates(portfolioID uuid.UUID, alerts []models.Alert, includeDismissed bool) []alertView {
+	var states map[string]*models.AlertState
+	if h.alertStateRepo != nil {
+		var err error
+		if states, err = h.alertStateRepo.GetByPortfolioID(portfolioID); err != nil {
+			log.Printf("alerts: loading states for portfolio %s: %v", portfolioID, err)
+		}
+	}
+
+	views := make([]alertView, 0, len(alerts))
+	for _, alert := range alerts {
+		view := alertView{Alert: alert, Key: alert.Key()}
+		state, ok := states[view.Key]
+		switch {
+		case !ok:
+		case state.Hides(alert) && !includeDismissed:
+			continue
+		case state.Status == models.AlertStatusDismissed && !state.Hides(alert):
+			view.Resurfaced = true
+		default:
+			view.Status = state.Status
+			view.StatusAt = &state.UpdatedAt
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// SetAlertState acknowledges or dismisses one of a portfolio's current
+// alerts. Dismissing remembers how bad the alert was, so it comes back if it
+// gets materially worse.
+func (h *Handler) SetAlertState(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		PortfolioID string `json:"portfolio_id"`
+		Key         string `json:"key"`
+		Status      string `json:"status"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	status, err := models.ParseAlertStatus(input.Status)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	portfolio.CalculateTotals()
+	alerts := models.NewAlertDetector().DetectAlertsWithTarget(portfolio, portfolio.CalculateLookThroughAllocation(), h.targetScenario(portfolio))
+
+	var alert *models.Alert
+	for i := range alerts {
+		if alerts[i].Key() == input.Key {
+			alert = &alerts[i]
+			break
+		}
+	}
+	if alert == nil {
+		h.jsonError(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+
+	state := &models.AlertState{
+		PortfolioID: portfolio.ID,
+		AlertKey:    input.Key,
+		Status:      status,
+		Value:       alert.Value,
+	}
+	if err := h.alertStateRepo.Upsert(state); err != nil {
+		h.jsonError(w, "Failed to save alert state", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+// ClearAlertState un-dismisses or un-acknowledges an alert
+func (h *Handler) ClearAlertState(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, r.URL.Query().Get("portfolio"))
+	if !ok {
+		return
+	}
+
+	if err := h.alertStateRepo.Delete(portfolio.ID, r.URL.Query().Get("key")); err != nil {
+		h.jsonError(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// getEditablePortfolio loads a portfolio by ID, writing an error response
+// unless the user can edit it
+func (h *Handler) getEditablePortfolio(w http.ResponseWriter, user *models.User, portfolioID string) (*models.Portfolio, bool) {
+	pid, err := uuid.Parse(portfolioID)
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	portfolio, err := h.getViewablePortfolio(user, pid)
+	if err != nil || portfolio == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return nil, false
+	}
+	return portfolio, true
+}
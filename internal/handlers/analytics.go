@@ -2,13 +2,24 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/findosh/truenorth/internal/middleware"
 	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/findosh/truenorth/internal/services/marketdata"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
-// APIPerformance returns portfolio performance data as JSON
+// APIPerformance returns portfolio performance data as JSON, broken down by
+// account with ?groupBy=account
 func (h *Handler) APIPerformance(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	if user == nil {
@@ -33,13 +44,82 @@ func (h *Handler) APIPerformance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	groupBy := r.URL.Query().Get("groupBy")
+	if groupBy != "" && groupBy != "account" {
+		h.jsonError(w, "groupBy must be account", http.StatusBadRequest)
+		return
+	}
+
 	portfolio.CalculateTotals()
 	performance := h.analyticsService.CalculatePortfolioPerformance(portfolio, period)
+	if performance != nil && groupBy == "account" {
+		performance.Accounts = h.analyticsService.CalculateAccountPerformance(portfolio)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(performance)
 }
 
+// APIHoldingPerformance returns one holding's return, volatility and max
+// drawdown over a period (default 1y), measured from its ticker's price
+// history, as JSON. The path is /api/holdings/{id}/performance.
+func (h *Handler) APIHoldingPerformance(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/holdings/"), "/")
+	if rest != "performance" {
+		h.jsonError(w, "Not found", http.StatusNotFound)
+		return
+	}
+	holdingID, err := uuid.Parse(id)
+	if err != nil {
+		h.jsonError(w, "Invalid holding ID", http.StatusBadRequest)
+		return
+	}
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.Period1Year
+	}
+
+	// Verify access through the holding's portfolio
+	holding, err := h.holdingRepo.GetByID(holdingID)
+	if err != nil || holding == nil {
+		h.jsonError(w, "Holding not found", http.StatusNotFound)
+		return
+	}
+	portfolio, err := h.portfolioRepo.GetSummary(holding.PortfolioID)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Holding not found", http.StatusNotFound)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	metrics, err := h.analyticsService.CalculateHoldingPerformance(holding, period)
+	switch {
+	case errors.Is(err, analytics.ErrInvalidPeriod):
+		h.jsonError(w, "Period must be one of 1w, 1m, 3m, 6m, 1y, 3y, 5y, ytd", http.StatusBadRequest)
+		return
+	case errors.Is(err, analytics.ErrNoPriceHistory):
+		h.jsonError(w, "No price history for "+holding.Ticker, http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
 // APIRiskReward returns risk-reward matrix as JSON
 func (h *Handler) APIRiskReward(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
@@ -96,6 +176,63 @@ func (h *Handler) APIExpenses(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(expenses)
 }
 
+// APIDiversification returns the portfolio diversification score as JSON
+func (h *Handler) APIDiversification(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID := r.URL.Query().Get("portfolio")
+
+	portfolio, err := h.getPortfolioForUser(user, portfolioID)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	portfolio.CalculateTotals()
+	diversification := h.analyticsService.CalculateDiversification(portfolio)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diversification)
+}
+
+// APIFrontier returns the efficient frontier for the portfolio's asset
+// classes as JSON
+func (h *Handler) APIFrontier(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID := r.URL.Query().Get("portfolio")
+
+	portfolio, err := h.getPortfolioForUser(user, portfolioID)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	portfolio.CalculateTotals()
+	frontier := h.analyticsService.CalculateEfficientFrontier(portfolio)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(frontier)
+}
+
 // APITimeSeries returns historical value time series as JSON
 func (h *Handler) APITimeSeries(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
@@ -128,6 +265,247 @@ func (h *Handler) APITimeSeries(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(timeSeries)
 }
 
+// APIRollingReturns returns rolling returns over a window (default 1y) as
+// JSON
+func (h *Handler) APIRollingReturns(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID := r.URL.Query().Get("portfolio")
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		window = models.Period1Year
+	}
+
+	portfolio, err := h.getPortfolioForUser(user, portfolioID)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Value snapshots aren't recorded yet, so the series is estimated
+	portfolio.CalculateTotals()
+	rolling, err := h.analyticsService.GenerateRollingReturns(portfolio, nil, window)
+	if err != nil {
+		h.jsonError(w, "Window must be one of 1m, 3m, 6m, 1y, 3y, 5y", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rolling)
+}
+
+// APIComparePortfolios returns two of the user's portfolios side by side,
+// with the differences between them, as JSON
+func (h *Handler) APIComparePortfolios(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	idA, errA := uuid.Parse(r.URL.Query().Get("a"))
+	idB, errB := uuid.Parse(r.URL.Query().Get("b"))
+	if errA != nil || errB != nil {
+		h.jsonError(w, "Both a and b must be portfolio IDs", http.StatusBadRequest)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = models.Period1Year
+	}
+
+	// Unlike the single-portfolio endpoints, there's no fallback to the
+	// user's first portfolio: both must exist and be theirs
+	a, err := h.getViewablePortfolio(user, idA)
+	if err != nil {
+		h.jsonError(w, "Failed to load portfolio", http.StatusInternalServerError)
+		return
+	}
+	b, err := h.getViewablePortfolio(user, idB)
+	if err != nil {
+		h.jsonError(w, "Failed to load portfolio", http.StatusInternalServerError)
+		return
+	}
+	if a == nil || b == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	comparison := h.analyticsService.ComparePortfolios(a, b, period)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comparison)
+}
+
+// APIAlerts returns portfolio alerts, including drift from the target
+// scenario, as JSON. Dismissed alerts are left out unless
+// ?include_dismissed=true.
+func (h *Handler) APIAlerts(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID := r.URL.Query().Get("portfolio")
+
+	portfolio, err := h.getPortfolioForUser(user, portfolioID)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolio.CalculateTotals()
+	alerts := h.detectAlerts(portfolio, portfolio.CalculateLookThroughAllocation())
+	includeDismissed := r.URL.Query().Get("include_dismissed") == "true"
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.applyAlertStates(portfolio.ID, alerts, includeDismissed))
+}
+
+// detectAlerts runs the alert detector, checking drift against the
+// portfolio's target scenario
+func (h *Handler) detectAlerts(portfolio *models.Portfolio, allocation *models.AllocationSummary) []models.Alert {
+	alerts := models.NewAlertDetector().DetectAlertsWithTarget(portfolio, allocation, h.targetScenario(portfolio))
+
+	// Push anything new to the user's webhooks without holding up the page
+	if h.webhookSvc != nil {
+		h.webhookSvc.NotifyAlertsInBackground(portfolio.UserID, portfolio.ID, alerts)
+	}
+
+	return alerts
+}
+
+// targetScenario returns the portfolio's target scenario, or the most
+// recently saved scenario when no target has been chosen. It's nil when the
+// portfolio has no scenarios.
+func (h *Handler) targetScenario(portfolio *models.Portfolio) *models.Scenario {
+	var target *models.Scenario
+	if portfolio.TargetScenarioID != nil {
+		target, _ = h.scenarioRepo.GetByID(*portfolio.TargetScenarioID)
+	}
+	if target == nil {
+		if scenarios, err := h.scenarioRepo.GetByPortfolioID(portfolio.ID); err == nil && len(scenarios) > 0 {
+			target = scenarios[0] // Newest first
+		}
+	}
+	return target
+}
+
+// APIDeployCash suggests how to invest free cash above the cash-drag
+// threshold, moving toward the target scenario, as JSON
+func (h *Handler) APIDeployCash(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolioID := r.URL.Query().Get("portfolio")
+
+	portfolio, err := h.getPortfolioForUser(user, portfolioID)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	portfolio.CalculateTotals()
+	deployment := h.analyticsService.SuggestCashDeployment(portfolio, h.targetScenario(portfolio), models.DefaultThresholds())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deployment)
+}
+
+// APIRetirement projects whether a portfolio can fund a retirement plan.
+// Amounts are annual and in today's dollars; current_value defaults to the
+// portfolio's value.
+func (h *Handler) APIRetirement(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	portfolio, err := h.getPortfolioForUser(user, query.Get("portfolio"))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	portfolio.CalculateTotals()
+	plan := models.RetirementPlan{CurrentValue: portfolio.TotalValue}
+	amounts := []struct {
+		param string
+		dest  *decimal.Decimal
+	}{
+		{"current_value", &plan.CurrentValue},
+		{"contribution", &plan.AnnualContribution},
+		{"spending", &plan.AnnualSpending},
+	}
+	for _, a := range amounts {
+		if v := query.Get(a.param); v != "" {
+			amount, err := decimal.NewFromString(v)
+			if err != nil || amount.IsNegative() {
+				h.jsonError(w, a.param+" must be a non-negative amount", http.StatusBadRequest)
+				return
+			}
+			*a.dest = amount
+		}
+	}
+
+	years := []struct {
+		param string
+		dest  *int
+	}{
+		{"years", &plan.YearsToRetirement},
+		{"retirement_years", &plan.RetirementYears},
+	}
+	for _, y := range years {
+		if v := query.Get(y.param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 || n > models.MaxProjectionYears {
+				h.jsonError(w, fmt.Sprintf("%s must be between 0 and %d", y.param, models.MaxProjectionYears), http.StatusBadRequest)
+				return
+			}
+			*y.dest = n
+		}
+	}
+
+	projection := h.analyticsService.ProjectRetirement(portfolio, plan)
+	if projection == nil {
+		h.jsonError(w, "Portfolio has no holdings to project", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projection)
+}
+
 // APIMarketStatus returns current market status
 func (h *Handler) APIMarketStatus(w http.ResponseWriter, r *http.Request) {
 	if h.marketDataSvc == nil {
@@ -149,18 +527,22 @@ func (h *Handler) APIQuote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ticker := r.URL.Query().Get("ticker")
-	if ticker == "" {
+	if r.URL.Query().Get("ticker") == "" {
 		h.jsonError(w, "ticker parameter required", http.StatusBadRequest)
 		return
 	}
+	ticker, err := models.NormalizeTicker(r.URL.Query().Get("ticker"))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if h.marketDataSvc == nil {
 		h.jsonError(w, "Market data service not available", http.StatusServiceUnavailable)
 		return
 	}
 
-	quote, err := h.marketDataSvc.GetQuote(ticker)
+	quote, err := h.marketDataSvc.GetQuote(ticker, r.URL.Query().Get("fresh") == "true")
 	if err != nil {
 		h.jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -170,6 +552,54 @@ func (h *Handler) APIQuote(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(quote)
 }
 
+// APIIntraday returns intraday candles for a ticker's current or most
+// recent session
+func (h *Handler) APIIntraday(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Query().Get("ticker") == "" {
+		h.jsonError(w, "ticker parameter required", http.StatusBadRequest)
+		return
+	}
+	ticker, err := models.NormalizeTicker(r.URL.Query().Get("ticker"))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	interval := r.URL.Query().Get("interval")
+	if interval == "" {
+		interval = marketdata.Interval5m
+	}
+
+	if h.marketDataSvc == nil {
+		h.jsonError(w, "Market data service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	candles, err := h.marketDataSvc.GetIntraday(ticker, interval)
+	if errors.Is(err, marketdata.ErrInvalidInterval) {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ticker":         ticker,
+		"interval":       interval,
+		"is_market_open": h.marketDataSvc.IsMarketOpen(),
+		"candles":        candles,
+	})
+}
+
 // APIRefreshPrices updates portfolio with live prices
 func (h *Handler) APIRefreshPrices(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
@@ -185,6 +615,10 @@ func (h *Handler) APIRefreshPrices(w http.ResponseWriter, r *http.Request) {
 		h.jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
 
 	if h.marketDataSvc == nil {
 		h.jsonError(w, "Market data service not available", http.StatusServiceUnavailable)
@@ -192,16 +626,17 @@ func (h *Handler) APIRefreshPrices(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update prices
-	if err := h.marketDataSvc.UpdatePortfolioValues(portfolio); err != nil {
+	unpriced, err := h.marketDataSvc.UpdatePortfolioValues(portfolio)
+	if err != nil {
 		h.jsonError(w, "Failed to refresh prices: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Save updated holdings
-	for _, holding := range portfolio.Holdings {
-		if err := h.holdingRepo.Update(&holding); err != nil {
+	for i := range portfolio.Holdings {
+		if err := h.holdingRepo.Update(&portfolio.Holdings[i]); err != nil {
 			// Log but don't fail
-			continue
+			log.Printf("refresh: saving holding %s: %v", portfolio.Holdings[i].ID, err)
 		}
 	}
 
@@ -216,29 +651,132 @@ func (h *Handler) APIRefreshPrices(w http.ResponseWriter, r *http.Request) {
 		"success":     true,
 		"total_value": portfolio.TotalValue,
 		"holdings":    len(portfolio.Holdings),
+		"unpriced":    unpriced, // Tickers that kept their previous prices
 	})
 }
 
-// Helper to get portfolio for authenticated user
-func (h *Handler) getPortfolioForUser(user *models.User, portfolioID string) (*models.Portfolio, error) {
-	portfolios, err := h.portfolioRepo.GetByUserID(user.ID)
+// APIRefreshAllPrices updates every one of the user's portfolios with live
+// prices, fetching each ticker once across all of them
+func (h *Handler) APIRefreshAllPrices(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if h.marketDataSvc == nil {
+		h.jsonError(w, "Market data service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Load every portfolio with its holdings
+	var portfolios []*models.Portfolio
+	for offset := 0; ; offset += storage.MaxPageSize {
+		page, total, err := h.portfolioRepo.GetByUserID(user.ID, storage.Page{Limit: storage.MaxPageSize, Offset: offset})
+		if err != nil {
+			h.jsonError(w, "Failed to load portfolios", http.StatusInternalServerError)
+			return
+		}
+		for _, p := range page {
+			portfolio, err := h.portfolioRepo.GetByID(p.ID)
+			if err != nil || portfolio == nil {
+				h.jsonError(w, "Failed to load portfolios", http.StatusInternalServerError)
+				return
+			}
+			portfolios = append(portfolios, portfolio)
+		}
+		if len(page) == 0 || offset+len(page) >= total {
+			break
+		}
+	}
+
+	// Update prices
+	unpriced, err := h.marketDataSvc.UpdatePortfoliosValues(portfolios)
 	if err != nil {
-		return nil, err
+		h.jsonError(w, "Failed to refresh prices: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	if len(portfolios) == 0 {
-		return nil, err
+	type portfolioTotal struct {
+		ID         uuid.UUID       `json:"id"`
+		Name       string          `json:"name"`
+		TotalValue decimal.Decimal `json:"total_value"`
+		Holdings   int             `json:"holdings"`
 	}
 
-	// If specific ID requested, find it
-	if portfolioID != "" {
-		for _, p := range portfolios {
-			if p.ID.String() == portfolioID {
-				return h.portfolioRepo.GetByID(p.ID)
+	totalValue := decimal.Zero
+	results := make([]portfolioTotal, 0, len(portfolios))
+	for _, portfolio := range portfolios {
+		// Save updated holdings
+		for i := range portfolio.Holdings {
+			if err := h.holdingRepo.Update(&portfolio.Holdings[i]); err != nil {
+				// Log but don't fail
+				log.Printf("refresh: saving holding %s: %v", portfolio.Holdings[i].ID, err)
 			}
 		}
+
+		if err := h.portfolioRepo.Update(portfolio); err != nil {
+			h.jsonError(w, "Failed to save portfolio: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		totalValue = totalValue.Add(portfolio.TotalValue)
+		results = append(results, portfolioTotal{
+			ID:         portfolio.ID,
+			Name:       portfolio.Name,
+			TotalValue: portfolio.TotalValue,
+			Holdings:   len(portfolio.Holdings),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"total_value": totalValue,
+		"portfolios":  results,
+		"unpriced":    unpriced,
+	})
+}
+
+// Helper to get portfolio for authenticated user. Portfolios shared with
+// them can be read too. Falls back to the user's newest portfolio, or the
+// newest one shared with them, when no ID is given or they can't see it.
+func (h *Handler) getPortfolioForUser(user *models.User, portfolioID string) (*models.Portfolio, error) {
+	// If specific ID requested, find it
+	if pid, err := uuid.Parse(portfolioID); err == nil {
+		portfolio, err := h.portfolioRepo.GetByID(pid)
+		if err != nil {
+			return nil, err
+		}
+		if portfolio != nil && h.canAccess(user, portfolio, accessView) {
+			return portfolio, nil
+		}
+	}
+
+	portfolios, _, err := h.portfolioRepo.GetByUserID(user.ID, storage.Page{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(portfolios) == 0 {
+		if portfolios, err = h.portfolioRepo.GetSharedWithUser(user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(portfolios) == 0 {
+		return nil, errors.New("no portfolios found")
 	}
 
 	// Return first portfolio
 	return h.portfolioRepo.GetByID(portfolios[0].ID)
 }
+
+// getViewablePortfolio loads a portfolio by ID, returning nil if it doesn't
+// exist or the user can't see it
+func (h *Handler) getViewablePortfolio(user *models.User, id uuid.UUID) (*models.Portfolio, error) {
+	portfolio, err := h.portfolioRepo.GetByID(id)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		return nil, err
+	}
+	return portfolio, nil
+}
@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/findosh/truenorth/internal/services/marketdata"
+	"github.com/shopspring/decimal"
+)
+
+func TestAPIRefreshPrices_SavesHoldings(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.marketDataSvc = marketdata.NewService(marketdata.Config{Provider: marketdata.ProviderMock})
+
+	user, portfolio := createTestUser(t, h, "refresh@example.com")
+	for _, ticker := range []string{"AAPL", "MSFT"} {
+		holding := models.NewHolding(portfolio.ID, ticker, ticker, "Brokerage")
+		holding.Quantity = decimal.NewFromInt(10)
+		if err := h.holdingRepo.Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/portfolio/refresh?portfolio="+portfolio.ID.String(), nil)
+	w := httptest.NewRecorder()
+	h.APIRefreshPrices(w, withUser(r, user))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refresh: got status %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	saved, err := h.portfolioRepo.GetByID(portfolio.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to reload portfolio: %v", err)
+	}
+	if len(saved.Holdings) != 2 {
+		t.Fatalf("Holdings: got %d, want 2", len(saved.Holdings))
+	}
+
+	// Each holding keeps its own price, not a copy of the last one refreshed
+	quotes, _ := h.marketDataSvc.GetQuotes([]string{"AAPL", "MSFT"}, false)
+	for _, holding := range saved.Holdings {
+		want := quotes[holding.Ticker].Price
+		if !holding.CurrentPrice.Equal(want) {
+			t.Errorf("%s price: got %s, want %s", holding.Ticker, holding.CurrentPrice, want)
+		}
+		if wantValue := want.Mul(holding.Quantity); !holding.MarketValue.Equal(wantValue) {
+			t.Errorf("%s market value: got %s, want %s", holding.Ticker, holding.MarketValue, wantValue)
+		}
+	}
+}
+
+func TestAPIComparePortfolios_Ownership(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.analyticsService = analytics.NewService()
+
+	owner, first := createTestUser(t, h, "owner@example.com")
+	_, others := createTestUser(t, h, "other@example.com")
+
+	second := models.NewPortfolio(owner.ID, "Second Portfolio")
+	if err := h.portfolioRepo.Create(second); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"both owned", "a=" + first.ID.String() + "&b=" + second.ID.String(), http.StatusOK},
+		{"other user's portfolio", "a=" + first.ID.String() + "&b=" + others.ID.String(), http.StatusNotFound},
+		{"missing b", "a=" + first.ID.String(), http.StatusBadRequest},
+		{"invalid id", "a=" + first.ID.String() + "&b=nope", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/analytics/compare?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			h.APIComparePortfolios(w, withUser(r, owner))
+			if w.Code != tt.want {
+				t.Errorf("Status: got %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPortfolioSharing_Roles(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.analyticsService = analytics.NewService()
+	h.marketDataSvc = marketdata.NewService(marketdata.Config{Provider: marketdata.ProviderMock})
+
+	owner, portfolio := createTestUser(t, h, "owner@example.com")
+	viewer, _ := createTestUser(t, h, "viewer@example.com")
+	editor, _ := createTestUser(t, h, "editor@example.com")
+	stranger, _ := createTestUser(t, h, "stranger@example.com")
+
+	share := func(email, role string) int {
+		body := `{"portfolio_id":"` + portfolio.ID.String() + `","email":"` + email + `","role":"` + role + `"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/portfolios/shares", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.CreateShare(w, withUser(r, owner))
+		return w.Code
+	}
+	// Sharing waits until the owner has confirmed their email
+	if code := share("viewer@example.com", "viewer"); code != http.StatusForbidden {
+		t.Errorf("Share while unverified: got status %d, want %d", code, http.StatusForbidden)
+	}
+	owner.Verified = true
+
+	if code := share("viewer@example.com", "viewer"); code != http.StatusCreated {
+		t.Fatalf("Share with viewer: got status %d", code)
+	}
+	if code := share("editor@example.com", "editor"); code != http.StatusCreated {
+		t.Fatalf("Share with editor: got status %d", code)
+	}
+	if code := share("nobody@example.com", "viewer"); code != http.StatusNotFound {
+		t.Errorf("Share with unknown email: got status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := share("owner@example.com", "viewer"); code != http.StatusBadRequest {
+		t.Errorf("Share with owner: got status %d, want %d", code, http.StatusBadRequest)
+	}
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		read    int
+		refresh int
+	}{
+		{"owner", owner, http.StatusOK, http.StatusOK},
+		{"editor", editor, http.StatusOK, http.StatusOK},
+		{"viewer", viewer, http.StatusOK, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/analytics/compare?a="+portfolio.ID.String()+"&b="+portfolio.ID.String(), nil)
+			w := httptest.NewRecorder()
+			h.APIComparePortfolios(w, withUser(r, tt.user))
+			if w.Code != tt.read {
+				t.Errorf("Read: got status %d, want %d: %s", w.Code, tt.read, w.Body.String())
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/api/portfolio/refresh?portfolio="+portfolio.ID.String(), nil)
+			w = httptest.NewRecorder()
+			h.APIRefreshPrices(w, withUser(r, tt.user))
+			if w.Code != tt.refresh {
+				t.Errorf("Refresh: got status %d, want %d: %s", w.Code, tt.refresh, w.Body.String())
+			}
+		})
+	}
+
+	// A user it isn't shared with can't see it
+	r := httptest.NewRequest(http.MethodGet, "/api/analytics/compare?a="+portfolio.ID.String()+"&b="+portfolio.ID.String(), nil)
+	w := httptest.NewRecorder()
+	h.APIComparePortfolios(w, withUser(r, stranger))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Stranger read: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// Only the owner manages sharing
+	r = httptest.NewRequest(http.MethodGet, "/api/portfolios/shares?portfolio="+portfolio.ID.String(), nil)
+	w = httptest.NewRecorder()
+	h.ListShares(w, withUser(r, editor))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Editor list shares: got status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	r = httptest.NewRequest(http.MethodDelete, "/api/portfolios/shares?portfolio="+portfolio.ID.String()+"&user="+viewer.ID.String(), nil)
+	w = httptest.NewRecorder()
+	h.DeleteShare(w, withUser(r, owner))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Revoke: got status %d: %s", w.Code, w.Body.String())
+	}
+	r = httptest.NewRequest(http.MethodGet, "/api/analytics/compare?a="+portfolio.ID.String()+"&b="+portfolio.ID.String(), nil)
+	w = httptest.NewRecorder()
+	h.APIComparePortfolios(w, withUser(r, viewer))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Read after revoke: got status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestAlertStates_DismissAndResurface(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	user, portfolio := createTestUser(t, h, "alerts@example.com")
+	aapl := models.NewHolding(portfolio.ID, "AAPL", "Apple", "Brokerage")
+	aapl.AssetClass = models.AssetClassEquity
+	aapl.MarketValue = decimal.NewFromInt(20000)
+	bnd := models.NewHolding(portfolio.ID, "BND", "Bonds", "Brokerage")
+	bnd.AssetClass = models.AssetClassFixedIncome
+	bnd.MarketValue = decimal.NewFromInt(80000)
+	for _, holding := range []*models.Holding{aapl, bnd} {
+		if err := h.holdingRepo.Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+
+	const key = "concentration:AAPL"
+	find := func(query string) (found bool, view alertView) {
+		r := httptest.NewRequest(http.MethodGet, "/api/alerts?portfolio="+portfolio.ID.String()+query, nil)
+		w := httptest.NewRecorder()
+		h.APIAlerts(w, withUser(r, user))
+		var views []alertView
+		if err := json.NewDecoder(w.Body).Decode(&views); err != nil {
+			t.Fatalf("Decode alerts: %v", err)
+		}
+		for _, v := range views {
+			if v.Key == key {
+				return true, v
+			}
+		}
+		return false, alertView{}
+	}
+
+	if found, _ := find(""); !found {
+		t.Fatal("Expected an AAPL concentration alert")
+	}
+
+	body := `{"portfolio_id": "` + portfolio.ID.String() + `", "key": "` + key + `", "status": "dismissed"}`
+	r := httptest.NewRequest(http.MethodPost, "/api/alerts/state", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.SetAlertState(w, withUser(r, user))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Dismiss: got status %d: %s", w.Code, w.Body.String())
+	}
+
+	if found, _ := find(""); found {
+		t.Error("Dismissed alert should be hidden")
+	}
+	if found, v := find("&include_dismissed=true"); !found || v.Status != models.AlertStatusDismissed {
+		t.Errorf("include_dismissed: got found=%v status=%q", found, v.Status)
+	}
+
+	// A small move stays dismissed; doubling the position brings it back
+	aapl.MarketValue = decimal.NewFromInt(21000)
+	h.holdingRepo.Update(aapl)
+	if found, _ := find(""); found {
+		t.Error("Alert that barely moved should stay hidden")
+	}
+	aapl.MarketValue = decimal.NewFromInt(40000)
+	h.holdingRepo.Update(aapl)
+	if found, v := find(""); !found || !v.Resurfaced {
+		t.Errorf("Worsened alert: got found=%v resurfaced=%v", found, v.Resurfaced)
+	}
+
+	r = httptest.NewRequest(http.MethodDelete, "/api/alerts/state?portfolio="+portfolio.ID.String()+"&key="+key, nil)
+	w = httptest.NewRecorder()
+	h.ClearAlertState(w, withUser(r, user))
+	if found, v := find(""); !found || v.Resurfaced || v.Status != "" {
+		t.Errorf("Un-dismissed alert: got found=%v status=%q", found, v.Status)
+	}
+}
+
+func TestAPIHoldingPerformance(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.analyticsService = analytics.NewService()
+	h.analyticsService.SetHistorySource(marketdata.NewService(marketdata.Config{Provider: marketdata.ProviderMock}))
+
+	user, portfolio := createTestUser(t, h, "holding-performance@example.com")
+	other, _ := createTestUser(t, h, "holding-performance-other@example.com")
+	holding := models.NewHolding(portfolio.ID, "VOO", "Vanguard S&P 500 ETF", "IRA")
+	if err := h.holdingRepo.Create(holding); err != nil {
+		t.Fatalf("Failed to create holding: %v", err)
+	}
+
+	get := func(path string, user *models.User) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.APIHoldingPerformance(w, withUser(httptest.NewRequest(http.MethodGet, path, nil), user))
+		return w
+	}
+
+	w := get("/api/holdings/"+holding.ID.String()+"/performance?period=6m", user)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Performance: got status %d: %s", w.Code, w.Body.String())
+	}
+	var metrics models.PerformanceMetrics
+	if err := json.NewDecoder(w.Body).Decode(&metrics); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if metrics.Ticker != "VOO" || metrics.Period != models.Period6Month || !metrics.Volatility.IsPositive() {
+		t.Errorf("Got %+v, want VOO over 6m with a volatility", metrics)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		user *models.User
+		want int
+	}{
+		{"other user's holding", "/api/holdings/" + holding.ID.String() + "/performance", other, http.StatusNotFound},
+		{"unknown period", "/api/holdings/" + holding.ID.String() + "/performance?period=1d", user, http.StatusBadRequest},
+		{"invalid id", "/api/holdings/nope/performance", user, http.StatusBadRequest},
+		{"other path", "/api/holdings/" + holding.ID.String(), user, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := get(tt.path, tt.user); w.Code != tt.want {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body.String())
+		}
+	}
+}
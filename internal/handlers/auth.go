@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
 	"github.com/findosh/truenorth/internal/services/auth"
+	"github.com/findosh/truenorth/internal/services/oauth"
 )
 
 // LoginPage renders the login page
@@ -18,10 +23,11 @@ func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := map[string]interface{}{
-		"Title": "Login - TrueNorth",
-		"Error": r.URL.Query().Get("error"),
+		"Title":       "Login - TrueNorth",
+		"Error":       r.URL.Query().Get("error"),
+		"GoogleLogin": h.google != nil,
 	}
-	h.render(w, "login.html", data)
+	h.render(w, r, "login.html", data)
 }
 
 // Login handles login form submission
@@ -48,16 +54,8 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    result.Token,
-		Path:     "/",
-		Expires:  result.Expires,
-		HttpOnly: true,
-		Secure:   h.cfg.IsProduction(),
-		SameSite: http.SameSiteLaxMode,
-	})
+	// Set access and refresh token cookies
+	middleware.SetSessionCookies(w, result, h.cfg.IsProduction())
 
 	h.redirect(w, r, "/dashboard")
 }
@@ -74,7 +72,7 @@ func (h *Handler) RegisterPage(w http.ResponseWriter, r *http.Request) {
 		"Title": "Register - TrueNorth",
 		"Error": r.URL.Query().Get("error"),
 	}
-	h.render(w, "register.html", data)
+	h.render(w, r, "register.html", data)
 }
 
 // Register handles registration form submission
@@ -120,6 +118,11 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Confirm the address, without holding up registration if mail fails
+	if err := h.authService.SendVerification(user); err != nil {
+		log.Printf("register: %v", err)
+	}
+
 	// Auto-login after registration
 	result, err := h.authService.Login(auth.LoginInput{
 		Email:    user.Email,
@@ -130,35 +133,192 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set session cookie
+	// Set access and refresh token cookies
+	middleware.SetSessionCookies(w, result, h.cfg.IsProduction())
+
+	h.redirect(w, r, "/dashboard")
+}
+
+// VerifyEmail confirms an email address from the link sent on registration
+func (h *Handler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.authService.Verify(r.URL.Query().Get("token")); err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenExpired) {
+			h.redirect(w, r, "/login?error=That+verification+link+is+invalid+or+has+expired")
+			return
+		}
+		log.Printf("verify email: %v", err)
+		h.redirect(w, r, "/login?error=Verification+failed")
+		return
+	}
+
+	h.redirect(w, r, "/dashboard")
+}
+
+// ResendVerification emails the signed-in user a new verification link
+func (h *Handler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.redirect(w, r, "/login")
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.authService.SendVerification(user); err != nil {
+		log.Printf("resend verification: %v", err)
+	}
+	h.redirect(w, r, "/dashboard")
+}
+
+// requireVerified writes a 403 unless the user has confirmed their email.
+// It guards features that send mail or notifications on the user's behalf.
+func (h *Handler) requireVerified(w http.ResponseWriter, user *models.User) bool {
+	if user.Verified {
+		return true
+	}
+	h.jsonError(w, "Verify your email address first", http.StatusForbidden)
+	return false
+}
+
+// oauthStateCookie holds the state sent to the provider until it redirects
+// back, so the callback can't be forged from another site
+const oauthStateCookie = "oauth_state"
+
+// GoogleLogin redirects to Google's consent page
+func (h *Handler) GoogleLogin(w http.ResponseWriter, r *http.Request) {
+	if h.google == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, err := oauth.NewState()
+	if err != nil {
+		h.redirect(w, r, "/login?error=Google+sign-in+failed")
+		return
+	}
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    result.Token,
-		Path:     "/",
-		Expires:  result.Expires,
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/auth/google",
+		MaxAge:   600,
 		HttpOnly: true,
 		Secure:   h.cfg.IsProduction(),
 		SameSite: http.SameSiteLaxMode,
 	})
 
+	http.Redirect(w, r, h.google.AuthCodeURL(state), http.StatusFound)
+}
+
+// GoogleCallback finishes Google sign-in, logging in the account with the
+// Google email or creating one
+func (h *Handler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
+	if h.google == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	cookie, err := r.Cookie(oauthStateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || cookie.Value == "" || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		h.redirect(w, r, "/login?error=Google+sign-in+expired,+please+try+again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/auth/google", MaxAge: -1, HttpOnly: true})
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		// The user declined, or Google reported an error
+		h.redirect(w, r, "/login?error=Google+sign-in+cancelled")
+		return
+	}
+
+	profile, err := h.google.Exchange(r.Context(), code)
+	if err != nil {
+		log.Printf("google sign-in: %v", err)
+		if errors.Is(err, oauth.ErrUnverifiedEmail) {
+			h.redirect(w, r, "/login?error=Your+Google+email+is+not+verified")
+			return
+		}
+		h.redirect(w, r, "/login?error=Google+sign-in+failed")
+		return
+	}
+
+	result, err := h.authService.LoginWithProvider(models.AuthProviderGoogle, profile.Email, profile.Name)
+	if err != nil {
+		log.Printf("google sign-in: %v", err)
+		h.redirect(w, r, "/login?error=Google+sign-in+failed")
+		return
+	}
+
+	middleware.SetSessionCookies(w, result, h.cfg.IsProduction())
 	h.redirect(w, r, "/dashboard")
 }
 
-// Logout handles user logout
+// Refresh exchanges a refresh token for a new access token, rotating the
+// refresh token. Browsers send it in the refresh cookie; API clients can
+// post {"refresh_token": "..."} instead and get the new one back in the
+// response.
+func (h *Handler) Refresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	fromBody := false
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			h.jsonError(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		fromBody = input.RefreshToken != ""
+	}
+	if !fromBody {
+		if cookie, err := r.Cookie(middleware.RefreshCookie); err == nil {
+			input.RefreshToken = cookie.Value
+		}
+	}
+
+	result, err := h.authService.Refresh(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrSessionExpired) {
+			middleware.ClearSessionCookies(w)
+			h.jsonError(w, "Session expired", http.StatusUnauthorized)
+			return
+		}
+		h.jsonError(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	middleware.SetSessionCookies(w, result, h.cfg.IsProduction())
+
+	response := map[string]interface{}{
+		"access_token": result.Token,
+		"expires_at":   result.Expires,
+	}
+	if fromBody {
+		response["refresh_token"] = result.RefreshToken
+		response["refresh_expires_at"] = result.RefreshExpires
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Logout handles user logout, revoking the browser's refresh token
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
-	user := middleware.GetUser(r)
-	if user != nil {
-		h.authService.Logout(user.ID)
+	if cookie, err := r.Cookie(middleware.RefreshCookie); err == nil && cookie.Value != "" {
+		if err := h.authService.RevokeRefreshToken(cookie.Value); err != nil {
+			log.Printf("logout: revoking refresh token: %v", err)
+		}
 	}
 
-	// Clear session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
-		Expires:  time.Unix(0, 0),
-		HttpOnly: true,
-	})
+	// Clear session cookies
+	middleware.ClearSessionCookies(w)
 
 	h.redirect(w, r, "/login")
 }
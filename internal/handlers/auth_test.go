@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/config"
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/services/auth"
+	"github.com/findosh/truenorth/internal/services/mail"
+	"github.com/findosh/truenorth/internal/storage"
+)
+
+func TestRefresh_RotatesRefreshToken(t *testing.T) {
+	h, db := newTestHandler(t)
+	h.cfg = &config.Config{SecretKey: "test", SessionDuration: time.Hour, AccessTokenDuration: time.Minute}
+	h.authService = auth.NewService(h.cfg, h.userRepo, storage.NewSessionRepository(db), storage.NewEmailVerificationRepository(db), mail.LogSender{})
+
+	if _, err := h.authService.Register(auth.RegisterInput{Email: "refresh@example.com", Password: "password123", Name: "Refresh"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := h.authService.Login(auth.LoginInput{Email: "refresh@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if !login.Expires.Before(login.RefreshExpires) {
+		t.Errorf("Access token should expire before the refresh token")
+	}
+
+	refresh := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		r.AddCookie(&http.Cookie{Name: middleware.RefreshCookie, Value: token})
+		w := httptest.NewRecorder()
+		h.Refresh(w, r)
+		return w
+	}
+
+	w := refresh(login.RefreshToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Refresh: got status %d: %s", w.Code, w.Body.String())
+	}
+	var rotated string
+	for _, c := range w.Result().Cookies() {
+		if c.Name == middleware.RefreshCookie {
+			rotated = c.Value
+		}
+	}
+	if rotated == "" || rotated == login.RefreshToken {
+		t.Fatalf("Refresh should set a new refresh token cookie, got %q", rotated)
+	}
+	if w := refresh(rotated); w.Code != http.StatusOK {
+		t.Errorf("Rotated token: got status %d", w.Code)
+	}
+
+	// The old token covers one request already in flight, not replays
+	if w := refresh(login.RefreshToken); w.Code != http.StatusOK {
+		t.Errorf("Old token within the grace period: got status %d", w.Code)
+	}
+	if w := refresh(login.RefreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Old token replayed: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	// Revoked and unknown tokens are rejected
+	if err := h.authService.RevokeRefreshToken(rotated); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if w := refresh(rotated); w.Code != http.StatusUnauthorized {
+		t.Errorf("Revoked token: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := refresh("not-a-token"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Unknown token: got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+// recordingSender keeps sent mail for inspection
+type recordingSender struct {
+	to, body []string
+}
+
+func (s *recordingSender) Send(to, subject, body string) error {
+	s.to = append(s.to, to)
+	s.body = append(s.body, body)
+	return nil
+}
+
+func TestVerifyEmail(t *testing.T) {
+	h, db := newTestHandler(t)
+	h.cfg = &config.Config{SecretKey: "test", BaseURL: "http://truenorth.test", SessionDuration: time.Hour, AccessTokenDuration: time.Minute}
+	sent := &recordingSender{}
+	h.authService = auth.NewService(h.cfg, h.userRepo, storage.NewSessionRepository(db), storage.NewEmailVerificationRepository(db), sent)
+
+	user, err := h.authService.Register(auth.RegisterInput{Email: "verify@example.com", Password: "password123", Name: "Verify"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if user.Verified {
+		t.Fatal("New users should start unverified")
+	}
+	if err := h.authService.SendVerification(user); err != nil {
+		t.Fatalf("SendVerification: %v", err)
+	}
+	if len(sent.to) != 1 || sent.to[0] != "verify@example.com" {
+		t.Fatalf("Sent to %v, want verify@example.com", sent.to)
+	}
+
+	const prefix = "http://truenorth.test/auth/verify?token="
+	start := strings.Index(sent.body[0], prefix)
+	if start < 0 {
+		t.Fatalf("No verification link in %q", sent.body[0])
+	}
+	link := strings.Fields(sent.body[0][start:])[0]
+
+	verify := func(target string) string {
+		w := httptest.NewRecorder()
+		h.VerifyEmail(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Header().Get("Location")
+	}
+
+	if got := verify("/auth/verify?token=wrong"); !strings.HasPrefix(got, "/login?error=") {
+		t.Errorf("Bad token: redirected to %q, want the login page with an error", got)
+	}
+	if got := verify(strings.TrimPrefix(link, "http://truenorth.test")); got != "/dashboard" {
+		t.Errorf("Good token: redirected to %q, want /dashboard", got)
+	}
+
+	reloaded, _ := h.userRepo.GetByID(user.ID)
+	if !reloaded.Verified {
+		t.Error("User should be verified")
+	}
+
+	// Links only work once
+	if got := verify(strings.TrimPrefix(link, "http://truenorth.test")); !strings.HasPrefix(got, "/login?error=") {
+		t.Errorf("Reused token: redirected to %q, want the login page with an error", got)
+	}
+}
+
+func TestLoginWithProvider_ClaimsUnverifiedAccount(t *testing.T) {
+	h, db := newTestHandler(t)
+	h.cfg = &config.Config{SecretKey: "test", SessionDuration: time.Hour, AccessTokenDuration: time.Minute}
+	h.authService = auth.NewService(h.cfg, h.userRepo, storage.NewSessionRepository(db), storage.NewEmailVerificationRepository(db), mail.LogSender{})
+
+	// Someone registers the address first, with a password they know
+	squatter, err := h.authService.Register(auth.RegisterInput{Email: "victim@example.com", Password: "password123", Name: "Squatter"})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	login, err := h.authService.Login(auth.LoginInput{Email: "victim@example.com", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+
+	result, err := h.authService.LoginWithProvider("google", "Victim@example.com", "Victim")
+	if err != nil {
+		t.Fatalf("LoginWithProvider: %v", err)
+	}
+	if result.User.ID != squatter.ID || !result.User.Verified {
+		t.Fatalf("Got user %s verified=%v, want the existing account verified", result.User.ID, result.User.Verified)
+	}
+
+	if _, err := h.authService.Login(auth.LoginInput{Email: "victim@example.com", Password: "password123"}); err != auth.ErrInvalidCredentials {
+		t.Errorf("Password login: got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := h.authService.Refresh(login.RefreshToken); err != auth.ErrInvalidToken {
+		t.Errorf("Earlier refresh token: got %v, want ErrInvalidToken", err)
+	}
+	if _, err := h.authService.Refresh(result.RefreshToken); err != nil {
+		t.Errorf("Provider refresh token: %v", err)
+	}
+
+	reloaded, _ := h.userRepo.GetByID(squatter.ID)
+	if reloaded.PasswordHash != "" || reloaded.AuthProvider != "google" || !reloaded.Verified {
+		t.Errorf("Stored user: password cleared=%v provider=%q verified=%v", reloaded.PasswordHash == "", reloaded.AuthProvider, reloaded.Verified)
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// toBaseFunc converts a holding's amounts from its own currency into its
+// portfolio's base currency
+type toBaseFunc func(*models.Holding) error
+
+// fxRate returns how many units of base one unit of currency is worth
+func (h *Handler) fxRate(currency, base string) (decimal.Decimal, error) {
+	if currency == base {
+		return decimal.NewFromInt(1), nil
+	}
+	if h.marketDataSvc == nil {
+		return decimal.Zero, fmt.Errorf("no exchange rate from %s to %s", currency, base)
+	}
+	rate, err := h.marketDataSvc.GetFXRate(currency, base)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("no exchange rate from %s to %s", currency, base)
+	}
+	return rate, nil
+}
+
+// toBase returns a converter into base for holdings entered or imported in
+// their own currency, looking each currency's rate up once
+func (h *Handler) toBase(base string) toBaseFunc {
+	rates := map[string]decimal.Decimal{base: decimal.NewFromInt(1)}
+	return func(holding *models.Holding) error {
+		currency := holding.CurrencyCode()
+		rate, ok := rates[currency]
+		if !ok {
+			var err error
+			if rate, err = h.fxRate(currency, base); err != nil {
+				return err
+			}
+			rates[currency] = rate
+		}
+		if !rate.Equal(decimal.NewFromInt(1)) {
+			holding.ConvertAmounts(rate)
+		}
+		return nil
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/findosh/truenorth/internal/middleware"
 	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/storage"
 )
 
 // Dashboard renders the main dashboard
@@ -15,44 +17,65 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user's portfolios
-	portfolios, err := h.portfolioRepo.GetByUserID(user.ID)
+	// Get user's portfolios for the selector
+	portfolios, portfolioCount, err := h.portfolioRepo.GetByUserID(user.ID, storage.NewPage(0, 0))
 	if err != nil {
 		http.Error(w, "Failed to load portfolios", http.StatusInternalServerError)
 		return
 	}
 
+	// Portfolios shared with the user are listed after their own
+	shared, err := h.portfolioRepo.GetSharedWithUser(user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load portfolios", http.StatusInternalServerError)
+		return
+	}
+	portfolios = append(portfolios, shared...)
+
 	// If no portfolios, redirect to create one
-	if len(portfolios) == 0 {
+	if portfolioCount == 0 && len(shared) == 0 {
 		h.redirect(w, r, "/portfolio/new")
 		return
 	}
 
-	// Get the first portfolio (or selected one)
-	portfolio := portfolios[0]
-	if portfolioID := r.URL.Query().Get("portfolio"); portfolioID != "" {
-		for _, p := range portfolios {
-			if p.ID.String() == portfolioID {
-				portfolio = p
-				break
-			}
+	// Load the selected portfolio (or the newest) with holdings
+	fullPortfolio, err := h.getPortfolioForUser(user, r.URL.Query().Get("portfolio"))
+	if err != nil || fullPortfolio == nil {
+		http.Error(w, "Failed to load portfolio", http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the selected portfolio in the selector even if it's past the
+	// first page
+	selected := false
+	for _, p := range portfolios {
+		if p.ID == fullPortfolio.ID {
+			selected = true
+			break
 		}
 	}
+	if !selected {
+		portfolios = append(portfolios, fullPortfolio)
+	}
 
-	// Load full portfolio with holdings
-	fullPortfolio, err := h.portfolioRepo.GetByID(portfolio.ID)
+	// Page through the holdings table; analytics still use every holding
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	holdingsPage := storage.NewPage(limit, offset)
+	pageHoldings, holdingCount, err := h.holdingRepo.GetByPortfolioID(fullPortfolio.ID, holdingsPage)
 	if err != nil {
-		http.Error(w, "Failed to load portfolio", http.StatusInternalServerError)
+		http.Error(w, "Failed to load holdings", http.StatusInternalServerError)
 		return
 	}
 
 	// Calculate totals and allocation
 	fullPortfolio.CalculateTotals()
-	allocation := fullPortfolio.CalculateAllocation()
+	lookThrough := fullPortfolio.CalculateLookThroughAllocation()
+	allocation := allocationView(r, fullPortfolio, lookThrough)
 
-	// Detect alerts
-	alertDetector := models.NewAlertDetector()
-	alerts := alertDetector.DetectAlerts(fullPortfolio, allocation)
+	// Detect alerts against the look-through view so concentration hidden
+	// inside funds is caught whichever view is shown
+	alerts := h.applyAlertStates(fullPortfolio.ID, h.detectAlerts(fullPortfolio, lookThrough), false)
 
 	// Calculate analytics (P1 features)
 	var performance *models.PortfolioPerformance
@@ -83,13 +106,27 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		"Alerts":       alerts,
 		"AlertCount":   len(alerts),
 		"HasHoldings":  len(fullPortfolio.Holdings) > 0,
+		"PageHoldings": pageHoldings,
+		"HoldingsPage": newPagination(holdingsPage, holdingCount),
 		"Performance":  performance,
 		"RiskReward":   riskReward,
 		"Expenses":     expenses,
 		"MarketStatus": marketStatus,
 	}
 
-	h.render(w, "dashboard.html", data)
+	h.render(w, r, "dashboard.html", data)
+}
+
+// allocationView returns the allocation to display: funds looked through to
+// their holdings by default, or each holding as tagged with ?view=plain
+func allocationView(r *http.Request, portfolio *models.Portfolio, lookThrough *models.AllocationSummary) *models.AllocationSummary {
+	if r.URL.Query().Get("view") == "plain" {
+		return portfolio.CalculateAllocation()
+	}
+	if lookThrough == nil {
+		lookThrough = portfolio.CalculateLookThroughAllocation()
+	}
+	return lookThrough
 }
 
 // Home renders the landing page
@@ -103,5 +140,5 @@ func (h *Handler) Home(w http.ResponseWriter, r *http.Request) {
 	data := map[string]interface{}{
 		"Title": "TrueNorth - Unified Portfolio Intelligence",
 	}
-	h.render(w, "home.html", data)
+	h.render(w, r, "home.html", data)
 }
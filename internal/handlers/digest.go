@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/services/digest"
+	"github.com/google/uuid"
+)
+
+// GetDigestSubscription returns whether the user gets the weekly digest
+func (h *Handler) GetDigestSubscription(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sub, err := h.digestSvc.Subscription(user.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load subscription", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sub)
+}
+
+// SetDigestSubscription opts the user in to or out of the weekly digest.
+// Opting in needs a verified email address.
+func (h *Handler) SetDigestSubscription(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		Enabled bool `json:"enabled"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if input.Enabled && !h.requireVerified(w, user) {
+		return
+	}
+
+	if err := h.digestSvc.SetEnabled(user.ID, input.Enabled); err != nil {
+		h.jsonError(w, "Failed to save subscription", http.StatusInternalServerError)
+		return
+	}
+
+	h.GetDigestSubscription(w, r)
+}
+
+// PreviewDigest returns the digest the user would be sent now, without
+// sending it
+func (h *Handler) PreviewDigest(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	d, err := h.digestSvc.Build(user)
+	if err != nil {
+		log.Printf("digest preview: %v", err)
+		h.jsonError(w, "Failed to build digest", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// UnsubscribeDigest handles the link at the bottom of every digest, which
+// works without signing in
+func (h *Handler) UnsubscribeDigest(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user"))
+	if err != nil {
+		http.Error(w, "This unsubscribe link is invalid.", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.digestSvc.Unsubscribe(userID, r.URL.Query().Get("token")); err != nil {
+		if errors.Is(err, digest.ErrInvalidToken) {
+			http.Error(w, "This unsubscribe link is invalid.", http.StatusBadRequest)
+			return
+		}
+		log.Printf("digest unsubscribe: %v", err)
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("You've been unsubscribed from the TrueNorth weekly digest.\n"))
+}
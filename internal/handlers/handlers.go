@@ -4,13 +4,17 @@ package handlers
 import (
 	"fmt"
 	"html/template"
+	"io/fs"
 	"net/http"
-	"path/filepath"
 
 	"github.com/findosh/truenorth/internal/config"
+	"github.com/findosh/truenorth/internal/middleware"
 	"github.com/findosh/truenorth/internal/services/analytics"
 	"github.com/findosh/truenorth/internal/services/auth"
+	"github.com/findosh/truenorth/internal/services/digest"
 	"github.com/findosh/truenorth/internal/services/marketdata"
+	"github.com/findosh/truenorth/internal/services/oauth"
+	"github.com/findosh/truenorth/internal/services/webhook"
 	"github.com/findosh/truenorth/internal/storage"
 	"github.com/shopspring/decimal"
 )
@@ -25,30 +29,45 @@ type Handler struct {
 	userRepo         *storage.UserRepository
 	portfolioRepo    *storage.PortfolioRepository
 	holdingRepo      *storage.HoldingRepository
+	lotRepo          *storage.HoldingLotRepository
+	shareRepo        *storage.ShareRepository
 	scenarioRepo     *storage.ScenarioRepository
+	overrideRepo     *storage.TickerOverrideRepository
+	webhookRepo      *storage.WebhookRepository
+	alertStateRepo   *storage.AlertStateRepository
+	transactionRepo  *storage.TransactionRepository
+	webhookSvc       *webhook.Service
+	digestSvc        *digest.Service
+	google           *oauth.Google // nil when Google sign-in isn't configured
+	simulations      *simulationCache
+	pendingImports   *pendingImports
 }
 
 // New creates a new handler with all dependencies
 func New(
 	cfg *config.Config,
-	templateDir string,
+	templates fs.FS,
 	authService *auth.Service,
 	analyticsService *analytics.Service,
 	marketDataSvc *marketdata.Service,
 	userRepo *storage.UserRepository,
 	portfolioRepo *storage.PortfolioRepository,
 	holdingRepo *storage.HoldingRepository,
+	lotRepo *storage.HoldingLotRepository,
+	shareRepo *storage.ShareRepository,
 	scenarioRepo *storage.ScenarioRepository,
+	overrideRepo *storage.TickerOverrideRepository,
+	webhookRepo *storage.WebhookRepository,
+	alertStateRepo *storage.AlertStateRepository,
+	transactionRepo *storage.TransactionRepository,
+	webhookSvc *webhook.Service,
+	digestSvc *digest.Service,
+	google *oauth.Google,
 ) (*Handler, error) {
-	// Parse all templates
-	pattern := filepath.Join(templateDir, "**", "*.html")
-	tmpl, err := template.New("").Funcs(templateFuncs()).ParseGlob(pattern)
+	// Templates sit one directory down, in layouts/, pages/ and components/
+	tmpl, err := template.New("").Funcs(templateFuncs()).ParseFS(templates, "*/*.html")
 	if err != nil {
-		// Try alternative pattern
-		tmpl, err = parseTemplates(templateDir)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
 	return &Handler{
@@ -60,38 +79,50 @@ func New(
 		userRepo:         userRepo,
 		portfolioRepo:    portfolioRepo,
 		holdingRepo:      holdingRepo,
+		lotRepo:          lotRepo,
+		shareRepo:        shareRepo,
 		scenarioRepo:     scenarioRepo,
+		overrideRepo:     overrideRepo,
+		webhookRepo:      webhookRepo,
+		alertStateRepo:   alertStateRepo,
+		transactionRepo:  transactionRepo,
+		webhookSvc:       webhookSvc,
+		digestSvc:        digestSvc,
+		google:           google,
+		simulations:      newSimulationCache(simulationCacheTTL),
+		pendingImports:   newPendingImports(pendingImportTTL),
 	}, nil
 }
 
-func parseTemplates(dir string) (*template.Template, error) {
-	tmpl := template.New("").Funcs(templateFuncs())
+// pagination describes a page of a list for templates
+type pagination struct {
+	Limit      int
+	Offset     int
+	Total      int
+	From, To   int // 1-based range shown
+	HasPrev    bool
+	HasNext    bool
+	PrevOffset int
+	NextOffset int
+}
 
-	// Parse layouts
-	layouts, _ := filepath.Glob(filepath.Join(dir, "layouts", "*.html"))
-	for _, f := range layouts {
-		if _, err := tmpl.ParseFiles(f); err != nil {
-			return nil, err
-		}
+func newPagination(page storage.Page, total int) pagination {
+	p := pagination{
+		Limit:      page.Limit,
+		Offset:     page.Offset,
+		Total:      total,
+		HasPrev:    page.Offset > 0,
+		HasNext:    page.Offset+page.Limit < total,
+		NextOffset: page.Offset + page.Limit,
 	}
-
-	// Parse pages
-	pages, _ := filepath.Glob(filepath.Join(dir, "pages", "*.html"))
-	for _, f := range pages {
-		if _, err := tmpl.ParseFiles(f); err != nil {
-			return nil, err
-		}
+	if p.PrevOffset = page.Offset - page.Limit; p.PrevOffset < 0 {
+		p.PrevOffset = 0
 	}
-
-	// Parse components
-	components, _ := filepath.Glob(filepath.Join(dir, "components", "*.html"))
-	for _, f := range components {
-		if _, err := tmpl.ParseFiles(f); err != nil {
-			return nil, err
-		}
+	if total > 0 {
+		p.From = page.Offset + 1
+		p.To = min(page.Offset+page.Limit, total)
 	}
-
-	return tmpl, nil
+	return p
 }
 
 func templateFuncs() template.FuncMap {
@@ -195,8 +226,10 @@ func signClass(v interface{}) string {
 	return ""
 }
 
-// render renders a template with the given data
-func (h *Handler) render(w http.ResponseWriter, name string, data interface{}) {
+// render renders a template with the given data, adding the request's CSP
+// nonce as .Nonce for inline scripts
+func (h *Handler) render(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	data["Nonce"] = middleware.CSPNonce(r)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	if err := h.templates.ExecuteTemplate(w, name, data); err != nil {
 		http.Error(w, "Template error: "+err.Error(), http.StatusInternalServerError)
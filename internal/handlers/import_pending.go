@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// pendingImportTTL is how long a preview can be confirmed after it's made
+const pendingImportTTL = 30 * time.Minute
+
+// maxPendingImports caps how many of a user's previews wait for
+// confirmation at once; past it that user's oldest is dropped
+const maxPendingImports = 16
+
+// pendingImports keeps previews server-side until they're confirmed, so a
+// confirmation can only save what was parsed from the uploaded file
+type pendingImports struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]pendingImport
+}
+
+type pendingImport struct {
+	userID  uuid.UUID
+	preview importPreview
+	expires time.Time
+}
+
+func newPendingImports(ttl time.Duration) *pendingImports {
+	return &pendingImports{ttl: ttl, entries: make(map[string]pendingImport)}
+}
+
+// put keeps a user's preview, returning the token that confirms it
+func (p *pendingImports) put(userID uuid.UUID, preview importPreview) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	pending := 0
+	for k, entry := range p.entries {
+		switch {
+		case now.After(entry.expires):
+			delete(p.entries, k)
+		case entry.userID == userID:
+			pending++
+			if oldest == "" || entry.expires.Before(p.entries[oldest].expires) {
+				oldest = k
+			}
+		}
+	}
+	if pending >= maxPendingImports {
+		delete(p.entries, oldest)
+	}
+	p.entries[token] = pendingImport{userID: userID, preview: preview, expires: now.Add(p.ttl)}
+	return token, nil
+}
+
+// get returns the user's unexpired preview for token
+func (p *pendingImports) get(token string, userID uuid.UUID) (importPreview, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[token]
+	if !ok || entry.userID != userID || time.Now().After(entry.expires) {
+		return importPreview{}, false
+	}
+	return entry.preview, true
+}
+
+// claim forgets the user's unexpired preview as it's confirmed, reporting
+// false if it has expired or another request already claimed it, so a
+// preview is only ever saved once
+func (p *pendingImports) claim(token string, userID uuid.UUID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	entry, ok := p.entries[token]
+	if !ok || entry.userID != userID {
+		return false
+	}
+	delete(p.entries, token)
+	return !time.Now().After(entry.expires)
+}
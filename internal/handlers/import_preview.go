@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/importer"
+	"github.com/google/uuid"
+)
+
+// importPreview is what an import would save, returned without saving it
+type importPreview struct {
+	Token       string              `json:"token"` // Confirms this preview with ConfirmImport
+	PortfolioID uuid.UUID           `json:"portfolio_id"`
+	AccountName string              `json:"account_name"`
+	Mode        importer.ImportMode `json:"mode"`
+	Holdings    []models.Holding    `json:"holdings"`
+	Errors      []importer.RowError `json:"errors"`  // Rows that didn't become holdings
+	Inserts     int                 `json:"inserts"` // Holdings that would be added
+	Updates     int                 `json:"updates"` // Existing holdings that would change, when merging
+}
+
+// PreviewImport parses and classifies an uploaded file the same way as
+// ImportCSV, but returns the holdings instead of saving them, along with
+// why any rows didn't become holdings
+func (h *Handler) PreviewImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.parseImportForm(w, r); err != nil {
+		if errors.Is(err, importer.ErrFileTooLarge) {
+			h.importError(w, err, nil)
+			return
+		}
+		h.jsonError(w, "Invalid upload", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, r.FormValue("portfolio_id"))
+	if !ok {
+		return
+	}
+
+	mode, err := importer.ParseImportMode(r.FormValue("mode"))
+	if err != nil {
+		h.jsonError(w, "Invalid import mode", http.StatusBadRequest)
+		return
+	}
+
+	accountName := r.FormValue("account_name")
+	if accountName == "" {
+		accountName = "Imported Account"
+	}
+
+	records, err := readImportRecords(r)
+	if err != nil {
+		h.importError(w, err, nil)
+		return
+	}
+
+	holdings, rowErrors, err := parseCSVRecords(records, portfolio.ID, accountName, h.toBase(portfolio.CurrencyCode()))
+	if err != nil {
+		h.importError(w, err, rowErrors)
+		return
+	}
+	h.tagImportedHoldings(portfolio, holdings)
+
+	preview := importPreview{
+		PortfolioID: portfolio.ID,
+		AccountName: accountName,
+		Mode:        mode,
+		Holdings:    holdings,
+		Errors:      rowErrors,
+	}
+	if preview.Errors == nil {
+		preview.Errors = []importer.RowError{}
+	}
+	switch mode {
+	case importer.ImportModeAppend:
+		preview.Inserts = len(holdings)
+	case importer.ImportModeReplace:
+		preview.Inserts = len(importer.ConsolidateHoldings(holdings))
+	default:
+		updates, inserts := importer.MergeHoldings(portfolio.Holdings, holdings)
+		preview.Updates, preview.Inserts = len(updates), len(inserts)
+	}
+
+	if preview.Token, err = h.pendingImports.put(user.ID, preview); err != nil {
+		h.jsonError(w, "Failed to save preview", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// ConfirmImport saves a preview made by PreviewImport, found by its token.
+// Holdings are saved as they were parsed from the upload; only their
+// classifications are taken from the request. Classifications changed since
+// the preview are kept and remembered for the owner's future imports, as if
+// edited on the dashboard.
+func (h *Handler) ConfirmImport(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input importPreview
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	pending, ok := h.pendingImports.get(input.Token, user.ID)
+	if !ok {
+		h.jsonError(w, "Preview not found or expired; preview the file again", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, pending.PortfolioID.String())
+	if !ok {
+		return
+	}
+	if len(input.Holdings) != len(pending.Holdings) {
+		h.jsonError(w, "Holdings don't match the preview", http.StatusBadRequest)
+		return
+	}
+
+	accountName, mode := pending.AccountName, pending.Mode
+	now := time.Now().UTC()
+	holdings := make([]models.Holding, len(pending.Holdings))
+	copy(holdings, pending.Holdings)
+	var overrides []models.TickerOverride
+	for i := range holdings {
+		holding, corrected := &holdings[i], input.Holdings[i]
+		if ticker, _ := models.NormalizeTicker(corrected.Ticker); ticker != holding.Ticker {
+			h.jsonError(w, "Holdings don't match the preview", http.StatusBadRequest)
+			return
+		}
+		if !isKnownAssetClass(corrected.AssetClass) {
+			h.jsonError(w, "Invalid asset class for "+holding.Ticker, http.StatusBadRequest)
+			return
+		}
+
+		holding.ID = uuid.New()
+		holding.IsManualEntry = false
+		holding.ImportedAt = now
+		holding.Lots = append([]models.HoldingLot(nil), holding.Lots...)
+		for j := range holding.Lots {
+			holding.Lots[j].ID = uuid.Nil
+		}
+
+		// The preview was tagged, so any difference is the user's correction
+		if holding.AssetClass == corrected.AssetClass && holding.Sector == corrected.Sector && holding.Geography == corrected.Geography {
+			continue
+		}
+		holding.AssetClass = corrected.AssetClass
+		holding.Sector = strings.TrimSpace(corrected.Sector)
+		holding.Geography = strings.TrimSpace(corrected.Geography)
+		overrides = append(overrides, models.TickerOverride{
+			UserID:     portfolio.UserID,
+			Ticker:     holding.Ticker,
+			AssetClass: holding.AssetClass,
+			Sector:     holding.Sector,
+			Geography:  holding.Geography,
+		})
+	}
+
+	// Nothing is saved, corrections included, unless this request claims the preview
+	if !h.pendingImports.claim(input.Token, user.ID) {
+		h.jsonError(w, "Preview not found or expired; preview the file again", http.StatusBadRequest)
+		return
+	}
+	for i := range overrides {
+		if err := h.overrideRepo.Upsert(&overrides[i]); err != nil {
+			h.jsonError(w, "Failed to save classification", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := h.saveImportedHoldings(portfolio, accountName, holdings, mode); err != nil {
+		h.jsonError(w, "Failed to save holdings", http.StatusInternalServerError)
+		return
+	}
+
+	// Update portfolio totals from everything now stored, not just this import
+	if updated, err := h.portfolioRepo.GetByID(portfolio.ID); err == nil && updated != nil {
+		updated.CalculateTotals()
+		h.portfolioRepo.Update(updated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"imported": len(holdings)})
+}
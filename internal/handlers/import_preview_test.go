@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestPreviewAndConfirmImport(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "import@example.com")
+
+	csv := "Symbol,Description,Quantity,Price,Market Value,Cost Basis\n" +
+		"AAPL,Apple Inc.,100,175.50,17550.00,15000.00\n" +
+		"BND,Vanguard Total Bond Market ETF,100,72.50,7250.00,7500.00\n" +
+		"Pending Activity\n" +
+		"Account Total,,,,24800.00,\n" +
+		",,,,,\n"
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("portfolio_id", portfolio.ID.String())
+	form.WriteField("account_name", "Schwab IRA")
+	file, _ := form.CreateFormFile("csv_file", "positions.csv")
+	file.Write([]byte(csv))
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "/api/import/preview", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.PreviewImport(w, withUser(r, user))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Preview: got status %d: %s", w.Code, w.Body.String())
+	}
+
+	var preview importPreview
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatalf("Decode preview: %v", err)
+	}
+	if len(preview.Holdings) != 2 || preview.Inserts != 2 {
+		t.Fatalf("Preview: got %d holdings, %d inserts, want 2 and 2", len(preview.Holdings), preview.Inserts)
+	}
+	if len(preview.Errors) != 2 || preview.Errors[0].Row != 4 || preview.Errors[0].Skipped ||
+		preview.Errors[1].Row != 5 || !preview.Errors[1].Skipped {
+		t.Errorf("Errors: got %+v, want row 4 failed and row 5 skipped", preview.Errors)
+	}
+
+	// Nothing is saved until the import is confirmed
+	if _, count, _ := h.holdingRepo.GetByPortfolioID(portfolio.ID, storage.Page{}); count != 0 {
+		t.Fatalf("Preview saved %d holdings", count)
+	}
+
+	confirm := func(preview importPreview) *httptest.ResponseRecorder {
+		payload, _ := json.Marshal(preview)
+		r := httptest.NewRequest(http.MethodPost, "/api/import/confirm", bytes.NewReader(payload))
+		w := httptest.NewRecorder()
+		h.ConfirmImport(w, withUser(r, user))
+		return w
+	}
+
+	// Correct one classification before confirming. Amounts can't be changed.
+	for i := range preview.Holdings {
+		if preview.Holdings[i].Ticker == "AAPL" {
+			preview.Holdings[i].Sector = "Consumer Electronics"
+			preview.Holdings[i].Quantity = decimal.NewFromInt(-1000000)
+			preview.Holdings[i].MarketValue = decimal.NewFromInt(999999999)
+		}
+	}
+	if w := confirm(importPreview{Token: "unknown", Holdings: preview.Holdings}); w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown token: got status %d, want 400", w.Code)
+	}
+	if w := confirm(importPreview{Token: preview.Token, Holdings: preview.Holdings[:1]}); w.Code != http.StatusBadRequest {
+		t.Errorf("Missing holdings: got status %d, want 400", w.Code)
+	}
+	if w := confirm(preview); w.Code != http.StatusOK {
+		t.Fatalf("Confirm: got status %d: %s", w.Code, w.Body.String())
+	}
+
+	// A reused token saves nothing, not even new corrections
+	for i := range preview.Holdings {
+		if preview.Holdings[i].Ticker == "BND" {
+			preview.Holdings[i].Sector = "Treasuries"
+		}
+	}
+	if w := confirm(preview); w.Code != http.StatusBadRequest {
+		t.Errorf("Confirming twice: got status %d, want 400", w.Code)
+	}
+
+	saved, count, _ := h.holdingRepo.GetByPortfolioID(portfolio.ID, storage.Page{})
+	if count != 2 {
+		t.Fatalf("Saved holdings: got %d, want 2", count)
+	}
+	for _, holding := range saved {
+		if holding.AccountName != "Schwab IRA" {
+			t.Errorf("%s account: got %q", holding.Ticker, holding.AccountName)
+		}
+		if holding.Ticker == "AAPL" && (holding.Quantity.String() != "100" || holding.MarketValue.String() != "17550") {
+			t.Errorf("AAPL saved as %s worth %s, want the uploaded 100 worth 17550", holding.Quantity, holding.MarketValue)
+		}
+	}
+
+	overrides, _ := h.overrideRepo.GetByUserID(user.ID)
+	if o, ok := overrides["AAPL"]; !ok || o.Sector != "Consumer Electronics" {
+		t.Errorf("Corrected classification should be remembered, got %+v", overrides)
+	}
+	if _, ok := overrides["BND"]; ok {
+		t.Error("Unchanged classification shouldn't be saved as an override")
+	}
+}
+
+func TestPendingImports_CapPerUser(t *testing.T) {
+	p := newPendingImports(pendingImportTTL)
+	other, busy := uuid.New(), uuid.New()
+
+	kept, err := p.put(other, importPreview{})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var first string
+	for i := 0; i <= maxPendingImports; i++ {
+		token, err := p.put(busy, importPreview{})
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		if i == 0 {
+			first = token
+		}
+	}
+
+	// Only the busy user's own oldest preview makes way
+	if _, ok := p.get(kept, other); !ok {
+		t.Error("Another user's preview was dropped")
+	}
+	if _, ok := p.get(first, busy); ok {
+		t.Error("The busy user's oldest preview should have been dropped")
+	}
+	if len(p.entries) != maxPendingImports+1 {
+		t.Errorf("Pending previews: got %d, want %d", len(p.entries), maxPendingImports+1)
+	}
+}
+
+func TestPreviewImport_ErrorCodes(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "import-errors@example.com")
+
+	tests := []struct {
+		name string
+		csv  string // Empty for no file at all
+		code string
+		rows int
+	}{
+		{"no file", "", "no_file", 0},
+		{"unknown format", "Date,Description,Amount\n2024-01-02,Coffee,4.50\n", "unknown_format", 0},
+		{"header only", "Symbol,Description,Quantity,Market Value\n", "no_holdings", 0},
+		{"no holdings", "Symbol,Description,Quantity,Market Value\nAccount Total,,,100.00\n", "no_holdings", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body bytes.Buffer
+			form := multipart.NewWriter(&body)
+			form.WriteField("portfolio_id", portfolio.ID.String())
+			if tt.csv != "" {
+				file, _ := form.CreateFormFile("csv_file", "positions.csv")
+				file.Write([]byte(tt.csv))
+			}
+			form.Close()
+
+			r := httptest.NewRequest(http.MethodPost, "/api/import/preview", &body)
+			r.Header.Set("Content-Type", form.FormDataContentType())
+			w := httptest.NewRecorder()
+			h.PreviewImport(w, withUser(r, user))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("got status %d, want 400: %s", w.Code, w.Body.String())
+			}
+
+			var resp struct {
+				Error  string            `json:"error"`
+				Code   string            `json:"code"`
+				Errors []json.RawMessage `json:"errors"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if resp.Code != tt.code || resp.Error == "" {
+				t.Errorf("got code %q, error %q; want code %q", resp.Code, resp.Error, tt.code)
+			}
+			if len(resp.Errors) != tt.rows {
+				t.Errorf("got %d row errors, want %d", len(resp.Errors), tt.rows)
+			}
+		})
+	}
+}
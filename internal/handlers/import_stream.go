@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/importer"
+	"github.com/google/uuid"
+)
+
+// importBatchSize is how many holdings a streamed import saves at a time
+const importBatchSize = 500
+
+// defaultMaxImportSize caps uploads when no configuration is loaded
+const defaultMaxImportSize = 100 << 20
+
+// importMemoryLimit is how much of an upload is kept in memory; the rest
+// is buffered to a temporary file
+const importMemoryLimit = 10 << 20
+
+// rowParser parses the data row at index i below the header, returning
+// either the holding or why the row isn't one, and neither for a blank line
+type rowParser func(i int, row []string) (*models.Holding, *importer.RowError)
+
+// maxImportSize is the largest upload an import accepts
+func (h *Handler) maxImportSize() int64 {
+	if h.cfg != nil && h.cfg.MaxImportSize > 0 {
+		return h.cfg.MaxImportSize
+	}
+	return defaultMaxImportSize
+}
+
+// parseImportForm reads an import upload, returning importer.ErrFileTooLarge
+// when it's over maxImportSize
+func (h *Handler) parseImportForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxImportSize())
+	if err := r.ParseMultipartForm(importMemoryLimit); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return importer.ErrFileTooLarge
+		}
+		return err
+	}
+	return nil
+}
+
+// importRowParsers returns the parsers to try, in order, for a file with
+// header: each brokerage format whose columns match or, failing those, the
+// generic format. Parsed amounts are converted with toBase, and a row in a
+// currency without a rate is reported like any unreadable row. It's empty
+// when no format matches.
+func importRowParsers(header []string, portfolioID uuid.UUID, accountName string, toBase toBaseFunc) []rowParser {
+	var candidates []rowParser
+	parsers := []importer.CSVParser{importer.NewCryptoParser(), importer.NewSchwabParser(), importer.NewFidelityParser(), importer.NewVanguardParser()}
+	var detected []importer.CSVParser
+	for _, parser := range parsers {
+		if parser.Detect(header) {
+			detected = append(detected, parser)
+		}
+	}
+	if generic := importer.NewGenericParser(); len(detected) == 0 && generic.Detect(header) {
+		detected = append(detected, generic)
+	}
+	for _, parser := range detected {
+		parser := parser
+		candidates = append(candidates, func(i int, row []string) (*models.Holding, *importer.RowError) {
+			return importer.ParseRecord(parser, i, row, header, portfolioID, accountName)
+		})
+	}
+
+	for k, parse := range candidates {
+		parse := parse
+		candidates[k] = func(i int, row []string) (*models.Holding, *importer.RowError) {
+			holding, rowErr := parse(i, row)
+			if holding != nil {
+				if err := toBase(holding); err != nil {
+					return nil, &importer.RowError{Row: i + 1, Cells: row, Reason: err.Error()}
+				}
+			}
+			return holding, rowErr
+		}
+	}
+	return candidates
+}
+
+// importCSVStream saves an uploaded CSV while reading it, so a large file
+// never has to fit in memory. Appended holdings are tagged and saved
+// importBatchSize at a time; merges and replacements hold one consolidated
+// holding per position until the file is read. It returns how many rows
+// became holdings and the rows that didn't.
+func (h *Handler) importCSVStream(file io.ReadSeeker, portfolio *models.Portfolio, accountName string, mode importer.ImportMode) (int, []importer.RowError, error) {
+	reader, header, err := readStreamHeader(file)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	candidates := importRowParsers(header, portfolio.ID, accountName, h.toBase(portfolio.CurrencyCode()))
+	if len(candidates) == 0 {
+		return 0, nil, importer.ErrUnknownFormat
+	}
+
+	tag := h.importTagger(portfolio)
+	var firstErrors []importer.RowError
+	for k, parse := range candidates {
+		// A format that found nothing saved nothing, so the next reads the file afresh
+		if k > 0 {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return 0, firstErrors, err
+			}
+			if reader, _, err = readStreamHeader(file); err != nil {
+				return 0, firstErrors, err
+			}
+		}
+
+		var count int
+		var rowErrors []importer.RowError
+		if mode == importer.ImportModeAppend {
+			count, rowErrors, err = h.appendStreamedRows(reader, parse, tag)
+		} else {
+			consolidator := importer.NewConsolidator()
+			count, rowErrors, err = streamRows(reader, parse, func(holding models.Holding) error {
+				consolidator.Add(holding)
+				return nil
+			})
+			if err == nil && count > 0 {
+				holdings := consolidator.Holdings()
+				tag(holdings)
+				err = h.saveImportedHoldings(portfolio, accountName, holdings, mode)
+			}
+		}
+		if err != nil || count > 0 {
+			return count, rowErrors, err
+		}
+		if k == 0 {
+			firstErrors = rowErrors
+		}
+	}
+	return 0, firstErrors, importer.ErrNoData
+}
+
+// readStreamHeader starts reading a CSV, returning the reader positioned
+// after the header row
+func readStreamHeader(file io.Reader) (*csv.Reader, []string, error) {
+	reader := importer.NewCSVReader(file)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, importer.ErrEmptyFile
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", importer.ErrInvalidCSV, err)
+	}
+	return reader, header, nil
+}
+
+// appendStreamedRows tags and saves holdings in batches as they're parsed
+func (h *Handler) appendStreamedRows(reader *csv.Reader, parse rowParser, tag func([]models.Holding)) (int, []importer.RowError, error) {
+	batch := make([]models.Holding, 0, importBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		tag(batch)
+		err := h.holdingRepo.CreateBatch(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	count, rowErrors, err := streamRows(reader, parse, func(holding models.Holding) error {
+		batch = append(batch, holding)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return count, rowErrors, err
+}
+
+// streamRows reads the rows after the header one at a time, passing each
+// holding to emit. A malformed line is reported like any other unreadable
+// row rather than failing the whole file.
+func streamRows(reader *csv.Reader, parse rowParser, emit func(models.Holding) error) (int, []importer.RowError, error) {
+	count := 0
+	var rowErrors []importer.RowError
+	for i := 1; ; i++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return count, rowErrors, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrors = append(rowErrors, importer.RowError{Row: i + 1, Cells: row, Reason: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return count, rowErrors, err
+		}
+
+		holding, rowErr := parse(i, row)
+		if rowErr != nil {
+			rowErrors = append(rowErrors, *rowErr)
+			continue
+		}
+		if holding == nil {
+			continue
+		}
+		if err := emit(*holding); err != nil {
+			return count, rowErrors, err
+		}
+		count++
+	}
+}
+
+// createHoldingsInBatches saves holdings importBatchSize at a time, keeping
+// each transaction small
+func (h *Handler) createHoldingsInBatches(holdings []models.Holding) error {
+	for start := 0; start < len(holdings); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(holdings) {
+			end = len(holdings)
+		}
+		if err := h.holdingRepo.CreateBatch(holdings[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
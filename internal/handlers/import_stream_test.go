@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/config"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// importRequest builds a multipart upload of csv to path
+func importRequest(path string, portfolioID uuid.UUID, mode, csv string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("portfolio_id", portfolioID.String())
+	form.WriteField("account_name", "Brokerage")
+	form.WriteField("mode", mode)
+	file, _ := form.CreateFormFile("csv_file", "positions.csv")
+	file.Write([]byte(csv))
+	form.Close()
+
+	r := httptest.NewRequest(http.MethodPost, path, &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// streamTicker names the nth of many generated positions
+func streamTicker(n int) string {
+	return fmt.Sprintf("T%c%c%c", 'A'+n/676%26, 'A'+n/26%26, 'A'+n%26)
+}
+
+func TestImportCSV_Streamed(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "stream@example.com")
+
+	// More rows than fit in a few batches, with a malformed line part way
+	const rows = 2*importBatchSize + 201
+	var csv strings.Builder
+	csv.WriteString("Symbol,Description,Quantity,Price,Market Value,Cost Basis\n")
+	for n := 0; n < rows; n++ {
+		fmt.Fprintf(&csv, "%s,Fund %d,10,5.00,50.00,40.00\n", streamTicker(n), n)
+		if n == importBatchSize {
+			csv.WriteString("BA\"D,Broken,1,1,1,1\n")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ImportCSV(w, withUser(importRequest("/import", portfolio.ID, "append", csv.String()), user))
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("Bad redirect: %v", err)
+	}
+	if got, want := location.Query().Get("success"), fmt.Sprintf("Imported %d holdings", rows); got != want {
+		t.Errorf("Success: got %q, want %q", got, want)
+	}
+	if got := location.Query().Get("error"); !strings.Contains(got, fmt.Sprintf("row %d:", importBatchSize+3)) {
+		t.Errorf("Error: got %q, want the malformed row reported", got)
+	}
+	if _, count, _ := h.holdingRepo.GetByPortfolioID(portfolio.ID, storage.Page{}); count != rows {
+		t.Fatalf("Saved %d holdings, want %d", count, rows)
+	}
+
+	// Merging consolidates repeated rows into the existing position
+	csv.Reset()
+	csv.WriteString("Symbol,Description,Quantity,Price,Market Value,Cost Basis\n")
+	fmt.Fprintf(&csv, "%s,Fund 0,10,5.00,50.00,40.00\n", streamTicker(0))
+	fmt.Fprintf(&csv, "%s,Fund 0,5,5.00,25.00,20.00\n", streamTicker(0))
+
+	w = httptest.NewRecorder()
+	h.ImportCSV(w, withUser(importRequest("/import", portfolio.ID, "merge", csv.String()), user))
+	if location := w.Header().Get("Location"); !strings.HasPrefix(location, "/dashboard") {
+		t.Fatalf("Merge redirected to %q", location)
+	}
+	holdings, count, _ := h.holdingRepo.GetByPortfolioID(portfolio.ID, storage.Page{})
+	if count != rows {
+		t.Fatalf("After merge: %d holdings, want %d", count, rows)
+	}
+	for _, holding := range holdings {
+		if holding.Ticker == streamTicker(0) && holding.Quantity.String() != "15" {
+			t.Errorf("Merged quantity: got %s, want 15", holding.Quantity)
+		}
+	}
+}
+
+func TestImportCSV_Generic(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "generic@example.com")
+
+	// Named like no brokerage's export
+	csv := "Ticker,Name,Shares,Price,Total Cost\n" +
+		"VTI,Total Stock Market,10,250.00,2000.00\n" +
+		"BND,Total Bond,20,70.00,1500.00\n"
+
+	w := httptest.NewRecorder()
+	h.ImportCSV(w, withUser(importRequest("/import", portfolio.ID, "append", csv), user))
+	if location := w.Header().Get("Location"); !strings.HasPrefix(location, "/dashboard") {
+		t.Fatalf("Import redirected to %q", location)
+	}
+
+	holdings, _, _ := h.holdingRepo.GetByPortfolioID(portfolio.ID, storage.Page{})
+	values := make(map[string]string)
+	for _, holding := range holdings {
+		values[holding.Ticker] = holding.MarketValue.String() + "/" + holding.CostBasis.String()
+	}
+	if values["VTI"] != "2500/2000" || values["BND"] != "1400/1500" {
+		t.Errorf("Value/cost basis by ticker: got %v", values)
+	}
+}
+
+func TestImport_TooLarge(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "large@example.com")
+	h.cfg = &config.Config{MaxImportSize: 1 << 10}
+
+	csv := "Symbol,Description,Quantity,Price,Market Value,Cost Basis\n" +
+		strings.Repeat("AAPL,Apple Inc.,100,175.50,17550.00,15000.00\n", 50)
+
+	w := httptest.NewRecorder()
+	h.ImportCSV(w, withUser(importRequest("/import", portfolio.ID, "", csv), user))
+	if location := w.Header().Get("Location"); !strings.Contains(location, "larger+than+the+import+limit") {
+		t.Errorf("Import redirected to %q, want the size limit explained", location)
+	}
+
+	w = httptest.NewRecorder()
+	h.PreviewImport(w, withUser(importRequest("/api/import/preview", portfolio.ID, "", csv), user))
+	var resp struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusBadRequest || resp.Code != "file_too_large" {
+		t.Errorf("Preview: got %d %q, want 400 file_too_large", w.Code, resp.Code)
+	}
+}
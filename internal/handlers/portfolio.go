@@ -2,14 +2,20 @@ package handlers
 
 import (
 	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/findosh/truenorth/internal/middleware"
 	"github.com/findosh/truenorth/internal/models"
 	"github.com/findosh/truenorth/internal/services/importer"
 	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
 )
 
 // NewPortfolioPage renders the create portfolio page
@@ -25,7 +31,7 @@ func (h *Handler) NewPortfolioPage(w http.ResponseWriter, r *http.Request) {
 		"User":  user,
 		"Error": r.URL.Query().Get("error"),
 	}
-	h.render(w, "portfolio_new.html", data)
+	h.render(w, r, "portfolio_new.html", data)
 }
 
 // CreatePortfolio handles portfolio creation
@@ -52,6 +58,11 @@ func (h *Handler) CreatePortfolio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Manual entry starts on the empty dashboard instead of the import page
+	if r.FormValue("start") == "manual" {
+		h.redirect(w, r, "/dashboard?portfolio="+portfolio.ID.String())
+		return
+	}
 	h.redirect(w, r, "/import?portfolio="+portfolio.ID.String())
 }
 
@@ -76,7 +87,7 @@ func (h *Handler) ImportPage(w http.ResponseWriter, r *http.Request) {
 		"Error":       r.URL.Query().Get("error"),
 		"Success":     r.URL.Query().Get("success"),
 	}
-	h.render(w, "import.html", data)
+	h.render(w, r, "import.html", data)
 }
 
 // ImportCSV handles CSV file upload
@@ -87,9 +98,12 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse multipart form (10MB max)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
-		h.redirect(w, r, "/import?error=File+too+large")
+	if err := h.parseImportForm(w, r); err != nil {
+		if errors.Is(err, importer.ErrFileTooLarge) {
+			h.redirect(w, r, "/import?error="+url.QueryEscape(importErrorMessage(err, nil)))
+			return
+		}
+		h.redirect(w, r, "/import?error=Invalid+upload")
 		return
 	}
 
@@ -105,138 +119,273 @@ func (h *Handler) ImportCSV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify portfolio belongs to user
+	// Verify the user can edit the portfolio
 	portfolio, err := h.portfolioRepo.GetByID(pid)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.redirect(w, r, "/dashboard?error=Portfolio+not+found")
 		return
 	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.redirect(w, r, "/dashboard?error=Read-only+access")
+		return
+	}
 
-	// Get uploaded file
-	file, _, err := r.FormFile("csv_file")
+	mode, err := importer.ParseImportMode(r.FormValue("mode"))
 	if err != nil {
-		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=No+file+uploaded")
+		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=Invalid+import+mode")
 		return
 	}
-	defer file.Close()
 
-	// Read CSV content
-	csvReader := csv.NewReader(file)
-	csvReader.FieldsPerRecord = -1
-	records, err := csvReader.ReadAll()
+	imported, rowErrors, err := h.importUpload(r, portfolio, accountName, mode)
 	if err != nil {
-		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=Invalid+CSV+format")
+		msg := importErrorMessage(err, rowErrors)
+		if importer.ErrorCode(err) == "import_failed" {
+			msg = "Failed to save holdings"
+			if imported > 0 {
+				msg = fmt.Sprintf("Failed to save holdings after importing %d", imported)
+			}
+		}
+		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error="+url.QueryEscape(msg))
 		return
 	}
+	log.Printf("import: %d holdings into portfolio %s, %d rows not imported", imported, portfolioID, len(rowErrors))
 
-	if len(records) < 2 {
-		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=CSV+file+is+empty")
-		return
+	// Update portfolio totals from everything now stored, not just this import
+	if updated, err := h.portfolioRepo.GetByID(pid); err == nil && updated != nil {
+		updated.CalculateTotals()
+		if err := h.portfolioRepo.Update(updated); err != nil {
+			// Non-fatal error
+		}
 	}
 
-	// Parse the CSV
-	holdings := parseCSVRecords(records, pid, accountName)
-	if len(holdings) == 0 {
-		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=No+valid+holdings+found")
+	// Stay on the import page when rows were dropped so they can be fixed
+	if detail := rowErrorMessage(rowErrors); detail != "" {
+		success := fmt.Sprintf("Imported %d holdings", imported)
+		h.redirect(w, r, "/import?portfolio="+portfolioID+"&success="+url.QueryEscape(success)+"&error="+url.QueryEscape(detail))
 		return
 	}
 
-	// Auto-tag the holdings
-	tagger := importer.NewTagger()
-	tagger.TagHoldings(holdings)
+	h.redirect(w, r, "/dashboard?portfolio="+portfolioID)
+}
 
-	// Save holdings
-	if err := h.holdingRepo.CreateBatch(holdings); err != nil {
-		h.redirect(w, r, "/import?portfolio="+portfolioID+"&error=Failed+to+save+holdings")
-		return
+// importUpload parses and saves the uploaded file. CSVs are streamed; an
+// XLSX workbook is read whole, since its rows can't be read one at a time.
+// It returns how many rows became holdings and the rows that didn't.
+func (h *Handler) importUpload(r *http.Request, portfolio *models.Portfolio, accountName string, mode importer.ImportMode) (int, []importer.RowError, error) {
+	file, header, err := r.FormFile("csv_file")
+	if err != nil {
+		return 0, nil, importer.ErrNoFile
 	}
+	defer file.Close()
 
-	// Update portfolio totals
-	portfolio.Holdings = holdings
-	portfolio.CalculateTotals()
-	if err := h.portfolioRepo.Update(portfolio); err != nil {
-		// Non-fatal error
+	if !importer.IsXLSX(header.Filename, header.Header.Get("Content-Type")) {
+		return h.importCSVStream(file, portfolio, accountName, mode)
 	}
 
-	h.redirect(w, r, "/dashboard?portfolio="+portfolioID)
+	records, err := readImportRecords(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	holdings, rowErrors, err := parseCSVRecords(records, portfolio.ID, accountName, h.toBase(portfolio.CurrencyCode()))
+	if err != nil {
+		return 0, rowErrors, err
+	}
+	h.tagImportedHoldings(portfolio, holdings)
+	if err := h.saveImportedHoldings(portfolio, accountName, holdings, mode); err != nil {
+		return 0, rowErrors, err
+	}
+	return len(holdings), rowErrors, nil
 }
 
-// parseCSVRecords parses CSV records into holdings
-func parseCSVRecords(records [][]string, portfolioID uuid.UUID, accountName string) []models.Holding {
-	var holdings []models.Holding
-
-	if len(records) < 2 {
-		return holdings
+// readImportRecords reads the uploaded spreadsheet (XLSX or CSV),
+// returning one of the importer's errors when it can't be used
+func readImportRecords(r *http.Request) ([][]string, error) {
+	file, header, err := r.FormFile("csv_file")
+	if err != nil {
+		return nil, importer.ErrNoFile
 	}
+	defer file.Close()
 
-	// Try each parser
-	schwab := importer.ParseSchwabCSV(records, portfolioID, accountName)
-	if len(schwab) > 0 {
-		return schwab
+	var records [][]string
+	if importer.IsXLSX(header.Filename, header.Header.Get("Content-Type")) {
+		records, err = importer.ReadXLSX(file, header.Size)
+		if err != nil && importer.ErrorCode(err) == "import_failed" {
+			err = fmt.Errorf("%w: %v", importer.ErrInvalidXLSX, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		records, err = importer.NewCSVReader(file).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", importer.ErrInvalidCSV, err)
+		}
 	}
 
-	fidelity := importer.ParseFidelityCSV(records, portfolioID, accountName)
-	if len(fidelity) > 0 {
-		return fidelity
+	if len(records) == 0 {
+		return nil, importer.ErrEmptyFile
 	}
+	return records, nil
+}
+
+// importErrorMessage tells the user what went wrong with an upload and what
+// to try next. Rows that couldn't be read are summarized after it.
+func importErrorMessage(err error, rowErrors []importer.RowError) string {
+	var msg string
+	switch {
+	case errors.Is(err, importer.ErrNoFile):
+		msg = "Choose a file to upload."
+	case errors.Is(err, importer.ErrFileTooLarge):
+		msg = "This file is larger than the import limit. Split it into smaller files, such as one per account."
+	case errors.Is(err, importer.ErrInvalidCSV):
+		msg = "This file couldn't be read as a CSV. Export your positions from your brokerage again, as CSV or Excel."
+	case errors.Is(err, importer.ErrEmptyFile):
+		msg = "This file is empty. Export your positions from your brokerage and upload that file."
+	case errors.Is(err, importer.ErrUnknownFormat):
+		msg = "We couldn't detect your brokerage from this file's columns. Try the generic template, with Symbol, Description, Quantity and Market Value columns."
+	case errors.Is(err, importer.ErrNoData):
+		msg = "We recognized this file but found no holdings in it. Check it's a positions export rather than account activity."
+	case errors.Is(err, importer.ErrEncryptedXLSX):
+		msg = "This workbook is password-protected. Remove the password and upload again."
+	case errors.Is(err, importer.ErrAmbiguousSheets):
+		msg = "This workbook has several sheets with holdings. Save the sheet you want as its own file."
+	case errors.Is(err, importer.ErrInvalidXLSX):
+		msg = "This workbook couldn't be read. Open it and save it again as .xlsx or CSV."
+	default:
+		msg = "The import failed. Please try again."
+	}
+	if detail := rowErrorMessage(rowErrors); detail != "" {
+		msg += " " + detail + "."
+	}
+	return msg
+}
+
+// importError answers an API import request that can't go ahead, with a
+// code from importer.ErrorCode and any rows that couldn't be read
+func (h *Handler) importError(w http.ResponseWriter, err error, rowErrors []importer.RowError) {
+	if rowErrors == nil {
+		rowErrors = []importer.RowError{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  importErrorMessage(err, nil),
+		"code":   importer.ErrorCode(err),
+		"errors": rowErrors,
+	})
+}
 
-	vanguard := importer.ParseVanguardCSV(records, portfolioID, accountName)
-	if len(vanguard) > 0 {
-		return vanguard
+// tagImportedHoldings auto-tags imported holdings, applying the portfolio
+// owner's saved classifications
+func (h *Handler) tagImportedHoldings(portfolio *models.Portfolio, holdings []models.Holding) {
+	h.importTagger(portfolio)(holdings)
+}
+
+// importTagger loads the portfolio owner's saved classifications once, for
+// tagging an import a batch at a time
+func (h *Handler) importTagger(portfolio *models.Portfolio) func([]models.Holding) {
+	overrides, err := h.overrideRepo.GetByUserID(portfolio.UserID)
+	if err != nil {
+		overrides = nil // Fall back to built-in tagging
+	}
+	tagger := importer.NewTagger()
+	return func(holdings []models.Holding) {
+		tagger.TagHoldingsWithOverrides(holdings, overrides)
 	}
+}
 
-	// Generic fallback parser
-	return parseGenericCSV(records, portfolioID, accountName)
+// saveImportedHoldings persists an import according to mode. Existing
+// holdings are taken from the loaded portfolio.
+func (h *Handler) saveImportedHoldings(portfolio *models.Portfolio, accountName string, holdings []models.Holding, mode importer.ImportMode) error {
+	switch mode {
+	case importer.ImportModeAppend:
+		return h.createHoldingsInBatches(holdings)
+
+	case importer.ImportModeReplace:
+		return h.holdingRepo.ReplaceAccount(portfolio.ID, accountName, importer.ConsolidateHoldings(holdings))
+
+	default:
+		updates, inserts := importer.MergeHoldings(portfolio.Holdings, holdings)
+		for i := range updates {
+			if err := h.holdingRepo.Update(&updates[i]); err != nil {
+				return err
+			}
+			if err := h.lotRepo.ReplaceForHolding(updates[i].ID, updates[i].Lots); err != nil {
+				return err
+			}
+		}
+		return h.createHoldingsInBatches(inserts)
+	}
 }
 
-func parseGenericCSV(records [][]string, portfolioID uuid.UUID, accountName string) []models.Holding {
-	var holdings []models.Holding
+// parseCSVRecords parses CSV records into holdings valued in the
+// portfolio's base currency by toBase, along with the rows that didn't
+// become one. It returns importer.ErrUnknownFormat when no format matches
+// the columns, and importer.ErrNoData when none of the rows were holdings.
+func parseCSVRecords(records [][]string, portfolioID uuid.UUID, accountName string, toBase toBaseFunc) ([]models.Holding, []importer.RowError, error) {
 	if len(records) < 2 {
-		return holdings
+		return nil, nil, importer.ErrNoData
 	}
 
-	header := records[0]
-	colMap := make(map[string]int)
-	for i, h := range header {
-		colMap[strings.ToLower(strings.TrimSpace(h))] = i
+	// Try each format whose columns match, reporting rows against the first
+	candidates := importRowParsers(records[0], portfolioID, accountName, toBase)
+	if len(candidates) == 0 {
+		return nil, nil, importer.ErrUnknownFormat
 	}
-
-	getCol := func(row []string, names ...string) string {
-		for _, name := range names {
-			if idx, ok := colMap[name]; ok && idx < len(row) {
-				return row[idx]
-			}
+	var rowErrors []importer.RowError
+	for k, parse := range candidates {
+		holdings, errs := parseRows(records, parse)
+		if len(holdings) > 0 {
+			return holdings, errs, nil
+		}
+		if k == 0 {
+			rowErrors = errs
 		}
-		return ""
 	}
+	return nil, rowErrors, importer.ErrNoData
+}
 
+// parseRows parses the rows below the header in records[0]
+func parseRows(records [][]string, parse rowParser) ([]models.Holding, []importer.RowError) {
+	var holdings []models.Holding
+	var rowErrors []importer.RowError
 	for i := 1; i < len(records); i++ {
-		row := records[i]
-		if len(row) < 3 {
+		holding, rowErr := parse(i, records[i])
+		if rowErr != nil {
+			rowErrors = append(rowErrors, *rowErr)
 			continue
 		}
-
-		ticker := strings.TrimSpace(strings.ToUpper(getCol(row, "symbol", "ticker")))
-		if ticker == "" {
-			continue
+		if holding != nil {
+			holdings = append(holdings, *holding)
 		}
+	}
+	return holdings, rowErrors
+}
 
-		name := getCol(row, "description", "name", "security")
-		quantity := importer.ParseSchwabCSV(records, portfolioID, accountName) // Placeholder
-
-		holding := models.NewHolding(portfolioID, ticker, name, accountName)
-		holding.Source = "generic_csv"
+// maxReportedRowErrors caps how many failed rows an import message lists
+const maxReportedRowErrors = 3
 
-		// Skip if parsed as empty
-		if holding.Quantity.IsZero() && holding.MarketValue.IsZero() {
-			_ = quantity // Use variable
-			continue
+// rowErrorMessage summarizes rows that couldn't be read, leaving out rows
+// that were skipped on purpose. It's empty when every row was read.
+func rowErrorMessage(rowErrors []importer.RowError) string {
+	var failed []string
+	for _, e := range rowErrors {
+		if !e.Skipped {
+			failed = append(failed, fmt.Sprintf("row %d: %s", e.Row, e.Reason))
 		}
-
-		holdings = append(holdings, *holding)
 	}
-
-	return holdings
+	count := len(failed)
+	if count == 0 {
+		return ""
+	}
+	if count > maxReportedRowErrors {
+		failed = append(failed[:maxReportedRowErrors], fmt.Sprintf("and %d more", count-maxReportedRowErrors))
+	}
+	noun := "rows"
+	if count == 1 {
+		noun = "row"
+	}
+	return fmt.Sprintf("%d %s couldn't be read (%s)", count, noun, strings.Join(failed, "; "))
 }
 
 // PortfolioView renders a single portfolio page
@@ -262,13 +411,13 @@ func (h *Handler) PortfolioView(w http.ResponseWriter, r *http.Request) {
 	}
 
 	portfolio, err := h.portfolioRepo.GetByID(portfolioID)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.redirect(w, r, "/dashboard?error=Portfolio+not+found")
 		return
 	}
 
 	portfolio.CalculateTotals()
-	allocation := portfolio.CalculateAllocation()
+	allocation := allocationView(r, portfolio, nil)
 
 	data := map[string]interface{}{
 		"Title":      portfolio.Name + " - TrueNorth",
@@ -277,10 +426,13 @@ func (h *Handler) PortfolioView(w http.ResponseWriter, r *http.Request) {
 		"Allocation": allocation,
 	}
 
-	h.render(w, "portfolio.html", data)
+	h.render(w, r, "portfolio.html", data)
 }
 
-// EditHolding handles holding classification updates
+// EditHolding handles holding classification updates. The classification
+// is also saved as the user's override for the ticker so future imports
+// keep it. A currency, when given, changes the one the holding is priced
+// in, revaluing it in the portfolio's base currency.
 func (h *Handler) EditHolding(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
 	if user == nil {
@@ -288,6 +440,11 @@ func (h *Handler) EditHolding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		h.jsonError(w, "Invalid request", http.StatusBadRequest)
 		return
@@ -299,25 +456,365 @@ func (h *Handler) EditHolding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get holding and verify ownership
-	// This would need a GetByID method on HoldingRepository
-	// For now, update directly
-
 	assetClass := models.AssetClass(r.FormValue("asset_class"))
-	sector := r.FormValue("sector")
-	geography := r.FormValue("geography")
+	if !isKnownAssetClass(assetClass) {
+		h.jsonError(w, "Invalid asset class", http.StatusBadRequest)
+		return
+	}
+
+	// Get holding and verify ownership through its portfolio
+	holding, err := h.holdingRepo.GetByID(holdingID)
+	if err != nil || holding == nil {
+		h.jsonError(w, "Holding not found", http.StatusNotFound)
+		return
+	}
+	portfolio, err := h.portfolioRepo.GetSummary(holding.PortfolioID)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Holding not found", http.StatusNotFound)
+		return
+	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
+
+	revalued := false
+	if code := strings.TrimSpace(r.FormValue("currency")); code != "" {
+		currency, err := models.NormalizeCurrency(code)
+		if err != nil {
+			h.jsonError(w, "Invalid currency: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if currency != holding.CurrencyCode() {
+			rate, err := h.fxRate(currency, portfolio.CurrencyCode())
+			if err != nil {
+				h.jsonError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			holding.Currency = currency
+			holding.CalculateMarketValue()
+			holding.MarketValue = holding.MarketValue.Mul(rate)
+			revalued = true
+		}
+	}
+
+	holding.AssetClass = assetClass
+	holding.Sector = strings.TrimSpace(r.FormValue("sector"))
+	holding.Geography = strings.TrimSpace(r.FormValue("geography"))
+	holding.IsManualEntry = true
+
+	if err := h.holdingRepo.Update(holding); err != nil {
+		h.jsonError(w, "Failed to update holding", http.StatusInternalServerError)
+		return
+	}
+	if revalued {
+		if updated, err := h.portfolioRepo.GetByID(portfolio.ID); err == nil && updated != nil {
+			updated.CalculateTotals()
+			h.portfolioRepo.Update(updated)
+		}
+	}
 
-	// Update holding
-	query := `UPDATE holdings SET asset_class = ?, sector = ?, geography = ?, is_manual_entry = 1 WHERE id = ?`
-	_ = query
-	_ = holdingID
-	_ = assetClass
-	_ = sector
-	_ = geography
+	// Remember the classification for the owner's future imports
+	if err := h.overrideRepo.Upsert(&models.TickerOverride{
+		UserID:     portfolio.UserID,
+		Ticker:     holding.Ticker,
+		AssetClass: holding.AssetClass,
+		Sector:     holding.Sector,
+		Geography:  holding.Geography,
+	}); err != nil {
+		h.jsonError(w, "Failed to save classification", http.StatusInternalServerError)
+		return
+	}
 
 	// Redirect back to portfolio
-	portfolioID := r.FormValue("portfolio_id")
-	h.redirect(w, r, "/dashboard?portfolio="+portfolioID)
+	h.redirect(w, r, "/dashboard?portfolio="+portfolio.ID.String())
+}
+
+// APIHoldings lists a portfolio's holdings at /api/portfolios/{id}/holdings.
+// ?sort= orders them by market_value (the default), gain_loss,
+// gain_loss_pct or ticker, and ?order=asc or desc overrides the sort's
+// natural direction. ?filter[account]= and ?filter[asset_class]= narrow
+// the list.
+func (h *Handler) APIHoldings(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, ok := holdingsPortfolioID(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pid, err := uuid.Parse(id)
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, err := h.getViewablePortfolio(user, pid)
+	if err != nil {
+		h.jsonError(w, "Failed to load portfolio", http.StatusInternalServerError)
+		return
+	}
+	if portfolio == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = models.SortMarketValue
+	}
+
+	// Amounts sort largest first and tickers A-Z unless asked otherwise
+	order := query.Get("order")
+	ascending := sortBy == models.SortTicker
+	switch order {
+	case "":
+		if ascending {
+			order = "asc"
+		} else {
+			order = "desc"
+		}
+	case "asc", "desc":
+	default:
+		h.jsonError(w, "order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	account := strings.TrimSpace(query.Get("filter[account]"))
+	assetClass := models.AssetClass(query.Get("filter[asset_class]"))
+	if assetClass != "" && !isKnownAssetClass(assetClass) {
+		h.jsonError(w, "Invalid asset class: "+string(assetClass), http.StatusBadRequest)
+		return
+	}
+
+	var holdings []models.Holding
+	for _, holding := range portfolio.Holdings {
+		if account != "" && !strings.EqualFold(holding.AccountName, account) {
+			continue
+		}
+		if assetClass != "" && holding.AssetClass != assetClass {
+			continue
+		}
+		holdings = append(holdings, holding)
+	}
+
+	if err := models.SortHoldings(holdings, sortBy, (order == "asc") != ascending); err != nil {
+		h.jsonError(w, "sort must be market_value, gain_loss, gain_loss_pct or ticker", http.StatusBadRequest)
+		return
+	}
+
+	type holdingRow struct {
+		models.Holding
+		GainLoss        decimal.Decimal `json:"gain_loss"`
+		GainLossPercent decimal.Decimal `json:"gain_loss_pct"`
+	}
+	rows := make([]holdingRow, 0, len(holdings))
+	for _, holding := range holdings {
+		rows = append(rows, holdingRow{
+			Holding:         holding,
+			GainLoss:        holding.GainLoss(),
+			GainLossPercent: holding.GainLossPercent(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"portfolio_id": portfolio.ID,
+		"sort":         sortBy,
+		"order":        order,
+		"count":        len(rows),
+		"holdings":     rows,
+	})
+}
+
+// holdingsPortfolioID returns the {id} of a /api/portfolios/{id}/holdings
+// path
+func holdingsPortfolioID(r *http.Request) (string, bool) {
+	id, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/portfolios/"), "/")
+	return id, rest == "holdings"
+}
+
+// APIAllocation returns a portfolio's allocation by asset class, sector,
+// geography and account, with its top holdings and ticker totals, as JSON.
+// Funds are looked through as on the dashboard unless ?view=plain.
+func (h *Handler) APIAllocation(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pid, err := uuid.Parse(r.URL.Query().Get("portfolio"))
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, err := h.getViewablePortfolio(user, pid)
+	if err != nil {
+		h.jsonError(w, "Failed to load portfolio", http.StatusInternalServerError)
+		return
+	}
+	if portfolio == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	portfolio.CalculateTotals()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(allocationView(r, portfolio, nil))
+}
+
+// manualAccountName is the account holdings are added to when none is given
+const manualAccountName = "Manual Entry"
+
+// AddHolding adds one holding to a portfolio at /api/portfolios/{id}/holdings,
+// for users entering positions by hand instead of importing a CSV. Without
+// a price, the holding is priced from a quote. The price and cost basis are
+// in the holding's currency, USD unless given, and its value and cost basis
+// are kept in the portfolio's. It's tagged like an import
+// and the portfolio's totals are recalculated.
+func (h *Handler) AddHolding(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, ok := holdingsPortfolioID(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		Ticker      string          `json:"ticker"`
+		Name        string          `json:"name"`
+		AccountName string          `json:"account_name"`
+		Quantity    decimal.Decimal `json:"quantity"`
+		Price       decimal.Decimal `json:"price"`
+		CostBasis   decimal.Decimal `json:"cost_basis"`
+		Currency    string          `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, id)
+	if !ok {
+		return
+	}
+
+	ticker, err := models.NormalizeTicker(input.Ticker)
+	if ticker == "" {
+		h.jsonError(w, "Ticker is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, "Invalid ticker: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !input.Quantity.IsPositive() {
+		h.jsonError(w, "Quantity must be positive", http.StatusBadRequest)
+		return
+	}
+	if input.Price.IsNegative() || input.CostBasis.IsNegative() {
+		h.jsonError(w, "Price and cost basis can't be negative", http.StatusBadRequest)
+		return
+	}
+	currency, err := models.NormalizeCurrency(input.Currency)
+	if err != nil {
+		h.jsonError(w, "Invalid currency: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	rate, err := h.fxRate(currency, portfolio.CurrencyCode())
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accountName := strings.TrimSpace(input.AccountName)
+	if accountName == "" {
+		accountName = manualAccountName
+	}
+	for _, existing := range portfolio.Holdings {
+		if existing.Ticker == ticker && strings.EqualFold(existing.AccountName, accountName) {
+			h.jsonError(w, ticker+" is already in that account", http.StatusConflict)
+			return
+		}
+	}
+
+	holding := models.NewHolding(portfolio.ID, ticker, strings.TrimSpace(input.Name), accountName)
+	holding.Quantity = input.Quantity
+	holding.CostBasis = input.CostBasis.Mul(rate)
+	holding.Currency = currency
+	holding.Source = "manual"
+
+	// Tag first, since coins are priced differently from stocks
+	tagged := []models.Holding{*holding}
+	h.tagImportedHoldings(portfolio, tagged)
+	*holding = tagged[0]
+
+	if input.Price.IsPositive() {
+		holding.CurrentPrice = input.Price
+		holding.CalculateMarketValue()
+		holding.MarketValue = holding.MarketValue.Mul(rate)
+	} else {
+		if h.marketDataSvc == nil {
+			h.jsonError(w, "Prices aren't available; enter one for "+ticker, http.StatusBadRequest)
+			return
+		}
+		priced := &models.Portfolio{Currency: portfolio.Currency, Holdings: []models.Holding{*holding}}
+		unpriced, err := h.marketDataSvc.UpdatePortfolioValues(priced)
+		if err != nil || len(unpriced) > 0 {
+			h.jsonError(w, "No price found for "+ticker+"; enter one", http.StatusBadRequest)
+			return
+		}
+		*holding = priced.Holdings[0]
+	}
+
+	if err := h.holdingRepo.Create(holding); err != nil {
+		h.jsonError(w, "Failed to save holding", http.StatusInternalServerError)
+		return
+	}
+
+	portfolio.Holdings = append(portfolio.Holdings, *holding)
+	portfolio.CalculateTotals()
+	if err := h.portfolioRepo.Update(portfolio); err != nil {
+		h.jsonError(w, "Failed to update portfolio totals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(holding)
+}
+
+func isKnownAssetClass(class models.AssetClass) bool {
+	for _, c := range models.AllAssetClasses() {
+		if c == class {
+			return true
+		}
+	}
+	return false
 }
 
 // DeletePortfolio handles portfolio deletion
@@ -339,11 +836,15 @@ func (h *Handler) DeletePortfolio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	portfolio, err := h.portfolioRepo.GetByID(portfolioID)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	portfolio, err := h.portfolioRepo.GetSummary(portfolioID)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.redirect(w, r, "/dashboard?error=Portfolio+not+found")
 		return
 	}
+	if !h.canAccess(user, portfolio, accessOwner) {
+		h.redirect(w, r, "/dashboard?error=Only+the+owner+can+delete+a+portfolio")
+		return
+	}
 
 	if err := h.portfolioRepo.Delete(portfolioID); err != nil {
 		h.redirect(w, r, "/dashboard?error=Failed+to+delete")
@@ -372,7 +873,5 @@ func (h *Handler) DownloadTemplate(w http.ResponseWriter, r *http.Request) {
 
 // Used by parseGenericCSV but defined here to avoid import issues
 func readCSVRecords(r io.Reader) ([][]string, error) {
-	reader := csv.NewReader(r)
-	reader.FieldsPerRecord = -1
-	return reader.ReadAll()
+	return importer.NewCSVReader(r).ReadAll()
 }
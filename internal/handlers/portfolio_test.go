@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/marketdata"
+	"github.com/shopspring/decimal"
+)
+
+func TestAPIHoldings_SortAndFilter(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "holdings@example.com")
+	_, others := createTestUser(t, h, "other@example.com")
+
+	for _, seed := range []struct {
+		ticker, account string
+		class           models.AssetClass
+		value, basis    int64
+	}{
+		{"VTI", "IRA", models.AssetClassEquity, 9000, 6000},
+		{"BND", "IRA", models.AssetClassFixedIncome, 4000, 5000},
+		{"AAPL", "Brokerage", models.AssetClassEquity, 5000, 1000},
+	} {
+		holding := models.NewHolding(portfolio.ID, seed.ticker, seed.ticker, seed.account)
+		holding.AssetClass = seed.class
+		holding.MarketValue = decimal.NewFromInt(seed.value)
+		holding.CostBasis = decimal.NewFromInt(seed.basis)
+		if err := h.holdingRepo.Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   []string
+	}{
+		{"default by value", "/api/portfolios/" + portfolio.ID.String() + "/holdings", http.StatusOK, []string{"VTI", "AAPL", "BND"}},
+		{"gain", "/api/portfolios/" + portfolio.ID.String() + "/holdings?sort=gain_loss_pct", http.StatusOK, []string{"AAPL", "VTI", "BND"}},
+		{"ticker descending", "/api/portfolios/" + portfolio.ID.String() + "/holdings?sort=ticker&order=desc", http.StatusOK, []string{"VTI", "BND", "AAPL"}},
+		{"account", "/api/portfolios/" + portfolio.ID.String() + "/holdings?filter[account]=ira&sort=gain_loss", http.StatusOK, []string{"VTI", "BND"}},
+		{"asset class", "/api/portfolios/" + portfolio.ID.String() + "/holdings?filter[asset_class]=equity&order=asc", http.StatusOK, []string{"AAPL", "VTI"}},
+		{"unknown sort", "/api/portfolios/" + portfolio.ID.String() + "/holdings?sort=name", http.StatusBadRequest, nil},
+		{"unknown asset class", "/api/portfolios/" + portfolio.ID.String() + "/holdings?filter[asset_class]=gold", http.StatusBadRequest, nil},
+		{"other user's portfolio", "/api/portfolios/" + others.ID.String() + "/holdings", http.StatusNotFound, nil},
+		{"unknown route", "/api/portfolios/" + portfolio.ID.String() + "/lots", http.StatusNotFound, nil},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.APIHoldings(w, withUser(httptest.NewRequest(http.MethodGet, tt.path, nil), user))
+		if w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+			continue
+		}
+		if tt.want == nil {
+			continue
+		}
+
+		var resp struct {
+			Holdings []struct {
+				Ticker string `json:"ticker"`
+			} `json:"holdings"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.name, err)
+		}
+		var got []string
+		for _, holding := range resp.Holdings {
+			got = append(got, holding.Ticker)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestAddHolding(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "manual@example.com")
+	_, others := createTestUser(t, h, "other@example.com")
+
+	add := func(portfolioID, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/portfolios/"+portfolioID+"/holdings", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.AddHolding(w, withUser(r, user))
+		return w
+	}
+
+	w := add(portfolio.ID.String(), `{"ticker": " voo ", "quantity": "10", "price": "400", "cost_basis": "3500"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Add: got status %d: %s", w.Code, w.Body.String())
+	}
+	var added models.Holding
+	if err := json.NewDecoder(w.Body).Decode(&added); err != nil {
+		t.Fatalf("Failed to decode holding: %v", err)
+	}
+	if added.Ticker != "VOO" || added.AccountName != manualAccountName || added.Source != "manual" {
+		t.Errorf("Added %s in %q from %q", added.Ticker, added.AccountName, added.Source)
+	}
+	if !added.MarketValue.Equal(decimal.NewFromInt(4000)) {
+		t.Errorf("Market value = %s, want 4000", added.MarketValue)
+	}
+	if added.AssetClass != models.AssetClassEquity {
+		t.Errorf("Asset class = %s, want it tagged as equity", added.AssetClass)
+	}
+
+	saved, err := h.portfolioRepo.GetByID(portfolio.ID)
+	if err != nil {
+		t.Fatalf("Failed to load portfolio: %v", err)
+	}
+	if len(saved.Holdings) != 1 || !saved.TotalValue.Equal(decimal.NewFromInt(4000)) {
+		t.Errorf("Portfolio has %d holdings worth %s, want 1 worth 4000", len(saved.Holdings), saved.TotalValue)
+	}
+
+	tests := []struct {
+		name        string
+		portfolioID string
+		body        string
+		status      int
+	}{
+		{"same ticker and account", portfolio.ID.String(), `{"ticker": "VOO", "quantity": "1", "price": "400"}`, http.StatusConflict},
+		{"missing ticker", portfolio.ID.String(), `{"quantity": "1", "price": "10"}`, http.StatusBadRequest},
+		{"invalid ticker", portfolio.ID.String(), `{"ticker": "V@O", "quantity": "1", "price": "10"}`, http.StatusBadRequest},
+		{"no quantity", portfolio.ID.String(), `{"ticker": "BND", "price": "70"}`, http.StatusBadRequest},
+		{"negative price", portfolio.ID.String(), `{"ticker": "BND", "quantity": "1", "price": "-70"}`, http.StatusBadRequest},
+		{"no price without market data", portfolio.ID.String(), `{"ticker": "BND", "quantity": "1"}`, http.StatusBadRequest},
+		{"other user's portfolio", others.ID.String(), `{"ticker": "BND", "quantity": "1", "price": "70"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if w := add(tt.portfolioID, tt.body); w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d: %s", tt.name, w.Code, tt.status, w.Body.String())
+		}
+	}
+}
+
+func TestAddHolding_OtherCurrency(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "currency@example.com")
+
+	add := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/portfolios/"+portfolio.ID.String()+"/holdings", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.AddHolding(w, withUser(r, user))
+		return w
+	}
+
+	if w := add(`{"ticker": "SAP.DE", "quantity": "10", "price": "100", "currency": "EUR"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Without exchange rates: got status %d, want 400", w.Code)
+	}
+
+	h.marketDataSvc = marketdata.NewService(marketdata.Config{Provider: marketdata.ProviderMock})
+	w := add(`{"ticker": "SAP.DE", "quantity": "10", "price": "100", "cost_basis": "900", "currency": "eur"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Add: got status %d: %s", w.Code, w.Body.String())
+	}
+	var added models.Holding
+	if err := json.NewDecoder(w.Body).Decode(&added); err != nil {
+		t.Fatalf("Failed to decode holding: %v", err)
+	}
+	if added.Currency != "EUR" || !added.CurrentPrice.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Added at %s %s, want 100 EUR", added.CurrentPrice, added.Currency)
+	}
+	if !added.MarketValue.Equal(decimal.NewFromInt(1080)) || !added.CostBasis.Equal(decimal.NewFromInt(972)) {
+		t.Errorf("Value %s and cost basis %s, want 1080 and 972 USD", added.MarketValue, added.CostBasis)
+	}
+
+	if w := add(`{"ticker": "BND", "quantity": "1", "price": "70", "currency": "euro"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Invalid currency: got status %d, want 400", w.Code)
+	}
+}
+
+func TestCreatePortfolio_ManualEntry(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, _ := createTestUser(t, h, "create@example.com")
+
+	for start, wantPrefix := range map[string]string{"import": "/import?portfolio=", "manual": "/dashboard?portfolio="} {
+		form := url.Values{"name": {"New"}, "start": {start}}
+		r := httptest.NewRequest(http.MethodPost, "/portfolio/new", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.CreatePortfolio(w, withUser(r, user))
+
+		if location := w.Header().Get("Location"); !strings.HasPrefix(location, wantPrefix) {
+			t.Errorf("start=%s redirected to %q, want %s...", start, location, wantPrefix)
+		}
+	}
+}
+
+func TestAPIAllocation(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "allocation@example.com")
+	_, others := createTestUser(t, h, "allocation-other@example.com")
+
+	for _, seed := range []struct {
+		ticker, account, sector string
+		class                   models.AssetClass
+		value                   int64
+	}{
+		{"VTI", "IRA", "Diversified", models.AssetClassEquity, 6000},
+		{"BND", "IRA", "Bonds", models.AssetClassFixedIncome, 3000},
+		{"VTI", "Brokerage", "Diversified", models.AssetClassEquity, 1000},
+	} {
+		holding := models.NewHolding(portfolio.ID, seed.ticker, seed.ticker, seed.account)
+		holding.AssetClass = seed.class
+		holding.Sector = seed.sector
+		holding.Geography = "US"
+		holding.MarketValue = decimal.NewFromInt(seed.value)
+		if err := h.holdingRepo.Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.APIAllocation(w, withUser(httptest.NewRequest(http.MethodGet, "/api/portfolio/allocation?"+query, nil), user))
+		return w
+	}
+
+	for _, tt := range []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"missing ID", "", http.StatusBadRequest},
+		{"other user's portfolio", "portfolio=" + others.ID.String(), http.StatusNotFound},
+	} {
+		if w := get(tt.query); w.Code != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+
+	for _, view := range []string{"", "plain"} {
+		w := get("portfolio=" + portfolio.ID.String() + "&view=" + view)
+		if w.Code != http.StatusOK {
+			t.Fatalf("view %q: got status %d: %s", view, w.Code, w.Body.String())
+		}
+
+		var allocation models.AllocationSummary
+		if err := json.NewDecoder(w.Body).Decode(&allocation); err != nil {
+			t.Fatalf("view %q: failed to decode response: %v", view, err)
+		}
+		if got := allocation.ByAssetClass[models.AssetClassEquity].Percentage; !got.Equal(decimal.NewFromInt(70)) {
+			t.Errorf("view %q: equity got %s%%, want 70%%", view, got)
+		}
+		if got := allocation.ByAccount["IRA"].Value; !got.Equal(decimal.NewFromInt(9000)) {
+			t.Errorf("view %q: IRA got %s, want 9000", view, got)
+		}
+		if got := allocation.TickerTotals["VTI"]; !got.Equal(decimal.NewFromInt(7000)) {
+			t.Errorf("view %q: VTI total got %s, want 7000", view, got)
+		}
+		if len(allocation.TopHoldings) == 0 || len(allocation.ByGeography) == 0 {
+			t.Errorf("view %q: expected top holdings and geographies, got %+v", view, allocation)
+		}
+
+		// VTI is spread across its sectors unless the plain view is asked for
+		_, diversified := allocation.BySector["Diversified"]
+		if allocation.LookThrough != (view == "") || diversified != (view == "plain") {
+			t.Errorf("view %q: got look-through %v, Diversified sector %v", view, allocation.LookThrough, diversified)
+		}
+	}
+}
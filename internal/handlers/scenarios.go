@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -32,7 +35,7 @@ func (h *Handler) ScenariosPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	portfolio, err := h.portfolioRepo.GetByID(pid)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.redirect(w, r, "/dashboard?error=Portfolio+not+found")
 		return
 	}
@@ -57,9 +60,13 @@ func (h *Handler) ScenariosPage(w http.ResponseWriter, r *http.Request) {
 		"Scenarios":       scenarios,
 		"AssetClasses":    models.AllAssetClasses(),
 		"AssetClassStats": models.AssetClassReturns,
+		"TargetID":        "",
+	}
+	if portfolio.TargetScenarioID != nil {
+		data["TargetID"] = portfolio.TargetScenarioID.String()
 	}
 
-	h.render(w, "scenarios.html", data)
+	h.render(w, r, "scenarios.html", data)
 }
 
 // SimulateScenario handles scenario simulation requests
@@ -71,11 +78,19 @@ func (h *Handler) SimulateScenario(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var input struct {
-		PortfolioID string             `json:"portfolio_id"`
-		Allocations map[string]float64 `json:"allocations"`
+		PortfolioID   string             `json:"portfolio_id"`
+		Allocations   map[string]float64 `json:"allocations"`
+		SectorTargets map[string]float64 `json:"sector_targets"`
+		GeoTargets    map[string]float64 `json:"geo_targets"`
 	}
 
+	r.Body = http.MaxBytesReader(w, r.Body, maxSimulationBodySize)
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.jsonError(w, "Request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		h.jsonError(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
@@ -87,12 +102,28 @@ func (h *Handler) SimulateScenario(w http.ResponseWriter, r *http.Request) {
 	}
 
 	portfolio, err := h.portfolioRepo.GetByID(pid)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
 		return
 	}
 
-	portfolio.CalculateTotals()
+	// Projections start from the persisted total, as they do when the
+	// scenario is saved; the holdings only give the current allocation
+	totalValue := portfolio.TotalValue
+
+	// Identical inputs give an identical result, so repeats are answered
+	// from the cache or, when the client already has it, not at all
+	key := simulationKey(portfolio, input.Allocations, input.SectorTargets, input.GeoTargets)
+	etag := `"` + key[:32] + `"`
+	if r.Header.Get("If-None-Match") == etag {
+		setSimulationCacheHeaders(w, etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if body, ok := h.simulations.get(key); ok {
+		writeSimulation(w, etag, body)
+		return
+	}
 
 	// Create scenario from input
 	scenario := models.NewScenario(pid, "Simulation")
@@ -100,27 +131,147 @@ func (h *Handler) SimulateScenario(w http.ResponseWriter, r *http.Request) {
 		class := models.AssetClass(classStr)
 		scenario.SetAllocation(class, decimal.NewFromFloat(pct))
 	}
+	setScenarioTargets(scenario, input.SectorTargets, input.GeoTargets)
 
-	// Calculate projections
-	scenario.CalculateProjections(portfolio.TotalValue)
-
-	// Get current allocation for comparison
-	allocation := portfolio.CalculateAllocation()
-	currentAlloc := make(map[models.AssetClass]decimal.Decimal)
-	for class, slice := range allocation.ByAssetClass {
-		currentAlloc[class] = slice.Percentage
+	if errs := scenario.CheckAllocations(); len(errs) > 0 {
+		h.allocationErrors(w, errs)
+		return
 	}
 
-	comparison := scenario.Compare(currentAlloc, portfolio.TotalValue)
+	// Calculate projections
+	scenario.CalculateProjections(totalValue)
+
+	// Compare with the current allocation, looking through funds as sector
+	// tilt alerts do. Its weights are shares of what the holdings add up to.
+	portfolio.CalculateTotals()
+	comparison := scenario.Compare(portfolio.CalculateLookThroughAllocation(), totalValue)
 
 	// Return results
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(map[string]interface{}{
 		"projections": scenario.Projections,
 		"comparison":  comparison,
 		"valid":       scenario.IsValid(),
 		"total_alloc": scenario.TotalAllocation().InexactFloat64(),
 	})
+	h.simulations.put(key, body.Bytes())
+	writeSimulation(w, etag, body.Bytes())
+}
+
+// setSimulationCacheHeaders lets the browser reuse a simulation for as long
+// as the server would
+func setSimulationCacheHeaders(w http.ResponseWriter, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(simulationCacheTTL.Seconds())))
+}
+
+// writeSimulation sends an encoded simulation result
+func writeSimulation(w http.ResponseWriter, etag string, body []byte) {
+	setSimulationCacheHeaders(w, etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// setScenarioTargets replaces a scenario's sector and geography targets.
+// Names are trimmed; an empty map leaves the dimension untargeted.
+func setScenarioTargets(scenario *models.Scenario, sectors, geographies map[string]float64) {
+	toDecimals := func(targets map[string]float64) map[string]decimal.Decimal {
+		if len(targets) == 0 {
+			return nil
+		}
+		out := make(map[string]decimal.Decimal, len(targets))
+		for name, pct := range targets {
+			out[strings.TrimSpace(name)] = decimal.NewFromFloat(pct)
+		}
+		return out
+	}
+	scenario.SectorTargets = toDecimals(sectors)
+	scenario.GeoTargets = toDecimals(geographies)
+}
+
+// allocationErrors rejects a scenario, listing each allocation problem
+// alongside the summary error
+func (h *Handler) allocationErrors(w http.ResponseWriter, errs []models.AllocationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "Invalid allocations",
+		"fields": errs,
+	})
+}
+
+// SimulateGlidePath projects the portfolio's value following a glide path,
+// an allocation schedule that shifts as the goal nears
+func (h *Handler) SimulateGlidePath(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PortfolioID string `json:"portfolio_id"`
+		Name        string `json:"name"`
+		Years       int    `json:"years"` // Until the goal
+		Schedule    []struct {
+			YearsOut    int                `json:"years_out"`
+			Allocations map[string]float64 `json:"allocations"`
+		} `json:"schedule"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	pid, err := uuid.Parse(input.PortfolioID)
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(pid)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+
+	if input.Years < 1 || input.Years > models.MaxProjectionYears {
+		h.jsonError(w, fmt.Sprintf("Years must be between 1 and %d", models.MaxProjectionYears), http.StatusBadRequest)
+		return
+	}
+
+	path := &models.GlidePath{Name: strings.TrimSpace(input.Name)}
+	if path.Name == "" {
+		path.Name = "Glide Path"
+	}
+	for _, step := range input.Schedule {
+		allocations := make(map[models.AssetClass]decimal.Decimal)
+		for classStr, pct := range step.Allocations {
+			class := models.AssetClass(classStr)
+			if !isKnownAssetClass(class) {
+				h.jsonError(w, "Invalid asset class: "+classStr, http.StatusBadRequest)
+				return
+			}
+			allocations[class] = decimal.NewFromFloat(pct)
+		}
+		path.Schedule = append(path.Schedule, models.GlidePathStep{YearsOut: step.YearsOut, Allocations: allocations})
+	}
+	if err := path.Validate(); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"glide_path": path,
+		"projection": h.analyticsService.ProjectGlidePath(path, portfolio.TotalValue, input.Years),
+	})
 }
 
 // SaveScenario saves a scenario for later reference
@@ -132,9 +283,11 @@ func (h *Handler) SaveScenario(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var input struct {
-		PortfolioID string             `json:"portfolio_id"`
-		Name        string             `json:"name"`
-		Allocations map[string]float64 `json:"allocations"`
+		PortfolioID   string             `json:"portfolio_id"`
+		Name          string             `json:"name"`
+		Allocations   map[string]float64 `json:"allocations"`
+		SectorTargets map[string]float64 `json:"sector_targets"`
+		GeoTargets    map[string]float64 `json:"geo_targets"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -148,13 +301,15 @@ func (h *Handler) SaveScenario(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	portfolio, err := h.portfolioRepo.GetByID(pid)
-	if err != nil || portfolio == nil || portfolio.UserID != user.ID {
+	portfolio, err := h.portfolioRepo.GetSummary(pid)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
 		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
 		return
 	}
-
-	portfolio.CalculateTotals()
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
 
 	// Create and save scenario
 	name := strings.TrimSpace(input.Name)
@@ -167,6 +322,12 @@ func (h *Handler) SaveScenario(w http.ResponseWriter, r *http.Request) {
 		class := models.AssetClass(classStr)
 		scenario.SetAllocation(class, decimal.NewFromFloat(pct))
 	}
+	setScenarioTargets(scenario, input.SectorTargets, input.GeoTargets)
+
+	if errs := scenario.Validate(); len(errs) > 0 {
+		h.allocationErrors(w, errs)
+		return
+	}
 
 	scenario.CalculateProjections(portfolio.TotalValue)
 
@@ -182,6 +343,150 @@ func (h *Handler) SaveScenario(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UpdateScenario edits a saved scenario in place, keeping its ID and
+// creation date
+func (h *Handler) UpdateScenario(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		ID            string             `json:"id"`
+		Name          string             `json:"name"`
+		Allocations   map[string]float64 `json:"allocations"`
+		SectorTargets map[string]float64 `json:"sector_targets"`
+		GeoTargets    map[string]float64 `json:"geo_targets"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	sid, err := uuid.Parse(input.ID)
+	if err != nil {
+		h.jsonError(w, "Invalid scenario ID", http.StatusBadRequest)
+		return
+	}
+
+	scenario, err := h.scenarioRepo.GetByID(sid)
+	if err != nil || scenario == nil {
+		h.jsonError(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(scenario.PortfolioID)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
+
+	if name := strings.TrimSpace(input.Name); name != "" {
+		scenario.Name = name
+	}
+
+	scenario.Allocations = make(map[models.AssetClass]decimal.Decimal)
+	for classStr, pct := range input.Allocations {
+		class := models.AssetClass(classStr)
+		scenario.SetAllocation(class, decimal.NewFromFloat(pct))
+	}
+	setScenarioTargets(scenario, input.SectorTargets, input.GeoTargets)
+
+	if errs := scenario.Validate(); len(errs) > 0 {
+		h.allocationErrors(w, errs)
+		return
+	}
+
+	scenario.CalculateProjections(portfolio.TotalValue)
+
+	if err := h.scenarioRepo.Update(scenario); err != nil {
+		h.jsonError(w, "Failed to update scenario", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"id":          scenario.ID.String(),
+		"projections": scenario.Projections,
+	})
+}
+
+// SetTargetScenario designates one of a portfolio's saved scenarios as its
+// target allocation, or clears the target when no scenario ID is given
+func (h *Handler) SetTargetScenario(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input struct {
+		PortfolioID string `json:"portfolio_id"`
+		ScenarioID  string `json:"scenario_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	pid, err := uuid.Parse(input.PortfolioID)
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(pid)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
+
+	var target *uuid.UUID
+	if input.ScenarioID != "" {
+		sid, err := uuid.Parse(input.ScenarioID)
+		if err != nil {
+			h.jsonError(w, "Invalid scenario ID", http.StatusBadRequest)
+			return
+		}
+
+		// The target must be one of this portfolio's own scenarios
+		scenario, err := h.scenarioRepo.GetByID(sid)
+		if err != nil || scenario == nil || scenario.PortfolioID != portfolio.ID {
+			h.jsonError(w, "Scenario not found", http.StatusNotFound)
+			return
+		}
+		target = &sid
+	}
+
+	if err := h.portfolioRepo.SetTargetScenario(portfolio.ID, target); err != nil {
+		h.jsonError(w, "Failed to set target", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":            true,
+		"target_scenario_id": target,
+	})
+}
+
 // DeleteScenario removes a saved scenario
 func (h *Handler) DeleteScenario(w http.ResponseWriter, r *http.Request) {
 	user := middleware.GetUser(r)
@@ -197,6 +502,23 @@ func (h *Handler) DeleteScenario(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only delete scenarios on the user's own portfolios
+	scenario, err := h.scenarioRepo.GetByID(sid)
+	if err != nil || scenario == nil {
+		h.jsonError(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(scenario.PortfolioID)
+	if err != nil || portfolio == nil || !h.canAccess(user, portfolio, accessView) {
+		h.jsonError(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+	if !h.canAccess(user, portfolio, accessEdit) {
+		h.jsonError(w, errReadOnly, http.StatusForbidden)
+		return
+	}
+
 	// Delete the scenario
 	if err := h.scenarioRepo.Delete(sid); err != nil {
 		h.jsonError(w, "Failed to delete", http.StatusInternalServerError)
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// newTestHandler returns a handler backed by a migrated temporary database
+func newTestHandler(t *testing.T) (*Handler, *storage.DB) {
+	t.Helper()
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	h := &Handler{
+		userRepo:        storage.NewUserRepository(db),
+		portfolioRepo:   storage.NewPortfolioRepository(db),
+		holdingRepo:     storage.NewHoldingRepository(db),
+		lotRepo:         storage.NewHoldingLotRepository(db),
+		shareRepo:       storage.NewShareRepository(db),
+		scenarioRepo:    storage.NewScenarioRepository(db),
+		overrideRepo:    storage.NewTickerOverrideRepository(db),
+		alertStateRepo:  storage.NewAlertStateRepository(db),
+		transactionRepo: storage.NewTransactionRepository(db),
+		simulations:     newSimulationCache(simulationCacheTTL),
+		pendingImports:  newPendingImports(pendingImportTTL),
+	}
+	return h, db
+}
+
+// createTestUser inserts a user with one portfolio
+func createTestUser(t *testing.T, h *Handler, email string) (*models.User, *models.Portfolio) {
+	t.Helper()
+
+	user := models.NewUser(email, "Test User", "hash")
+	if err := h.userRepo.Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	portfolio := models.NewPortfolio(user.ID, "Test Portfolio")
+	if err := h.portfolioRepo.Create(portfolio); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+	return user, portfolio
+}
+
+// withUser attaches an authenticated user to a request
+func withUser(r *http.Request, user *models.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), middleware.UserContextKey, user))
+}
+
+func TestDeleteScenario_Ownership(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	owner, portfolio := createTestUser(t, h, "owner@example.com")
+	other, _ := createTestUser(t, h, "other@example.com")
+
+	scenario := models.NewScenario(portfolio.ID, "Target")
+	scenario.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(100))
+	if err := h.scenarioRepo.Create(scenario); err != nil {
+		t.Fatalf("Failed to create scenario: %v", err)
+	}
+
+	deleteAs := func(user *models.User) int {
+		r := httptest.NewRequest(http.MethodDelete, "/api/scenarios?id="+scenario.ID.String(), nil)
+		w := httptest.NewRecorder()
+		h.DeleteScenario(w, withUser(r, user))
+		return w.Code
+	}
+
+	// Another user can't delete it, and can't tell it exists
+	if code := deleteAs(other); code != http.StatusNotFound {
+		t.Errorf("Cross-user delete: got status %d, want %d", code, http.StatusNotFound)
+	}
+	if saved, _ := h.scenarioRepo.GetByID(scenario.ID); saved == nil {
+		t.Fatal("Scenario was deleted by another user")
+	}
+
+	if code := deleteAs(owner); code != http.StatusOK {
+		t.Errorf("Owner delete: got status %d, want %d", code, http.StatusOK)
+	}
+	if saved, _ := h.scenarioRepo.GetByID(scenario.ID); saved != nil {
+		t.Error("Expected scenario to be deleted by its owner")
+	}
+
+	// Already gone
+	if code := deleteAs(owner); code != http.StatusNotFound {
+		t.Errorf("Repeat delete: got status %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestSetTargetScenario(t *testing.T) {
+	h, _ := newTestHandler(t)
+
+	owner, portfolio := createTestUser(t, h, "target@example.com")
+	_, otherPortfolio := createTestUser(t, h, "elsewhere@example.com")
+
+	scenario := models.NewScenario(portfolio.ID, "60/40")
+	scenario.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(60))
+	scenario.SetAllocation(models.AssetClassFixedIncome, decimal.NewFromInt(40))
+	foreign := models.NewScenario(otherPortfolio.ID, "Someone else's")
+	for _, s := range []*models.Scenario{scenario, foreign} {
+		if err := h.scenarioRepo.Create(s); err != nil {
+			t.Fatalf("Failed to create scenario: %v", err)
+		}
+	}
+
+	setTarget := func(scenarioID string) int {
+		body := `{"portfolio_id":"` + portfolio.ID.String() + `","scenario_id":"` + scenarioID + `"}`
+		r := httptest.NewRequest(http.MethodPost, "/api/portfolio/target", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.SetTargetScenario(w, withUser(r, owner))
+		return w.Code
+	}
+	savedTarget := func() *uuid.UUID {
+		p, err := h.portfolioRepo.GetByID(portfolio.ID)
+		if err != nil || p == nil {
+			t.Fatalf("Failed to load portfolio: %v", err)
+		}
+		return p.TargetScenarioID
+	}
+
+	// A scenario from another portfolio can't be the target
+	if code := setTarget(foreign.ID.String()); code != http.StatusNotFound {
+		t.Errorf("Foreign scenario: got status %d, want %d", code, http.StatusNotFound)
+	}
+	if got := savedTarget(); got != nil {
+		t.Errorf("Foreign scenario: target got %s, want none", got)
+	}
+
+	if code := setTarget(scenario.ID.String()); code != http.StatusOK {
+		t.Fatalf("Set target: got status %d, want %d", code, http.StatusOK)
+	}
+	if got := savedTarget(); got == nil || *got != scenario.ID {
+		t.Errorf("Set target: got %v, want %s", got, scenario.ID)
+	}
+
+	if code := setTarget(""); code != http.StatusOK {
+		t.Fatalf("Clear target: got status %d, want %d", code, http.StatusOK)
+	}
+	if got := savedTarget(); got != nil {
+		t.Errorf("Clear target: got %s, want none", got)
+	}
+
+	// Deleting the target scenario clears it
+	setTarget(scenario.ID.String())
+	if err := h.scenarioRepo.Delete(scenario.ID); err != nil {
+		t.Fatalf("Failed to delete scenario: %v", err)
+	}
+	if got := savedTarget(); got != nil {
+		t.Errorf("After delete: got %s, want none", got)
+	}
+}
+
+func TestSimulateScenario_Cached(t *testing.T) {
+	h, _ := newTestHandler(t)
+	user, portfolio := createTestUser(t, h, "simulate@example.com")
+
+	addHolding := func(ticker string, class models.AssetClass, value int64) {
+		holding := models.NewHolding(portfolio.ID, ticker, ticker, "Brokerage")
+		holding.AssetClass = class
+		holding.MarketValue = decimal.NewFromInt(value)
+		if err := h.holdingRepo.Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+	addHolding("VTI", models.AssetClassEquity, 6000)
+
+	simulate := func(allocations, etag string) *httptest.ResponseRecorder {
+		body := `{"portfolio_id":"` + portfolio.ID.String() + `","allocations":` + allocations + `}`
+		r := httptest.NewRequest(http.MethodPost, "/api/scenarios/simulate", strings.NewReader(body))
+		if etag != "" {
+			r.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		h.SimulateScenario(w, withUser(r, user))
+		return w
+	}
+
+	first := simulate(`{"equity": 60, "fixed_income": 40}`, "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Header().Get("Cache-Control") == "" {
+		t.Fatalf("First: got status %d, ETag %q, Cache-Control %q", first.Code, etag, first.Header().Get("Cache-Control"))
+	}
+
+	// The same weights written differently are the same simulation
+	second := simulate(`{"fixed_income": 40.0, "equity": 60}`, "")
+	if second.Header().Get("ETag") != etag || second.Body.String() != first.Body.String() {
+		t.Errorf("Repeat: got ETag %q, want %q with the same body", second.Header().Get("ETag"), etag)
+	}
+
+	if w := simulate(`{"equity": 60, "fixed_income": 40}`, etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match: got status %d with %d bytes, want 304 and no body", w.Code, w.Body.Len())
+	}
+
+	// A change to the holdings changes the comparison
+	addHolding("BND", models.AssetClassFixedIncome, 4000)
+	if w := simulate(`{"equity": 60, "fixed_income": 40}`, etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("After a new holding: got status %d, ETag %q, want a fresh result", w.Code, w.Header().Get("ETag"))
+	}
+
+	if w := simulate(`{"equity": -10}`, ""); w.Code != http.StatusBadRequest || w.Header().Get("ETag") != "" {
+		t.Errorf("Invalid: got status %d, ETag %q, want 400 without one", w.Code, w.Header().Get("ETag"))
+	}
+
+	// Projections start from the persisted total, as a saved scenario's do,
+	// even when it differs from what the holdings add up to
+	portfolio.TotalValue = decimal.NewFromInt(20000)
+	if err := h.portfolioRepo.Update(portfolio); err != nil {
+		t.Fatalf("Failed to update portfolio: %v", err)
+	}
+	w := simulate(`{"equity": 100}`, "")
+	var result struct {
+		Projections models.ScenarioProjections `json:"projections"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	saved := models.NewScenario(portfolio.ID, "Saved")
+	saved.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(100))
+	saved.CalculateProjections(portfolio.TotalValue)
+	if !result.Projections.ExpectedValue.Equal(saved.Projections.ExpectedValue) {
+		t.Errorf("Expected value: got %s, want %s as when saved", result.Projections.ExpectedValue, saved.Projections.ExpectedValue)
+	}
+
+	huge := `{"equity": 60, "fixed_income": 40, "padding": "` + strings.Repeat("x", maxSimulationBodySize) + `"}`
+	if w := simulate(huge, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Oversized body: got status %d, want 413", w.Code)
+	}
+}
+
+func TestSimulationCache_EvictsOldest(t *testing.T) {
+	cache := newSimulationCache(time.Minute)
+	for i := 0; i <= maxSimulations; i++ {
+		cache.put(fmt.Sprintf("key-%d", i), []byte("{}"))
+		time.Sleep(time.Microsecond) // Distinct expiries, so the oldest is known
+	}
+
+	if len(cache.entries) != maxSimulations {
+		t.Errorf("Cached %d simulations, want %d", len(cache.entries), maxSimulations)
+	}
+	if _, ok := cache.get("key-0"); ok {
+		t.Error("Expected the oldest simulation to be evicted")
+	}
+	if _, ok := cache.get(fmt.Sprintf("key-%d", maxSimulations)); !ok {
+		t.Error("Expected the newest simulation to be cached")
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// access is what a user may do with a portfolio. Levels are ordered, so a
+// check for one level passes for every level above it.
+type access int
+
+const (
+	accessNone  access = iota
+	accessView         // Shared with them as a viewer
+	accessEdit         // Shared as an editor: import, refresh, reclassify, scenarios
+	accessOwner        // Everything, including deleting and sharing
+)
+
+// errReadOnly is shown to collaborators who try to change a portfolio they
+// can only view
+const errReadOnly = "You have read-only access to this portfolio"
+
+// portfolioAccess returns the user's access level on a portfolio
+func (h *Handler) portfolioAccess(user *models.User, portfolio *models.Portfolio) access {
+	if portfolio.UserID == user.ID {
+		return accessOwner
+	}
+	if h.shareRepo == nil {
+		return accessNone
+	}
+
+	role, err := h.shareRepo.GetRole(portfolio.ID, user.ID)
+	if err != nil {
+		log.Printf("shares: loading role on portfolio %s: %v", portfolio.ID, err)
+		return accessNone
+	}
+	switch role {
+	case models.ShareRoleEditor:
+		return accessEdit
+	case models.ShareRoleViewer:
+		return accessView
+	default:
+		return accessNone
+	}
+}
+
+// canAccess reports whether the user has at least the given access level
+func (h *Handler) canAccess(user *models.User, portfolio *models.Portfolio, need access) bool {
+	return h.portfolioAccess(user, portfolio) >= need
+}
+
+// ListShares returns who a portfolio is shared with. Only the owner can see
+// the list.
+func (h *Handler) ListShares(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolio, ok := h.getSharedPortfolio(w, user, r.URL.Query().Get("portfolio"))
+	if !ok {
+		return
+	}
+
+	shares, err := h.shareRepo.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load shares", http.StatusInternalServerError)
+		return
+	}
+	if shares == nil {
+		shares = []models.PortfolioShare{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shares)
+}
+
+// CreateShare invites an existing user, by email, to a portfolio. Inviting
+// someone already on it changes their role.
+func (h *Handler) CreateShare(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireVerified(w, user) {
+		return
+	}
+
+	var input struct {
+		PortfolioID string `json:"portfolio_id"`
+		Email       string `json:"email"`
+		Role        string `json:"role"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	role, err := models.ParseShareRole(input.Role)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getSharedPortfolio(w, user, input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(input.Email))
+	if email == "" {
+		h.jsonError(w, "Email is required", http.StatusBadRequest)
+		return
+	}
+
+	invitee, err := h.userRepo.GetByEmail(email)
+	if err != nil {
+		h.jsonError(w, "Failed to look up user", http.StatusInternalServerError)
+		return
+	}
+	if invitee == nil {
+		h.jsonError(w, "No user with that email", http.StatusNotFound)
+		return
+	}
+	if invitee.ID == user.ID {
+		h.jsonError(w, "You already own this portfolio", http.StatusBadRequest)
+		return
+	}
+
+	share := &models.PortfolioShare{
+		PortfolioID: portfolio.ID,
+		UserID:      invitee.ID,
+		Email:       invitee.Email,
+		Name:        invitee.Name,
+		Role:        role,
+	}
+	if err := h.shareRepo.Upsert(share); err != nil {
+		h.jsonError(w, "Failed to share portfolio", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+// DeleteShare revokes a collaborator's access. The owner can remove anyone;
+// a collaborator can remove themselves.
+func (h *Handler) DeleteShare(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	pid, err := uuid.Parse(r.URL.Query().Get("portfolio"))
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return
+	}
+	uid, err := uuid.Parse(r.URL.Query().Get("user"))
+	if err != nil {
+		h.jsonError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(pid)
+	if err != nil || portfolio == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+	level := h.portfolioAccess(user, portfolio)
+	if level == accessNone {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return
+	}
+	if level != accessOwner && uid != user.ID {
+		h.jsonError(w, "Only the owner can manage sharing", http.StatusForbidden)
+		return
+	}
+
+	if err := h.shareRepo.Delete(pid, uid); err != nil {
+		h.jsonError(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// getSharedPortfolio loads a portfolio whose sharing the user manages,
+// writing an error response unless they own it
+func (h *Handler) getSharedPortfolio(w http.ResponseWriter, user *models.User, portfolioID string) (*models.Portfolio, bool) {
+	pid, err := uuid.Parse(portfolioID)
+	if err != nil {
+		h.jsonError(w, "Invalid portfolio ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	portfolio, err := h.portfolioRepo.GetSummary(pid)
+	if err != nil || portfolio == nil {
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	switch h.portfolioAccess(user, portfolio) {
+	case accessOwner:
+		return portfolio, true
+	case accessNone:
+		h.jsonError(w, "Portfolio not found", http.StatusNotFound)
+	default:
+		h.jsonError(w, "Only the owner can manage sharing", http.StatusForbidden)
+	}
+	return nil, false
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// simulationCacheTTL is how long a simulation result is reused, here and by
+// the browser. Dragging a slider back and forth repeats the same inputs
+// within seconds.
+const simulationCacheTTL = time.Minute
+
+// maxSimulations caps how many simulation responses are cached at once;
+// past it the oldest is dropped
+const maxSimulations = 1024
+
+// maxSimulationBodySize caps a simulation request. Weights for every asset
+// class, sector and region fit in a small fraction of it.
+const maxSimulationBodySize = 64 << 10
+
+// simulationCache keeps encoded simulation responses by simulationKey
+type simulationCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]simulationEntry
+}
+
+type simulationEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+func newSimulationCache(ttl time.Duration) *simulationCache {
+	return &simulationCache{ttl: ttl, entries: make(map[string]simulationEntry)}
+}
+
+// get returns the response cached for key, if it hasn't expired
+func (c *simulationCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// put caches a response for key, dropping any that have expired and the
+// oldest when the cache is full
+func (c *simulationCache) put(key string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var oldest string
+	for k, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, k)
+		} else if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+			oldest = k
+		}
+	}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxSimulations {
+		delete(c.entries, oldest)
+	}
+	c.entries[key] = simulationEntry{body: body, expires: now.Add(c.ttl)}
+}
+
+// simulationKey identifies a simulation by everything its result depends
+// on: the holdings it's compared with, the cash yield projections assume
+// and the requested weights. Weights are compared by value, so 25 and 25.0
+// are the same request, and in name order; target names are trimmed as
+// setScenarioTargets trims them.
+func simulationKey(portfolio *models.Portfolio, allocations, sectors, geographies map[string]float64) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s|%s|%s\n", portfolio.ID, portfolio.TotalValue, models.CashYield)
+	for _, h := range portfolio.Holdings {
+		fmt.Fprintf(hash, "%s|%s|%s|%s|%s|%s\n", h.ID, h.Ticker, h.AssetClass, h.Sector, h.Geography, h.MarketValue)
+	}
+	writeWeights(hash, allocations, false)
+	writeWeights(hash, sectors, true)
+	writeWeights(hash, geographies, true)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// writeWeights writes weights to w in name order
+func writeWeights(w io.Writer, weights map[string]float64, trim bool) {
+	names := make([]string, 0, len(weights))
+	normalized := make(map[string]string, len(weights))
+	for name, pct := range weights {
+		if trim {
+			name = strings.TrimSpace(name)
+		}
+		names = append(names, name)
+		normalized[name] = decimal.NewFromFloat(pct).String()
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "%q=%s;", name, normalized[name])
+	}
+	fmt.Fprintln(w)
+}
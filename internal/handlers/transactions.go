@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ListTransactions returns a portfolio's buys and sells, oldest first
+func (h *Handler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolio, err := h.getPortfolioForUser(user, r.URL.Query().Get("portfolio"))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transactions, err := h.transactionRepo.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load transactions", http.StatusInternalServerError)
+		return
+	}
+	if transactions == nil {
+		transactions = []models.Transaction{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+// CreateTransaction records a buy or sell in a portfolio. Holdings aren't
+// changed; transactions are the history realized gains are worked out from.
+func (h *Handler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var input struct {
+		PortfolioID string          `json:"portfolio_id"`
+		Ticker      string          `json:"ticker"`
+		AccountName string          `json:"account_name"`
+		Type        string          `json:"type"`
+		Quantity    decimal.Decimal `json:"quantity"`
+		Price       decimal.Decimal `json:"price"`
+		Fees        decimal.Decimal `json:"fees"`
+		Date        string          `json:"date"` // YYYY-MM-DD
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	txType, err := models.ParseTransactionType(input.Type)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ticker, err := models.NormalizeTicker(input.Ticker)
+	if ticker == "" {
+		h.jsonError(w, "Ticker is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.jsonError(w, "Invalid ticker: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !input.Quantity.IsPositive() {
+		h.jsonError(w, "Quantity must be positive", http.StatusBadRequest)
+		return
+	}
+	if input.Price.IsNegative() || input.Fees.IsNegative() {
+		h.jsonError(w, "Price and fees can't be negative", http.StatusBadRequest)
+		return
+	}
+	date, err := time.Parse("2006-01-02", input.Date)
+	if err != nil {
+		h.jsonError(w, "Date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if date.After(time.Now().UTC()) {
+		h.jsonError(w, "Date can't be in the future", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, input.PortfolioID)
+	if !ok {
+		return
+	}
+
+	tx := models.NewTransaction(portfolio.ID, ticker, txType, input.Quantity, input.Price, date)
+	tx.AccountName = strings.TrimSpace(input.AccountName)
+	tx.Fees = input.Fees
+
+	// A sale has to be covered by the shares bought before it
+	if txType == models.TransactionSell {
+		existing, err := h.transactionRepo.GetByPortfolioID(portfolio.ID)
+		if err != nil {
+			h.jsonError(w, "Failed to load transactions", http.StatusInternalServerError)
+			return
+		}
+		if _, err := analytics.RealizedGains(append(existing, *tx), models.CostBasisFIFO); errors.Is(err, analytics.ErrOversold) {
+			h.jsonError(w, "Sale is for more "+ticker+" shares than were held", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := h.transactionRepo.Create(tx); err != nil {
+		h.jsonError(w, "Failed to save transaction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tx)
+}
+
+// DeleteTransaction removes one of a portfolio's transactions. A purchase
+// that later sales depend on can't be removed, since the sales would then
+// be for more shares than were held.
+func (h *Handler) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(r.URL.Query().Get("id"))
+	if err != nil {
+		h.jsonError(w, "Invalid transaction ID", http.StatusBadRequest)
+		return
+	}
+
+	portfolio, ok := h.getEditablePortfolio(w, user, r.URL.Query().Get("portfolio"))
+	if !ok {
+		return
+	}
+
+	existing, err := h.transactionRepo.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load transactions", http.StatusInternalServerError)
+		return
+	}
+	remaining := make([]models.Transaction, 0, len(existing))
+	for _, t := range existing {
+		if t.ID != id {
+			remaining = append(remaining, t)
+		}
+	}
+	if _, err := analytics.RealizedGains(remaining, models.CostBasisFIFO); errors.Is(err, analytics.ErrOversold) {
+		h.jsonError(w, "Later sales depend on this purchase; delete them first", http.StatusConflict)
+		return
+	}
+
+	if err := h.transactionRepo.Delete(portfolio.ID, id); err != nil {
+		h.jsonError(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// APIRealizedGains returns the gains realized by a portfolio's sales, with
+// this year's total, as JSON. The method query parameter picks FIFO or
+// average cost, defaulting to the configured method.
+func (h *Handler) APIRealizedGains(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	portfolio, err := h.getPortfolioForUser(user, r.URL.Query().Get("portfolio"))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if h.analyticsService == nil {
+		h.jsonError(w, "Analytics service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	methodName := r.URL.Query().Get("method")
+	if methodName == "" && h.cfg != nil {
+		methodName = h.cfg.CostBasisMethod
+	}
+	method := models.CostBasisFIFO
+	if methodName != "" {
+		if method, err = models.ParseCostBasisMethod(methodName); err != nil {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	transactions, err := h.transactionRepo.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load transactions", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := h.analyticsService.CalculateRealizedGains(transactions, method, time.Now().UTC())
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
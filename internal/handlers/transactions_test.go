@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/shopspring/decimal"
+)
+
+func TestTransactionsAndRealizedGains(t *testing.T) {
+	h, _ := newTestHandler(t)
+	h.analyticsService = analytics.NewService()
+	user, portfolio := createTestUser(t, h, "transactions@example.com")
+
+	create := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/transactions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.CreateTransaction(w, withUser(r, user))
+		return w
+	}
+	pid := portfolio.ID.String()
+
+	for _, body := range []string{
+		`{"portfolio_id": "` + pid + `", "ticker": "aapl", "type": "buy", "quantity": "10", "price": "100", "date": "2022-01-10"}`,
+		`{"portfolio_id": "` + pid + `", "ticker": "AAPL", "type": "buy", "quantity": "10", "price": "150", "date": "2023-06-01"}`,
+		`{"portfolio_id": "` + pid + `", "ticker": "AAPL", "type": "sell", "quantity": "15", "price": "200", "date": "2024-03-01"}`,
+	} {
+		if w := create(body); w.Code != http.StatusCreated {
+			t.Fatalf("Create: got status %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// Only 5 shares are left to sell
+	if w := create(`{"portfolio_id": "` + pid + `", "ticker": "AAPL", "type": "sell", "quantity": "6", "price": "200", "date": "2024-04-01"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Oversold: got status %d, want 400", w.Code)
+	}
+	if w := create(`{"portfolio_id": "` + pid + `", "ticker": "AAPL", "type": "gift", "quantity": "1", "price": "1", "date": "2024-04-01"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown type: got status %d, want 400", w.Code)
+	}
+
+	// The sale depends on both purchases; the second is worth deleting only
+	// once the sale is gone
+	saved, err := h.transactionRepo.GetByPortfolioID(portfolio.ID)
+	if err != nil || len(saved) != 3 {
+		t.Fatalf("Saved %d transactions (%v), want 3", len(saved), err)
+	}
+	del := func(id string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodDelete, "/api/transactions?portfolio="+pid+"&id="+id, nil)
+		w := httptest.NewRecorder()
+		h.DeleteTransaction(w, withUser(r, user))
+		return w
+	}
+	for _, tx := range saved {
+		if tx.Type == models.TransactionBuy {
+			if w := del(tx.ID.String()); w.Code != http.StatusConflict {
+				t.Errorf("Delete purchase on %s: got status %d, want 409", tx.Date.Format("2006-01-02"), w.Code)
+			}
+		}
+	}
+	if saved, _ := h.transactionRepo.GetByPortfolioID(portfolio.ID); len(saved) != 3 {
+		t.Errorf("Got %d transactions after refused deletes, want 3", len(saved))
+	}
+
+	tests := []struct {
+		method string
+		total  string
+	}{
+		{"", "1250"}, // FIFO when nothing is configured
+		{"average", "1125"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/analytics/realized-gains?portfolio="+pid+"&method="+tt.method, nil)
+		w := httptest.NewRecorder()
+		h.APIRealizedGains(w, withUser(r, user))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Realized gains %q: got status %d: %s", tt.method, w.Code, w.Body.String())
+		}
+
+		var report models.RealizedGainsReport
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if len(report.Gains) != 2 || !report.TotalGain.Equal(decimal.RequireFromString(tt.total)) {
+			t.Errorf("Realized gains %q: got %d gains totalling %s, want 2 totalling %s", tt.method, len(report.Gains), report.TotalGain, tt.total)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/analytics/realized-gains?portfolio="+pid+"&method=lifo", nil)
+	w := httptest.NewRecorder()
+	h.APIRealizedGains(w, withUser(r, user))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Unknown method: got status %d, want 400", w.Code)
+	}
+
+	// Without the sale, the purchases can go
+	for _, txType := range []models.TransactionType{models.TransactionSell, models.TransactionBuy} {
+		for _, tx := range saved {
+			if tx.Type != txType {
+				continue
+			}
+			if w := del(tx.ID.String()); w.Code != http.StatusOK {
+				t.Errorf("Delete %s on %s: got status %d: %s", tx.Type, tx.Date.Format("2006-01-02"), w.Code, w.Body.String())
+			}
+		}
+	}
+}
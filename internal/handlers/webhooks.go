@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/findosh/truenorth/internal/middleware"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/webhook"
+	"github.com/google/uuid"
+)
+
+// ListWebhooks returns the user's registered alert webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hooks, err := h.webhookRepo.GetByUserID(user.ID)
+	if err != nil {
+		h.jsonError(w, "Failed to load webhooks", http.StatusInternalServerError)
+		return
+	}
+	if hooks == nil {
+		hooks = []*models.Webhook{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// CreateWebhook registers a URL to receive new alerts. The signing secret
+// is returned only in this response.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !h.requireVerified(w, user) {
+		return
+	}
+
+	var input struct {
+		URL string `json:"url"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	hookURL := strings.TrimSpace(input.URL)
+	if err := webhook.ValidateURL(hookURL); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		h.jsonError(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	hook := models.NewWebhook(user.ID, hookURL, secret)
+	if err := h.webhookRepo.Create(hook); err != nil {
+		h.jsonError(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     hook.ID.String(),
+		"url":    hook.URL,
+		"secret": hook.Secret,
+	})
+}
+
+// DeleteWebhook removes one of the user's webhooks
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	hook, ok := h.getWebhookForUser(w, user, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	if err := h.webhookRepo.Delete(hook.ID); err != nil {
+		h.jsonError(w, "Failed to delete", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// TestWebhook sends a sample alert to one of the user's webhooks and
+// reports whether the endpoint accepted it. Why a delivery failed is only
+// logged, so the response can't be used to probe other hosts.
+func (h *Handler) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUser(r)
+	if user == nil {
+		h.jsonError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		h.jsonError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hook, ok := h.getWebhookForUser(w, user, r.URL.Query().Get("id"))
+	if !ok {
+		return
+	}
+
+	if h.webhookSvc == nil {
+		h.jsonError(w, "Webhook service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := map[string]interface{}{"success": true}
+	if err := h.webhookSvc.SendTest(hook); err != nil {
+		log.Printf("webhook %s: test delivery failed: %v", hook.ID, err)
+		result = map[string]interface{}{"success": false, "error": "Delivery failed"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// getWebhookForUser loads a webhook by ID, writing a 404 unless it belongs
+// to the user
+func (h *Handler) getWebhookForUser(w http.ResponseWriter, user *models.User, webhookID string) (*models.Webhook, bool) {
+	id, err := uuid.Parse(webhookID)
+	if err != nil {
+		h.jsonError(w, "Invalid webhook ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	hook, err := h.webhookRepo.GetByID(id)
+	if err != nil || hook == nil || hook.UserID != user.ID {
+		h.jsonError(w, "Webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+	return hook, true
+}
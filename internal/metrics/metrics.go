@@ -0,0 +1,44 @@
+// Package metrics defines the Prometheus metrics exported by the server
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// HTTPRequests counts served requests by route and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "truenorth_http_requests_total",
+		Help: "HTTP requests served, by route pattern and status code.",
+	}, []string{"path", "status"})
+
+	// HTTPDuration tracks how long requests take to serve by route
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "truenorth_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"path"})
+
+	// QuoteCache counts quote lookups answered from cache ("hit"), from
+	// the provider ("miss"), or by joining a fetch already in flight
+	// ("shared")
+	QuoteCache = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "truenorth_quote_cache_requests_total",
+		Help: "Quote lookups, by whether they were served from cache.",
+	}, []string{"result"})
+
+	// QuoteProviderErrors counts failed quote fetches by provider
+	QuoteProviderErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "truenorth_quote_provider_errors_total",
+		Help: "Failed quote fetches, by market data provider.",
+	}, []string{"provider"})
+)
+
+// Handler serves all registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
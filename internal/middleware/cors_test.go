@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	cors := NewCORS([]string{"https://app.example.com/", "*"})
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+	handler := cors.Handler(inner)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods bool
+	}{
+		{"allowed preflight", http.MethodOptions, "/api/alerts", "https://app.example.com", true, http.StatusNoContent, "https://app.example.com", true},
+		{"disallowed preflight", http.MethodOptions, "/api/alerts", "https://evil.example.com", true, http.StatusNoContent, "", false},
+		{"allowed request", http.MethodGet, "/api/alerts", "https://app.example.com", false, http.StatusUnauthorized, "https://app.example.com", false},
+		{"wildcard not honored", http.MethodGet, "/api/alerts", "https://other.example.com", false, http.StatusUnauthorized, "", false},
+		{"non-API route", http.MethodGet, "/dashboard", "https://app.example.com", false, http.StatusUnauthorized, "", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		if tt.preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%s: status got %d, want %d", tt.name, rec.Code, tt.wantStatus)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: allow origin got %q, want %q", tt.name, got, tt.wantOrigin)
+		}
+		if tt.wantOrigin != "" && rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+			t.Errorf("%s: missing Allow-Credentials", tt.name)
+		}
+		if got := rec.Header().Get("Access-Control-Allow-Methods") != ""; got != tt.wantMethods {
+			t.Errorf("%s: allow methods present got %v, want %v", tt.name, got, tt.wantMethods)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// Access log formats accepted by RequestLogger
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// requestIDHeader carries the request ID in from a proxy and back out to
+// the client
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps client-supplied request IDs so they can't bloat
+// the logs
+const maxRequestIDLength = 128
+
+const requestInfoKey contextKey = "request_info"
+
+// accessLog writes JSON access log lines without the standard logger's
+// timestamp prefix, so each line is a valid JSON object
+var accessLog = log.New(os.Stderr, "", 0)
+
+// requestInfo holds details the logger can only learn once the request has
+// passed through inner middleware, such as who the user is
+type requestInfo struct {
+	ID     string
+	UserID string
+}
+
+// accessLogEntry is one JSON access log line
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	RequestID  string    `json:"request_id"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	UserID     string    `json:"user_id,omitempty"`
+}
+
+// RequestLogger returns the access logging middleware for format. Anything
+// other than LogFormatJSON gets the plain Logger.
+func RequestLogger(format string) func(http.Handler) http.Handler {
+	if format == LogFormatJSON {
+		return JSONLogger
+	}
+	return Logger
+}
+
+// JSONLogger logs each request as a single JSON object for log aggregators
+func JSONLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		info := &requestInfo{ID: requestID(r)}
+		w.Header().Set(requestIDHeader, info.ID)
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey, info))
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+
+		line, err := json.Marshal(accessLogEntry{
+			Time:       start.UTC(),
+			RequestID:  info.ID,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rw.Status(),
+			Bytes:      rw.bytes,
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			UserID:     info.UserID,
+		})
+		if err != nil {
+			log.Printf("access log: %v", err)
+			return
+		}
+		accessLog.Println(string(line))
+	})
+}
+
+// requestID reuses the ID set by an upstream proxy, or makes a new one
+func requestID(r *http.Request) string {
+	if id := r.Header.Get(requestIDHeader); id != "" && len(id) <= maxRequestIDLength {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// recordUser tells the access logger which user made the request
+func recordUser(r *http.Request, user *models.User) {
+	if info, ok := r.Context().Value(requestInfoKey).(*requestInfo); ok {
+		info.UserID = user.ID.String()
+	}
+}
+
+// responseWriter records the status code and body size of a response
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Flush passes through to the wrapped writer so streamed responses still
+// reach the client as they're written
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns the response status, which is 200 if the handler never
+// set one explicitly
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// captureAccessLog redirects JSON access log output for the test
+func captureAccessLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	orig := accessLog
+	accessLog = log.New(&buf, "", 0)
+	t.Cleanup(func() { accessLog = orig })
+	return &buf
+}
+
+func TestJSONLogger(t *testing.T) {
+	buf := captureAccessLog(t)
+
+	user := &models.User{ID: uuid.New()}
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recordUser(r, user)
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/alerts?portfolio=x", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	JSONLogger(inner).ServeHTTP(rec, req)
+
+	var entry accessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v (%q)", err, buf.String())
+	}
+
+	if entry.Method != http.MethodGet || entry.Path != "/api/alerts" {
+		t.Errorf("request: got %s %s, want GET /api/alerts", entry.Method, entry.Path)
+	}
+	if entry.Status != http.StatusTeapot {
+		t.Errorf("status: got %d, want %d", entry.Status, http.StatusTeapot)
+	}
+	if entry.Bytes != len("short and stout") {
+		t.Errorf("bytes: got %d, want %d", entry.Bytes, len("short and stout"))
+	}
+	if entry.RequestID != "req-123" {
+		t.Errorf("request ID: got %q, want %q", entry.RequestID, "req-123")
+	}
+	if got := rec.Header().Get(requestIDHeader); got != "req-123" {
+		t.Errorf("response request ID: got %q, want %q", got, "req-123")
+	}
+	if entry.UserID != user.ID.String() {
+		t.Errorf("user ID: got %q, want %q", entry.UserID, user.ID)
+	}
+}
+
+func TestJSONLogger_DefaultsStatusAndGeneratesID(t *testing.T) {
+	buf := captureAccessLog(t)
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	JSONLogger(inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var entry accessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	if entry.Status != http.StatusOK {
+		t.Errorf("status: got %d, want %d", entry.Status, http.StatusOK)
+	}
+	if _, err := uuid.Parse(entry.RequestID); err != nil {
+		t.Errorf("request ID: got %q, want a generated UUID", entry.RequestID)
+	}
+	if entry.UserID != "" {
+		t.Errorf("user ID: got %q, want none for anonymous requests", entry.UserID)
+	}
+}
+
+func TestLogger_RecordsStatusAndBytes(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	Logger(inner).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/import", nil))
+
+	if line := buf.String(); !strings.Contains(line, "POST /import 500 5B ") {
+		t.Errorf("log line: got %q, want status 500 and 5 bytes", line)
+	}
+}
+
+func TestResponseWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = &responseWriter{ResponseWriter: rec}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		t.Fatal("responseWriter does not implement http.Flusher")
+	}
+	flusher.Flush()
+
+	if !rec.Flushed {
+		t.Error("Flush was not passed through to the underlying writer")
+	}
+}
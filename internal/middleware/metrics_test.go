@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetrics_CountsByRouteAndStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/alerts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("/api/holdings/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	handler := Metrics(mux)(mux)
+
+	ok := metrics.HTTPRequests.WithLabelValues("/api/alerts", "200")
+	holdings := metrics.HTTPRequests.WithLabelValues("/api/holdings/", "200")
+	unmatched := metrics.HTTPRequests.WithLabelValues("unmatched", "404")
+	okBefore, unmatchedBefore := testutil.ToFloat64(ok), testutil.ToFloat64(unmatched)
+	holdingsBefore := testutil.ToFloat64(holdings)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/alerts", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/holdings/0b7d4c1e-53a2-4f0e-9d55-2c6a1f0e7a10/performance", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/holdings/5f3c2a9b-8e41-4d7a-b6c2-91d0e4f8a3b7/performance", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-admin/1", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wp-admin/2", nil))
+
+	if got := testutil.ToFloat64(ok) - okBefore; got != 1 {
+		t.Errorf("/api/alerts 200: got %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(holdings) - holdingsBefore; got != 2 {
+		t.Errorf("/api/holdings/ 200: got %v, want 2 under the route pattern", got)
+	}
+	if got := testutil.ToFloat64(unmatched) - unmatchedBefore; got != 2 {
+		t.Errorf("unmatched 404: got %v, want 2", got)
+	}
+}
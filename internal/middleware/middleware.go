@@ -3,11 +3,15 @@ package middleware
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/findosh/truenorth/internal/metrics"
 	"github.com/findosh/truenorth/internal/models"
 	"github.com/findosh/truenorth/internal/services/auth"
 )
@@ -16,27 +20,98 @@ type contextKey string
 
 const (
 	UserContextKey contextKey = "user"
+
+	nonceContextKey contextKey = "csp-nonce"
 )
 
 // Logger logs all HTTP requests
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r)
+		log.Printf("%s %s %d %dB %s", r.Method, r.URL.Path, rw.Status(), rw.bytes, time.Since(start))
 	})
 }
 
-// SecurityHeaders adds security headers to all responses
-func SecurityHeaders(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.Header().Set("X-Frame-Options", "DENY")
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
-		w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
-		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'self' 'unsafe-inline'; script-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net; img-src 'self' data:;")
-		next.ServeHTTP(w, r)
-	})
+// Metrics records request counts and latencies for Prometheus, labelled
+// with the route pattern in routes that served the request rather than its
+// path, so IDs in paths don't each create a series. Requests that match no
+// route or 404 share one label so scanners can't create unbounded series.
+func Metrics(routes *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			_, path := routes.Handler(r)
+			if path == "" || rw.Status() == http.StatusNotFound {
+				path = "unmatched"
+			}
+			metrics.HTTPRequests.WithLabelValues(path, strconv.Itoa(rw.Status())).Inc()
+			metrics.HTTPDuration.WithLabelValues(path).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+// ContentSecurityPolicy lists the sources allowed beyond 'self'. Inline
+// scripts run only when they carry the request's nonce.
+type ContentSecurityPolicy struct {
+	ScriptSources []string
+	StyleSources  []string
+	ImageSources  []string
+}
+
+// header builds the Content-Security-Policy value for a request's nonce
+func (p ContentSecurityPolicy) header(nonce string) string {
+	directive := func(name string, sources ...string) string {
+		return name + " " + strings.Join(append([]string{"'self'"}, sources...), " ")
+	}
+	return strings.Join([]string{
+		"default-src 'self'",
+		directive("style-src", p.StyleSources...),
+		directive("script-src", append([]string{"'nonce-" + nonce + "'"}, p.ScriptSources...)...),
+		directive("img-src", p.ImageSources...),
+	}, "; ") + ";"
+}
+
+// SecurityHeaders adds security headers to all responses, with a fresh
+// CSP nonce for each request that templates read back with CSPNonce
+func SecurityHeaders(policy ContentSecurityPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			nonce, err := generateNonce()
+			if err != nil {
+				log.Printf("failed to generate CSP nonce: %v", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("X-Frame-Options", "DENY")
+			w.Header().Set("X-XSS-Protection", "1; mode=block")
+			w.Header().Set("Referrer-Policy", "strict-origin-when-cross-origin")
+			w.Header().Set("Content-Security-Policy", policy.header(nonce))
+
+			ctx := context.WithValue(r.Context(), nonceContextKey, nonce)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// CSPNonce returns the nonce inline scripts need to run on this request
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(nonceContextKey).(string)
+	return nonce
+}
+
+func generateNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
 }
 
 // Recover handles panics gracefully
@@ -52,20 +127,63 @@ func Recover(next http.Handler) http.Handler {
 	})
 }
 
+// Cookie names for the access and refresh tokens
+const (
+	SessionCookie = "session"
+	RefreshCookie = "refresh"
+)
+
+// SetSessionCookies stores the tokens from a login or refresh in cookies
+func SetSessionCookies(w http.ResponseWriter, result *auth.LoginResult, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    result.Token,
+		Path:     "/",
+		Expires:  result.Expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     RefreshCookie,
+		Value:    result.RefreshToken,
+		Path:     "/",
+		Expires:  result.RefreshExpires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearSessionCookies removes the access and refresh token cookies
+func ClearSessionCookies(w http.ResponseWriter) {
+	for _, name := range []string{SessionCookie, RefreshCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			Expires:  time.Unix(0, 0),
+			HttpOnly: true,
+		})
+	}
+}
+
 // Auth middleware for protected routes
 type Auth struct {
-	authService *auth.Service
+	authService   *auth.Service
+	secureCookies bool
 }
 
-// NewAuth creates a new auth middleware
-func NewAuth(authService *auth.Service) *Auth {
-	return &Auth{authService: authService}
+// NewAuth creates a new auth middleware. Cookies it refreshes are marked
+// Secure when secureCookies is set.
+func NewAuth(authService *auth.Service, secureCookies bool) *Auth {
+	return &Auth{authService: authService, secureCookies: secureCookies}
 }
 
 // RequireAuth ensures the user is authenticated
 func (m *Auth) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := m.getUserFromRequest(r)
+		user := m.getUserFromRequest(w, r)
 		if user == nil {
 			// Redirect to login for HTML requests
 			if strings.Contains(r.Header.Get("Accept"), "text/html") {
@@ -77,6 +195,8 @@ func (m *Auth) RequireAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		recordUser(r, user)
+
 		// Add user to context
 		ctx := context.WithValue(r.Context(), UserContextKey, user)
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -86,8 +206,9 @@ func (m *Auth) RequireAuth(next http.Handler) http.Handler {
 // OptionalAuth adds user to context if authenticated, but doesn't require it
 func (m *Auth) OptionalAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := m.getUserFromRequest(r)
+		user := m.getUserFromRequest(w, r)
 		if user != nil {
+			recordUser(r, user)
 			ctx := context.WithValue(r.Context(), UserContextKey, user)
 			r = r.WithContext(ctx)
 		}
@@ -95,9 +216,9 @@ func (m *Auth) OptionalAuth(next http.Handler) http.Handler {
 	})
 }
 
-func (m *Auth) getUserFromRequest(r *http.Request) *models.User {
+func (m *Auth) getUserFromRequest(w http.ResponseWriter, r *http.Request) *models.User {
 	// Try cookie first
-	cookie, err := r.Cookie("session")
+	cookie, err := r.Cookie(SessionCookie)
 	if err == nil && cookie.Value != "" {
 		user, err := m.authService.ValidateToken(cookie.Value)
 		if err == nil {
@@ -115,6 +236,15 @@ func (m *Auth) getUserFromRequest(r *http.Request) *models.User {
 		}
 	}
 
+	// An expired access token is renewed from the refresh cookie, so
+	// browser sessions last as long as the refresh token
+	if refresh, err := r.Cookie(RefreshCookie); err == nil && refresh.Value != "" {
+		if result, err := m.authService.Refresh(refresh.Value); err == nil {
+			SetSessionCookies(w, result, m.secureCookies)
+			return result.User
+		}
+	}
+
 	return nil
 }
 
@@ -134,3 +264,62 @@ func Chain(h http.Handler, middleware ...func(http.Handler) http.Handler) http.H
 	}
 	return h
 }
+
+// CORS adds cross-origin headers to JSON API responses for an explicit
+// list of allowed origins
+type CORS struct {
+	allowed map[string]bool
+}
+
+// corsAllowedMethods and corsAllowedHeaders are what the API accepts from
+// cross-origin clients
+const (
+	corsAllowedMethods = "GET, POST, PUT, DELETE, OPTIONS"
+	corsAllowedHeaders = "Authorization, Content-Type, X-Request-ID"
+)
+
+// NewCORS creates a CORS middleware for the given origins. The wildcard "*"
+// is ignored: the API is called with the session cookie, and browsers
+// refuse credentialed responses that don't name the origin.
+func NewCORS(origins []string) *CORS {
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		origin = strings.TrimRight(strings.TrimSpace(origin), "/")
+		if origin != "" && origin != "*" {
+			allowed[origin] = true
+		}
+	}
+	return &CORS{allowed: allowed}
+}
+
+// Handler applies CORS to /api/ routes and answers their preflight requests
+// before authentication runs. Other routes pass through untouched.
+func (c *CORS) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && c.allowed[origin]
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Preflight
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+				w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecurityHeaders_Nonce(t *testing.T) {
+	policy := ContentSecurityPolicy{
+		ScriptSources: []string{"https://cdn.jsdelivr.net"},
+		StyleSources:  []string{"'unsafe-inline'"},
+		ImageSources:  []string{"data:"},
+	}
+
+	var nonces []string
+	handler := SecurityHeaders(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r))
+	}))
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/dashboard", nil))
+
+		nonce := nonces[i]
+		if nonce == "" {
+			t.Fatal("Expected a nonce in the request context")
+		}
+		want := "default-src 'self'; style-src 'self' 'unsafe-inline'; script-src 'self' 'nonce-" + nonce +
+			"' https://cdn.jsdelivr.net; img-src 'self' data:;"
+		if got := rec.Header().Get("Content-Security-Policy"); got != want {
+			t.Errorf("CSP got %q, want %q", got, want)
+		}
+	}
+
+	if nonces[0] == nonces[1] {
+		t.Error("Expected a different nonce on each request")
+	}
+}
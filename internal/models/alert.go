@@ -2,6 +2,9 @@ package models
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
@@ -10,12 +13,17 @@ import (
 type AlertType string
 
 const (
-	AlertConcentration AlertType = "concentration" // >10% in single ticker
-	AlertOverlap       AlertType = "overlap"       // Same ticker in 3+ accounts
-	AlertHighExpense   AlertType = "high_expense"  // Expense ratio >1%
-	AlertUnclassified  AlertType = "unclassified"  // Holdings in "Other"
-	AlertCashDrag      AlertType = "cash_drag"     // >10% in cash
-	AlertSectorTilt    AlertType = "sector_tilt"   // >30% in single sector
+	AlertConcentration        AlertType = "concentration"         // >10% in single ticker
+	AlertIssuerConcentration  AlertType = "issuer_concentration"  // >10% across one company's tickers
+	AlertOverlap              AlertType = "overlap"               // Same ticker in 3+ accounts
+	AlertAccountConcentration AlertType = "account_concentration" // >75% in a single account
+	AlertHighExpense          AlertType = "high_expense"          // Expense ratio >1%
+	AlertUnclassified         AlertType = "unclassified"          // Holdings in "Other"
+	AlertCashDrag             AlertType = "cash_drag"             // >10% in cash
+	AlertSectorTilt           AlertType = "sector_tilt"           // >30% in single sector
+	AlertDrift                AlertType = "drift"                 // Asset class off target by >5 points
+	AlertOptionExpiry         AlertType = "option_expiry"         // Options expiring within 14 days
+	AlertStalePrices          AlertType = "stale_prices"          // Prices older than 4 days or missing
 )
 
 // Severity levels for alerts
@@ -29,12 +37,55 @@ const (
 
 // Alert represents a portfolio risk or issue notification
 type Alert struct {
-	Type       AlertType `json:"type"`
-	Severity   Severity  `json:"severity"`
-	Title      string    `json:"title"`
-	Message    string    `json:"message"`
-	Holdings   []string  `json:"holdings,omitempty"` // Affected tickers
-	Suggestion string    `json:"suggestion"`
+	Type       AlertType       `json:"type"`
+	Severity   Severity        `json:"severity"`
+	Title      string          `json:"title"`
+	Message    string          `json:"message"`
+	Holdings   []string        `json:"holdings,omitempty"` // Affected tickers
+	Suggestion string          `json:"suggestion"`
+	Value      decimal.Decimal `json:"value"`                  // Measure that tripped it; higher is worse
+	Drift      *Drift          `json:"drift,omitempty"`        // Set for drift alerts
+	Sector     string          `json:"sector,omitempty"`       // Set for sector tilt alerts
+	Account    string          `json:"account,omitempty"`      // Set for account concentration alerts
+	PricesAsOf *time.Time      `json:"prices_as_of,omitempty"` // Set for stale price alerts: the oldest price
+}
+
+// Key identifies an alert across detection runs, so the same condition
+// can be recognized even as the numbers in its message change
+func (a Alert) Key() string {
+	holdings := append([]string(nil), a.Holdings...)
+	sort.Strings(holdings)
+
+	key := string(a.Type) + ":" + strings.Join(holdings, ",")
+	if a.Sector != "" {
+		key += ":" + a.Sector
+	}
+	if a.Account != "" {
+		key += ":" + a.Account
+	}
+	if a.Drift != nil {
+		key += ":" + string(a.Drift.AssetClass)
+	}
+	return key
+}
+
+// materialWorsening is how much an alert's value must grow, relative to
+// when it was dismissed, before it's shown again
+var materialWorsening = decimal.NewFromFloat(1.2)
+
+// WorsenedSince reports whether the alert has become materially worse than
+// when its value was the given one: up by a fifth or more
+func (a Alert) WorsenedSince(value decimal.Decimal) bool {
+	return a.Value.GreaterThanOrEqual(value.Mul(materialWorsening)) && a.Value.GreaterThan(value)
+}
+
+// Drift describes how far an asset class has moved from its target
+type Drift struct {
+	AssetClass AssetClass      `json:"asset_class"`
+	Scenario   string          `json:"scenario"`  // Name of the target scenario
+	Current    decimal.Decimal `json:"current"`   // Current allocation %
+	Target     decimal.Decimal `json:"target"`    // Target allocation %
+	Rebalance  decimal.Decimal `json:"rebalance"` // Dollars to buy (+) or sell (-)
 }
 
 // AlertThresholds defines the thresholds for triggering alerts
@@ -43,6 +94,10 @@ type AlertThresholds struct {
 	SectorTiltPercent    decimal.Decimal // Single sector max %
 	CashDragPercent      decimal.Decimal // Cash max %
 	OverlapAccountCount  int             // Same ticker in N+ accounts
+	AccountPercent       decimal.Decimal // Single account max %
+	DriftBandPercent     decimal.Decimal // Allowed drift from target, in points
+	OptionExpiryDays     int             // Warn about options expiring within N days
+	StalePriceDays       int             // Warn about prices older than N days
 }
 
 // DefaultThresholds returns the default alert thresholds
@@ -52,6 +107,10 @@ func DefaultThresholds() *AlertThresholds {
 		SectorTiltPercent:    decimal.NewFromInt(30),
 		CashDragPercent:      decimal.NewFromInt(10),
 		OverlapAccountCount:  3,
+		AccountPercent:       decimal.NewFromInt(75),
+		DriftBandPercent:     decimal.NewFromInt(5),
+		OptionExpiryDays:     14,
+		StalePriceDays:       4, // Covers a long weekend
 	}
 }
 
@@ -72,11 +131,25 @@ func (d *AlertDetector) DetectAlerts(p *Portfolio, allocation *AllocationSummary
 	var alerts []Alert
 
 	alerts = append(alerts, d.detectConcentration(p, allocation)...)
+	alerts = append(alerts, d.detectIssuerConcentration(p, allocation)...)
 	alerts = append(alerts, d.detectOverlap(p)...)
+	alerts = append(alerts, d.detectAccountConcentration(allocation)...)
 	alerts = append(alerts, d.detectCashDrag(allocation)...)
 	alerts = append(alerts, d.detectSectorTilt(allocation)...)
 	alerts = append(alerts, d.detectUnclassified(p)...)
+	alerts = append(alerts, d.detectExpiringOptions(p, time.Now())...)
+	alerts = append(alerts, d.detectStalePrices(p, time.Now())...)
+
+	return alerts
+}
 
+// DetectAlertsWithTarget returns all applicable alerts plus drift alerts
+// against a target scenario. target may be nil when none has been saved.
+func (d *AlertDetector) DetectAlertsWithTarget(p *Portfolio, allocation *AllocationSummary, target *Scenario) []Alert {
+	alerts := d.DetectAlerts(p, allocation)
+	if target != nil {
+		alerts = append(alerts, d.detectDrift(p, allocation, target)...)
+	}
 	return alerts
 }
 
@@ -101,6 +174,7 @@ func (d *AlertDetector) detectConcentration(p *Portfolio, allocation *Allocation
 					ticker, pct.InexactFloat64(), d.Thresholds.ConcentrationPercent.String()),
 				Holdings:   []string{ticker},
 				Suggestion: "Consider reducing this position to lower single-stock risk",
+				Value:      pct,
 			})
 		}
 	}
@@ -108,6 +182,52 @@ func (d *AlertDetector) detectConcentration(p *Portfolio, allocation *Allocation
 	return alerts
 }
 
+// detectIssuerConcentration finds companies held through several tickers,
+// such as share classes or a stock and its single-stock ETF, whose combined
+// value is over the threshold. Issuers held through one ticker are left to
+// detectConcentration.
+func (d *AlertDetector) detectIssuerConcentration(p *Portfolio, allocation *AllocationSummary) []Alert {
+	var alerts []Alert
+
+	if p.TotalValue.IsZero() {
+		return alerts
+	}
+
+	values := make(map[string]decimal.Decimal)
+	tickers := make(map[string][]string)
+	for ticker, value := range allocation.TickerTotals {
+		issuer := Issuer(ticker)
+		values[issuer] = values[issuer].Add(value)
+		tickers[issuer] = append(tickers[issuer], ticker)
+	}
+
+	hundred := decimal.NewFromInt(100)
+	for issuer, value := range values {
+		if len(tickers[issuer]) < 2 {
+			continue
+		}
+		pct := value.Div(p.TotalValue).Mul(hundred)
+		if !pct.GreaterThan(d.Thresholds.ConcentrationPercent) {
+			continue
+		}
+
+		held := tickers[issuer]
+		sort.Strings(held)
+		alerts = append(alerts, Alert{
+			Type:     AlertIssuerConcentration,
+			Severity: SeverityWarning,
+			Title:    "Issuer Concentration",
+			Message: fmt.Sprintf("%s (%s) represents %.1f%% of your portfolio combined, exceeding the %s%% threshold",
+				issuer, strings.Join(held, ", "), pct.InexactFloat64(), d.Thresholds.ConcentrationPercent.String()),
+			Holdings:   held,
+			Suggestion: "These tickers carry the same company's risk; consider reducing your combined exposure",
+			Value:      pct,
+		})
+	}
+
+	return alerts
+}
+
 // detectOverlap finds tickers held in multiple accounts
 func (d *AlertDetector) detectOverlap(p *Portfolio) []Alert {
 	var alerts []Alert
@@ -135,6 +255,36 @@ func (d *AlertDetector) detectOverlap(p *Portfolio) []Alert {
 					ticker, len(accounts)),
 				Holdings:   []string{ticker},
 				Suggestion: "Consider consolidating for easier management and potential tax efficiency",
+				Value:      decimal.NewFromInt(int64(len(accounts))),
+			})
+		}
+	}
+
+	return alerts
+}
+
+// detectAccountConcentration finds single accounts holding too much of the
+// portfolio, where one custodian or plan failing would hit most of it.
+// Portfolios with one account are skipped: that's usually just the only
+// account imported so far.
+func (d *AlertDetector) detectAccountConcentration(allocation *AllocationSummary) []Alert {
+	var alerts []Alert
+
+	if len(allocation.ByAccount) < 2 {
+		return alerts
+	}
+
+	for account, slice := range allocation.ByAccount {
+		if slice.Percentage.GreaterThan(d.Thresholds.AccountPercent) {
+			alerts = append(alerts, Alert{
+				Type:     AlertAccountConcentration,
+				Severity: SeverityInfo,
+				Title:    "Account Concentration",
+				Message: fmt.Sprintf("%s holds %.1f%% of the portfolio, above the %s%% threshold",
+					account, slice.Percentage.InexactFloat64(), d.Thresholds.AccountPercent.String()),
+				Suggestion: "Consider spreading assets across custodians to limit custodial and plan-specific risk",
+				Account:    account,
+				Value:      slice.Percentage,
 			})
 		}
 	}
@@ -159,6 +309,7 @@ func (d *AlertDetector) detectCashDrag(allocation *AllocationSummary) []Alert {
 			Message: fmt.Sprintf("Cash holdings at %.1f%% may be reducing long-term returns",
 				cashSlice.Percentage.InexactFloat64()),
 			Suggestion: "Consider deploying excess cash into investments aligned with your goals",
+			Value:      cashSlice.Percentage,
 		})
 	}
 
@@ -178,6 +329,8 @@ func (d *AlertDetector) detectSectorTilt(allocation *AllocationSummary) []Alert
 				Message: fmt.Sprintf("%s sector at %.1f%% exceeds %s%% threshold",
 					sector, slice.Percentage.InexactFloat64(), d.Thresholds.SectorTiltPercent.String()),
 				Suggestion: "Consider diversifying across sectors to reduce concentration risk",
+				Sector:     sector,
+				Value:      slice.Percentage,
 			})
 		}
 	}
@@ -185,6 +338,127 @@ func (d *AlertDetector) detectSectorTilt(allocation *AllocationSummary) []Alert
 	return alerts
 }
 
+// detectDrift finds asset classes that have moved more than the drift band
+// away from the target scenario's allocation
+func (d *AlertDetector) detectDrift(p *Portfolio, allocation *AllocationSummary, target *Scenario) []Alert {
+	var alerts []Alert
+
+	if p.TotalValue.IsZero() || len(target.Allocations) == 0 {
+		return alerts
+	}
+
+	comparison := target.Compare(allocation, p.TotalValue)
+	current := comparison.Current
+
+	for _, class := range AllAssetClasses() {
+		change := comparison.Changes[class]
+		if !change.Abs().GreaterThan(d.Thresholds.DriftBandPercent) {
+			continue
+		}
+
+		rebalance := comparison.Rebalance[class]
+		action := "Buy"
+		if rebalance.IsNegative() {
+			action = "Sell"
+		}
+
+		alerts = append(alerts, Alert{
+			Type:     AlertDrift,
+			Severity: SeverityWarning,
+			Title:    "Allocation Drift",
+			Message: fmt.Sprintf("%s at %.1f%% has drifted from its %.1f%% target in %q",
+				class.DisplayName(), current[class].InexactFloat64(), target.Allocations[class].InexactFloat64(), target.Name),
+			Suggestion: fmt.Sprintf("%s about $%s of %s to rebalance",
+				action, rebalance.Abs().StringFixed(0), class.DisplayName()),
+			Value: change.Abs(),
+			Drift: &Drift{
+				AssetClass: class,
+				Scenario:   target.Name,
+				Current:    current[class],
+				Target:     target.Allocations[class],
+				Rebalance:  rebalance,
+			},
+		})
+	}
+
+	return alerts
+}
+
+// detectExpiringOptions finds option positions that expire within the
+// threshold, including any already past expiry that are still held
+func (d *AlertDetector) detectExpiringOptions(p *Portfolio, now time.Time) []Alert {
+	var alerts []Alert
+	var expiring []string
+
+	cutoff := now.AddDate(0, 0, d.Thresholds.OptionExpiryDays)
+	for _, h := range p.Holdings {
+		opt, ok := h.Option()
+		if !ok || h.Quantity.IsZero() {
+			continue
+		}
+		if opt.Expiry.Before(cutoff) {
+			expiring = append(expiring, h.Ticker)
+		}
+	}
+
+	if len(expiring) > 0 {
+		sort.Strings(expiring)
+		alerts = append(alerts, Alert{
+			Type:     AlertOptionExpiry,
+			Severity: SeverityWarning,
+			Title:    "Options Expiring Soon",
+			Message: fmt.Sprintf("%d option positions expire within %d days",
+				len(expiring), d.Thresholds.OptionExpiryDays),
+			Holdings:   expiring,
+			Suggestion: "Decide whether to close, roll, or let these contracts expire",
+		})
+	}
+
+	return alerts
+}
+
+// detectStalePrices finds holdings whose price is missing or hasn't been
+// refreshed within the threshold, so a stale total isn't trusted. Holdings
+// without a ticker and cash aren't quoted, and holdings with no record of
+// when they were priced are left alone.
+func (d *AlertDetector) detectStalePrices(p *Portfolio, now time.Time) []Alert {
+	var alerts []Alert
+	var stale []string
+	var oldest time.Time
+
+	cutoff := now.AddDate(0, 0, -d.Thresholds.StalePriceDays)
+	for _, h := range p.Holdings {
+		if h.IsCash() || h.Ticker == "" || h.Quantity.IsZero() {
+			continue
+		}
+		asOf := h.PriceAsOf()
+		if asOf.IsZero() || (asOf.After(cutoff) && !h.CurrentPrice.IsZero()) {
+			continue
+		}
+		stale = append(stale, h.Ticker)
+		if oldest.IsZero() || asOf.Before(oldest) {
+			oldest = asOf
+		}
+	}
+
+	if len(stale) > 0 {
+		sort.Strings(stale)
+		alerts = append(alerts, Alert{
+			Type:     AlertStalePrices,
+			Severity: SeverityWarning,
+			Title:    "Stale Prices",
+			Message: fmt.Sprintf("%d holdings have stale or missing prices, the oldest from %s",
+				len(stale), oldest.Format("Jan 2, 2006")),
+			Holdings:   stale,
+			Value:      decimal.NewFromInt(int64(len(stale))),
+			PricesAsOf: &oldest,
+			Suggestion: "Refresh prices, and check these tickers with your broker if they still can't be priced",
+		})
+	}
+
+	return alerts
+}
+
 // detectUnclassified finds holdings that still need classification
 func (d *AlertDetector) detectUnclassified(p *Portfolio) []Alert {
 	var alerts []Alert
@@ -203,6 +477,7 @@ func (d *AlertDetector) detectUnclassified(p *Portfolio) []Alert {
 			Title:    "Unclassified Holdings",
 			Message:  fmt.Sprintf("%d holdings need classification for accurate analysis", len(unclassified)),
 			Holdings: unclassified,
+			Value:    decimal.NewFromInt(int64(len(unclassified))),
 			Suggestion: "Review and classify these holdings to get accurate allocation insights",
 		})
 	}
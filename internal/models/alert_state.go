@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AlertStatus is what a user has decided about an alert
+type AlertStatus string
+
+const (
+	// AlertStatusAcknowledged alerts are still shown, marked as seen
+	AlertStatusAcknowledged AlertStatus = "acknowledged"
+	// AlertStatusDismissed alerts are hidden until they materially worsen
+	AlertStatusDismissed AlertStatus = "dismissed"
+)
+
+// ParseAlertStatus validates a status string
+func ParseAlertStatus(s string) (AlertStatus, error) {
+	switch status := AlertStatus(strings.ToLower(strings.TrimSpace(s))); status {
+	case AlertStatusAcknowledged, AlertStatusDismissed:
+		return status, nil
+	default:
+		return "", fmt.Errorf("unknown alert status %q", s)
+	}
+}
+
+// AlertState records a user's decision about one of a portfolio's alerts,
+// identified by its Key
+type AlertState struct {
+	PortfolioID uuid.UUID       `json:"portfolio_id"`
+	AlertKey    string          `json:"key"`
+	Status      AlertStatus     `json:"status"`
+	Value       decimal.Decimal `json:"value"` // Alert's value when the decision was made
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// Hides reports whether the state keeps the alert out of view. Dismissed
+// alerts come back once they're materially worse than when dismissed.
+func (s *AlertState) Hides(alert Alert) bool {
+	return s.Status == AlertStatusDismissed && !alert.WorsenedSince(s.Value)
+}
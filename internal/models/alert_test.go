@@ -1,7 +1,9 @@
 package models
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -22,6 +24,12 @@ func TestDefaultThresholds(t *testing.T) {
 	if thresholds.OverlapAccountCount != 3 {
 		t.Errorf("Expected overlap count 3, got %d", thresholds.OverlapAccountCount)
 	}
+	if !thresholds.AccountPercent.Equal(decimal.NewFromInt(75)) {
+		t.Errorf("Expected account threshold 75, got %s", thresholds.AccountPercent)
+	}
+	if !thresholds.DriftBandPercent.Equal(decimal.NewFromInt(5)) {
+		t.Errorf("Expected drift band 5, got %s", thresholds.DriftBandPercent)
+	}
 }
 
 func TestAlertDetector_DetectConcentration(t *testing.T) {
@@ -176,6 +184,47 @@ func TestAlertDetector_DetectSectorTilt(t *testing.T) {
 	}
 }
 
+func TestAlertDetector_DetectSectorTilt_BondSectors(t *testing.T) {
+	detector := NewAlertDetector()
+
+	holding := func(ticker, sector string, value int64) Holding {
+		return Holding{Ticker: ticker, AssetClass: AssetClassFixedIncome, Sector: sector, MarketValue: decimal.NewFromInt(value)}
+	}
+	tiltedSectors := func(p *Portfolio) []string {
+		p.CalculateTotals()
+		var sectors []string
+		for _, a := range detector.DetectAlerts(p, p.CalculateAllocation()) {
+			if a.Type == AlertSectorTilt {
+				sectors = append(sectors, a.Sector)
+			}
+		}
+		return sectors
+	}
+
+	// Half the portfolio in bonds, spread across categories, is no tilt
+	spread := &Portfolio{ID: uuid.New(), Holdings: []Holding{
+		holding("IEF", "Treasury", 20000),
+		holding("LQD", "Corporate", 15000),
+		holding("MUB", "Municipal", 15000),
+		{Ticker: "VTI", AssetClass: AssetClassEquity, Sector: "Diversified", MarketValue: decimal.NewFromInt(25000)},
+		{Ticker: "QQQ", AssetClass: AssetClassEquity, Sector: "Technology", MarketValue: decimal.NewFromInt(25000)},
+	}}
+	if got := tiltedSectors(spread); len(got) != 0 {
+		t.Errorf("Spread bonds: got tilts %v, want none", got)
+	}
+
+	// Concentrated in one category, the tilt names it
+	concentrated := &Portfolio{ID: uuid.New(), Holdings: []Holding{
+		holding("TLT", "Treasury", 40000),
+		holding("HYG", "High-Yield", 10000),
+		{Ticker: "VTI", AssetClass: AssetClassEquity, Sector: "Diversified", MarketValue: decimal.NewFromInt(25000)},
+		{Ticker: "QQQ", AssetClass: AssetClassEquity, Sector: "Technology", MarketValue: decimal.NewFromInt(25000)},
+	}}
+	if got := tiltedSectors(concentrated); len(got) != 1 || got[0] != "Treasury" {
+		t.Errorf("Concentrated bonds: got tilts %v, want [Treasury]", got)
+	}
+}
+
 func TestAlertDetector_DetectUnclassified(t *testing.T) {
 	detector := NewAlertDetector()
 
@@ -219,6 +268,77 @@ func TestAlertDetector_DetectUnclassified(t *testing.T) {
 	}
 }
 
+func TestAlertDetector_DetectExpiringOptions(t *testing.T) {
+	detector := NewAlertDetector()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	p := &Portfolio{
+		ID: uuid.New(),
+		Holdings: []Holding{
+			{Ticker: "AAPL240119C00150000", Quantity: decimal.NewFromInt(2)}, // 9 days out
+			{Ticker: "SPY240105P00450000", Quantity: decimal.NewFromInt(1)},  // Already expired
+			{Ticker: "MSFT240621C00400000", Quantity: decimal.NewFromInt(1)}, // Months away
+			{Ticker: "TSLA240112C00250000", Quantity: decimal.Zero},          // Closed out
+			{Ticker: "AAPL", Quantity: decimal.NewFromInt(100)},
+		},
+	}
+
+	alerts := detector.detectExpiringOptions(p, now)
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 expiry alert, got %d", len(alerts))
+	}
+	want := []string{"AAPL240119C00150000", "SPY240105P00450000"}
+	if got := alerts[0].Holdings; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Holdings: got %v, want %v", got, want)
+	}
+
+	// Nothing close to expiry, no alert
+	if alerts := detector.detectExpiringOptions(p, now.AddDate(0, 0, -30)); len(alerts) != 0 {
+		t.Errorf("Expected no alerts a month earlier, got %d", len(alerts))
+	}
+}
+
+func TestAlertDetector_DetectStalePrices(t *testing.T) {
+	detector := NewAlertDetector()
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	price := decimal.NewFromInt(100)
+
+	p := &Portfolio{
+		ID: uuid.New(),
+		Holdings: []Holding{
+			{Ticker: "AAPL", Quantity: decimal.NewFromInt(10), CurrentPrice: price, PricedAt: now.Add(-time.Hour)},
+			{Ticker: "VOO", Quantity: decimal.NewFromInt(5), CurrentPrice: price, PricedAt: now.AddDate(0, 0, -6)},
+			{Ticker: "XYZ", Quantity: decimal.NewFromInt(5), ImportedAt: now.AddDate(0, 0, -1)}, // No price
+			{Ticker: "MSFT", Quantity: decimal.NewFromInt(5), CurrentPrice: price, ImportedAt: now.AddDate(0, 0, -1)},
+			{Name: "House", Quantity: decimal.NewFromInt(1), IsManualEntry: true, ImportedAt: now.AddDate(-1, 0, 0)},
+			// Reclassified by hand, but still quoted
+			{Ticker: "GLD", Quantity: decimal.NewFromInt(2), IsManualEntry: true, AssetClass: AssetClassAlternative, CurrentPrice: price, PricedAt: now.AddDate(0, 0, -8)},
+			{Ticker: "SWVXX", Quantity: decimal.NewFromInt(100), AssetClass: AssetClassCash, ImportedAt: now.AddDate(-1, 0, 0)},
+		},
+	}
+
+	alerts := detector.detectStalePrices(p, now)
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 stale price alert, got %d", len(alerts))
+	}
+	want := []string{"GLD", "VOO", "XYZ"}
+	if got := alerts[0].Holdings; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Holdings: got %v, want %v", got, want)
+	}
+	if asOf := alerts[0].PricesAsOf; asOf == nil || !asOf.Equal(now.AddDate(0, 0, -8)) {
+		t.Errorf("Prices as of: got %v, want the GLD price's time", asOf)
+	}
+	if !strings.Contains(alerts[0].Message, "Jan 2, 2024") {
+		t.Errorf("Message should give the oldest price's date, got %q", alerts[0].Message)
+	}
+
+	// Holdings with no record of when they were priced are left alone
+	p = &Portfolio{ID: uuid.New(), Holdings: []Holding{{Ticker: "AAPL", Quantity: decimal.NewFromInt(10)}}}
+	if alerts := detector.detectStalePrices(p, now); len(alerts) != 0 {
+		t.Errorf("Expected no alerts without price times, got %d", len(alerts))
+	}
+}
+
 func TestAlertDetector_NoAlerts(t *testing.T) {
 	detector := NewAlertDetector()
 
@@ -249,3 +369,202 @@ func TestAlertDetector_NoAlerts(t *testing.T) {
 		t.Errorf("Expected no alerts, got %d: %+v", len(alerts), alerts)
 	}
 }
+
+func TestAlertDetector_DetectDrift(t *testing.T) {
+	detector := NewAlertDetector()
+
+	p := &Portfolio{
+		ID:         uuid.New(),
+		TotalValue: decimal.NewFromFloat(100000.00),
+	}
+
+	alloc := &AllocationSummary{
+		ByAssetClass: map[AssetClass]AllocationSlice{
+			AssetClassEquity:      {Percentage: decimal.NewFromFloat(72.0)}, // 12 over target - should alert
+			AssetClassFixedIncome: {Percentage: decimal.NewFromFloat(26.0)}, // 4 under target - within band
+			AssetClassCash:        {Percentage: decimal.NewFromFloat(2.0)},  // 8 under target - should alert
+		},
+	}
+
+	target := NewScenario(p.ID, "60/30/10")
+	target.SetAllocation(AssetClassEquity, decimal.NewFromInt(60))
+	target.SetAllocation(AssetClassFixedIncome, decimal.NewFromInt(30))
+	target.SetAllocation(AssetClassCash, decimal.NewFromInt(10))
+
+	alerts := detector.DetectAlertsWithTarget(p, alloc, target)
+
+	drift := make(map[AssetClass]*Drift)
+	for _, a := range alerts {
+		if a.Type == AlertDrift {
+			drift[a.Drift.AssetClass] = a.Drift
+		}
+	}
+
+	if len(drift) != 2 {
+		t.Fatalf("Expected 2 drift alerts, got %d", len(drift))
+	}
+	if d := drift[AssetClassEquity]; d == nil || !d.Rebalance.Equal(decimal.NewFromInt(-12000)) {
+		t.Errorf("Equity: expected rebalance -12000, got %v", d)
+	}
+	if d := drift[AssetClassCash]; d == nil || !d.Rebalance.Equal(decimal.NewFromInt(8000)) {
+		t.Errorf("Cash: expected rebalance 8000, got %v", d)
+	}
+	if _, ok := drift[AssetClassFixedIncome]; ok {
+		t.Error("Fixed income is within the drift band and should not alert")
+	}
+
+	// Without a target there is nothing to drift from
+	for _, a := range detector.DetectAlertsWithTarget(p, alloc, nil) {
+		if a.Type == AlertDrift {
+			t.Error("Expected no drift alerts without a target scenario")
+		}
+	}
+}
+
+func TestAlert_Key(t *testing.T) {
+	a := Alert{Type: AlertUnclassified, Message: "2 holdings", Holdings: []string{"XYZ", "ABC"}}
+	b := Alert{Type: AlertUnclassified, Message: "2 holdings need it", Holdings: []string{"ABC", "XYZ"}}
+	if a.Key() != b.Key() {
+		t.Errorf("same holdings in different order: got %q and %q, want equal", a.Key(), b.Key())
+	}
+
+	tech := Alert{Type: AlertSectorTilt, Sector: "Technology"}
+	health := Alert{Type: AlertSectorTilt, Sector: "Healthcare"}
+	if tech.Key() == health.Key() {
+		t.Errorf("different sectors: both got %q", tech.Key())
+	}
+
+	equity := Alert{Type: AlertDrift, Drift: &Drift{AssetClass: AssetClassEquity}}
+	bonds := Alert{Type: AlertDrift, Drift: &Drift{AssetClass: AssetClassFixedIncome}}
+	if equity.Key() == bonds.Key() {
+		t.Errorf("different drift classes: both got %q", equity.Key())
+	}
+}
+
+func TestAlertDetector_DetectSectorTilt_LookThrough(t *testing.T) {
+	detector := NewAlertDetector()
+
+	p := &Portfolio{ID: uuid.New(), Holdings: []Holding{
+		{Ticker: "VTI", AssetClass: AssetClassEquity, Sector: "Diversified", MarketValue: decimal.NewFromInt(50000)},
+		{Ticker: "VOO", AssetClass: AssetClassEquity, Sector: "Diversified", MarketValue: decimal.NewFromInt(50000)},
+	}}
+	p.CalculateTotals()
+
+	hasTilt := func(allocation *AllocationSummary, sector string) bool {
+		for _, a := range detector.DetectAlerts(p, allocation) {
+			if a.Type == AlertSectorTilt && a.Sector == sector {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Tech hides inside the funds until they're looked through
+	if hasTilt(p.CalculateAllocation(), "Technology") {
+		t.Error("Plain view should not see a Technology tilt")
+	}
+	if !hasTilt(p.CalculateLookThroughAllocation(), "Technology") {
+		t.Error("Look-through view should flag the Technology tilt")
+	}
+}
+
+func TestAlertDetector_DetectIssuerConcentration(t *testing.T) {
+	detector := NewAlertDetector()
+
+	p := &Portfolio{
+		ID:         uuid.New(),
+		TotalValue: decimal.NewFromInt(100000),
+		Holdings: []Holding{
+			{Ticker: "BRK.A", MarketValue: decimal.NewFromInt(6000)},
+			{Ticker: "BRK.B", MarketValue: decimal.NewFromInt(6000)},
+			{Ticker: "NVDA", MarketValue: decimal.NewFromInt(5000)},
+			{Ticker: "NVDL", MarketValue: decimal.NewFromInt(4000)},
+			{Ticker: "SHOP.TO", MarketValue: decimal.NewFromInt(8000)},
+			{Ticker: "VTI", MarketValue: decimal.NewFromInt(71000)},
+		},
+	}
+	alloc := p.CalculateAllocation()
+
+	var issuers [][]string
+	for _, a := range detector.DetectAlerts(p, alloc) {
+		if a.Type == AlertIssuerConcentration {
+			issuers = append(issuers, a.Holdings)
+		}
+	}
+
+	// BRK.A and BRK.B are 12% together; NVIDIA is 9%, under the threshold
+	if len(issuers) != 1 || strings.Join(issuers[0], ",") != "BRK.A,BRK.B" {
+		t.Errorf("Issuer alerts: got %v, want [[BRK.A BRK.B]]", issuers)
+	}
+}
+
+func TestIssuer(t *testing.T) {
+	tests := []struct {
+		ticker string
+		want   string
+	}{
+		{"BRK.B", "BRK"},
+		{"brk-a", "BRK"},
+		{"BF/B", "BF"},
+		{"GOOG", "Alphabet"},
+		{"GGLL", "Alphabet"},
+		{"SHOP.TO", "SHOP.TO"}, // Exchange suffix, not a share class
+		{"BP.L", "BP.L"},
+		{"V", "V"},
+		{"AAPL", "Apple"},
+	}
+
+	for _, tt := range tests {
+		if got := Issuer(tt.ticker); got != tt.want {
+			t.Errorf("Issuer(%q) = %q, want %q", tt.ticker, got, tt.want)
+		}
+	}
+}
+
+func TestAlertDetector_DetectAccountConcentration(t *testing.T) {
+	detector := NewAlertDetector()
+
+	tests := []struct {
+		name     string
+		holdings []Holding
+		want     []string
+	}{
+		{
+			name: "one account dominates",
+			holdings: []Holding{
+				{Ticker: "VTI", AccountName: "401k", MarketValue: decimal.NewFromInt(90000)},
+				{Ticker: "VXUS", AccountName: "IRA", MarketValue: decimal.NewFromInt(10000)},
+			},
+			want: []string{"401k"},
+		},
+		{
+			name: "balanced accounts",
+			holdings: []Holding{
+				{Ticker: "VTI", AccountName: "401k", MarketValue: decimal.NewFromInt(60000)},
+				{Ticker: "VXUS", AccountName: "IRA", MarketValue: decimal.NewFromInt(40000)},
+			},
+		},
+		{
+			name: "single account is skipped",
+			holdings: []Holding{
+				{Ticker: "VTI", AccountName: "401k", MarketValue: decimal.NewFromInt(100000)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Portfolio{ID: uuid.New(), TotalValue: decimal.NewFromInt(100000), Holdings: tt.holdings}
+
+			var got []string
+			for _, a := range detector.DetectAlerts(p, p.CalculateAllocation()) {
+				if a.Type == AlertAccountConcentration {
+					got = append(got, a.Account)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Account alerts: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
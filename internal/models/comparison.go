@@ -0,0 +1,51 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Comparison winners
+const (
+	ComparisonA   = "a"
+	ComparisonB   = "b"
+	ComparisonTie = "tie"
+)
+
+// PortfolioComparison puts two portfolios' analytics side by side
+type PortfolioComparison struct {
+	A       ComparisonSide    `json:"a"`
+	B       ComparisonSide    `json:"b"`
+	Deltas  ComparisonDeltas  `json:"deltas"` // B minus A
+	Summary ComparisonSummary `json:"summary"`
+}
+
+// ComparisonSide is one portfolio's analytics within a comparison
+type ComparisonSide struct {
+	PortfolioID string                `json:"portfolio_id"`
+	Name        string                `json:"name"`
+	TotalValue  decimal.Decimal       `json:"total_value"`
+	Allocation  *AllocationSummary    `json:"allocation"`
+	Expenses    *PortfolioExpenses    `json:"expenses"`
+	RiskReward  RiskRewardMetrics     `json:"risk_reward"`
+	Performance *PortfolioPerformance `json:"performance,omitempty"`
+}
+
+// ComparisonDeltas are the differences between two portfolios, as B minus A
+type ComparisonDeltas struct {
+	TotalValue       decimal.Decimal                `json:"total_value"`
+	Allocation       map[AssetClass]decimal.Decimal `json:"allocation"` // Percentage points
+	ExpenseRatio     decimal.Decimal                `json:"expense_ratio"`
+	AnnualExpenses   decimal.Decimal                `json:"annual_expenses"`
+	ExpectedReturn   decimal.Decimal                `json:"expected_return"`
+	Volatility       decimal.Decimal                `json:"volatility"`
+	SharpeRatio      decimal.Decimal                `json:"sharpe_ratio"`
+	MaxDrawdown      decimal.Decimal                `json:"max_drawdown"`
+	AnnualizedReturn decimal.Decimal                `json:"annualized_return"`
+}
+
+// ComparisonSummary says which portfolio comes out ahead, as "a", "b", or
+// "tie"
+type ComparisonSummary struct {
+	LowerFees                string `json:"lower_fees"`
+	HigherRiskAdjustedReturn string `json:"higher_risk_adjusted_return"`
+}
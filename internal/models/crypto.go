@@ -0,0 +1,58 @@
+package models
+
+// CryptoCoin is a coin that's held directly, as on an exchange, rather than
+// through a fund
+type CryptoCoin struct {
+	Symbol      string
+	Name        string
+	CoinGeckoID string // Used to look up its spot price
+}
+
+// CryptoCoins maps the symbols of widely held coins to their details.
+// Coins aren't listed on stock exchanges, so they're priced separately.
+var CryptoCoins = map[string]CryptoCoin{
+	"BTC":   {"BTC", "Bitcoin", "bitcoin"},
+	"ETH":   {"ETH", "Ethereum", "ethereum"},
+	"SOL":   {"SOL", "Solana", "solana"},
+	"XRP":   {"XRP", "XRP", "ripple"},
+	"ADA":   {"ADA", "Cardano", "cardano"},
+	"DOGE":  {"DOGE", "Dogecoin", "dogecoin"},
+	"LTC":   {"LTC", "Litecoin", "litecoin"},
+	"BCH":   {"BCH", "Bitcoin Cash", "bitcoin-cash"},
+	"DOT":   {"DOT", "Polkadot", "polkadot"},
+	"AVAX":  {"AVAX", "Avalanche", "avalanche-2"},
+	"LINK":  {"LINK", "Chainlink", "chainlink"},
+	"MATIC": {"MATIC", "Polygon", "matic-network"},
+	"XLM":   {"XLM", "Stellar", "stellar"},
+	"ATOM":  {"ATOM", "Cosmos", "cosmos"},
+	"UNI":   {"UNI", "Uniswap", "uniswap"},
+	"SHIB":  {"SHIB", "Shiba Inu", "shiba-inu"},
+	"USDC":  {"USDC", "USD Coin", "usd-coin"},
+	"USDT":  {"USDT", "Tether", "tether"},
+}
+
+// cryptoFunds are listed funds that hold crypto. They're classified as
+// crypto but trade, and are priced, as stocks.
+var cryptoFunds = map[string]bool{
+	"GBTC": true, "IBIT": true, "FBTC": true, "ARKB": true,
+	"BITB": true, "HODL": true, "BTCO": true, "BRRR": true, "EZBC": true,
+	"BTCW": true, "BITO": true, "ETHE": true, "ETHA": true, "FETH": true,
+	"ETHW": true, "ETHV": true, "CETH": true,
+}
+
+// brokerageSources are imports from brokerage accounts, which hold listed
+// securities rather than coins
+var brokerageSources = map[string]bool{
+	"schwab_csv":   true,
+	"fidelity_csv": true,
+	"vanguard_csv": true,
+}
+
+// IsCryptoCoin reports whether the holding is a coin held directly, priced
+// at its spot price instead of a stock quote. Anything classified as crypto
+// is taken to be a coin, even when its symbol is also a stock ticker, unless
+// it's a listed crypto fund like GBTC or came from a brokerage export.
+// Coins without a spot price go unpriced rather than take a stock's price.
+func (h *Holding) IsCryptoCoin() bool {
+	return h.AssetClass == AssetClassCrypto && !cryptoFunds[h.Ticker] && !brokerageSources[h.Source]
+}
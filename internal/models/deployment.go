@@ -0,0 +1,52 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// CashDeployment suggests how to put idle cash to work, moving the portfolio
+// toward its target allocation
+type CashDeployment struct {
+	PortfolioID  string            `json:"portfolio_id"`
+	FreeCash     decimal.Decimal   `json:"free_cash"`
+	CashPercent  decimal.Decimal   `json:"cash_percent"`
+	Threshold    decimal.Decimal   `json:"threshold"` // Cash-drag alert threshold %
+	Triggered    bool              `json:"triggered"` // Cash is above the threshold
+	Target       string            `json:"target"`    // Scenario name, or "Current allocation"
+	Deployable   decimal.Decimal   `json:"deployable"`
+	ByAssetClass []ClassDeployment `json:"by_asset_class"`
+}
+
+// ClassDeployment is the cash to put into one asset class
+type ClassDeployment struct {
+	AssetClass AssetClass      `json:"asset_class"`
+	Current    decimal.Decimal `json:"current"` // Current allocation %
+	Target     decimal.Decimal `json:"target"`  // Target allocation %
+	Amount     decimal.Decimal `json:"amount"`
+	Buys       []DeploymentBuy `json:"buys"`
+}
+
+// DeploymentBuy is a suggested purchase of a single ticker
+type DeploymentBuy struct {
+	Ticker      string          `json:"ticker"`
+	Name        string          `json:"name"`
+	Amount      decimal.Decimal `json:"amount"`
+	NewPosition bool            `json:"new_position"` // Not currently held
+}
+
+// DeploymentFund is a broad, low-cost fund suggested for an asset class the
+// portfolio doesn't hold yet
+type DeploymentFund struct {
+	Ticker string
+	Name   string
+}
+
+// DeploymentFunds maps asset classes to the fund suggested when the
+// portfolio has no existing holding to add to
+var DeploymentFunds = map[AssetClass]DeploymentFund{
+	AssetClassEquity:      {"VTI", "Vanguard Total Stock Market ETF"},
+	AssetClassFixedIncome: {"BND", "Vanguard Total Bond Market ETF"},
+	AssetClassAlternative: {"VNQ", "Vanguard Real Estate ETF"},
+	AssetClassCommodity:   {"GLD", "SPDR Gold Trust"},
+	AssetClassCrypto:      {"GBTC", "Grayscale Bitcoin Trust"},
+}
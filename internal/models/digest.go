@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DigestInterval is how often a subscribed user is sent a digest
+const DigestInterval = 7 * 24 * time.Hour
+
+// DigestTopMovers is how many of a portfolio's biggest movers a digest lists
+const DigestTopMovers = 3
+
+// DigestSubscription records whether a user has opted in to the weekly
+// digest and when one was last sent
+type DigestSubscription struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Enabled    bool       `json:"enabled"`
+	LastSentAt *time.Time `json:"last_sent_at,omitempty"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// Digest is one user's weekly summary across their portfolios
+type Digest struct {
+	Name           string            `json:"name"`
+	Email          string            `json:"email"`
+	WeekEnding     time.Time         `json:"week_ending"`
+	Portfolios     []PortfolioDigest `json:"portfolios"`
+	UnsubscribeURL string            `json:"unsubscribe_url"`
+}
+
+// PortfolioDigest summarizes a portfolio's week
+type PortfolioDigest struct {
+	PortfolioID  uuid.UUID       `json:"portfolio_id"`
+	Name         string          `json:"name"`
+	Value        decimal.Decimal `json:"value"`
+	Change       decimal.Decimal `json:"change"`     // Value change over the week
+	ChangePct    decimal.Decimal `json:"change_pct"` // Percentage of the starting value
+	TopMovers    []DigestMover   `json:"top_movers"`
+	NewAlerts    []Alert         `json:"new_alerts"`         // Raised since the last digest
+	ExpenseRatio decimal.Decimal `json:"expense_ratio"`      // Weighted average, as a percentage
+	AnnualFees   decimal.Decimal `json:"annual_fees"`        // Dollars a year at today's value
+	Unpriced     []string        `json:"unpriced,omitempty"` // Tickers without a current price
+}
+
+// DigestMover is a holding whose price moved the most over the week
+type DigestMover struct {
+	Ticker    string          `json:"ticker"`
+	Name      string          `json:"name"`
+	ChangePct decimal.Decimal `json:"change_pct"`
+	Change    decimal.Decimal `json:"change"` // Effect on the holding's value
+}
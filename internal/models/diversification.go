@@ -0,0 +1,80 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// DiversificationScore summarizes how concentrated a portfolio is, both
+// across individual tickers and across sectors
+type DiversificationScore struct {
+	PortfolioID string                 `json:"portfolio_id"`
+	Holdings    DiversificationMeasure `json:"holdings"`
+	Sectors     DiversificationMeasure `json:"sectors"`
+}
+
+// DiversificationMeasure is the Herfindahl-Hirschman Index (HHI) of a set of
+// positions along with friendlier ways of reading it
+type DiversificationMeasure struct {
+	Count int `json:"count"`
+
+	// HHI is the sum of squared weights, from 1/Count (equal weight) to 1
+	// (everything in one position)
+	HHI decimal.Decimal `json:"hhi"`
+
+	// EffectiveCount is 1/HHI: the number of equal-weight positions that
+	// would be as concentrated as this portfolio
+	EffectiveCount decimal.Decimal `json:"effective_count"`
+
+	// Score is (1 - HHI) on a 0-100 scale. A single position scores 0 and
+	// 50 equal-weight positions score 98.
+	Score decimal.Decimal `json:"score"`
+}
+
+// CalculateDiversification computes the HHI-based measure for a set of
+// position values. Zero and negative values are ignored.
+func CalculateDiversification(values []decimal.Decimal) DiversificationMeasure {
+	var measure DiversificationMeasure
+
+	total := decimal.Zero
+	for _, v := range values {
+		if v.IsPositive() {
+			total = total.Add(v)
+			measure.Count++
+		}
+	}
+	if total.IsZero() {
+		return measure
+	}
+
+	hhi := decimal.Zero
+	for _, v := range values {
+		if v.IsPositive() {
+			weight := v.Div(total)
+			hhi = hhi.Add(weight.Mul(weight))
+		}
+	}
+
+	measure.HHI = hhi.Round(4)
+	measure.EffectiveCount = decimal.NewFromInt(1).Div(hhi).Round(2)
+	measure.Score = decimal.NewFromInt(1).Sub(hhi).Mul(decimal.NewFromInt(100)).Round(2)
+	return measure
+}
+
+// TickerDiversification measures concentration across tickers, aggregated over
+// accounts
+func (s *AllocationSummary) TickerDiversification() DiversificationMeasure {
+	values := make([]decimal.Decimal, 0, len(s.TickerTotals))
+	for _, v := range s.TickerTotals {
+		values = append(values, v)
+	}
+	return CalculateDiversification(values)
+}
+
+// SectorDiversification measures concentration across sectors
+func (s *AllocationSummary) SectorDiversification() DiversificationMeasure {
+	values := make([]decimal.Decimal, 0, len(s.BySector))
+	for _, slice := range s.BySector {
+		values = append(values, slice.Value)
+	}
+	return CalculateDiversification(values)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestCalculateDiversification(t *testing.T) {
+	equal := func(n int) []decimal.Decimal {
+		values := make([]decimal.Decimal, n)
+		for i := range values {
+			values[i] = decimal.NewFromInt(1000)
+		}
+		return values
+	}
+
+	tests := []struct {
+		name          string
+		values        []decimal.Decimal
+		wantCount     int
+		wantEffective float64
+		wantScore     float64
+	}{
+		{"Empty", nil, 0, 0, 0},
+		{"Single stock", equal(1), 1, 1, 0},
+		{"Two equal", equal(2), 2, 2, 50},
+		{"Fifty equal", equal(50), 50, 50, 98},
+		{
+			name:          "Concentrated",
+			values:        []decimal.Decimal{decimal.NewFromInt(9000), decimal.NewFromInt(500), decimal.NewFromInt(500)},
+			wantCount:     3,
+			wantEffective: 1.23,
+			wantScore:     18.5,
+		},
+		{
+			name:          "Ignores zero positions",
+			values:        []decimal.Decimal{decimal.NewFromInt(1000), decimal.Zero},
+			wantCount:     1,
+			wantEffective: 1,
+			wantScore:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := CalculateDiversification(tt.values)
+			if m.Count != tt.wantCount {
+				t.Errorf("Count: got %d, want %d", m.Count, tt.wantCount)
+			}
+			if got := m.EffectiveCount.InexactFloat64(); got != tt.wantEffective {
+				t.Errorf("Effective count: got %v, want %v", got, tt.wantEffective)
+			}
+			if got := m.Score.InexactFloat64(); got != tt.wantScore {
+				t.Errorf("Score: got %v, want %v", got, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestAllocationSummary_Diversification(t *testing.T) {
+	portfolio := &Portfolio{
+		ID: uuid.New(),
+		Holdings: []Holding{
+			{Ticker: "AAPL", AccountName: "IRA", Sector: "Technology", MarketValue: decimal.NewFromInt(5000)},
+			{Ticker: "AAPL", AccountName: "Brokerage", Sector: "Technology", MarketValue: decimal.NewFromInt(5000)},
+			{Ticker: "JNJ", AccountName: "IRA", Sector: "Healthcare", MarketValue: decimal.NewFromInt(10000)},
+		},
+	}
+	portfolio.CalculateTotals()
+	summary := portfolio.CalculateAllocation()
+
+	// AAPL is one position even though it's held in two accounts
+	holdings := summary.TickerDiversification()
+	if holdings.Count != 2 || holdings.Score.InexactFloat64() != 50 {
+		t.Errorf("Holdings: got count %d score %s, want 2 and 50", holdings.Count, holdings.Score)
+	}
+
+	sectors := summary.SectorDiversification()
+	if sectors.Count != 2 || sectors.EffectiveCount.InexactFloat64() != 2 {
+		t.Errorf("Sectors: got count %d effective %s, want 2 and 2", sectors.Count, sectors.EffectiveCount)
+	}
+}
@@ -107,6 +107,8 @@ var DefaultExpenseRatios = map[AssetClass]decimal.Decimal{
 	AssetClassEquity:      decimal.NewFromFloat(0.10), // 0.10% for stocks/ETFs
 	AssetClassFixedIncome: decimal.NewFromFloat(0.15), // 0.15% for bond funds
 	AssetClassAlternative: decimal.NewFromFloat(0.75), // 0.75% for alternatives
+	AssetClassCommodity:   decimal.NewFromFloat(0.50), // 0.50% for commodity funds
+	AssetClassDerivative:  decimal.Zero,                // Options carry no expense ratio
 	AssetClassCrypto:      decimal.NewFromFloat(1.00), // 1.00% for crypto products
 	AssetClassCash:        decimal.NewFromFloat(0.40), // 0.40% for money market
 	AssetClassOther:       decimal.NewFromFloat(0.50), // 0.50% default
@@ -51,6 +51,7 @@ func TestGetExpenseRatio_DefaultByAssetClass(t *testing.T) {
 	}{
 		{AssetClassFixedIncome, "UNKNOWNBOND", 0.15},
 		{AssetClassAlternative, "UNKNOWNALT", 0.75},
+		{AssetClassCommodity, "UNKNOWNCMDTY", 0.50},
 		{AssetClassCrypto, "UNKNOWNCRYPTO", 1.00},
 		{AssetClassCash, "UNKNOWNCASH", 0.40},
 	}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// EfficientFrontier is the set of allocations across a portfolio's asset
+// classes that give the highest expected return for each level of risk
+type EfficientFrontier struct {
+	PortfolioID  string          `json:"portfolio_id"`
+	AssetClasses []AssetClass    `json:"asset_classes"`
+	Points       []FrontierPoint `json:"points"` // Sorted by volatility
+
+	// Current is where the portfolio's own allocation falls
+	Current FrontierPoint `json:"current"`
+}
+
+// FrontierPoint is one allocation and its expected risk and return
+type FrontierPoint struct {
+	Volatility     decimal.Decimal                `json:"volatility"`      // Annualized std dev %
+	ExpectedReturn decimal.Decimal                `json:"expected_return"` // Annual return %
+	Weights        map[AssetClass]decimal.Decimal `json:"weights"`         // Percentages summing to 100
+}
+
+// assetClassCorrelations holds long-run correlations of annual returns
+// between asset classes. Each pair is listed once; see AssetClassCorrelation.
+var assetClassCorrelations = map[[2]AssetClass]float64{
+	{AssetClassEquity, AssetClassFixedIncome}:      0.10,
+	{AssetClassEquity, AssetClassAlternative}:      0.60,
+	{AssetClassEquity, AssetClassCommodity}:        0.30,
+	{AssetClassEquity, AssetClassDerivative}:       0.70,
+	{AssetClassEquity, AssetClassCrypto}:           0.40,
+	{AssetClassEquity, AssetClassCash}:             0.00,
+	{AssetClassEquity, AssetClassOther}:            0.50,
+	{AssetClassFixedIncome, AssetClassAlternative}: 0.20,
+	{AssetClassFixedIncome, AssetClassCommodity}:   0.00,
+	{AssetClassFixedIncome, AssetClassDerivative}:  0.00,
+	{AssetClassFixedIncome, AssetClassCrypto}:      0.00,
+	{AssetClassFixedIncome, AssetClassCash}:        0.10,
+	{AssetClassFixedIncome, AssetClassOther}:       0.20,
+	{AssetClassAlternative, AssetClassCommodity}:   0.30,
+	{AssetClassAlternative, AssetClassDerivative}:  0.40,
+	{AssetClassAlternative, AssetClassCrypto}:      0.30,
+	{AssetClassAlternative, AssetClassCash}:        0.00,
+	{AssetClassAlternative, AssetClassOther}:       0.40,
+	{AssetClassCommodity, AssetClassDerivative}:    0.20,
+	{AssetClassCommodity, AssetClassCrypto}:        0.20,
+	{AssetClassCommodity, AssetClassCash}:          0.00,
+	{AssetClassCommodity, AssetClassOther}:         0.20,
+	{AssetClassDerivative, AssetClassCrypto}:       0.30,
+	{AssetClassDerivative, AssetClassCash}:         0.00,
+	{AssetClassDerivative, AssetClassOther}:        0.30,
+	{AssetClassCrypto, AssetClassCash}:             0.00,
+	{AssetClassCrypto, AssetClassOther}:            0.20,
+	{AssetClassCash, AssetClassOther}:              0.00,
+}
+
+// AssetClassCorrelation returns the correlation between two asset classes,
+// from -1 to 1
+func AssetClassCorrelation(a, b AssetClass) float64 {
+	if a == b {
+		return 1
+	}
+	if c, ok := assetClassCorrelations[[2]AssetClass{a, b}]; ok {
+		return c
+	}
+	return assetClassCorrelations[[2]AssetClass{b, a}]
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxProjectionYears is the longest horizon glide paths and retirement plans
+// are projected over
+const MaxProjectionYears = 60
+
+// GlidePathStep is the target allocation once the goal is YearsOut years away
+type GlidePathStep struct {
+	YearsOut    int                            `json:"years_out"`
+	Allocations map[AssetClass]decimal.Decimal `json:"allocations"` // Percentages summing to 100
+}
+
+// GlidePath is a scenario whose allocation shifts as the goal nears, the way
+// a target-date fund moves from equity into bonds ahead of retirement.
+// Between steps the allocation moves in a straight line; before the first
+// step and after the last it holds.
+type GlidePath struct {
+	Name     string          `json:"name"`
+	Schedule []GlidePathStep `json:"schedule"`
+}
+
+// GlidePathProjection is the simulated outcome of following a glide path
+type GlidePathProjection struct {
+	Years        int             `json:"years"`
+	Trials       int             `json:"trials"`
+	StartValue   decimal.Decimal `json:"start_value"`
+	MeanValue    decimal.Decimal `json:"mean_value"`
+	Percentile10 decimal.Decimal `json:"percentile_10"`
+	Percentile25 decimal.Decimal `json:"percentile_25"`
+	MedianValue  decimal.Decimal `json:"median_value"`
+	Percentile75 decimal.Decimal `json:"percentile_75"`
+	Percentile90 decimal.Decimal `json:"percentile_90"`
+
+	// Milestones are the start, the end and each year a schedule step is reached
+	Milestones []GlidePathMilestone `json:"milestones"`
+}
+
+// GlidePathMilestone is the allocation at a point along the path
+type GlidePathMilestone struct {
+	Year        int                            `json:"year"` // Years from now
+	YearsOut    int                            `json:"years_out"`
+	Allocations map[AssetClass]decimal.Decimal `json:"allocations"`
+	MedianValue decimal.Decimal                `json:"median_value"`
+}
+
+// Validate checks the schedule has at least one step, no repeated
+// YearsOut, and allocations that each pass a scenario's checks and sum to
+// 100%. With none negative, none can be over 100% either.
+func (g *GlidePath) Validate() error {
+	if len(g.Schedule) == 0 {
+		return fmt.Errorf("glide path needs at least one step")
+	}
+
+	seen := make(map[int]bool)
+	for _, step := range g.Schedule {
+		if step.YearsOut < 0 {
+			return fmt.Errorf("years out can't be negative")
+		}
+		if seen[step.YearsOut] {
+			return fmt.Errorf("more than one step at %d years out", step.YearsOut)
+		}
+		seen[step.YearsOut] = true
+
+		if errs := (&Scenario{Allocations: step.Allocations}).CheckAllocations(); len(errs) > 0 {
+			return fmt.Errorf("allocation at %d years out: %s %s", step.YearsOut, errs[0].Field, errs[0].Message)
+		}
+		total := decimal.Zero
+		for _, pct := range step.Allocations {
+			total = total.Add(pct)
+		}
+		if !total.Equal(decimal.NewFromInt(100)) {
+			return fmt.Errorf("allocation at %d years out sums to %s%%, not 100%%", step.YearsOut, total)
+		}
+	}
+	return nil
+}
+
+// AllocationAt returns the allocation when the goal is yearsOut years away
+func (g *GlidePath) AllocationAt(yearsOut float64) map[AssetClass]decimal.Decimal {
+	steps := g.sortedSteps()
+	if len(steps) == 0 {
+		return map[AssetClass]decimal.Decimal{}
+	}
+
+	if yearsOut >= float64(steps[0].YearsOut) {
+		return copyAllocations(steps[0].Allocations)
+	}
+	for i := 1; i < len(steps); i++ {
+		far, near := steps[i-1], steps[i]
+		if yearsOut < float64(near.YearsOut) {
+			continue
+		}
+
+		// Fraction of the way from the farther step to the nearer one
+		t := decimal.NewFromFloat((float64(far.YearsOut) - yearsOut) / float64(far.YearsOut-near.YearsOut))
+		blended := make(map[AssetClass]decimal.Decimal)
+		for _, class := range AllAssetClasses() {
+			from, to := far.Allocations[class], near.Allocations[class]
+			if pct := from.Add(to.Sub(from).Mul(t)); !pct.IsZero() {
+				blended[class] = pct
+			}
+		}
+		return blended
+	}
+	return copyAllocations(steps[len(steps)-1].Allocations)
+}
+
+// sortedSteps returns the schedule from farthest to nearest
+func (g *GlidePath) sortedSteps() []GlidePathStep {
+	steps := make([]GlidePathStep, len(g.Schedule))
+	copy(steps, g.Schedule)
+	sort.Slice(steps, func(i, j int) bool { return steps[i].YearsOut > steps[j].YearsOut })
+	return steps
+}
+
+func copyAllocations(allocations map[AssetClass]decimal.Decimal) map[AssetClass]decimal.Decimal {
+	out := make(map[AssetClass]decimal.Decimal, len(allocations))
+	for class, pct := range allocations {
+		out[class] = pct
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+// testGlidePath moves from 90% equity 20 years out to 40% at the goal
+func testGlidePath() *GlidePath {
+	return &GlidePath{
+		Name: "Target 2045",
+		Schedule: []GlidePathStep{
+			{YearsOut: 0, Allocations: map[AssetClass]decimal.Decimal{
+				AssetClassEquity:      decimal.NewFromInt(40),
+				AssetClassFixedIncome: decimal.NewFromInt(50),
+				AssetClassCash:        decimal.NewFromInt(10),
+			}},
+			{YearsOut: 20, Allocations: map[AssetClass]decimal.Decimal{
+				AssetClassEquity:      decimal.NewFromInt(90),
+				AssetClassFixedIncome: decimal.NewFromInt(10),
+			}},
+		},
+	}
+}
+
+func TestGlidePath_AllocationAt(t *testing.T) {
+	path := testGlidePath()
+
+	tests := []struct {
+		name     string
+		yearsOut float64
+		class    AssetClass
+		want     int64
+	}{
+		{"Before the first step holds it", 30, AssetClassEquity, 90},
+		{"At a step", 20, AssetClassFixedIncome, 10},
+		{"Halfway between steps", 10, AssetClassEquity, 65},
+		{"Class only in the nearer step", 10, AssetClassCash, 5},
+		{"At the goal", 0, AssetClassFixedIncome, 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := path.AllocationAt(tt.yearsOut)
+			if !got[tt.class].Equal(decimal.NewFromInt(tt.want)) {
+				t.Errorf("%s at %v years out: got %s, want %d", tt.class, tt.yearsOut, got[tt.class], tt.want)
+			}
+
+			total := decimal.Zero
+			for _, pct := range got {
+				total = total.Add(pct)
+			}
+			if !total.Equal(decimal.NewFromInt(100)) {
+				t.Errorf("Allocation sums to %s, want 100", total)
+			}
+		})
+	}
+}
+
+func TestGlidePath_Validate(t *testing.T) {
+	if err := testGlidePath().Validate(); err != nil {
+		t.Errorf("Expected valid glide path, got %v", err)
+	}
+
+	if err := (&GlidePath{}).Validate(); err == nil {
+		t.Error("Expected an error for an empty schedule")
+	}
+
+	short := testGlidePath()
+	short.Schedule[0].Allocations[AssetClassCash] = decimal.NewFromInt(5)
+	if err := short.Validate(); err == nil {
+		t.Error("Expected an error for an allocation summing to 95%")
+	}
+
+	repeated := testGlidePath()
+	repeated.Schedule[1].YearsOut = 0
+	if err := repeated.Validate(); err == nil {
+		t.Error("Expected an error for two steps at the same years out")
+	}
+
+	// Sums to 100%, but with 150% in equity against a short position in bonds
+	leveraged := testGlidePath()
+	leveraged.Schedule[1].Allocations = map[AssetClass]decimal.Decimal{
+		AssetClassEquity:      decimal.NewFromInt(150),
+		AssetClassFixedIncome: decimal.NewFromInt(-50),
+	}
+	if err := leveraged.Validate(); err == nil {
+		t.Error("Expected an error for a negative allocation")
+	}
+
+	unknown := testGlidePath()
+	unknown.Schedule[0].Allocations["gold"] = decimal.Zero
+	if err := unknown.Validate(); err == nil {
+		t.Error("Expected an error for an unknown asset class")
+	}
+}
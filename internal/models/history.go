@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HoldingChange records a holding as it was before and after an update
+type HoldingChange struct {
+	ID        uuid.UUID `json:"id"`
+	HoldingID uuid.UUID `json:"holding_id"`
+	Before    Holding   `json:"before"`
+	After     Holding   `json:"after"`
+	ChangedAt time.Time `json:"changed_at"`
+}
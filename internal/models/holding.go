@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +17,8 @@ const (
 	AssetClassEquity      AssetClass = "equity"
 	AssetClassFixedIncome AssetClass = "fixed_income"
 	AssetClassAlternative AssetClass = "alternative" // PE, VC, Real Estate
+	AssetClassCommodity   AssetClass = "commodity"   // Gold, silver, oil, broad commodities
+	AssetClassDerivative  AssetClass = "derivative"  // Listed options
 	AssetClassCrypto      AssetClass = "crypto"
 	AssetClassCash        AssetClass = "cash"
 	AssetClassOther       AssetClass = "other" // Should be zero in final view
@@ -25,6 +30,8 @@ func AllAssetClasses() []AssetClass {
 		AssetClassEquity,
 		AssetClassFixedIncome,
 		AssetClassAlternative,
+		AssetClassCommodity,
+		AssetClassDerivative,
 		AssetClassCrypto,
 		AssetClassCash,
 		AssetClassOther,
@@ -40,6 +47,10 @@ func (a AssetClass) DisplayName() string {
 		return "Fixed Income"
 	case AssetClassAlternative:
 		return "Alternatives"
+	case AssetClassCommodity:
+		return "Commodities"
+	case AssetClassDerivative:
+		return "Derivatives"
 	case AssetClassCrypto:
 		return "Cryptocurrency"
 	case AssetClassCash:
@@ -51,6 +62,9 @@ func (a AssetClass) DisplayName() string {
 	}
 }
 
+// DefaultCurrency is assumed for holdings and portfolios that don't set one
+const DefaultCurrency = "USD"
+
 // Holding represents a single position in an account
 type Holding struct {
 	ID           uuid.UUID       `json:"id"`
@@ -60,8 +74,10 @@ type Holding struct {
 	Name         string          `json:"name"`         // e.g., "Apple Inc."
 	Quantity     decimal.Decimal `json:"quantity"`
 	CostBasis    decimal.Decimal `json:"cost_basis"`
-	CurrentPrice decimal.Decimal `json:"current_price"`
-	MarketValue  decimal.Decimal `json:"market_value"`
+	CurrentPrice decimal.Decimal `json:"current_price"` // In Currency
+	MarketValue  decimal.Decimal `json:"market_value"`  // In the portfolio's base currency
+	Currency     string          `json:"currency"`      // ISO 4217, e.g. "EUR"
+	PricedAt     time.Time       `json:"priced_at"`     // Last priced from a quote; zero if never
 
 	// Classification (AI-tagged or manual)
 	AssetClass AssetClass `json:"asset_class"`
@@ -72,6 +88,10 @@ type Holding struct {
 	IsManualEntry bool      `json:"is_manual_entry"`
 	Source        string    `json:"source"` // "schwab_csv", "fidelity_csv", "manual"
 	ImportedAt    time.Time `json:"imported_at"`
+
+	// Lots are the holding's tax lots, when the import included lot detail.
+	// CostBasis is kept as their sum.
+	Lots []HoldingLot `json:"lots,omitempty"`
 }
 
 // NewHolding creates a new holding with generated ID
@@ -86,27 +106,87 @@ func NewHolding(portfolioID uuid.UUID, ticker, name, accountName string) *Holdin
 		CostBasis:    decimal.Zero,
 		CurrentPrice: decimal.Zero,
 		MarketValue:  decimal.Zero,
+		Currency:     DefaultCurrency,
 		AssetClass:   AssetClassOther,
 		ImportedAt:   time.Now().UTC(),
 	}
 }
 
-// CalculateMarketValue updates market value based on quantity and current price
+// CurrencyCode returns the holding's currency, defaulting to USD
+func (h *Holding) CurrencyCode() string {
+	if h.Currency == "" {
+		return DefaultCurrency
+	}
+	return strings.ToUpper(h.Currency)
+}
+
+// NormalizeCurrency returns a currency code upper-cased, or USD when it's
+// blank. The error is non-nil unless it's three letters, as ISO 4217 codes
+// are.
+func NormalizeCurrency(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" {
+		return DefaultCurrency, nil
+	}
+	if len(s) != 3 || strings.Trim(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return s, fmt.Errorf("currency %q isn't a three-letter code like EUR", s)
+	}
+	return s, nil
+}
+
+// ConvertAmounts converts the holding's market value and cost basis, and
+// its lots' cost basis, from its own currency into the portfolio's base
+// currency at rate. The price stays in the holding's currency.
+func (h *Holding) ConvertAmounts(rate decimal.Decimal) {
+	h.MarketValue = h.MarketValue.Mul(rate)
+	h.CostBasis = h.CostBasis.Mul(rate)
+	for i := range h.Lots {
+		h.Lots[i].CostBasis = h.Lots[i].CostBasis.Mul(rate)
+	}
+}
+
+// CalculateMarketValue updates market value based on quantity and current
+// price, scaled by the contract multiplier for options
 func (h *Holding) CalculateMarketValue() {
-	h.MarketValue = h.Quantity.Mul(h.CurrentPrice)
+	h.MarketValue = h.Quantity.Mul(h.CurrentPrice).Mul(h.ContractMultiplier())
+}
+
+// Option returns the contract details when the ticker is an OCC option symbol
+func (h *Holding) Option() (*OptionContract, bool) {
+	return ParseOptionSymbol(h.Ticker)
+}
+
+// ContractMultiplier is how many units of the underlying one unit of the
+// holding represents: 100 for options, 1 for everything else
+func (h *Holding) ContractMultiplier() decimal.Decimal {
+	if opt, ok := h.Option(); ok {
+		return decimal.NewFromInt(int64(opt.Multiplier))
+	}
+	return decimal.NewFromInt(1)
 }
 
-// GainLoss returns the unrealized gain/loss
+// GainLoss returns the unrealized gain/loss against the total cost of the
+// holding's lots
 func (h *Holding) GainLoss() decimal.Decimal {
-	return h.MarketValue.Sub(h.CostBasis)
+	return h.MarketValue.Sub(h.TotalCostBasis())
 }
 
 // GainLossPercent returns the unrealized gain/loss as a percentage
 func (h *Holding) GainLossPercent() decimal.Decimal {
-	if h.CostBasis.IsZero() {
+	costBasis := h.TotalCostBasis()
+	if costBasis.IsZero() {
 		return decimal.Zero
 	}
-	return h.GainLoss().Div(h.CostBasis).Mul(decimal.NewFromInt(100)).Round(2)
+	return h.GainLoss().Div(costBasis).Mul(decimal.NewFromInt(100)).Round(2)
+}
+
+// PriceAsOf returns when CurrentPrice was last set: the latest quote, or
+// the import for holdings that have never been refreshed
+func (h *Holding) PriceAsOf() time.Time {
+	if h.PricedAt.IsZero() {
+		return h.ImportedAt
+	}
+	return h.PricedAt
 }
 
 // IsCash returns true if this holding represents cash or money market
@@ -119,6 +199,23 @@ func (h *Holding) NeedsClassification() bool {
 	return h.AssetClass == AssetClassOther
 }
 
+// DisplayTicker returns the ticker without its exchange suffix. Ticker
+// itself keeps the suffix since quote providers need it.
+func (h *Holding) DisplayTicker() string {
+	return DisplayTicker(h.Ticker)
+}
+
+// TickerOverride is a user's own classification for a ticker. It takes
+// precedence over built-in data and heuristics on every future import.
+type TickerOverride struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Ticker     string     `json:"ticker"`
+	AssetClass AssetClass `json:"asset_class"`
+	Sector     string     `json:"sector"`
+	Geography  string     `json:"geography"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
 // Standard sectors for classification
 var StandardSectors = []string{
 	"Technology",
@@ -133,12 +230,249 @@ var StandardSectors = []string{
 	"Basic Materials",
 	"Communication Services",
 	"Diversified",
+
+	// Fixed income
+	"Treasury",
+	"Corporate",
+	"Municipal",
+	"TIPS",
+	"High-Yield",
+	"Bonds", // Broad bond market
+
+	// Alternatives
+	"Private Equity",
+	"Alternatives",
 }
 
 // Standard geographies for classification
 var StandardGeographies = []string{
 	"US",
+	"Canada",
+	"Japan",
 	"International Developed",
 	"Emerging Markets",
 	"Global",
 }
+
+// ExchangeSuffix describes a non-US listing identified by its ticker suffix
+type ExchangeSuffix struct {
+	Geography string
+	// NumericBase requires the part before the suffix to be all digits, as
+	// with Tokyo listings like 7203.T
+	NumericBase bool
+}
+
+// ExchangeSuffixes maps ticker suffixes (e.g. ".TO" in "SHOP.TO") to the
+// geography of the exchange
+var ExchangeSuffixes = map[string]ExchangeSuffix{
+	".TO": {Geography: "Canada"},
+	".V":  {Geography: "Canada"},
+	".L":  {Geography: "International Developed"},
+	".DE": {Geography: "International Developed"},
+	".PA": {Geography: "International Developed"},
+	".AS": {Geography: "International Developed"},
+	".SW": {Geography: "International Developed"},
+	".T":  {Geography: "Japan", NumericBase: true},
+}
+
+// SplitExchangeSuffix splits a ticker such as "BP.L" into its base ticker
+// and known exchange suffix. ok is false for US tickers, including share
+// classes like "BRK.B".
+func SplitExchangeSuffix(ticker string) (base string, suffix ExchangeSuffix, ok bool) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	dot := strings.LastIndex(ticker, ".")
+	if dot <= 0 {
+		return ticker, ExchangeSuffix{}, false
+	}
+
+	base = ticker[:dot]
+	suffix, ok = ExchangeSuffixes[ticker[dot:]]
+	if !ok || (suffix.NumericBase && !isDigits(base)) {
+		return ticker, ExchangeSuffix{}, false
+	}
+	return base, suffix, true
+}
+
+// DisplayTicker strips a known exchange suffix for display
+func DisplayTicker(ticker string) string {
+	if base, _, ok := SplitExchangeSuffix(ticker); ok {
+		return base
+	}
+	return ticker
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// maxTickerLength fits a normalized OCC option symbol: a six-character
+// root plus its 15-character suffix
+const maxTickerLength = 6 + occSuffixLength
+
+// NormalizeTicker returns the canonical form of a ticker as typed by a user
+// or exported by a broker: trimmed, upper-cased, without the trailing
+// asterisks some brokers add, and with option roots unpadded. Share classes
+// and exchange suffixes keep their dots ("BRK.B", "SHOP.TO").
+//
+// The error is non-nil for symbols that can't be a ticker: empty, too long,
+// or containing spaces or punctuation other than . - / ^ and =. The
+// normalized string is returned either way so importers can still
+// recognise cash and separator rows before rejecting them.
+func NormalizeTicker(s string) (string, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	s = strings.TrimRight(s, " *")
+
+	// Brokers pad option roots with spaces; quote providers want them without
+	if opt, ok := ParseOptionSymbol(s); ok {
+		s = opt.Symbol()
+	}
+
+	switch {
+	case s == "":
+		return s, fmt.Errorf("ticker is empty")
+	case len(s) > maxTickerLength:
+		return s, fmt.Errorf("ticker %q is longer than %d characters", s, maxTickerLength)
+	}
+	for i, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '^' && i == 0:
+			// Index symbols such as ^GSPC
+		case strings.ContainsRune(".-/=", r) && i > 0 && i < len(s)-1:
+		default:
+			return s, fmt.Errorf("ticker %q contains invalid character %q", s, r)
+		}
+	}
+	return s, nil
+}
+
+// OptionType is a call or a put
+type OptionType string
+
+const (
+	OptionCall OptionType = "call"
+	OptionPut  OptionType = "put"
+)
+
+// OptionContractMultiplier is the number of shares a standard US equity
+// option contract covers
+const OptionContractMultiplier = 100
+
+// OptionContract describes a listed option parsed from its OCC symbol
+type OptionContract struct {
+	Underlying string          `json:"underlying"`
+	Expiry     time.Time       `json:"expiry"`
+	Strike     decimal.Decimal `json:"strike"`
+	Type       OptionType      `json:"type"`
+	Multiplier int             `json:"multiplier"`
+}
+
+// occSuffixLength is the YYMMDD expiry, C/P flag, and 8-digit strike that
+// end every OCC option symbol
+const occSuffixLength = 15
+
+// ParseOptionSymbol parses an OCC option symbol such as
+// "AAPL  240119C00150000": the underlying (padded or not), expiry as
+// YYMMDD, C or P, and the strike in thousandths of a dollar. ok is false for
+// anything else.
+func ParseOptionSymbol(symbol string) (*OptionContract, bool) {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if len(symbol) <= occSuffixLength {
+		return nil, false
+	}
+
+	suffix := symbol[len(symbol)-occSuffixLength:]
+	root := strings.TrimRight(symbol[:len(symbol)-occSuffixLength], " ")
+	if len(root) == 0 || len(root) > 6 || strings.ContainsAny(root, " ") {
+		return nil, false
+	}
+
+	expiry, err := time.Parse("060102", suffix[:6])
+	if err != nil {
+		return nil, false
+	}
+
+	var optType OptionType
+	switch suffix[6] {
+	case 'C':
+		optType = OptionCall
+	case 'P':
+		optType = OptionPut
+	default:
+		return nil, false
+	}
+
+	if !isDigits(suffix[7:]) {
+		return nil, false
+	}
+	strike, err := decimal.NewFromString(suffix[7:])
+	if err != nil {
+		return nil, false
+	}
+
+	return &OptionContract{
+		Underlying: root,
+		Expiry:     expiry,
+		Strike:     strike.Div(decimal.NewFromInt(1000)),
+		Type:       optType,
+		Multiplier: OptionContractMultiplier,
+	}, true
+}
+
+// Symbol returns the contract's OCC symbol without padding, the form quote
+// providers accept
+func (o *OptionContract) Symbol() string {
+	flag := "C"
+	if o.Type == OptionPut {
+		flag = "P"
+	}
+	strike := o.Strike.Mul(decimal.NewFromInt(1000)).IntPart()
+	return fmt.Sprintf("%s%s%s%08d", o.Underlying, o.Expiry.Format("060102"), flag, strike)
+}
+
+// Orders a holdings list can be sorted in
+const (
+	SortMarketValue = "market_value"
+	SortGainLoss    = "gain_loss"
+	SortGainLossPct = "gain_loss_pct"
+	SortTicker      = "ticker"
+)
+
+// SortHoldings orders holdings in place by one of the Sort orders. Amounts
+// sort largest first and tickers alphabetically unless reverse is set; ties
+// are broken by ticker so the order is stable between requests.
+func SortHoldings(holdings []Holding, order string, reverse bool) error {
+	var key func(h *Holding) decimal.Decimal
+	switch order {
+	case SortMarketValue:
+		key = func(h *Holding) decimal.Decimal { return h.MarketValue }
+	case SortGainLoss:
+		key = func(h *Holding) decimal.Decimal { return h.GainLoss() }
+	case SortGainLossPct:
+		key = func(h *Holding) decimal.Decimal { return h.GainLossPercent() }
+	case SortTicker:
+	default:
+		return fmt.Errorf("unknown sort %q", order)
+	}
+
+	sort.SliceStable(holdings, func(i, j int) bool {
+		a, b := &holdings[i], &holdings[j]
+		if key != nil {
+			if ka, kb := key(a), key(b); !ka.Equal(kb) {
+				return ka.GreaterThan(kb) != reverse
+			}
+		} else if a.Ticker != b.Ticker {
+			return (a.Ticker < b.Ticker) != reverse
+		}
+		if a.Ticker != b.Ticker {
+			return a.Ticker < b.Ticker
+		}
+		return a.AccountName < b.AccountName
+	})
+	return nil
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
@@ -125,6 +126,8 @@ func TestAssetClass_DisplayName(t *testing.T) {
 		{AssetClassEquity, "Equities"},
 		{AssetClassFixedIncome, "Fixed Income"},
 		{AssetClassAlternative, "Alternatives"},
+		{AssetClassCommodity, "Commodities"},
+		{AssetClassDerivative, "Derivatives"},
 		{AssetClassCrypto, "Cryptocurrency"},
 		{AssetClassCash, "Cash"},
 		{AssetClassOther, "Other"},
@@ -140,7 +143,241 @@ func TestAssetClass_DisplayName(t *testing.T) {
 
 func TestAllAssetClasses(t *testing.T) {
 	classes := AllAssetClasses()
-	if len(classes) != 6 {
-		t.Errorf("Expected 6 asset classes, got %d", len(classes))
+	if len(classes) != 8 {
+		t.Errorf("Expected 8 asset classes, got %d", len(classes))
+	}
+}
+
+func TestSplitExchangeSuffix(t *testing.T) {
+	tests := []struct {
+		ticker   string
+		wantBase string
+		wantGeo  string
+		wantOK   bool
+	}{
+		{"SHOP.TO", "SHOP", "Canada", true},
+		{"bp.l", "BP", "International Developed", true},
+		{"7203.T", "7203", "Japan", true},
+		{"ABC.T", "ABC.T", "", false}, // Tokyo listings are numeric
+		{"BRK.B", "BRK.B", "", false}, // US share class, not an exchange
+		{"AAPL", "AAPL", "", false},
+		{".TO", ".TO", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ticker, func(t *testing.T) {
+			base, suffix, ok := SplitExchangeSuffix(tt.ticker)
+			if ok != tt.wantOK {
+				t.Fatalf("ok: got %v, want %v", ok, tt.wantOK)
+			}
+			if base != tt.wantBase {
+				t.Errorf("Base: got %s, want %s", base, tt.wantBase)
+			}
+			if suffix.Geography != tt.wantGeo {
+				t.Errorf("Geography: got %s, want %s", suffix.Geography, tt.wantGeo)
+			}
+		})
+	}
+}
+
+func TestNormalizeTicker(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"AAPL", "AAPL", false},
+		{"  aapl  ", "AAPL", false},
+		{"MSFT*", "MSFT", false},
+		{"googl**", "GOOGL", false},
+		{"AAPL  240119C00150000", "AAPL240119C00150000", false},
+		{"BRK.B", "BRK.B", false},
+		{"brk.a", "BRK.A", false},
+		{"SHOP.TO", "SHOP.TO", false},
+		{"BRK/B", "BRK/B", false},
+		{"BTC-USD", "BTC-USD", false},
+		{"^gspc", "^GSPC", false},
+		{"", "", true},
+		{"  * ", "", true},
+		{"PENDING ACTIVITY", "PENDING ACTIVITY", true},
+		{"--", "--", true},
+		{".B", ".B", true},
+		{"BRK.", "BRK.", true},
+		{"AA^PL", "AA^PL", true},
+		{"MSFT$", "MSFT$", true},
+		{"ABCDEFGHIJKLMNOPQRSTUV", "ABCDEFGHIJKLMNOPQRSTUV", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result, err := NormalizeTicker(tt.input)
+			if result != tt.expected {
+				t.Errorf("NormalizeTicker(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeTicker(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+func TestHolding_DisplayTicker(t *testing.T) {
+	h := &Holding{Ticker: "SHOP.TO"}
+	if got := h.DisplayTicker(); got != "SHOP" {
+		t.Errorf("DisplayTicker: got %s, want SHOP", got)
+	}
+	if h.Ticker != "SHOP.TO" {
+		t.Errorf("Ticker should keep its suffix for quotes, got %s", h.Ticker)
+	}
+
+	h.Ticker = "BRK.B"
+	if got := h.DisplayTicker(); got != "BRK.B" {
+		t.Errorf("DisplayTicker: got %s, want BRK.B", got)
+	}
+}
+
+func TestParseOptionSymbol(t *testing.T) {
+	tests := []struct {
+		symbol     string
+		underlying string
+		expiry     string
+		strike     string
+		optType    OptionType
+	}{
+		{"AAPL  240119C00150000", "AAPL", "2024-01-19", "150", OptionCall},
+		{"AAPL 240119C00150000", "AAPL", "2024-01-19", "150", OptionCall},
+		{"spy241220p00432500", "SPY", "2024-12-20", "432.5", OptionPut},
+		{"BRK.B 250321C00400000", "BRK.B", "2025-03-21", "400", OptionCall},
+		{"F     250117P00012500", "F", "2025-01-17", "12.5", OptionPut},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.symbol, func(t *testing.T) {
+			opt, ok := ParseOptionSymbol(tt.symbol)
+			if !ok {
+				t.Fatal("Expected symbol to parse")
+			}
+			if opt.Underlying != tt.underlying {
+				t.Errorf("Underlying: got %s, want %s", opt.Underlying, tt.underlying)
+			}
+			if got := opt.Expiry.Format("2006-01-02"); got != tt.expiry {
+				t.Errorf("Expiry: got %s, want %s", got, tt.expiry)
+			}
+			if !opt.Strike.Equal(decimal.RequireFromString(tt.strike)) {
+				t.Errorf("Strike: got %s, want %s", opt.Strike, tt.strike)
+			}
+			if opt.Type != tt.optType {
+				t.Errorf("Type: got %s, want %s", opt.Type, tt.optType)
+			}
+			if opt.Multiplier != 100 {
+				t.Errorf("Multiplier: got %d, want 100", opt.Multiplier)
+			}
+		})
+	}
+
+	for _, symbol := range []string{"AAPL", "VTSAX", "AAPL 241319C00150000", "AAPL 240119X00150000", "TOOLONGX240119C00150000", "240119C00150000"} {
+		if _, ok := ParseOptionSymbol(symbol); ok {
+			t.Errorf("%q should not parse as an option", symbol)
+		}
+	}
+
+	opt, _ := ParseOptionSymbol("SPY   241220P00432500")
+	if got := opt.Symbol(); got != "SPY241220P00432500" {
+		t.Errorf("Symbol: got %s, want SPY241220P00432500", got)
+	}
+}
+
+func TestHolding_CalculateMarketValue_Option(t *testing.T) {
+	h := &Holding{
+		Ticker:       "AAPL240119C00150000",
+		Quantity:     decimal.NewFromInt(3),
+		CurrentPrice: decimal.NewFromFloat(2.50),
+	}
+	h.CalculateMarketValue()
+
+	// 3 contracts x $2.50 x 100 shares each
+	if !h.MarketValue.Equal(decimal.NewFromInt(750)) {
+		t.Errorf("Market value: got %s, want 750", h.MarketValue)
+	}
+}
+
+func TestHolding_GainLoss_Lots(t *testing.T) {
+	h := &Holding{
+		Quantity:    decimal.NewFromInt(15),
+		MarketValue: decimal.NewFromInt(4000),
+		CostBasis:   decimal.NewFromInt(1), // Stale; the lots win
+		Lots: []HoldingLot{
+			{Quantity: decimal.NewFromInt(10), CostBasis: decimal.NewFromInt(2000)},
+			{Quantity: decimal.NewFromInt(5), CostBasis: decimal.NewFromInt(1000)},
+		},
+	}
+
+	if got := h.GainLoss(); !got.Equal(decimal.NewFromInt(1000)) {
+		t.Errorf("GainLoss: got %s, want 1000", got)
+	}
+	if got := h.GainLossPercent(); !got.Equal(decimal.NewFromFloat(33.33)) {
+		t.Errorf("GainLossPercent: got %s, want 33.33", got)
+	}
+	if got := h.AverageCost(); !got.Equal(decimal.NewFromInt(200)) {
+		t.Errorf("AverageCost: got %s, want 200", got)
+	}
+}
+
+func TestSortHoldings(t *testing.T) {
+	holdings := []Holding{
+		{Ticker: "VTI", AccountName: "IRA", MarketValue: decimal.NewFromInt(100)},
+		{Ticker: "AAPL", AccountName: "Brokerage", MarketValue: decimal.NewFromInt(100)},
+		{Ticker: "BND", AccountName: "IRA", MarketValue: decimal.NewFromInt(300)},
+		{Ticker: "AAPL", AccountName: "401k", MarketValue: decimal.NewFromInt(100)},
+	}
+
+	tickers := func() string {
+		var out []string
+		for _, h := range holdings {
+			out = append(out, h.Ticker+"/"+h.AccountName)
+		}
+		return strings.Join(out, " ")
+	}
+
+	// Ties keep ticker then account order whichever way the values run
+	if err := SortHoldings(holdings, SortMarketValue, false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got, want := tickers(), "BND/IRA AAPL/401k AAPL/Brokerage VTI/IRA"; got != want {
+		t.Errorf("By value: got %s, want %s", got, want)
+	}
+	SortHoldings(holdings, SortMarketValue, true)
+	if got, want := tickers(), "AAPL/401k AAPL/Brokerage VTI/IRA BND/IRA"; got != want {
+		t.Errorf("By value reversed: got %s, want %s", got, want)
+	}
+
+	if err := SortHoldings(holdings, "name", false); err == nil {
+		t.Error("Expected an error for an unknown sort")
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{"", "USD", false},
+		{"  ", "USD", false},
+		{"eur", "EUR", false},
+		{" GBP ", "GBP", false},
+		{"EURO", "", true},
+		{"E1R", "", true},
+		{"$", "", true},
+	}
+
+	for _, tt := range tests {
+		result, err := NormalizeCurrency(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NormalizeCurrency(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && result != tt.expected {
+			t.Errorf("NormalizeCurrency(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
 	}
 }
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strings"
+)
+
+// IssuerTickers maps tickers to the company whose shares they represent,
+// for tickers that share-class suffix stripping can't group on its own:
+// share classes with different base tickers and single-stock ETFs, whose
+// value rides on one company even though it's a different security
+var IssuerTickers = map[string]string{
+	// Share classes with unrelated tickers
+	"GOOGL": "Alphabet",
+	"GOOG":  "Alphabet",
+	"FOXA":  "Fox",
+	"FOX":   "Fox",
+	"NWSA":  "News Corp",
+	"NWS":   "News Corp",
+
+	// Single-stock ETFs
+	"TSLL": "Tesla",
+	"TSLQ": "Tesla",
+	"NVDL": "NVIDIA",
+	"NVDU": "NVIDIA",
+	"AAPU": "Apple",
+	"AAPD": "Apple",
+	"MSFU": "Microsoft",
+	"AMZU": "Amazon",
+	"GGLL": "Alphabet",
+	"FBL":  "Meta Platforms",
+	"CONL": "Coinbase",
+
+	// Stocks, so they group with their single-stock ETFs
+	"TSLA": "Tesla",
+	"NVDA": "NVIDIA",
+	"AAPL": "Apple",
+	"MSFT": "Microsoft",
+	"AMZN": "Amazon",
+	"META": "Meta Platforms",
+	"COIN": "Coinbase",
+}
+
+// Issuer returns a key grouping tickers of the same company: the name from
+// IssuerTickers when listed, otherwise the ticker with any share-class
+// suffix removed, so BRK.A and BRK.B are both "BRK"
+func Issuer(ticker string) string {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if issuer, ok := IssuerTickers[ticker]; ok {
+		return issuer
+	}
+	if _, _, ok := SplitExchangeSuffix(ticker); ok {
+		return ticker
+	}
+	return stripShareClass(ticker)
+}
+
+// stripShareClass removes a one-letter class suffix written as .B, -B or /B
+func stripShareClass(ticker string) string {
+	n := len(ticker)
+	if n < 3 {
+		return ticker
+	}
+	class := ticker[n-1]
+	if sep := ticker[n-2]; (sep == '.' || sep == '-' || sep == '/') && class >= 'A' && class <= 'Z' {
+		return ticker[:n-2]
+	}
+	return ticker
+}
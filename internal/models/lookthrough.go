@@ -0,0 +1,103 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// FundExposure is the approximate makeup of a fund, as percentages that each
+// sum to 100
+type FundExposure struct {
+	Sectors     map[string]decimal.Decimal
+	Geographies map[string]decimal.Decimal
+}
+
+// weights converts whole-number percentages to decimals
+func weights(pct map[string]int64) map[string]decimal.Decimal {
+	m := make(map[string]decimal.Decimal, len(pct))
+	for name, p := range pct {
+		m[name] = decimal.NewFromInt(p)
+	}
+	return m
+}
+
+var (
+	sp500Sectors = weights(map[string]int64{
+		"Technology": 32, "Financial Services": 13, "Healthcare": 11,
+		"Consumer Cyclical": 10, "Communication Services": 9, "Industrials": 8,
+		"Consumer Defensive": 6, "Energy": 4, "Utilities": 3, "Real Estate": 2,
+		"Basic Materials": 2,
+	})
+	totalUSSectors = weights(map[string]int64{
+		"Technology": 30, "Financial Services": 13, "Healthcare": 11,
+		"Consumer Cyclical": 10, "Industrials": 10, "Communication Services": 8,
+		"Consumer Defensive": 5, "Energy": 4, "Real Estate": 3, "Utilities": 3,
+		"Basic Materials": 3,
+	})
+	developedSectors = weights(map[string]int64{
+		"Financial Services": 21, "Industrials": 16, "Technology": 13,
+		"Consumer Cyclical": 10, "Healthcare": 9, "Consumer Defensive": 7,
+		"Basic Materials": 7, "Communication Services": 5, "Energy": 5,
+		"Real Estate": 4, "Utilities": 3,
+	})
+	emergingSectors = weights(map[string]int64{
+		"Technology": 23, "Financial Services": 22, "Consumer Cyclical": 13,
+		"Communication Services": 9, "Basic Materials": 7, "Industrials": 7,
+		"Energy": 5, "Consumer Defensive": 5, "Healthcare": 4, "Utilities": 3,
+		"Real Estate": 2,
+	})
+	usOnly = weights(map[string]int64{"US": 100})
+)
+
+// FundExposures maps broad index ETFs to their approximate underlying sector
+// and geography weights, so a fund tagged "Diversified" can be looked
+// through to what it holds. Weights drift with the market; they're meant to
+// surface hidden concentration, not to be exact.
+var FundExposures = map[string]FundExposure{
+	"SPY":  {Sectors: sp500Sectors, Geographies: usOnly},
+	"VOO":  {Sectors: sp500Sectors, Geographies: usOnly},
+	"IVV":  {Sectors: sp500Sectors, Geographies: usOnly},
+	"VTI":  {Sectors: totalUSSectors, Geographies: usOnly},
+	"ITOT": {Sectors: totalUSSectors, Geographies: usOnly},
+	"QQQ": {
+		Sectors: weights(map[string]int64{
+			"Technology": 50, "Communication Services": 16, "Consumer Cyclical": 14,
+			"Consumer Defensive": 6, "Healthcare": 6, "Industrials": 5,
+			"Utilities": 1, "Basic Materials": 1, "Financial Services": 1,
+		}),
+		Geographies: usOnly,
+	},
+	"VEA": {
+		Sectors:     developedSectors,
+		Geographies: weights(map[string]int64{"International Developed": 69, "Japan": 21, "Canada": 10}),
+	},
+	"VXUS": {
+		Sectors:     developedSectors,
+		Geographies: weights(map[string]int64{"International Developed": 52, "Emerging Markets": 25, "Japan": 15, "Canada": 8}),
+	},
+	"VWO": {
+		Sectors:     emergingSectors,
+		Geographies: weights(map[string]int64{"Emerging Markets": 100}),
+	},
+	"VT": {
+		Sectors: weights(map[string]int64{
+			"Technology": 25, "Financial Services": 16, "Consumer Cyclical": 11,
+			"Industrials": 11, "Healthcare": 10, "Communication Services": 7,
+			"Consumer Defensive": 6, "Basic Materials": 4, "Energy": 4,
+			"Utilities": 3, "Real Estate": 3,
+		}),
+		Geographies: weights(map[string]int64{"US": 62, "International Developed": 19, "Emerging Markets": 10, "Japan": 6, "Canada": 3}),
+	},
+}
+
+// LookThrough returns the fund's underlying exposure, if it's a fund we know
+// the makeup of. Holdings the user has classified by hand are taken at their
+// word and never looked through.
+func (h *Holding) LookThrough() (FundExposure, bool) {
+	if h.IsManualEntry {
+		return FundExposure{}, false
+	}
+	exposure, ok := FundExposures[strings.ToUpper(h.Ticker)]
+	return exposure, ok
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// HoldingLot is one tax lot of a holding: shares bought together on one date
+type HoldingLot struct {
+	ID         uuid.UUID       `json:"id"`
+	HoldingID  uuid.UUID       `json:"holding_id"`
+	Quantity   decimal.Decimal `json:"quantity"`
+	CostBasis  decimal.Decimal `json:"cost_basis"` // Total cost of the lot
+	AcquiredAt time.Time       `json:"acquired_at"`
+}
+
+// NewHoldingLot creates a new lot with generated ID
+func NewHoldingLot(holdingID uuid.UUID, quantity, costBasis decimal.Decimal, acquiredAt time.Time) *HoldingLot {
+	return &HoldingLot{
+		ID:         uuid.New(),
+		HoldingID:  holdingID,
+		Quantity:   quantity,
+		CostBasis:  costBasis,
+		AcquiredAt: acquiredAt,
+	}
+}
+
+// HasLots returns true if the holding's cost basis is broken down into lots
+func (h *Holding) HasLots() bool {
+	return len(h.Lots) > 0
+}
+
+// TotalCostBasis returns the sum of the holding's lots, or its single cost
+// basis when it has none
+func (h *Holding) TotalCostBasis() decimal.Decimal {
+	if !h.HasLots() {
+		return h.CostBasis
+	}
+	total := decimal.Zero
+	for _, lot := range h.Lots {
+		total = total.Add(lot.CostBasis)
+	}
+	return total
+}
+
+// AverageCost returns the cost basis per share
+func (h *Holding) AverageCost() decimal.Decimal {
+	if h.Quantity.IsZero() {
+		return decimal.Zero
+	}
+	return h.TotalCostBasis().Div(h.Quantity).Round(4)
+}
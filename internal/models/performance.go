@@ -27,7 +27,7 @@ type PerformanceMetrics struct {
 	StartPrice      decimal.Decimal `json:"start_price"`
 	EndPrice        decimal.Decimal `json:"end_price"`
 	TotalReturn     decimal.Decimal `json:"total_return"`      // Percentage
-	AnnualizedReturn decimal.Decimal `json:"annualized_return"` // CAGR
+	AnnualizedReturn decimal.Decimal `json:"annualized_return"` // CAGR, or the period return under a year
 	Volatility      decimal.Decimal `json:"volatility"`        // Standard deviation of returns
 	MaxDrawdown     decimal.Decimal `json:"max_drawdown"`      // Worst peak-to-trough
 	SharpeRatio     decimal.Decimal `json:"sharpe_ratio"`      // Risk-adjusted return
@@ -41,7 +41,7 @@ type PortfolioPerformance struct {
 	StartValue       decimal.Decimal      `json:"start_value"`
 	EndValue         decimal.Decimal      `json:"end_value"`
 	TotalReturn      decimal.Decimal      `json:"total_return"`
-	AnnualizedReturn decimal.Decimal      `json:"annualized_return"`
+	AnnualizedReturn decimal.Decimal      `json:"annualized_return"` // Time-weighted
 	Volatility       decimal.Decimal      `json:"volatility"`
 	SharpeRatio      decimal.Decimal      `json:"sharpe_ratio"`
 	MaxDrawdown      decimal.Decimal      `json:"max_drawdown"`
@@ -50,6 +50,12 @@ type PortfolioPerformance struct {
 	PositiveMonths   int                  `json:"positive_months"`
 	NegativeMonths   int                  `json:"negative_months"`
 	Holdings         []HoldingPerformance `json:"holdings,omitempty"`
+	Accounts         []AccountPerformance `json:"accounts,omitempty"` // Set when grouped by account
+
+	// MoneyWeightedReturn is the annualized IRR of the portfolio's dated
+	// cash flows. Unlike AnnualizedReturn it reflects when money was added,
+	// so it's nil when contributions aren't known.
+	MoneyWeightedReturn *decimal.Decimal `json:"money_weighted_return,omitempty"`
 }
 
 // HoldingPerformance tracks individual holding performance
@@ -63,6 +69,24 @@ type HoldingPerformance struct {
 	Weight           decimal.Decimal `json:"weight"`           // Current portfolio weight
 }
 
+// AccountPerformance attributes the portfolio's return to one account, such
+// as an IRA or a taxable brokerage account
+type AccountPerformance struct {
+	Account         string          `json:"account"`
+	Holdings        int             `json:"holdings"`
+	Value           decimal.Decimal `json:"value"`
+	Weight          decimal.Decimal `json:"weight"`           // Current portfolio weight
+	ExpectedReturn  decimal.Decimal `json:"expected_return"`  // Weighted from its holdings' returns
+	ContributionPct decimal.Decimal `json:"contribution_pct"` // Contribution to portfolio return
+
+	// CostBasis and UnrealizedReturn only count holdings with a known cost
+	// basis; BasisCoverage is the percentage of the account's value they
+	// make up. UnrealizedReturn is nil when none of them have one.
+	CostBasis        decimal.Decimal  `json:"cost_basis"`
+	UnrealizedReturn *decimal.Decimal `json:"unrealized_return,omitempty"`
+	BasisCoverage    decimal.Decimal  `json:"basis_coverage"`
+}
+
 // ValueSnapshot stores portfolio value at a point in time
 type ValueSnapshot struct {
 	PortfolioID string          `json:"portfolio_id"`
@@ -71,12 +95,26 @@ type ValueSnapshot struct {
 	CashValue   decimal.Decimal `json:"cash_value"`
 }
 
+// CashFlow is money moving into or out of a portfolio on a date. From the
+// investor's side, contributions are negative and withdrawals (or the
+// ending value) are positive.
+type CashFlow struct {
+	Date   time.Time       `json:"date"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
 // TimeSeriesPoint for charting
 type TimeSeriesPoint struct {
 	Date  time.Time       `json:"date"`
 	Value decimal.Decimal `json:"value"`
 }
 
+// RollingReturnPoint is the return over the window ending on Date
+type RollingReturnPoint struct {
+	Date          time.Time       `json:"date"`
+	RollingReturn decimal.Decimal `json:"rolling_return"` // Percentage
+}
+
 // PerformancePeriod constants
 const (
 	Period1Day   = "1d"
@@ -91,8 +129,16 @@ const (
 	PeriodAll    = "all"
 )
 
-// GetPeriodDuration returns the duration for a period string
+// GetPeriodDuration returns the duration for a period string, ending now.
+// PeriodAll falls back to a year since there's no creation date to start
+// from; use PeriodDuration when there is one.
 func GetPeriodDuration(period string) time.Duration {
+	return PeriodDuration(period, time.Now().UTC(), time.Time{})
+}
+
+// PeriodDuration returns the length of a period ending at now. YTD runs from
+// January 1st and PeriodAll from created, the portfolio's creation date.
+func PeriodDuration(period string, now, created time.Time) time.Duration {
 	switch period {
 	case Period1Day:
 		return 24 * time.Hour
@@ -110,6 +156,8 @@ func GetPeriodDuration(period string) time.Duration {
 		return 3 * 365 * 24 * time.Hour
 	case Period5Year:
 		return 5 * 365 * 24 * time.Hour
+	case PeriodYTD, PeriodAll:
+		return now.Sub(PeriodStartDate(period, now, created))
 	default:
 		return 365 * 24 * time.Hour
 	}
@@ -117,8 +165,12 @@ func GetPeriodDuration(period string) time.Duration {
 
 // GetPeriodStartDate calculates start date for a period
 func GetPeriodStartDate(period string) time.Time {
-	now := time.Now().UTC()
+	return PeriodStartDate(period, time.Now().UTC(), time.Time{})
+}
 
+// PeriodStartDate calculates the start date for a period ending at now.
+// PeriodAll starts at created, or a year back when it's unknown.
+func PeriodStartDate(period string, now, created time.Time) time.Time {
 	switch period {
 	case Period1Day:
 		return now.AddDate(0, 0, -1)
@@ -138,6 +190,11 @@ func GetPeriodStartDate(period string) time.Time {
 		return now.AddDate(-5, 0, 0)
 	case PeriodYTD:
 		return time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case PeriodAll:
+		if !created.IsZero() && created.Before(now) {
+			return created
+		}
+		return now.AddDate(-1, 0, 0)
 	default:
 		return now.AddDate(-1, 0, 0)
 	}
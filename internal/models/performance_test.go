@@ -90,6 +90,35 @@ func TestGetPeriodStartDate(t *testing.T) {
 	}
 }
 
+func TestPeriodDuration_YTDAndAll(t *testing.T) {
+	now := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2022, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		period    string
+		created   time.Time
+		wantStart time.Time
+		wantDays  float64
+	}{
+		{"ytd", PeriodYTD, created, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 182.5},
+		{"all since creation", PeriodAll, created, created, 731},
+		{"all without creation date", PeriodAll, time.Time{}, now.AddDate(-1, 0, 0), 366},
+		{"all created in the future", PeriodAll, now.Add(time.Hour), now.AddDate(-1, 0, 0), 366},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if start := PeriodStartDate(tt.period, now, tt.created); !start.Equal(tt.wantStart) {
+				t.Errorf("PeriodStartDate = %v, want %v", start, tt.wantStart)
+			}
+			if days := PeriodDuration(tt.period, now, tt.created).Hours() / 24; days != tt.wantDays {
+				t.Errorf("PeriodDuration = %v days, want %v", days, tt.wantDays)
+			}
+		})
+	}
+}
+
 func TestPeriodConstants(t *testing.T) {
 	// Verify period constants are defined correctly
 	periods := []string{
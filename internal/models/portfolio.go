@@ -1,6 +1,8 @@
 package models
 
 import (
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,8 +17,12 @@ type Portfolio struct {
 	Holdings    []Holding       `json:"holdings,omitempty"`
 	TotalValue  decimal.Decimal `json:"total_value"`
 	FreeCash    decimal.Decimal `json:"free_cash"`
+	Currency    string          `json:"currency"` // Base currency holdings are valued in
 	LastUpdated time.Time       `json:"last_updated"`
 	CreatedAt   time.Time       `json:"created_at"`
+
+	// TargetScenarioID is the saved scenario drift is measured against
+	TargetScenarioID *uuid.UUID `json:"target_scenario_id,omitempty"`
 }
 
 // NewPortfolio creates a new portfolio with generated ID
@@ -29,12 +35,22 @@ func NewPortfolio(userID uuid.UUID, name string) *Portfolio {
 		Holdings:    []Holding{},
 		TotalValue:  decimal.Zero,
 		FreeCash:    decimal.Zero,
+		Currency:    DefaultCurrency,
 		LastUpdated: now,
 		CreatedAt:   now,
 	}
 }
 
-// CalculateTotals recalculates TotalValue and FreeCash from holdings
+// CurrencyCode returns the portfolio's base currency, defaulting to USD
+func (p *Portfolio) CurrencyCode() string {
+	if p.Currency == "" {
+		return DefaultCurrency
+	}
+	return strings.ToUpper(p.Currency)
+}
+
+// CalculateTotals recalculates TotalValue and FreeCash from holdings. Market
+// values are already converted into the base currency, so they sum directly.
 func (p *Portfolio) CalculateTotals() {
 	total := decimal.Zero
 	cash := decimal.Zero
@@ -59,6 +75,7 @@ type AllocationSummary struct {
 	ByAccount    map[string]AllocationSlice     `json:"by_account"`
 	TopHoldings  []HoldingSummary               `json:"top_holdings"`
 	TickerTotals map[string]decimal.Decimal     `json:"ticker_totals"`
+	LookThrough  bool                           `json:"look_through"` // Funds spread across their sectors and geographies
 }
 
 // AllocationSlice represents a portion of the portfolio
@@ -77,8 +94,26 @@ type HoldingSummary struct {
 	AssetClass  AssetClass      `json:"asset_class"`
 }
 
-// CalculateAllocation computes the full allocation breakdown
+// DisplayTicker returns the ticker without its exchange suffix
+func (h HoldingSummary) DisplayTicker() string {
+	return DisplayTicker(h.Ticker)
+}
+
+// CalculateAllocation computes the full allocation breakdown, classifying
+// each holding by its own sector and geography
 func (p *Portfolio) CalculateAllocation() *AllocationSummary {
+	return p.calculateAllocation(false)
+}
+
+// CalculateLookThroughAllocation computes the allocation breakdown with each
+// fund in FundExposures spread across the sectors and geographies it holds,
+// so a total-market fund counts toward Technology and US rather than
+// Diversified
+func (p *Portfolio) CalculateLookThroughAllocation() *AllocationSummary {
+	return p.calculateAllocation(true)
+}
+
+func (p *Portfolio) calculateAllocation(lookThrough bool) *AllocationSummary {
 	summary := &AllocationSummary{
 		ByAssetClass: make(map[AssetClass]AllocationSlice),
 		BySector:     make(map[string]AllocationSlice),
@@ -86,6 +121,7 @@ func (p *Portfolio) CalculateAllocation() *AllocationSummary {
 		ByAccount:    make(map[string]AllocationSlice),
 		TopHoldings:  []HoldingSummary{},
 		TickerTotals: make(map[string]decimal.Decimal),
+		LookThrough:  lookThrough,
 	}
 
 	if p.TotalValue.IsZero() {
@@ -100,20 +136,24 @@ func (p *Portfolio) CalculateAllocation() *AllocationSummary {
 		slice.Count++
 		summary.ByAssetClass[h.AssetClass] = slice
 
-		// By sector
-		if h.Sector != "" {
-			slice := summary.BySector[h.Sector]
-			slice.Value = slice.Value.Add(h.MarketValue)
-			slice.Count++
-			summary.BySector[h.Sector] = slice
-		}
-
-		// By geography
-		if h.Geography != "" {
-			slice := summary.ByGeography[h.Geography]
-			slice.Value = slice.Value.Add(h.MarketValue)
-			slice.Count++
-			summary.ByGeography[h.Geography] = slice
+		// By sector and geography, through the fund when looking through
+		exposure, isFund := h.LookThrough()
+		if lookThrough && isFund {
+			spreadAllocation(summary.BySector, exposure.Sectors, h.MarketValue)
+			spreadAllocation(summary.ByGeography, exposure.Geographies, h.MarketValue)
+		} else {
+			if h.Sector != "" {
+				slice := summary.BySector[h.Sector]
+				slice.Value = slice.Value.Add(h.MarketValue)
+				slice.Count++
+				summary.BySector[h.Sector] = slice
+			}
+			if h.Geography != "" {
+				slice := summary.ByGeography[h.Geography]
+				slice.Value = slice.Value.Add(h.MarketValue)
+				slice.Count++
+				summary.ByGeography[h.Geography] = slice
+			}
 		}
 
 		// By account
@@ -151,17 +191,38 @@ func (p *Portfolio) CalculateAllocation() *AllocationSummary {
 	return summary
 }
 
-// getTopHoldings returns the top N holdings by market value
+// spreadAllocation divides a holding's value across slices by percentage
+// weight. The holding counts once toward every slice it reaches.
+func spreadAllocation(slices map[string]AllocationSlice, weights map[string]decimal.Decimal, value decimal.Decimal) {
+	hundred := decimal.NewFromInt(100)
+	for name, weight := range weights {
+		slice := slices[name]
+		slice.Value = slice.Value.Add(value.Mul(weight).Div(hundred))
+		slice.Count++
+		slices[name] = slice
+	}
+}
+
+// getTopHoldings returns the top N holdings by market value. A ticker held
+// in several accounts takes its asset class from a classified holding and
+// the longest of its names, since brokers describe the same security
+// differently.
 func (p *Portfolio) getTopHoldings(n int) []HoldingSummary {
 	// Aggregate by ticker first
 	tickerHoldings := make(map[string]*HoldingSummary)
 	for _, h := range p.Holdings {
 		if existing, ok := tickerHoldings[h.Ticker]; ok {
 			existing.MarketValue = existing.MarketValue.Add(h.MarketValue)
+			if existing.AssetClass == AssetClassOther && !h.NeedsClassification() {
+				existing.AssetClass = h.AssetClass
+			}
+			if name := strings.TrimSpace(h.Name); len(name) > len(existing.Name) {
+				existing.Name = name
+			}
 		} else {
 			tickerHoldings[h.Ticker] = &HoldingSummary{
 				Ticker:      h.Ticker,
-				Name:        h.Name,
+				Name:        strings.TrimSpace(h.Name),
 				MarketValue: h.MarketValue,
 				AssetClass:  h.AssetClass,
 			}
@@ -177,14 +238,13 @@ func (p *Portfolio) getTopHoldings(n int) []HoldingSummary {
 		holdings = append(holdings, *h)
 	}
 
-	// Simple bubble sort (sufficient for small n)
-	for i := 0; i < len(holdings); i++ {
-		for j := i + 1; j < len(holdings); j++ {
-			if holdings[j].MarketValue.GreaterThan(holdings[i].MarketValue) {
-				holdings[i], holdings[j] = holdings[j], holdings[i]
-			}
+	// Largest first, by ticker on ties so the order doesn't depend on map iteration
+	sort.Slice(holdings, func(i, j int) bool {
+		if c := holdings[i].MarketValue.Cmp(holdings[j].MarketValue); c != 0 {
+			return c > 0
 		}
-	}
+		return holdings[i].Ticker < holdings[j].Ticker
+	})
 
 	if len(holdings) > n {
 		holdings = holdings[:n]
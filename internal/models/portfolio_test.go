@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/google/uuid"
@@ -133,6 +134,69 @@ func TestPortfolio_CalculateAllocation(t *testing.T) {
 	}
 }
 
+func TestPortfolio_CalculateLookThroughAllocation(t *testing.T) {
+	p := &Portfolio{
+		ID:         uuid.New(),
+		TotalValue: decimal.NewFromInt(100000),
+		Holdings: []Holding{
+			{Ticker: "VTI", MarketValue: decimal.NewFromInt(60000), AssetClass: AssetClassEquity, Sector: "Diversified", Geography: "US"},
+			{Ticker: "VXUS", MarketValue: decimal.NewFromInt(20000), AssetClass: AssetClassEquity, Sector: "Diversified", Geography: "International Developed"},
+			{Ticker: "AAPL", MarketValue: decimal.NewFromInt(20000), AssetClass: AssetClassEquity, Sector: "Technology", Geography: "US"},
+		},
+	}
+
+	plain := p.CalculateAllocation()
+	if plain.LookThrough || !plain.BySector["Diversified"].Percentage.Equal(decimal.NewFromInt(80)) {
+		t.Errorf("Plain Diversified: got %s, want 80", plain.BySector["Diversified"].Percentage)
+	}
+
+	alloc := p.CalculateLookThroughAllocation()
+	if !alloc.LookThrough {
+		t.Error("Expected LookThrough to be set")
+	}
+	if _, ok := alloc.BySector["Diversified"]; ok {
+		t.Error("Funds should be spread out of Diversified")
+	}
+
+	// 30% of VTI and 13% of VXUS are tech, plus all of AAPL
+	wantTech := decimal.NewFromFloat(40.6)
+	if got := alloc.BySector["Technology"]; !got.Percentage.Equal(wantTech) || got.Count != 3 {
+		t.Errorf("Technology: got %s%% across %d holdings, want %s%% across 3", got.Percentage, got.Count, wantTech)
+	}
+	if got := alloc.ByGeography["US"].Percentage; !got.Equal(decimal.NewFromInt(80)) {
+		t.Errorf("US: got %s, want 80", got)
+	}
+	if got := alloc.ByGeography["Japan"].Percentage; !got.Equal(decimal.NewFromInt(3)) {
+		t.Errorf("Japan: got %s, want 3", got)
+	}
+
+	// Asset classes aren't affected
+	if !alloc.ByAssetClass[AssetClassEquity].Percentage.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("Equity: got %s, want 100", alloc.ByAssetClass[AssetClassEquity].Percentage)
+	}
+
+	// A hand-classified fund is taken as tagged
+	p.Holdings[0].IsManualEntry = true
+	if got := p.CalculateLookThroughAllocation().BySector["Diversified"].Percentage; !got.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Manual Diversified: got %s, want 60", got)
+	}
+}
+
+func TestFundExposures_SumTo100(t *testing.T) {
+	hundred := decimal.NewFromInt(100)
+	for ticker, exposure := range FundExposures {
+		for name, weights := range map[string]map[string]decimal.Decimal{"sectors": exposure.Sectors, "geographies": exposure.Geographies} {
+			total := decimal.Zero
+			for _, w := range weights {
+				total = total.Add(w)
+			}
+			if !total.Equal(hundred) {
+				t.Errorf("%s %s: sum to %s, want 100", ticker, name, total)
+			}
+		}
+	}
+}
+
 func TestPortfolio_CalculateAllocation_Empty(t *testing.T) {
 	p := &Portfolio{
 		ID:         uuid.New(),
@@ -177,6 +241,47 @@ func TestPortfolio_getTopHoldings(t *testing.T) {
 	}
 }
 
+func BenchmarkPortfolio_getTopHoldings(b *testing.B) {
+	p := &Portfolio{ID: uuid.New(), TotalValue: decimal.NewFromInt(1000000)}
+	for i := 0; i < 500; i++ {
+		p.Holdings = append(p.Holdings, Holding{
+			Ticker:      fmt.Sprintf("T%03d", i),
+			MarketValue: decimal.NewFromInt(int64((i * 7919) % 10007)),
+		})
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.getTopHoldings(10)
+	}
+}
+
+func TestPortfolio_getTopHoldings_PrefersClassified(t *testing.T) {
+	p := &Portfolio{
+		ID:         uuid.New(),
+		TotalValue: decimal.NewFromFloat(30000.00),
+		Holdings: []Holding{
+			{Ticker: "AAPL", Name: "APPLE INC", AssetClass: AssetClassOther, MarketValue: decimal.NewFromFloat(10000.00)},
+			{Ticker: "AAPL", Name: "Apple Inc. Common Stock", AssetClass: AssetClassEquity, MarketValue: decimal.NewFromFloat(20000.00)},
+			{Ticker: "AAPL", Name: "", AssetClass: AssetClassOther, MarketValue: decimal.NewFromFloat(0)},
+		},
+	}
+
+	top := p.getTopHoldings(10)
+	if len(top) != 1 {
+		t.Fatalf("Expected AAPL aggregated into 1 holding, got %d", len(top))
+	}
+	if top[0].AssetClass != AssetClassEquity {
+		t.Errorf("Expected the classified asset class %s, got %s", AssetClassEquity, top[0].AssetClass)
+	}
+	if top[0].Name != "Apple Inc. Common Stock" {
+		t.Errorf("Expected the longest name, got %q", top[0].Name)
+	}
+	if !top[0].MarketValue.Equal(decimal.NewFromFloat(30000.00)) {
+		t.Errorf("Expected market value 30000, got %s", top[0].MarketValue)
+	}
+}
+
 func TestPortfolio_AggregatesSameTicker(t *testing.T) {
 	p := &Portfolio{
 		ID:         uuid.New(),
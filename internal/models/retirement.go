@@ -0,0 +1,47 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// AssumedInflation is the annual inflation rate, in percent, that
+// retirement projections take out of returns so spending stays in today's
+// dollars
+var AssumedInflation = decimal.NewFromFloat(2.5)
+
+// DefaultRetirementYears is how long retirement savings must last when a
+// plan doesn't say
+const DefaultRetirementYears = 30
+
+// RetirementConfidence is the share of simulated markets, in percent, a
+// safe withdrawal must survive
+const RetirementConfidence = 90
+
+// RetirementPlan is a savings and spending plan to test. Amounts are in
+// today's dollars.
+type RetirementPlan struct {
+	CurrentValue       decimal.Decimal `json:"current_value"`
+	AnnualContribution decimal.Decimal `json:"annual_contribution"` // Saved each year until retirement
+	AnnualSpending     decimal.Decimal `json:"annual_spending"`     // Withdrawn each year of retirement
+	YearsToRetirement  int             `json:"years_to_retirement"`
+	RetirementYears    int             `json:"retirement_years"` // How long the money must last
+}
+
+// RetirementProjection is how a plan fares across simulated markets
+type RetirementProjection struct {
+	Plan   RetirementPlan `json:"plan"`
+	Trials int            `json:"trials"`
+
+	// SuccessProbability is the percentage of trials where the money lasts
+	SuccessProbability decimal.Decimal `json:"success_probability"`
+
+	// SafeWithdrawal is the most that can be spent each year of retirement
+	// and still last in RetirementConfidence percent of trials
+	SafeWithdrawal decimal.Decimal `json:"safe_withdrawal"`
+
+	MedianAtRetirement decimal.Decimal `json:"median_at_retirement"`
+	MedianEndingValue  decimal.Decimal `json:"median_ending_value"` // After spending AnnualSpending throughout
+
+	ExpectedReturn decimal.Decimal `json:"expected_return"` // Annual %, after inflation
+	Volatility     decimal.Decimal `json:"volatility"`      // Annual %
+}
@@ -36,6 +36,7 @@ type RiskRewardMetrics struct {
 	// Risk metrics
 	Volatility        decimal.Decimal `json:"volatility"`         // Annualized std dev
 	DownsideDeviation decimal.Decimal `json:"downside_deviation"` // Downside volatility
+	DownsideEstimated bool            `json:"downside_estimated"` // Approximated from volatility, not measured
 	MaxDrawdown       decimal.Decimal `json:"max_drawdown"`
 	VaR95             decimal.Decimal `json:"var_95"`             // Value at Risk 95%
 
@@ -128,9 +129,58 @@ var BenchmarkReturns = map[string]RiskRewardMetrics{
 	},
 }
 
+// TickerBetas holds published 5-year betas against the S&P 500 for common
+// tickers, used when there is no price history to regress on
+var TickerBetas = map[string]decimal.Decimal{
+	// Broad market and international equity ETFs
+	"SPY":  decimal.NewFromFloat(1.00),
+	"VOO":  decimal.NewFromFloat(1.00),
+	"IVV":  decimal.NewFromFloat(1.00),
+	"VTI":  decimal.NewFromFloat(1.02),
+	"QQQ":  decimal.NewFromFloat(1.18),
+	"VEA":  decimal.NewFromFloat(0.86),
+	"VXUS": decimal.NewFromFloat(0.85),
+	"VWO":  decimal.NewFromFloat(0.78),
+
+	// Bond ETFs
+	"BND": decimal.NewFromFloat(0.05),
+	"AGG": decimal.NewFromFloat(0.05),
+	"TLT": decimal.NewFromFloat(0.15),
+	"IEF": decimal.NewFromFloat(0.05),
+	"SHY": decimal.NewFromFloat(0.01),
+	"TIP": decimal.NewFromFloat(0.10),
+
+	// Alternatives
+	"VNQ":  decimal.NewFromFloat(0.95),
+	"GLD":  decimal.NewFromFloat(0.12),
+	"GBTC": decimal.NewFromFloat(1.60),
+
+	// Large-cap stocks
+	"AAPL":  decimal.NewFromFloat(1.24),
+	"MSFT":  decimal.NewFromFloat(0.90),
+	"GOOGL": decimal.NewFromFloat(1.05),
+	"GOOG":  decimal.NewFromFloat(1.05),
+	"AMZN":  decimal.NewFromFloat(1.15),
+	"NVDA":  decimal.NewFromFloat(1.68),
+	"META":  decimal.NewFromFloat(1.21),
+	"TSLA":  decimal.NewFromFloat(2.30),
+	"BRK.B": decimal.NewFromFloat(0.88),
+	"JPM":   decimal.NewFromFloat(1.10),
+	"V":     decimal.NewFromFloat(0.95),
+	"JNJ":   decimal.NewFromFloat(0.52),
+	"UNH":   decimal.NewFromFloat(0.58),
+	"XOM":   decimal.NewFromFloat(0.88),
+	"PG":    decimal.NewFromFloat(0.41),
+	"MA":    decimal.NewFromFloat(1.08),
+	"HD":    decimal.NewFromFloat(1.01),
+	"CVX":   decimal.NewFromFloat(1.07),
+	"MRK":   decimal.NewFromFloat(0.39),
+	"ABBV":  decimal.NewFromFloat(0.58),
+}
+
 // DefaultRiskMetrics returns default metrics for an asset class
 func DefaultRiskMetrics(class AssetClass) RiskRewardMetrics {
-	stats := AssetClassReturns[class]
+	stats := ReturnStats(class)
 
 	sharpe := decimal.Zero
 	if !stats.Volatility.IsZero() {
@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +16,10 @@ type Scenario struct {
 	PortfolioID uuid.UUID                    `json:"portfolio_id"`
 	Name        string                       `json:"name"`
 	Allocations map[AssetClass]decimal.Decimal `json:"allocations"` // Target percentages
+	// Optional sector and geography targets, as percentages summing to 100.
+	// They're compared against but don't change projections.
+	SectorTargets map[string]decimal.Decimal `json:"sector_targets,omitempty"`
+	GeoTargets    map[string]decimal.Decimal `json:"geo_targets,omitempty"`
 	Projections ScenarioProjections          `json:"projections"`
 	CreatedAt   time.Time                    `json:"created_at"`
 }
@@ -56,6 +63,91 @@ func (s *Scenario) IsValid() bool {
 	return s.TotalAllocation().Equal(decimal.NewFromInt(100))
 }
 
+// AllocationError is a problem with one field of a scenario's allocations
+type AllocationError struct {
+	Field   string `json:"field"` // Asset class, or "total" for the sum; sector and geography fields are prefixed "sector:" and "geography:"
+	Message string `json:"message"`
+}
+
+// CheckAllocations reports allocations to unknown asset classes and
+// negative percentages, in asset class order. These are never simulated.
+func (s *Scenario) CheckAllocations() []AllocationError {
+	known := make(map[AssetClass]bool)
+	for _, class := range AllAssetClasses() {
+		known[class] = true
+	}
+
+	var unknown []string
+	for class := range s.Allocations {
+		if !known[class] {
+			unknown = append(unknown, string(class))
+		}
+	}
+	sort.Strings(unknown)
+
+	var errs []AllocationError
+	for _, class := range unknown {
+		errs = append(errs, AllocationError{Field: class, Message: "unknown asset class"})
+	}
+	for _, class := range AllAssetClasses() {
+		if pct, ok := s.Allocations[class]; ok && pct.IsNegative() {
+			errs = append(errs, AllocationError{Field: string(class), Message: "can't be negative"})
+		}
+	}
+	errs = append(errs, checkTargets("sector", s.SectorTargets)...)
+	errs = append(errs, checkTargets("geography", s.GeoTargets)...)
+	return errs
+}
+
+// checkTargets reports unnamed and negative sector or geography targets,
+// in name order
+func checkTargets(dimension string, targets map[string]decimal.Decimal) []AllocationError {
+	var errs []AllocationError
+	for _, name := range sortedKeys(targets) {
+		switch {
+		case strings.TrimSpace(name) == "":
+			errs = append(errs, AllocationError{Field: dimension + ":", Message: "needs a name"})
+		case targets[name].IsNegative():
+			errs = append(errs, AllocationError{Field: dimension + ":" + name, Message: "can't be negative"})
+		}
+	}
+	return errs
+}
+
+// Validate is CheckAllocations plus the allocations summing to 100%, which
+// a scenario needs before it's saved
+func (s *Scenario) Validate() []AllocationError {
+	errs := s.CheckAllocations()
+	if !s.IsValid() {
+		errs = append(errs, AllocationError{
+			Field:   "total",
+			Message: fmt.Sprintf("sums to %s%%, not 100%%", s.TotalAllocation()),
+		})
+	}
+	errs = append(errs, checkTargetsTotal("sector", s.SectorTargets)...)
+	errs = append(errs, checkTargetsTotal("geography", s.GeoTargets)...)
+	return errs
+}
+
+// checkTargetsTotal reports sector or geography targets that don't sum to
+// 100%. Having none is fine.
+func checkTargetsTotal(dimension string, targets map[string]decimal.Decimal) []AllocationError {
+	if len(targets) == 0 {
+		return nil
+	}
+	total := decimal.Zero
+	for _, pct := range targets {
+		total = total.Add(pct)
+	}
+	if total.Equal(decimal.NewFromInt(100)) {
+		return nil
+	}
+	return []AllocationError{{
+		Field:   dimension + "_total",
+		Message: fmt.Sprintf("sums to %s%%, not 100%%", total),
+	}}
+}
+
 // Historical return assumptions by asset class (annualized)
 // Based on long-term historical averages
 var AssetClassReturns = map[AssetClass]AssetClassStats{
@@ -77,6 +169,18 @@ var AssetClassReturns = map[AssetClass]AssetClassStats{
 		Average:     decimal.NewFromFloat(8.0),
 		Volatility:  decimal.NewFromFloat(12.0),
 	},
+	AssetClassCommodity: {
+		BestYear:    decimal.NewFromFloat(40.0),
+		WorstYear:   decimal.NewFromFloat(-35.0),
+		Average:     decimal.NewFromFloat(5.0),
+		Volatility:  decimal.NewFromFloat(18.0),
+	},
+	AssetClassDerivative: {
+		BestYear:    decimal.NewFromFloat(100.0),
+		WorstYear:   decimal.NewFromFloat(-100.0), // Options can expire worthless
+		Average:     decimal.NewFromFloat(2.0),
+		Volatility:  decimal.NewFromFloat(60.0),
+	},
 	AssetClassCrypto: {
 		BestYear:    decimal.NewFromFloat(300.0),
 		WorstYear:   decimal.NewFromFloat(-75.0),
@@ -105,6 +209,22 @@ type AssetClassStats struct {
 	Volatility decimal.Decimal `json:"volatility"`
 }
 
+// CashYield is the prevailing money-market yield, in percent, before fund
+// expenses. Cash returns follow current rates rather than their long-run
+// average, so this replaces it in return estimates.
+var CashYield = decimal.NewFromFloat(4.5) // As of late 2024
+
+// ReturnStats returns the return assumptions for an asset class. Cash is
+// expected to earn CashYield net of the default money-market expense ratio.
+func ReturnStats(class AssetClass) AssetClassStats {
+	stats := AssetClassReturns[class]
+	if class == AssetClassCash {
+		stats.Average = CashYield.Sub(DefaultExpenseRatios[AssetClassCash])
+		stats.BestYear = decimal.Max(stats.BestYear, stats.Average)
+	}
+	return stats
+}
+
 // CalculateProjections computes projected returns based on allocations
 func (s *Scenario) CalculateProjections(currentValue decimal.Decimal) {
 	hundred := decimal.NewFromInt(100)
@@ -115,7 +235,7 @@ func (s *Scenario) CalculateProjections(currentValue decimal.Decimal) {
 
 	for class, allocation := range s.Allocations {
 		weight := allocation.Div(hundred)
-		stats := AssetClassReturns[class]
+		stats := ReturnStats(class)
 
 		bestCase = bestCase.Add(stats.BestYear.Mul(weight))
 		worstCase = worstCase.Add(stats.WorstYear.Mul(weight))
@@ -139,10 +259,29 @@ type ScenarioComparison struct {
 	Target    map[AssetClass]decimal.Decimal `json:"target"`
 	Changes   map[AssetClass]decimal.Decimal `json:"changes"` // Difference
 	Rebalance map[AssetClass]decimal.Decimal `json:"rebalance"` // Dollar amounts to move
+
+	// Set when the scenario has sector or geography targets
+	Sectors     *DimensionComparison `json:"sectors,omitempty"`
+	Geographies *DimensionComparison `json:"geographies,omitempty"`
+}
+
+// DimensionComparison compares current sector or geography weights to a
+// scenario's targets for them. Anything held but not targeted has a target
+// of zero.
+type DimensionComparison struct {
+	Current   map[string]decimal.Decimal `json:"current"`
+	Target    map[string]decimal.Decimal `json:"target"`
+	Changes   map[string]decimal.Decimal `json:"changes"`
+	Rebalance map[string]decimal.Decimal `json:"rebalance"`
 }
 
 // Compare creates a comparison between current allocation and scenario target
-func (s *Scenario) Compare(current map[AssetClass]decimal.Decimal, totalValue decimal.Decimal) *ScenarioComparison {
+func (s *Scenario) Compare(allocation *AllocationSummary, totalValue decimal.Decimal) *ScenarioComparison {
+	current := make(map[AssetClass]decimal.Decimal)
+	for class, slice := range allocation.ByAssetClass {
+		current[class] = slice.Percentage
+	}
+
 	comparison := &ScenarioComparison{
 		Current:   current,
 		Target:    s.Allocations,
@@ -160,5 +299,49 @@ func (s *Scenario) Compare(current map[AssetClass]decimal.Decimal, totalValue de
 		comparison.Rebalance[class] = totalValue.Mul(diff).Div(hundred).Round(2)
 	}
 
+	if len(s.SectorTargets) > 0 {
+		comparison.Sectors = compareDimension(allocation.BySector, s.SectorTargets, totalValue)
+	}
+	if len(s.GeoTargets) > 0 {
+		comparison.Geographies = compareDimension(allocation.ByGeography, s.GeoTargets, totalValue)
+	}
+
+	return comparison
+}
+
+func compareDimension(slices map[string]AllocationSlice, targets map[string]decimal.Decimal, totalValue decimal.Decimal) *DimensionComparison {
+	comparison := &DimensionComparison{
+		Current:   make(map[string]decimal.Decimal),
+		Target:    targets,
+		Changes:   make(map[string]decimal.Decimal),
+		Rebalance: make(map[string]decimal.Decimal),
+	}
+
+	hundred := decimal.NewFromInt(100)
+
+	for name, slice := range slices {
+		comparison.Current[name] = slice.Percentage
+	}
+	compare := func(name string) {
+		diff := targets[name].Sub(comparison.Current[name])
+		comparison.Changes[name] = diff.Round(2)
+		comparison.Rebalance[name] = totalValue.Mul(diff).Div(hundred).Round(2)
+	}
+	for name := range targets {
+		compare(name)
+	}
+	for name := range comparison.Current {
+		compare(name)
+	}
+
 	return comparison
 }
+
+func sortedKeys(m map[string]decimal.Decimal) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/google/uuid"
@@ -106,6 +107,95 @@ func TestScenario_IsValid(t *testing.T) {
 	}
 }
 
+func TestScenario_Validate(t *testing.T) {
+	s := NewScenario(uuid.New(), "Test")
+	s.SetAllocation(AssetClassEquity, decimal.NewFromInt(120))
+	s.SetAllocation(AssetClassCash, decimal.NewFromInt(-20))
+	s.SetAllocation("gold", decimal.NewFromInt(10))
+
+	want := []AllocationError{
+		{Field: "gold", Message: "unknown asset class"},
+		{Field: "cash", Message: "can't be negative"},
+	}
+	if got := s.CheckAllocations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckAllocations got %+v, want %+v", got, want)
+	}
+
+	want = append(want, AllocationError{Field: "total", Message: "sums to 110%, not 100%"})
+	if got := s.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate got %+v, want %+v", got, want)
+	}
+
+	valid := NewScenario(uuid.New(), "Valid")
+	valid.SetAllocation(AssetClassEquity, decimal.NewFromInt(100))
+	valid.SetAllocation(AssetClassCash, decimal.Zero)
+	if errs := valid.Validate(); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %+v", errs)
+	}
+}
+
+func TestScenario_ValidateTargets(t *testing.T) {
+	s := NewScenario(uuid.New(), "Tilted")
+	s.SetAllocation(AssetClassEquity, decimal.NewFromInt(100))
+	s.SectorTargets = map[string]decimal.Decimal{
+		"Technology": decimal.NewFromInt(50),
+		"Healthcare": decimal.NewFromInt(-10),
+		" ":          decimal.NewFromInt(20),
+	}
+	s.GeoTargets = map[string]decimal.Decimal{"US": decimal.NewFromInt(60), "International": decimal.NewFromInt(40)}
+
+	want := []AllocationError{
+		{Field: "sector:", Message: "needs a name"},
+		{Field: "sector:Healthcare", Message: "can't be negative"},
+		{Field: "sector_total", Message: "sums to 60%, not 100%"},
+	}
+	if got := s.Validate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate got %+v, want %+v", got, want)
+	}
+
+	s.SectorTargets = map[string]decimal.Decimal{"Technology": decimal.NewFromInt(30), "Healthcare": decimal.NewFromInt(70)}
+	if errs := s.Validate(); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %+v", errs)
+	}
+}
+
+func TestScenario_CompareTargets(t *testing.T) {
+	s := NewScenario(uuid.New(), "Tilted")
+	s.SetAllocation(AssetClassEquity, decimal.NewFromInt(100))
+	s.SectorTargets = map[string]decimal.Decimal{"Technology": decimal.NewFromInt(40), "Healthcare": decimal.NewFromInt(60)}
+
+	current := &AllocationSummary{
+		ByAssetClass: map[AssetClass]AllocationSlice{AssetClassEquity: {Percentage: decimal.NewFromInt(100)}},
+		BySector: map[string]AllocationSlice{
+			"Technology": {Percentage: decimal.NewFromInt(70)},
+			"Energy":     {Percentage: decimal.NewFromInt(30)},
+		},
+		ByGeography: map[string]AllocationSlice{"US": {Percentage: decimal.NewFromInt(100)}},
+	}
+	comparison := s.Compare(current, decimal.NewFromInt(100000))
+
+	if comparison.Geographies != nil {
+		t.Errorf("Expected no geography comparison without targets, got %+v", comparison.Geographies)
+	}
+	if comparison.Sectors == nil {
+		t.Fatal("Expected a sector comparison")
+	}
+
+	// Energy isn't targeted, so all of it is to be sold
+	want := map[string]string{"Technology": "-30000", "Healthcare": "60000", "Energy": "-30000"}
+	if len(comparison.Sectors.Rebalance) != len(want) {
+		t.Errorf("Expected %d sectors, got %+v", len(want), comparison.Sectors.Rebalance)
+	}
+	for sector, amount := range want {
+		if got := comparison.Sectors.Rebalance[sector]; !got.Equal(decimal.RequireFromString(amount)) {
+			t.Errorf("%s rebalance: got %s, want %s", sector, got, amount)
+		}
+	}
+	if got := comparison.Sectors.Changes["Healthcare"]; !got.Equal(decimal.NewFromInt(60)) {
+		t.Errorf("Healthcare change: got %s, want 60", got)
+	}
+}
+
 func TestScenario_CalculateProjections(t *testing.T) {
 	s := NewScenario(uuid.New(), "Balanced")
 	s.SetAllocation(AssetClassEquity, decimal.NewFromInt(60))
@@ -148,11 +238,11 @@ func TestScenario_Compare(t *testing.T) {
 	s.SetAllocation(AssetClassFixedIncome, decimal.NewFromInt(20))
 	s.SetAllocation(AssetClassCash, decimal.NewFromInt(10))
 
-	current := map[AssetClass]decimal.Decimal{
-		AssetClassEquity:      decimal.NewFromInt(60),
-		AssetClassFixedIncome: decimal.NewFromInt(30),
-		AssetClassCash:        decimal.NewFromInt(10),
-	}
+	current := &AllocationSummary{ByAssetClass: map[AssetClass]AllocationSlice{
+		AssetClassEquity:      {Percentage: decimal.NewFromInt(60)},
+		AssetClassFixedIncome: {Percentage: decimal.NewFromInt(30)},
+		AssetClassCash:        {Percentage: decimal.NewFromInt(10)},
+	}}
 
 	totalValue := decimal.NewFromFloat(1000000.00)
 	comparison := s.Compare(current, totalValue)
@@ -182,6 +272,30 @@ func TestScenario_Compare(t *testing.T) {
 	}
 }
 
+func TestReturnStats_CashYield(t *testing.T) {
+	defer func(yield decimal.Decimal) { CashYield = yield }(CashYield)
+	CashYield = decimal.NewFromFloat(5.4)
+
+	// Cash earns the yield after the money-market fund's expenses
+	stats := ReturnStats(AssetClassCash)
+	if want := decimal.NewFromFloat(5.0); !stats.Average.Equal(want) {
+		t.Errorf("Cash average: got %s, want %s", stats.Average, want)
+	}
+	if stats.BestYear.LessThan(stats.Average) {
+		t.Errorf("Cash best year %s is below its average %s", stats.BestYear, stats.Average)
+	}
+	if equity := ReturnStats(AssetClassEquity); !equity.Average.Equal(AssetClassReturns[AssetClassEquity].Average) {
+		t.Errorf("Equity average changed to %s", equity.Average)
+	}
+
+	s := NewScenario(uuid.New(), "All cash")
+	s.SetAllocation(AssetClassCash, decimal.NewFromInt(100))
+	s.CalculateProjections(decimal.NewFromInt(10000))
+	if want := decimal.NewFromInt(10500); !s.Projections.ExpectedValue.Equal(want) {
+		t.Errorf("Expected value: got %s, want %s", s.Projections.ExpectedValue, want)
+	}
+}
+
 func TestAssetClassReturns(t *testing.T) {
 	// Verify all asset classes have return statistics
 	for _, class := range AllAssetClasses() {
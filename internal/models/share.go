@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareRole is what a collaborator may do with a shared portfolio
+type ShareRole string
+
+const (
+	// ShareRoleViewer can see the portfolio and its analytics
+	ShareRoleViewer ShareRole = "viewer"
+	// ShareRoleEditor can also import, refresh prices, reclassify holdings
+	// and manage scenarios
+	ShareRoleEditor ShareRole = "editor"
+)
+
+// ParseShareRole validates a role string, defaulting to viewer when empty
+func ParseShareRole(s string) (ShareRole, error) {
+	switch role := ShareRole(strings.ToLower(strings.TrimSpace(s))); role {
+	case "":
+		return ShareRoleViewer, nil
+	case ShareRoleViewer, ShareRoleEditor:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown share role %q", s)
+	}
+}
+
+// PortfolioShare gives another user access to a portfolio
+type PortfolioShare struct {
+	PortfolioID uuid.UUID `json:"portfolio_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	Email       string    `json:"email"`
+	Name        string    `json:"name"`
+	Role        ShareRole `json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+}
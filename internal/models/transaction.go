@@ -0,0 +1,112 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TransactionType is whether shares were bought or sold
+type TransactionType string
+
+const (
+	TransactionBuy  TransactionType = "buy"
+	TransactionSell TransactionType = "sell"
+)
+
+// ParseTransactionType validates a transaction type string
+func ParseTransactionType(s string) (TransactionType, error) {
+	switch t := TransactionType(strings.ToLower(strings.TrimSpace(s))); t {
+	case TransactionBuy, TransactionSell:
+		return t, nil
+	default:
+		return "", fmt.Errorf("unknown transaction type %q", s)
+	}
+}
+
+// CostBasisMethod decides which shares a sale is matched against
+type CostBasisMethod string
+
+const (
+	// CostBasisFIFO sells the oldest shares first, at what they cost
+	CostBasisFIFO CostBasisMethod = "fifo"
+	// CostBasisAverage sells shares at the average cost of all those held,
+	// as funds usually report them
+	CostBasisAverage CostBasisMethod = "average"
+)
+
+// ParseCostBasisMethod validates a cost basis method string
+func ParseCostBasisMethod(s string) (CostBasisMethod, error) {
+	switch m := CostBasisMethod(strings.ToLower(strings.TrimSpace(s))); m {
+	case CostBasisFIFO, CostBasisAverage:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cost basis method %q", s)
+	}
+}
+
+// Transaction is one purchase or sale of a security in a portfolio
+type Transaction struct {
+	ID          uuid.UUID       `json:"id"`
+	PortfolioID uuid.UUID       `json:"portfolio_id"`
+	Ticker      string          `json:"ticker"`
+	AccountName string          `json:"account_name"`
+	Type        TransactionType `json:"type"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Price       decimal.Decimal `json:"price"` // Per share
+	Fees        decimal.Decimal `json:"fees"`
+	Date        time.Time       `json:"date"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// NewTransaction creates a new transaction with generated ID
+func NewTransaction(portfolioID uuid.UUID, ticker string, txType TransactionType, quantity, price decimal.Decimal, date time.Time) *Transaction {
+	return &Transaction{
+		ID:          uuid.New(),
+		PortfolioID: portfolioID,
+		Ticker:      ticker,
+		Type:        txType,
+		Quantity:    quantity,
+		Price:       price,
+		Date:        date,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// Amount returns the cash that changed hands: the cost of a purchase
+// including fees, or the proceeds of a sale after them
+func (t *Transaction) Amount() decimal.Decimal {
+	gross := t.Quantity.Mul(t.Price)
+	if t.Type == TransactionSell {
+		return gross.Sub(t.Fees)
+	}
+	return gross.Add(t.Fees)
+}
+
+// RealizedGain is the gain or loss on shares from one purchase that were
+// sold together
+type RealizedGain struct {
+	Ticker      string          `json:"ticker"`
+	AccountName string          `json:"account_name"`
+	AcquiredAt  time.Time       `json:"acquired_at"`
+	SoldAt      time.Time       `json:"sold_at"`
+	Quantity    decimal.Decimal `json:"quantity"`
+	Proceeds    decimal.Decimal `json:"proceeds"`
+	CostBasis   decimal.Decimal `json:"cost_basis"`
+	Gain        decimal.Decimal `json:"gain"`
+	LongTerm    bool            `json:"long_term"` // Held more than a year
+}
+
+// RealizedGainsReport sums a portfolio's realized gains for the year so far
+type RealizedGainsReport struct {
+	Method       CostBasisMethod `json:"method"`
+	Year         int             `json:"year"`
+	Gains        []RealizedGain  `json:"gains"` // Every sale, oldest first
+	YTDGain      decimal.Decimal `json:"ytd_gain"`
+	YTDShortTerm decimal.Decimal `json:"ytd_short_term"`
+	YTDLongTerm  decimal.Decimal `json:"ytd_long_term"`
+	TotalGain    decimal.Decimal `json:"total_gain"` // Since the first transaction
+}
@@ -14,11 +14,20 @@ type User struct {
 	PasswordHash string    `json:"-"` // Never serialize to JSON
 	Name         string    `json:"name"`
 	MFAEnabled   bool      `json:"mfa_enabled"`
-	MFASecret    string    `json:"-"` // Never serialize
+	MFASecret    string    `json:"-"`             // Never serialize
+	AuthProvider string    `json:"auth_provider"` // How the account signs in
+	Verified     bool      `json:"verified"`      // Email address confirmed
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
+// Auth providers. Accounts created through an OAuth provider have no
+// password.
+const (
+	AuthProviderPassword = "password"
+	AuthProviderGoogle   = "google"
+)
+
 // NewUser creates a new user with generated ID and timestamps
 func NewUser(email, name, passwordHash string) *User {
 	now := time.Now().UTC()
@@ -28,6 +37,7 @@ func NewUser(email, name, passwordHash string) *User {
 		Name:         name,
 		PasswordHash: passwordHash,
 		MFAEnabled:   false,
+		AuthProvider: AuthProviderPassword,
 		CreatedAt:    now,
 		UpdatedAt:    now,
 	}
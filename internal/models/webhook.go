@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook is an endpoint that receives a user's new portfolio alerts
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"` // Signs deliveries; only shown at registration
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewWebhook creates a webhook with generated ID
+func NewWebhook(userID uuid.UUID, url, secret string) *Webhook {
+	return &Webhook{
+		ID:        uuid.New(),
+		UserID:    userID,
+		URL:       url,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// WebhookFailure records a delivery that still failed after every retry,
+// so it can be inspected or replayed later
+type WebhookFailure struct {
+	ID        uuid.UUID `json:"id"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	Payload   string    `json:"payload"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+}
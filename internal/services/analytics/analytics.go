@@ -13,12 +13,26 @@ import (
 type Service struct {
 	// Historical data cache (in production, this would come from database/API)
 	priceCache map[string][]models.PriceHistory
+	history    HistorySource // nil to estimate time series from asset class returns
+
+	// transactions dates contributions to the money-weighted return; nil
+	// to date each holding's cost basis from its import
+	transactions TransactionSource
+
+	// expenseRatios is consulted before the curated expense ratios; nil to
+	// use only those
+	expenseRatios ExpenseRatioSource
+
+	// minAcceptableReturn is the annual return, as a fraction, that
+	// downside deviation is measured below
+	minAcceptableReturn decimal.Decimal
 }
 
 // NewService creates a new analytics service
 func NewService() *Service {
 	return &Service{
-		priceCache: make(map[string][]models.PriceHistory),
+		priceCache:          make(map[string][]models.PriceHistory),
+		minAcceptableReturn: models.RiskFreeRate,
 	}
 }
 
@@ -28,15 +42,17 @@ func (s *Service) CalculatePortfolioPerformance(portfolio *models.Portfolio, per
 		return nil
 	}
 
-	// Use historical asset class returns for estimation
+	// Use price history when there is some, otherwise historical asset
+	// class returns for estimation
 	totalValue := portfolio.TotalValue
-	startValue := s.estimateHistoricalValue(portfolio, period)
+	startValue, years := s.periodStartValue(portfolio, period)
 
 	// Calculate total return
 	totalReturn := decimal.Zero
 	if !startValue.IsZero() {
 		totalReturn = totalValue.Sub(startValue).Div(startValue).Mul(decimal.NewFromInt(100))
 	}
+	annualized := annualizedReturn(startValue, totalValue, years)
 
 	// Calculate weighted metrics across holdings
 	var weightedVolatility decimal.Decimal
@@ -47,7 +63,7 @@ func (s *Service) CalculatePortfolioPerformance(portfolio *models.Portfolio, per
 			continue
 		}
 		weight := h.MarketValue.Div(totalValue)
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 
 		weightedReturn = weightedReturn.Add(weight.Mul(stats.Average))
 		weightedVolatility = weightedVolatility.Add(weight.Mul(stats.Volatility))
@@ -66,7 +82,7 @@ func (s *Service) CalculatePortfolioPerformance(portfolio *models.Portfolio, per
 	// Calculate holding contributions
 	holdingPerfs := make([]models.HoldingPerformance, 0, len(portfolio.Holdings))
 	for _, h := range portfolio.Holdings {
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 		holdingReturn := stats.Average
 
 		// Estimate contribution to portfolio return
@@ -93,14 +109,80 @@ func (s *Service) CalculatePortfolioPerformance(portfolio *models.Portfolio, per
 		StartValue:       startValue,
 		EndValue:         totalValue,
 		TotalReturn:      totalReturn.Round(2),
-		AnnualizedReturn: weightedReturn.Round(2),
+		AnnualizedReturn: annualized.Round(2),
 		Volatility:       weightedVolatility.Round(2),
 		SharpeRatio:      sharpeRatio,
 		MaxDrawdown:      maxDrawdown,
 		Holdings:         holdingPerfs,
+
+		MoneyWeightedReturn: s.moneyWeightedReturn(portfolio),
 	}
 }
 
+// CalculateAccountPerformance attributes the portfolio's return to each of
+// its accounts, largest first. Like holding returns, expected returns come
+// from asset class averages; unrealized returns use cost basis where the
+// import included it.
+func (s *Service) CalculateAccountPerformance(portfolio *models.Portfolio) []models.AccountPerformance {
+	if portfolio == nil || len(portfolio.Holdings) == 0 {
+		return nil
+	}
+
+	hundred := decimal.NewFromInt(100)
+	byAccount := portfolio.CalculateAllocation().ByAccount
+	accounts := make(map[string]*models.AccountPerformance)
+	returns := make(map[string]decimal.Decimal)    // Sum of market value times expected return
+	basisValue := make(map[string]decimal.Decimal) // Market value of holdings with a cost basis
+
+	for _, h := range portfolio.Holdings {
+		acct, ok := accounts[h.AccountName]
+		if !ok {
+			slice := byAccount[h.AccountName]
+			acct = &models.AccountPerformance{
+				Account: h.AccountName,
+				Value:   slice.Value,
+				Weight:  slice.Percentage,
+			}
+			accounts[h.AccountName] = acct
+		}
+		acct.Holdings++
+
+		returns[h.AccountName] = returns[h.AccountName].Add(h.MarketValue.Mul(models.ReturnStats(h.AssetClass).Average))
+
+		if basis := h.TotalCostBasis(); basis.IsPositive() {
+			acct.CostBasis = acct.CostBasis.Add(basis)
+			basisValue[h.AccountName] = basisValue[h.AccountName].Add(h.MarketValue)
+		}
+	}
+
+	result := make([]models.AccountPerformance, 0, len(accounts))
+	for name, acct := range accounts {
+		if !acct.Value.IsZero() {
+			acct.ExpectedReturn = returns[name].Div(acct.Value).Round(2)
+		}
+		if !portfolio.TotalValue.IsZero() {
+			acct.ContributionPct = returns[name].Div(portfolio.TotalValue).Round(2)
+		}
+
+		if acct.CostBasis.IsPositive() {
+			unrealized := basisValue[name].Sub(acct.CostBasis).Div(acct.CostBasis).Mul(hundred).Round(2)
+			acct.UnrealizedReturn = &unrealized
+			if !acct.Value.IsZero() {
+				acct.BasisCoverage = basisValue[name].Div(acct.Value).Mul(hundred).Round(2)
+			}
+		}
+		result = append(result, *acct)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Value.Equal(result[j].Value) {
+			return result[i].Value.GreaterThan(result[j].Value)
+		}
+		return result[i].Account < result[j].Account
+	})
+	return result
+}
+
 // CalculateRiskRewardMatrix builds the full risk-reward analysis
 func (s *Service) CalculateRiskRewardMatrix(portfolio *models.Portfolio) *models.RiskRewardMatrix {
 	if portfolio == nil {
@@ -136,6 +218,21 @@ func (s *Service) CalculateRiskRewardMatrix(portfolio *models.Portfolio) *models
 	return matrix
 }
 
+// CalculateDiversification scores how spread out a portfolio is across
+// tickers and sectors
+func (s *Service) CalculateDiversification(portfolio *models.Portfolio) *models.DiversificationScore {
+	if portfolio == nil {
+		return nil
+	}
+
+	allocation := portfolio.CalculateAllocation()
+	return &models.DiversificationScore{
+		PortfolioID: portfolio.ID.String(),
+		Holdings:    allocation.TickerDiversification(),
+		Sectors:     allocation.SectorDiversification(),
+	}
+}
+
 // CalculateExpenses analyzes portfolio expenses
 func (s *Service) CalculateExpenses(portfolio *models.Portfolio) *models.PortfolioExpenses {
 	if portfolio == nil {
@@ -156,8 +253,9 @@ func (s *Service) CalculateExpenses(portfolio *models.Portfolio) *models.Portfol
 		count        int
 	})
 
+	fromSource := s.sourceExpenseRatios(portfolio.Holdings)
 	for _, h := range portfolio.Holdings {
-		expenseRatio := models.GetExpenseRatio(h.Ticker, h.AssetClass)
+		expenseRatio := expenseRatio(h.Ticker, h.AssetClass, fromSource)
 		annualCost := models.CalculateAnnualExpense(h.MarketValue, expenseRatio)
 
 		// Add to totals
@@ -235,12 +333,46 @@ func (s *Service) CalculateExpenses(portfolio *models.Portfolio) *models.Portfol
 
 // Helper methods
 
-func (s *Service) estimateHistoricalValue(portfolio *models.Portfolio, period string) decimal.Decimal {
+// periodStartValue returns the portfolio's value at the start of the period
+// and how many years ago that was. The value comes from price history when
+// there's a history source, and is estimated otherwise.
+func (s *Service) periodStartValue(portfolio *models.Portfolio, period string) (decimal.Decimal, float64) {
+	if s.history != nil {
+		if series, ok := s.historicalTimeSeries(portfolio, period); ok {
+			return series[0].Value, time.Since(series[0].Date).Hours() / 24 / 365
+		}
+	}
+	now := time.Now().UTC()
+	start := models.PeriodStartDate(period, now, portfolio.CreatedAt)
+	return s.estimateHistoricalValue(portfolio, start, now), now.Sub(start).Hours() / 24 / 365
+}
+
+// annualizedReturn is the compound annual growth rate, in percent, of
+// going from start to end over the given number of years. Under a year it's
+// the plain return over the period, since compounding a few weeks' move
+// into a yearly rate overstates it wildly.
+func annualizedReturn(start, end decimal.Decimal, years float64) decimal.Decimal {
+	if !start.IsPositive() || years <= 0 {
+		return decimal.Zero
+	}
+	if !end.IsPositive() {
+		return decimal.NewFromInt(-100)
+	}
+
+	growth := end.Div(start).InexactFloat64()
+	if years >= 1 {
+		growth = math.Pow(growth, 1/years)
+	}
+	return decimal.NewFromFloat((growth - 1) * 100)
+}
+
+// estimateHistoricalValue estimates what the portfolio was worth at start,
+// given that it's worth its current value at end
+func (s *Service) estimateHistoricalValue(portfolio *models.Portfolio, start, end time.Time) decimal.Decimal {
 	// Estimate historical value based on average returns
 	// In production, this would use actual historical data
 
-	duration := models.GetPeriodDuration(period)
-	years := decimal.NewFromFloat(duration.Hours() / 24 / 365)
+	years := decimal.NewFromFloat(end.Sub(start).Hours() / 24 / 365)
 
 	if years.IsZero() {
 		return portfolio.TotalValue
@@ -253,7 +385,7 @@ func (s *Service) estimateHistoricalValue(portfolio *models.Portfolio, period st
 			continue
 		}
 		weight := h.MarketValue.Div(portfolio.TotalValue)
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 		weightedReturn = weightedReturn.Add(weight.Mul(stats.Average))
 	}
 
@@ -280,7 +412,7 @@ func (s *Service) estimateMaxDrawdown(portfolio *models.Portfolio) decimal.Decim
 			continue
 		}
 		weight := h.MarketValue.Div(portfolio.TotalValue)
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 		weightedDrawdown = weightedDrawdown.Add(weight.Mul(stats.WorstYear))
 	}
 
@@ -295,31 +427,27 @@ func (s *Service) calculatePortfolioMetrics(portfolio *models.Portfolio) models.
 	}
 
 	// Calculate weighted averages
-	var totalReturn, volatility, maxDrawdown, beta decimal.Decimal
+	var totalReturn, volatility, maxDrawdown decimal.Decimal
 
 	for _, h := range portfolio.Holdings {
 		weight := h.MarketValue.Div(portfolio.TotalValue)
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 
 		totalReturn = totalReturn.Add(weight.Mul(stats.Average))
 		volatility = volatility.Add(weight.Mul(stats.Volatility))
 		maxDrawdown = maxDrawdown.Add(weight.Mul(stats.WorstYear))
 	}
 
-	// Estimate beta (simplified - equity-weighted)
-	equityWeight := decimal.Zero
-	for _, h := range portfolio.Holdings {
-		if h.AssetClass == models.AssetClassEquity {
-			equityWeight = equityWeight.Add(h.MarketValue.Div(portfolio.TotalValue))
-		}
-	}
-	beta = equityWeight // Beta roughly equals equity exposure for diversified portfolio
+	// Beta from per-ticker regressions or published betas
+	beta, alpha, rSquared := s.portfolioBeta(portfolio)
 
 	metrics.ExpectedReturn = totalReturn.Round(2)
 	metrics.AnnualizedReturn = totalReturn.Round(2)
 	metrics.Volatility = volatility.Round(2)
 	metrics.MaxDrawdown = maxDrawdown.Round(2)
 	metrics.Beta = beta.Round(2)
+	metrics.Alpha = alpha.Round(2)
+	metrics.RSquared = rSquared.Round(2)
 
 	// Sharpe ratio
 	if !volatility.IsZero() {
@@ -327,11 +455,16 @@ func (s *Service) calculatePortfolioMetrics(portfolio *models.Portfolio) models.
 		metrics.SharpeRatio = excessReturn.Div(volatility).Round(2)
 	}
 
-	// Sortino ratio (using downside deviation estimate)
-	downsideVol := volatility.Mul(decimal.NewFromFloat(0.7)) // Approximate
+	// Sortino ratio, from downside deviation measured on the return series
+	// when there is one
+	downsideVol, measured := s.portfolioDownsideDeviation(portfolio)
+	if !measured {
+		downsideVol = volatility.Mul(estimatedDownsideFactor)
+		metrics.DownsideEstimated = true
+	}
 	metrics.DownsideDeviation = downsideVol.Round(2)
 	if !downsideVol.IsZero() {
-		excessReturn := totalReturn.Sub(models.RiskFreeRate.Mul(decimal.NewFromInt(100)))
+		excessReturn := totalReturn.Sub(s.minAcceptableReturn.Mul(decimal.NewFromInt(100)))
 		metrics.SortinoRatio = excessReturn.Div(downsideVol).Round(2)
 	}
 
@@ -350,7 +483,7 @@ func (s *Service) calculatePortfolioMetrics(portfolio *models.Portfolio) models.
 }
 
 func (s *Service) calculateAssetClassMetrics(class models.AssetClass, holdings []models.Holding, totalPortfolioValue decimal.Decimal) models.RiskRewardMetrics {
-	stats := models.AssetClassReturns[class]
+	stats := models.ReturnStats(class)
 
 	metrics := models.RiskRewardMetrics{
 		ExpectedReturn:   stats.Average,
@@ -377,7 +510,7 @@ func (s *Service) calculateHoldingMetrics(portfolio *models.Portfolio) []models.
 	count := decimal.NewFromInt(int64(len(portfolio.Holdings)))
 
 	for _, h := range portfolio.Holdings {
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 		avgReturn = avgReturn.Add(stats.Average)
 		avgVol = avgVol.Add(stats.Volatility)
 	}
@@ -388,7 +521,7 @@ func (s *Service) calculateHoldingMetrics(portfolio *models.Portfolio) []models.
 	}
 
 	for _, h := range portfolio.Holdings {
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 
 		weight := decimal.Zero
 		if !portfolio.TotalValue.IsZero() {
@@ -453,14 +586,21 @@ func (s *Service) analyzeQuadrants(holdings []models.HoldingRiskReward) models.Q
 	return analysis
 }
 
-// GenerateTimeSeries creates a historical value time series for charting
+// GenerateTimeSeries creates a historical value time series for charting.
+// With a history source it's built from the holdings' prices; otherwise, or
+// when no holding has history, it's estimated from asset class returns.
 func (s *Service) GenerateTimeSeries(portfolio *models.Portfolio, period string) []models.TimeSeriesPoint {
 	if portfolio == nil {
 		return nil
 	}
+	if s.history != nil {
+		if points, ok := s.historicalTimeSeries(portfolio, period); ok {
+			return points
+		}
+	}
 
 	endDate := time.Now().UTC()
-	startDate := models.GetPeriodStartDate(period)
+	startDate := models.PeriodStartDate(period, endDate, portfolio.CreatedAt)
 
 	// Calculate weighted return for the portfolio
 	weightedReturn := decimal.Zero
@@ -469,7 +609,7 @@ func (s *Service) GenerateTimeSeries(portfolio *models.Portfolio, period string)
 			continue
 		}
 		weight := h.MarketValue.Div(portfolio.TotalValue)
-		stats := models.AssetClassReturns[h.AssetClass]
+		stats := models.ReturnStats(h.AssetClass)
 		weightedReturn = weightedReturn.Add(weight.Mul(stats.Average))
 	}
 
@@ -482,7 +622,7 @@ func (s *Service) GenerateTimeSeries(portfolio *models.Portfolio, period string)
 	dailyReturn := weightedReturn.Div(decimal.NewFromFloat(365)).Div(decimal.NewFromInt(100))
 
 	// Back-calculate starting value
-	startValue := s.estimateHistoricalValue(portfolio, period)
+	startValue := s.estimateHistoricalValue(portfolio, startDate, endDate)
 	currentValue := startValue
 
 	for current.Before(endDate) {
@@ -1,7 +1,9 @@
 package analytics
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/findosh/truenorth/internal/models"
 	"github.com/google/uuid"
@@ -55,6 +57,80 @@ func TestService_CalculatePortfolioPerformance(t *testing.T) {
 	}
 }
 
+func TestService_CalculatePortfolioPerformance_Annualized(t *testing.T) {
+	svc := NewService()
+	portfolio := createTestPortfolio()
+
+	perf := svc.CalculatePortfolioPerformance(portfolio, models.Period5Year)
+	if !perf.TotalReturn.IsPositive() {
+		t.Fatalf("Expected a positive total return, got %s", perf.TotalReturn)
+	}
+	if !perf.AnnualizedReturn.LessThan(perf.TotalReturn) {
+		t.Errorf("CAGR %s should be less than the 5 year total return %s", perf.AnnualizedReturn, perf.TotalReturn)
+	}
+
+	// CAGR compounds back to the total return
+	compounded := math.Pow(1+perf.AnnualizedReturn.InexactFloat64()/100, 5) - 1
+	if got := compounded * 100; math.Abs(got-perf.TotalReturn.InexactFloat64()) > 0.1 {
+		t.Errorf("Compounded CAGR: got %.2f%%, want %s%%", got, perf.TotalReturn)
+	}
+
+	// With price history, a period under a year reports its own return
+	// rather than compounding it into a yearly rate
+	svc.SetHistorySource(staticHistory{
+		"VOO": {
+			{Date: time.Now().AddDate(0, -3, 0), Close: decimal.NewFromInt(4500)},
+			{Date: time.Now(), Close: decimal.NewFromInt(5000)},
+		},
+	})
+	portfolio.Holdings[0].CurrentPrice = decimal.NewFromInt(5000)
+	short := svc.CalculatePortfolioPerformance(portfolio, models.Period3Month)
+	if !short.TotalReturn.IsPositive() || !short.AnnualizedReturn.Equal(short.TotalReturn) {
+		t.Errorf("3 month annualized return %s, want its total return %s", short.AnnualizedReturn, short.TotalReturn)
+	}
+}
+
+func TestService_CalculateAccountPerformance(t *testing.T) {
+	svc := NewService()
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "VTI", AccountName: "IRA", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(6000), CostBasis: decimal.NewFromInt(5000)},
+			{Ticker: "BND", AccountName: "IRA", AssetClass: models.AssetClassFixedIncome, MarketValue: decimal.NewFromInt(2000)},
+			{Ticker: "SWVXX", AccountName: "Brokerage", AssetClass: models.AssetClassCash, MarketValue: decimal.NewFromInt(2000)},
+		},
+	}
+	portfolio.CalculateTotals()
+
+	accounts := svc.CalculateAccountPerformance(portfolio)
+	if len(accounts) != 2 || accounts[0].Account != "IRA" || accounts[1].Account != "Brokerage" {
+		t.Fatalf("Expected IRA then Brokerage, got %+v", accounts)
+	}
+
+	// 6000 x 10.5% + 2000 x 5% = 730 of 8000 in the IRA, 730 of the portfolio's 10000
+	ira := accounts[0]
+	if ira.Holdings != 2 || !ira.Weight.Equal(decimal.NewFromInt(80)) {
+		t.Errorf("IRA: got %d holdings at %s%%, want 2 at 80%%", ira.Holdings, ira.Weight)
+	}
+	if !ira.ExpectedReturn.Equal(decimal.NewFromFloat(9.13)) || !ira.ContributionPct.Equal(decimal.NewFromFloat(7.3)) {
+		t.Errorf("IRA returns: got expected %s contribution %s, want 9.13 and 7.3", ira.ExpectedReturn, ira.ContributionPct)
+	}
+
+	// Only VTI has a cost basis: up 20% on 75% of the account
+	if ira.UnrealizedReturn == nil || !ira.UnrealizedReturn.Equal(decimal.NewFromInt(20)) || !ira.BasisCoverage.Equal(decimal.NewFromInt(75)) {
+		t.Errorf("IRA unrealized: got %v on %s%%, want 20 on 75%%", ira.UnrealizedReturn, ira.BasisCoverage)
+	}
+
+	// No cost basis at all
+	if brokerage := accounts[1]; brokerage.UnrealizedReturn != nil || !brokerage.BasisCoverage.IsZero() {
+		t.Errorf("Brokerage: got unrealized %v on %s%%, want none", brokerage.UnrealizedReturn, brokerage.BasisCoverage)
+	}
+
+	if svc.CalculateAccountPerformance(nil) != nil {
+		t.Error("Expected nil for a nil portfolio")
+	}
+}
+
 func TestService_CalculateRiskRewardMatrix(t *testing.T) {
 	svc := NewService()
 
@@ -78,6 +154,52 @@ func TestService_CalculateRiskRewardMatrix(t *testing.T) {
 	}
 }
 
+func TestDownsideDeviation(t *testing.T) {
+	// Only the -2% and -4% returns fall short of a 0% minimum
+	got := DownsideDeviation([]float64{0.01, -0.02, 0.03, -0.04}, 0)
+	want := math.Sqrt((0.02*0.02 + 0.04*0.04) / 4)
+	if math.Abs(got-want) > 1e-12 {
+		t.Errorf("DownsideDeviation: got %v, want %v", got, want)
+	}
+
+	if got := DownsideDeviation([]float64{0.01, 0.02}, 0); got != 0 {
+		t.Errorf("No shortfall: got %v, want 0", got)
+	}
+}
+
+func TestService_CalculateRiskRewardMatrix_DownsideDeviation(t *testing.T) {
+	svc := NewService()
+	portfolio := createTestPortfolio()
+
+	estimated := svc.CalculateRiskRewardMatrix(portfolio).Portfolio
+	if !estimated.DownsideEstimated {
+		t.Error("Without history, downside deviation should be flagged as estimated")
+	}
+
+	// A year of daily closes alternating up 1% and down 1%
+	var history []models.PriceHistory
+	price := 100.0
+	start := time.Now().AddDate(-1, 0, 0)
+	for i := 0; i < 250; i++ {
+		history = append(history, models.PriceHistory{Date: start.AddDate(0, 0, i), Close: decimal.NewFromFloat(price)})
+		if i%2 == 0 {
+			price *= 1.01
+		} else {
+			price *= 0.99
+		}
+	}
+	svc.SetHistorySource(staticHistory{"VOO": history})
+	portfolio.Holdings[0].CurrentPrice = history[len(history)-1].Close
+
+	measured := svc.CalculateRiskRewardMatrix(portfolio).Portfolio
+	if measured.DownsideEstimated {
+		t.Error("With history, downside deviation should be measured")
+	}
+	if !measured.DownsideDeviation.IsPositive() || measured.DownsideDeviation.Equal(estimated.DownsideDeviation) {
+		t.Errorf("Measured downside deviation: got %s, estimate was %s", measured.DownsideDeviation, estimated.DownsideDeviation)
+	}
+}
+
 func TestService_CalculateRiskRewardMatrix_Nil(t *testing.T) {
 	svc := NewService()
 
@@ -115,6 +237,72 @@ func TestService_CalculateExpenses_Nil(t *testing.T) {
 	}
 }
 
+// staticRatios is an ExpenseRatioSource serving fixed expense ratios
+type staticRatios map[string]float64
+
+func (r staticRatios) GetExpenseRatios(tickers []string) map[string]decimal.Decimal {
+	ratios := make(map[string]decimal.Decimal)
+	for _, ticker := range tickers {
+		if ratio, ok := r[ticker]; ok {
+			ratios[ticker] = decimal.NewFromFloat(ratio)
+		}
+	}
+	return ratios
+}
+
+func TestService_CalculateExpenses_ExpenseRatioSource(t *testing.T) {
+	svc := NewService()
+	svc.SetExpenseRatioSource(staticRatios{"VOO": 0.04, "FXAIX": 0.015})
+
+	holding := func(ticker string, class models.AssetClass) models.Holding {
+		return models.Holding{Ticker: ticker, AssetClass: class, MarketValue: decimal.NewFromInt(10000)}
+	}
+	portfolio := &models.Portfolio{
+		TotalValue: decimal.NewFromInt(30000),
+		Holdings: []models.Holding{
+			holding("VOO", models.AssetClassEquity),           // Provider beats the curated list
+			holding("FXAIX", models.AssetClassEquity),         // Would otherwise count as a stock
+			holding("BONDFUND", models.AssetClassFixedIncome), // Unknown to the provider
+		},
+	}
+
+	want := map[string]float64{"VOO": 0.04, "FXAIX": 0.015, "BONDFUND": 0.15}
+	expenses := svc.CalculateExpenses(portfolio)
+	for _, h := range expenses.HighestExpense {
+		if !h.ExpenseRatio.Equal(decimal.NewFromFloat(want[h.Ticker])) {
+			t.Errorf("%s: expense ratio %s, want %v", h.Ticker, h.ExpenseRatio, want[h.Ticker])
+		}
+	}
+	if len(expenses.HighestExpense) != len(want) {
+		t.Errorf("Expected %d holdings, got %d", len(want), len(expenses.HighestExpense))
+	}
+}
+
+func TestService_CalculateDiversification(t *testing.T) {
+	svc := NewService()
+
+	portfolio := createTestPortfolio()
+	portfolio.CalculateTotals()
+	score := svc.CalculateDiversification(portfolio)
+
+	if score == nil {
+		t.Fatal("Expected diversification to be calculated")
+	}
+	if score.PortfolioID != portfolio.ID.String() {
+		t.Error("Portfolio ID should match")
+	}
+	if score.Holdings.Count != len(portfolio.Holdings) {
+		t.Errorf("Holdings count: got %d, want %d", score.Holdings.Count, len(portfolio.Holdings))
+	}
+	if !score.Holdings.Score.IsPositive() || score.Holdings.Score.GreaterThan(decimal.NewFromInt(100)) {
+		t.Errorf("Score should be within (0, 100], got %s", score.Holdings.Score)
+	}
+
+	if svc.CalculateDiversification(nil) != nil {
+		t.Error("Expected nil for nil portfolio")
+	}
+}
+
 func TestService_GenerateTimeSeries(t *testing.T) {
 	svc := NewService()
 
@@ -137,6 +325,37 @@ func TestService_GenerateTimeSeries(t *testing.T) {
 	}
 }
 
+func TestService_GenerateTimeSeries_PeriodStart(t *testing.T) {
+	svc := NewService()
+	portfolio := createTestPortfolio()
+	portfolio.CreatedAt = time.Now().UTC().AddDate(-2, 0, 0)
+
+	// All starts when the portfolio was created, not a year back
+	series := svc.GenerateTimeSeries(portfolio, models.PeriodAll)
+	if first := series[0].Date; !first.Equal(portfolio.CreatedAt) {
+		t.Errorf("All series starts %v, want %v", first, portfolio.CreatedAt)
+	}
+	all := svc.CalculatePortfolioPerformance(portfolio, models.PeriodAll)
+	if !all.StartValue.Equal(series[0].Value) {
+		t.Errorf("All start value %s, series starts at %s", all.StartValue, series[0].Value)
+	}
+
+	// YTD starts on January 1st and is estimated over the part of the year
+	// that's gone, so it moves less than a full year
+	series = svc.GenerateTimeSeries(portfolio, models.PeriodYTD)
+	if first := series[0].Date; first.Month() != time.January || first.Day() != 1 {
+		t.Errorf("YTD series starts %v, want January 1st", first)
+	}
+	ytd := svc.CalculatePortfolioPerformance(portfolio, models.PeriodYTD)
+	year := svc.CalculatePortfolioPerformance(portfolio, models.Period1Year)
+	if !ytd.StartValue.Equal(series[0].Value) {
+		t.Errorf("YTD start value %s, series starts at %s", ytd.StartValue, series[0].Value)
+	}
+	if !ytd.StartValue.GreaterThanOrEqual(year.StartValue) {
+		t.Errorf("YTD start value %s should be at least the 1 year start value %s", ytd.StartValue, year.StartValue)
+	}
+}
+
 func TestService_GenerateTimeSeries_Nil(t *testing.T) {
 	svc := NewService()
 
@@ -146,6 +365,61 @@ func TestService_GenerateTimeSeries_Nil(t *testing.T) {
 	}
 }
 
+// staticHistory is a HistorySource serving fixed price histories
+type staticHistory map[string][]models.PriceHistory
+
+func (h staticHistory) GetHistoricalPricesBatch(tickers []string, period string) (map[string][]models.PriceHistory, error) {
+	out := make(map[string][]models.PriceHistory)
+	for _, t := range tickers {
+		if prices, ok := h[t]; ok {
+			out[t] = prices
+		}
+	}
+	return out, nil
+}
+
+func TestService_GenerateTimeSeries_History(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 21, 0, 0, 0, time.UTC) }
+	closeOn := func(ticker string, d int, price float64) models.PriceHistory {
+		return models.PriceHistory{Ticker: ticker, Date: day(d), Close: decimal.NewFromFloat(price)}
+	}
+
+	svc := NewService()
+	svc.SetHistorySource(staticHistory{
+		"AAA": {closeOn("AAA", 4, 10), closeOn("AAA", 5, 11), closeOn("AAA", 6, 12)},
+		// Trades on another calendar with no close on the 5th, out of order
+		"BBB": {closeOn("BBB", 6, 21), closeOn("BBB", 4, 20)},
+		"DDD": {closeOn("DDD", 4, 1), closeOn("DDD", 5, 2), closeOn("DDD", 6, 3)},
+	})
+
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "AAA", CurrentPrice: decimal.NewFromInt(12), MarketValue: decimal.NewFromInt(120)}, // 10 shares
+			{Ticker: "BBB", CurrentPrice: decimal.NewFromInt(21), MarketValue: decimal.NewFromInt(42)},  // 2 shares
+			{Ticker: "HOUSE", IsManualEntry: true, MarketValue: decimal.NewFromInt(1000)},
+			{Ticker: "CCC", CurrentPrice: decimal.NewFromInt(5), MarketValue: decimal.NewFromInt(50)}, // No history
+			// Reclassified by hand, but still quoted
+			{Ticker: "DDD", IsManualEntry: true, AssetClass: models.AssetClassAlternative, CurrentPrice: decimal.NewFromInt(3), MarketValue: decimal.NewFromInt(30)}, // 10 shares
+		},
+	}
+
+	series := svc.GenerateTimeSeries(portfolio, models.Period1Week)
+	want := []float64{
+		1050 + 100 + 40 + 10, // 4th
+		1050 + 110 + 40 + 20, // 5th: BBB didn't trade, so its last close carries over
+		1050 + 120 + 42 + 30, // 6th
+	}
+	if len(series) != len(want) {
+		t.Fatalf("Points: got %d, want %d", len(series), len(want))
+	}
+	for i, w := range want {
+		if !series[i].Value.Equal(decimal.NewFromFloat(w)) {
+			t.Errorf("Point %d (%s): got %s, want %v", i, series[i].Date.Format("2006-01-02"), series[i].Value, w)
+		}
+	}
+}
+
 func TestService_AnalyzeQuadrants(t *testing.T) {
 	svc := NewService()
 
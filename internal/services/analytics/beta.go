@@ -0,0 +1,218 @@
+package analytics
+
+import (
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// BenchmarkTicker is the market index holdings are regressed against
+const BenchmarkTicker = "SPY"
+
+// minRegressionObservations is the fewest paired daily returns needed
+// before a regression is trusted over the beta table
+const minRegressionObservations = 30
+
+// tradingDaysPerYear annualizes daily alpha
+const tradingDaysPerYear = 252
+
+// betaEstimate is a holding's market sensitivity. Alpha (annualized, in
+// percent) and R-squared are only known when the estimate came from a
+// regression.
+type betaEstimate struct {
+	Beta      float64
+	Alpha     float64
+	RSquared  float64
+	Regressed bool
+}
+
+// betaPeriod is how much daily history betas are regressed over
+const betaPeriod = models.Period1Year
+
+// SetPriceHistory loads daily price history for a ticker, used for its beta
+// regression in place of the history source's
+func (s *Service) SetPriceHistory(ticker string, history []models.PriceHistory) {
+	s.priceCache[strings.ToUpper(ticker)] = history
+}
+
+// betaHistories returns the daily price history to regress tickers against
+// the benchmark with: what SetPriceHistory loaded and, for the rest, a
+// year from the history source when there is one. Tickers without history
+// are left out.
+func (s *Service) betaHistories(tickers []string) map[string][]models.PriceHistory {
+	histories := make(map[string][]models.PriceHistory, len(tickers)+1)
+	if len(tickers) == 0 {
+		return histories
+	}
+
+	seen := make(map[string]bool, len(tickers)+1)
+	var missing []string
+	for _, ticker := range append([]string{BenchmarkTicker}, tickers...) {
+		ticker = strings.ToUpper(ticker)
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+		if cached, ok := s.priceCache[ticker]; ok {
+			histories[ticker] = cached
+		} else {
+			missing = append(missing, ticker)
+		}
+	}
+	if s.history == nil || len(missing) == 0 {
+		return histories
+	}
+
+	fetched, err := s.history.GetHistoricalPricesBatch(missing, betaPeriod)
+	if err != nil {
+		log.Printf("analytics: loading price history for betas: %v", err)
+		return histories
+	}
+	for ticker, prices := range fetched {
+		histories[ticker] = prices
+	}
+	return histories
+}
+
+// estimateBeta returns the best available beta for a ticker: a regression
+// against the benchmark when both have enough price history in histories,
+// otherwise the published beta. ok is false when neither exists.
+func (s *Service) estimateBeta(ticker string, histories map[string][]models.PriceHistory) (betaEstimate, bool) {
+	ticker = strings.ToUpper(ticker)
+
+	if est, ok := regressAgainstBenchmark(histories[ticker], histories[BenchmarkTicker]); ok {
+		return est, true
+	}
+
+	if beta, ok := models.TickerBetas[ticker]; ok {
+		return betaEstimate{Beta: beta.InexactFloat64()}, true
+	}
+
+	return betaEstimate{}, false
+}
+
+// portfolioBeta computes portfolio beta as the value-weighted sum of
+// holding betas. Holdings without beta data fall back to the equity-weight
+// heuristic (1 for equities, 0 otherwise). Alpha is likewise weighted, and
+// R-squared is the weighted average over regressed holdings only, since the
+// table carries no fit statistics.
+func (s *Service) portfolioBeta(portfolio *models.Portfolio) (beta, alpha, rSquared decimal.Decimal) {
+	var betaSum, alphaSum, r2Sum, r2Weight float64
+
+	// Cash and coins have no stock price history to regress
+	var tickers []string
+	for _, h := range portfolio.Holdings {
+		if h.Ticker != "" && h.AssetClass != models.AssetClassCash && !h.IsCryptoCoin() {
+			tickers = append(tickers, h.Ticker)
+		}
+	}
+	histories := s.betaHistories(tickers)
+
+	for _, h := range portfolio.Holdings {
+		weight := h.MarketValue.Div(portfolio.TotalValue).InexactFloat64()
+
+		est, ok := s.estimateBeta(h.Ticker, histories)
+		if !ok {
+			if h.AssetClass == models.AssetClassEquity {
+				betaSum += weight
+			}
+			continue
+		}
+
+		betaSum += weight * est.Beta
+		if est.Regressed {
+			alphaSum += weight * est.Alpha
+			r2Sum += weight * est.RSquared
+			r2Weight += weight
+		}
+	}
+
+	beta = decimal.NewFromFloat(betaSum)
+	alpha = decimal.NewFromFloat(alphaSum)
+	if r2Weight > 0 {
+		rSquared = decimal.NewFromFloat(r2Sum / r2Weight)
+	}
+	return beta, alpha, rSquared
+}
+
+// regressAgainstBenchmark runs an ordinary least squares regression of the
+// asset's daily returns on the benchmark's, pairing days by date
+func regressAgainstBenchmark(asset, benchmark []models.PriceHistory) (betaEstimate, bool) {
+	assetReturns := dailyReturns(asset)
+	benchReturns := dailyReturns(benchmark)
+
+	var xs, ys []float64
+	for date, y := range assetReturns {
+		if x, ok := benchReturns[date]; ok {
+			xs = append(xs, x)
+			ys = append(ys, y)
+		}
+	}
+	if len(xs) < minRegressionObservations {
+		return betaEstimate{}, false
+	}
+
+	n := float64(len(xs))
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= n
+	meanY /= n
+
+	var covXY, varX, varY float64
+	for i := range xs {
+		dx, dy := xs[i]-meanX, ys[i]-meanY
+		covXY += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 {
+		return betaEstimate{}, false
+	}
+
+	est := betaEstimate{
+		Beta:      covXY / varX,
+		Regressed: true,
+	}
+	est.Alpha = (meanY - est.Beta*meanX) * tradingDaysPerYear * 100
+	if varY > 0 {
+		est.RSquared = (covXY * covXY) / (varX * varY)
+	}
+	if math.IsNaN(est.Beta) || math.IsInf(est.Beta, 0) {
+		return betaEstimate{}, false
+	}
+	return est, true
+}
+
+// dailyReturns converts a price history into simple returns keyed by date,
+// preferring adjusted closes so dividends and splits don't read as moves
+func dailyReturns(history []models.PriceHistory) map[string]float64 {
+	sorted := make([]models.PriceHistory, len(history))
+	copy(sorted, history)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	returns := make(map[string]float64, len(sorted))
+	for i := 1; i < len(sorted); i++ {
+		prev, curr := closePrice(sorted[i-1]), closePrice(sorted[i])
+		if prev == 0 {
+			continue
+		}
+		returns[sorted[i].Date.Format("2006-01-02")] = curr/prev - 1
+	}
+	return returns
+}
+
+func closePrice(p models.PriceHistory) float64 {
+	if !p.AdjClose.IsZero() {
+		return p.AdjClose.InexactFloat64()
+	}
+	return p.Close.InexactFloat64()
+}
@@ -0,0 +1,164 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// buildHistory turns daily returns into a price series starting at 100
+func buildHistory(ticker string, start time.Time, returns []float64) []models.PriceHistory {
+	price := 100.0
+	history := []models.PriceHistory{{Ticker: ticker, Date: start, Close: decimal.NewFromFloat(price)}}
+	for i, r := range returns {
+		price *= 1 + r
+		history = append(history, models.PriceHistory{
+			Ticker: ticker,
+			Date:   start.AddDate(0, 0, i+1),
+			Close:  decimal.NewFromFloat(price),
+		})
+	}
+	return history
+}
+
+func TestRegressAgainstBenchmark(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	market := make([]float64, 60)
+	asset := make([]float64, 60)
+	for i := range market {
+		market[i] = 0.01 * math.Sin(float64(i))
+		asset[i] = 1.5*market[i] + 0.0001
+	}
+
+	est, ok := regressAgainstBenchmark(buildHistory("XYZ", start, asset), buildHistory("SPY", start, market))
+	if !ok {
+		t.Fatal("Expected regression to succeed")
+	}
+	if math.Abs(est.Beta-1.5) > 1e-6 {
+		t.Errorf("Beta: got %v, want 1.5", est.Beta)
+	}
+	if math.Abs(est.Alpha-2.52) > 1e-6 {
+		t.Errorf("Alpha: got %v, want 2.52", est.Alpha)
+	}
+	if math.Abs(est.RSquared-1) > 1e-6 {
+		t.Errorf("R-squared: got %v, want 1", est.RSquared)
+	}
+
+	// Too little overlapping history
+	if _, ok := regressAgainstBenchmark(buildHistory("XYZ", start, asset[:10]), buildHistory("SPY", start, market)); ok {
+		t.Error("Expected regression to need more observations")
+	}
+}
+
+func TestService_PortfolioBeta(t *testing.T) {
+	newPortfolio := func(holdings ...models.Holding) *models.Portfolio {
+		p := &models.Portfolio{ID: uuid.New(), Holdings: holdings}
+		p.CalculateTotals()
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		portfolio *models.Portfolio
+		wantBeta  float64
+	}{
+		{
+			name: "Table betas",
+			portfolio: newPortfolio(
+				models.Holding{Ticker: "QQQ", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(5000)},
+				models.Holding{Ticker: "BND", AssetClass: models.AssetClassFixedIncome, MarketValue: decimal.NewFromInt(5000)},
+			),
+			wantBeta: 0.62, // 0.5*1.18 + 0.5*0.05
+		},
+		{
+			name: "Falls back to equity weight",
+			portfolio: newPortfolio(
+				models.Holding{Ticker: "ZZZZ", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(6000)},
+				models.Holding{Ticker: "CASH", AssetClass: models.AssetClassCash, MarketValue: decimal.NewFromInt(4000)},
+			),
+			wantBeta: 0.6,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService()
+			beta, _, _ := svc.portfolioBeta(tt.portfolio)
+			if got := beta.Round(2).InexactFloat64(); got != tt.wantBeta {
+				t.Errorf("Beta: got %v, want %v", got, tt.wantBeta)
+			}
+		})
+	}
+}
+
+func TestService_PortfolioBeta_PrefersRegression(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	market := make([]float64, 60)
+	asset := make([]float64, 60)
+	for i := range market {
+		market[i] = 0.01 * math.Sin(float64(i))
+		asset[i] = 2 * market[i]
+	}
+
+	svc := NewService()
+	svc.SetPriceHistory("SPY", buildHistory("SPY", start, market))
+	svc.SetPriceHistory("QQQ", buildHistory("QQQ", start, asset))
+
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "QQQ", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(10000)},
+		},
+	}
+	portfolio.CalculateTotals()
+
+	metrics := svc.calculatePortfolioMetrics(portfolio)
+	if got := metrics.Beta.InexactFloat64(); got != 2 {
+		t.Errorf("Beta: got %v, want regressed 2 over table 1.18", got)
+	}
+	if got := metrics.RSquared.InexactFloat64(); got != 1 {
+		t.Errorf("R-squared: got %v, want 1", got)
+	}
+}
+
+func TestService_PortfolioBeta_FromHistorySource(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	market := make([]float64, 60)
+	asset := make([]float64, 60)
+	for i := range market {
+		market[i] = 0.01 * math.Sin(float64(i))
+		asset[i] = 0.5*market[i] + 0.001
+	}
+
+	svc := NewService()
+	svc.SetHistorySource(staticHistory{
+		"SPY": buildHistory("SPY", start, market),
+		"VTI": buildHistory("VTI", start, asset),
+	})
+
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "VTI", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(10000)},
+		},
+	}
+	portfolio.CalculateTotals()
+
+	metrics := svc.calculatePortfolioMetrics(portfolio)
+	if got := metrics.Beta.InexactFloat64(); got != 0.5 {
+		t.Errorf("Beta: got %v, want regressed 0.5 over table 1.02", got)
+	}
+	if got := metrics.RSquared.InexactFloat64(); got != 1 {
+		t.Errorf("R-squared: got %v, want 1", got)
+	}
+	if !metrics.Alpha.IsPositive() {
+		t.Errorf("Alpha: got %s, want the daily excess return annualized", metrics.Alpha)
+	}
+}
@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ComparePortfolios returns allocation, expenses, risk-reward, and
+// performance for two portfolios along with the differences between them
+func (s *Service) ComparePortfolios(a, b *models.Portfolio, period string) *models.PortfolioComparison {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	sideA := s.comparisonSide(a, period)
+	sideB := s.comparisonSide(b, period)
+
+	deltas := models.ComparisonDeltas{
+		TotalValue:     sideB.TotalValue.Sub(sideA.TotalValue),
+		Allocation:     make(map[models.AssetClass]decimal.Decimal),
+		ExpenseRatio:   sideB.Expenses.WeightedExpenseRatio.Sub(sideA.Expenses.WeightedExpenseRatio),
+		AnnualExpenses: sideB.Expenses.TotalAnnualExpenses.Sub(sideA.Expenses.TotalAnnualExpenses),
+		ExpectedReturn: sideB.RiskReward.ExpectedReturn.Sub(sideA.RiskReward.ExpectedReturn),
+		Volatility:     sideB.RiskReward.Volatility.Sub(sideA.RiskReward.Volatility),
+		SharpeRatio:    sideB.RiskReward.SharpeRatio.Sub(sideA.RiskReward.SharpeRatio),
+		MaxDrawdown:    sideB.RiskReward.MaxDrawdown.Sub(sideA.RiskReward.MaxDrawdown),
+	}
+	for _, class := range models.AllAssetClasses() {
+		pctA := sideA.Allocation.ByAssetClass[class].Percentage
+		pctB := sideB.Allocation.ByAssetClass[class].Percentage
+		if pctA.IsZero() && pctB.IsZero() {
+			continue
+		}
+		deltas.Allocation[class] = pctB.Sub(pctA)
+	}
+	if sideA.Performance != nil && sideB.Performance != nil {
+		deltas.AnnualizedReturn = sideB.Performance.AnnualizedReturn.Sub(sideA.Performance.AnnualizedReturn)
+	}
+
+	return &models.PortfolioComparison{
+		A:      sideA,
+		B:      sideB,
+		Deltas: deltas,
+		Summary: models.ComparisonSummary{
+			// Lower is better for fees, so the sign is flipped
+			LowerFees:                comparisonWinner(deltas.ExpenseRatio.Neg()),
+			HigherRiskAdjustedReturn: comparisonWinner(deltas.SharpeRatio),
+		},
+	}
+}
+
+func (s *Service) comparisonSide(portfolio *models.Portfolio, period string) models.ComparisonSide {
+	portfolio.CalculateTotals()
+
+	return models.ComparisonSide{
+		PortfolioID: portfolio.ID.String(),
+		Name:        portfolio.Name,
+		TotalValue:  portfolio.TotalValue,
+		Allocation:  portfolio.CalculateAllocation(),
+		Expenses:    s.CalculateExpenses(portfolio),
+		RiskReward:  s.calculatePortfolioMetrics(portfolio),
+		Performance: s.CalculatePortfolioPerformance(portfolio, period),
+	}
+}
+
+// comparisonWinner names the side favored by a B-minus-A difference where
+// larger is better
+func comparisonWinner(delta decimal.Decimal) string {
+	switch {
+	case delta.IsPositive():
+		return models.ComparisonB
+	case delta.IsNegative():
+		return models.ComparisonA
+	default:
+		return models.ComparisonTie
+	}
+}
@@ -0,0 +1,84 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestService_ComparePortfolios(t *testing.T) {
+	svc := NewService()
+
+	a := createTestPortfolio()
+	b := &models.Portfolio{
+		ID:   uuid.New(),
+		Name: "All Equity",
+		Holdings: []models.Holding{
+			{
+				ID:          uuid.New(),
+				Ticker:      "VOO",
+				Quantity:    decimal.NewFromInt(100),
+				MarketValue: decimal.NewFromInt(500000),
+				AssetClass:  models.AssetClassEquity,
+				Sector:      "Diversified",
+				Geography:   "US",
+			},
+		},
+	}
+
+	comparison := svc.ComparePortfolios(a, b, models.Period1Year)
+	if comparison == nil {
+		t.Fatal("Expected comparison")
+	}
+
+	if comparison.A.Name != "Test Portfolio" || comparison.B.Name != "All Equity" {
+		t.Errorf("Sides: got %s and %s", comparison.A.Name, comparison.B.Name)
+	}
+	if !comparison.Deltas.TotalValue.Equal(decimal.NewFromInt(-500000)) {
+		t.Errorf("Total value delta: got %s, want -500000", comparison.Deltas.TotalValue)
+	}
+
+	// 50% equity in A, 100% in B
+	if got := comparison.Deltas.Allocation[models.AssetClassEquity]; !got.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Equity delta: got %s, want 50", got)
+	}
+	if got := comparison.Deltas.Allocation[models.AssetClassCash]; !got.Equal(decimal.NewFromInt(-20)) {
+		t.Errorf("Cash delta: got %s, want -20", got)
+	}
+	if _, ok := comparison.Deltas.Allocation[models.AssetClassCrypto]; ok {
+		t.Error("Classes neither portfolio holds should be left out")
+	}
+
+	// A's money market fund makes it the more expensive of the two
+	if comparison.Summary.LowerFees != models.ComparisonB {
+		t.Errorf("Lower fees: got %s, want b", comparison.Summary.LowerFees)
+	}
+
+	wantRiskAdjusted := comparisonWinner(comparison.B.RiskReward.SharpeRatio.Sub(comparison.A.RiskReward.SharpeRatio))
+	if comparison.Summary.HigherRiskAdjustedReturn != wantRiskAdjusted {
+		t.Errorf("Higher risk-adjusted return: got %s, want %s", comparison.Summary.HigherRiskAdjustedReturn, wantRiskAdjusted)
+	}
+
+	if svc.ComparePortfolios(a, nil, models.Period1Year) != nil {
+		t.Error("Expected nil when a portfolio is missing")
+	}
+}
+
+func TestComparisonWinner(t *testing.T) {
+	tests := []struct {
+		delta float64
+		want  string
+	}{
+		{0.5, models.ComparisonB},
+		{-0.5, models.ComparisonA},
+		{0, models.ComparisonTie},
+	}
+
+	for _, tt := range tests {
+		if got := comparisonWinner(decimal.NewFromFloat(tt.delta)); got != tt.want {
+			t.Errorf("comparisonWinner(%v): got %s, want %s", tt.delta, got, tt.want)
+		}
+	}
+}
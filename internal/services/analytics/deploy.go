@@ -0,0 +1,190 @@
+package analytics
+
+import (
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// SuggestCashDeployment suggests ticker-level buys that put free cash above
+// the cash-drag threshold to work. Cash goes first to the asset classes
+// furthest below target; target may be nil, in which case the current mix of
+// invested holdings is kept. Nothing is suggested unless the cash-drag alert
+// would fire.
+func (s *Service) SuggestCashDeployment(portfolio *models.Portfolio, target *models.Scenario, thresholds *models.AlertThresholds) *models.CashDeployment {
+	if portfolio == nil {
+		return nil
+	}
+	if thresholds == nil {
+		thresholds = models.DefaultThresholds()
+	}
+
+	hundred := decimal.NewFromInt(100)
+	deployment := &models.CashDeployment{
+		PortfolioID:  portfolio.ID.String(),
+		FreeCash:     portfolio.FreeCash,
+		Threshold:    thresholds.CashDragPercent,
+		ByAssetClass: []models.ClassDeployment{},
+	}
+	if portfolio.TotalValue.IsZero() {
+		return deployment
+	}
+
+	total := portfolio.TotalValue
+	deployment.CashPercent = portfolio.FreeCash.Div(total).Mul(hundred).Round(2)
+	deployment.Triggered = deployment.CashPercent.GreaterThan(thresholds.CashDragPercent)
+
+	current := make(map[models.AssetClass]decimal.Decimal)
+	for _, h := range portfolio.Holdings {
+		current[h.AssetClass] = current[h.AssetClass].Add(h.MarketValue)
+	}
+
+	targets, reserve := deploymentTargets(current, total, target, thresholds.CashDragPercent)
+	if target != nil {
+		deployment.Target = target.Name
+	} else {
+		deployment.Target = "Current allocation"
+	}
+
+	if !deployment.Triggered {
+		return deployment
+	}
+
+	// Keep the target's cash, or the threshold when there's no target
+	deployable := portfolio.FreeCash.Sub(total.Mul(reserve).Div(hundred))
+	if !deployable.IsPositive() {
+		return deployment
+	}
+	deployment.Deployable = deployable.Round(2)
+
+	amounts := splitDeployable(deployable, current, targets, total)
+	for _, class := range models.AllAssetClasses() {
+		amount, ok := amounts[class]
+		if !ok || !amount.IsPositive() {
+			continue
+		}
+		deployment.ByAssetClass = append(deployment.ByAssetClass, models.ClassDeployment{
+			AssetClass: class,
+			Current:    current[class].Div(total).Mul(hundred).Round(2),
+			Target:     targets[class].Round(2),
+			Amount:     amount.Round(2),
+			Buys:       deploymentBuys(portfolio, class, amount),
+		})
+	}
+
+	return deployment
+}
+
+// deploymentTargets returns target percentages for each invested asset class
+// and the percentage to hold back as cash. Without a scenario, the current
+// invested mix is scaled to fill everything above the cash threshold.
+func deploymentTargets(current map[models.AssetClass]decimal.Decimal, total decimal.Decimal, target *models.Scenario, cashThreshold decimal.Decimal) (map[models.AssetClass]decimal.Decimal, decimal.Decimal) {
+	targets := make(map[models.AssetClass]decimal.Decimal)
+
+	if target != nil {
+		for class, pct := range target.Allocations {
+			if class != models.AssetClassCash && pct.IsPositive() {
+				targets[class] = pct
+			}
+		}
+		return targets, target.Allocations[models.AssetClassCash]
+	}
+
+	invested := total.Sub(current[models.AssetClassCash])
+	if !invested.IsPositive() {
+		return targets, cashThreshold
+	}
+
+	room := decimal.NewFromInt(100).Sub(cashThreshold)
+	for class, value := range current {
+		if class != models.AssetClassCash && value.IsPositive() {
+			targets[class] = value.Div(invested).Mul(room)
+		}
+	}
+	return targets, cashThreshold
+}
+
+// splitDeployable divides cash among asset classes. Underweight classes are
+// filled first, in proportion to how far below target they are; anything
+// left over is spread by target weight.
+func splitDeployable(deployable decimal.Decimal, current, targets map[models.AssetClass]decimal.Decimal, total decimal.Decimal) map[models.AssetClass]decimal.Decimal {
+	amounts := make(map[models.AssetClass]decimal.Decimal)
+	hundred := decimal.NewFromInt(100)
+
+	shortfalls := make(map[models.AssetClass]decimal.Decimal)
+	totalShortfall := decimal.Zero
+	for class, pct := range targets {
+		shortfall := total.Mul(pct).Div(hundred).Sub(current[class])
+		if shortfall.IsPositive() {
+			shortfalls[class] = shortfall
+			totalShortfall = totalShortfall.Add(shortfall)
+		}
+	}
+
+	if deployable.LessThanOrEqual(totalShortfall) {
+		for class, shortfall := range shortfalls {
+			amounts[class] = deployable.Mul(shortfall).Div(totalShortfall)
+		}
+		return amounts
+	}
+
+	for class, shortfall := range shortfalls {
+		amounts[class] = shortfall
+	}
+
+	remaining := deployable.Sub(totalShortfall)
+	totalTarget := decimal.Zero
+	for _, pct := range targets {
+		totalTarget = totalTarget.Add(pct)
+	}
+	if totalTarget.IsZero() {
+		return amounts
+	}
+	for class, pct := range targets {
+		amounts[class] = amounts[class].Add(remaining.Mul(pct).Div(totalTarget))
+	}
+	return amounts
+}
+
+// deploymentBuys spreads an asset class's amount across its existing
+// holdings by value, or suggests a broad fund when none are held
+func deploymentBuys(portfolio *models.Portfolio, class models.AssetClass, amount decimal.Decimal) []models.DeploymentBuy {
+	var tickers []string
+	values := make(map[string]decimal.Decimal)
+	names := make(map[string]string)
+	classTotal := decimal.Zero
+
+	for _, h := range portfolio.Holdings {
+		if h.AssetClass != class || !h.MarketValue.IsPositive() {
+			continue
+		}
+		if _, seen := values[h.Ticker]; !seen {
+			tickers = append(tickers, h.Ticker)
+			names[h.Ticker] = h.Name
+		}
+		values[h.Ticker] = values[h.Ticker].Add(h.MarketValue)
+		classTotal = classTotal.Add(h.MarketValue)
+	}
+
+	if classTotal.IsZero() {
+		fund, ok := models.DeploymentFunds[class]
+		if !ok {
+			return []models.DeploymentBuy{}
+		}
+		return []models.DeploymentBuy{{
+			Ticker:      fund.Ticker,
+			Name:        fund.Name,
+			Amount:      amount.Round(2),
+			NewPosition: true,
+		}}
+	}
+
+	buys := make([]models.DeploymentBuy, 0, len(tickers))
+	for _, ticker := range tickers {
+		buys = append(buys, models.DeploymentBuy{
+			Ticker: ticker,
+			Name:   names[ticker],
+			Amount: amount.Mul(values[ticker]).Div(classTotal).Round(2),
+		})
+	}
+	return buys
+}
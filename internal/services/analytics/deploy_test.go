@@ -0,0 +1,126 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func createCashHeavyPortfolio() *models.Portfolio {
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "VTI", Name: "Vanguard Total Stock Market ETF", MarketValue: decimal.NewFromInt(30000), AssetClass: models.AssetClassEquity, AccountName: "IRA"},
+			{Ticker: "VTI", Name: "Vanguard Total Stock Market ETF", MarketValue: decimal.NewFromInt(10000), AssetClass: models.AssetClassEquity, AccountName: "Brokerage"},
+			{Ticker: "VXUS", Name: "Vanguard Total International Stock ETF", MarketValue: decimal.NewFromInt(10000), AssetClass: models.AssetClassEquity, AccountName: "IRA"},
+			{Ticker: "BND", Name: "Vanguard Total Bond Market ETF", MarketValue: decimal.NewFromInt(20000), AssetClass: models.AssetClassFixedIncome, AccountName: "IRA"},
+			{Ticker: "SPAXX", Name: "Fidelity Money Market", MarketValue: decimal.NewFromInt(30000), AssetClass: models.AssetClassCash, AccountName: "Brokerage"},
+		},
+	}
+	portfolio.CalculateTotals()
+	return portfolio
+}
+
+func findClassDeployment(t *testing.T, d *models.CashDeployment, class models.AssetClass) models.ClassDeployment {
+	t.Helper()
+	for _, c := range d.ByAssetClass {
+		if c.AssetClass == class {
+			return c
+		}
+	}
+	t.Fatalf("No deployment for %s", class)
+	return models.ClassDeployment{}
+}
+
+func TestService_SuggestCashDeployment_CurrentAllocation(t *testing.T) {
+	svc := NewService()
+
+	d := svc.SuggestCashDeployment(createCashHeavyPortfolio(), nil, nil)
+	if !d.Triggered {
+		t.Fatal("Expected 30% cash to trigger deployment")
+	}
+	// Everything above the 10% threshold
+	if !d.Deployable.Equal(decimal.NewFromInt(20000)) {
+		t.Errorf("Deployable: got %s, want 20000", d.Deployable)
+	}
+
+	// The 50/20 invested mix is kept
+	equity := findClassDeployment(t, d, models.AssetClassEquity)
+	if !equity.Amount.Equal(decimal.NewFromFloat(14285.71)) {
+		t.Errorf("Equity amount: got %s, want 14285.71", equity.Amount)
+	}
+	fixed := findClassDeployment(t, d, models.AssetClassFixedIncome)
+	if !fixed.Amount.Equal(decimal.NewFromFloat(5714.29)) {
+		t.Errorf("Fixed income amount: got %s, want 5714.29", fixed.Amount)
+	}
+
+	// VTI is held in two accounts and is 80% of equities
+	if len(equity.Buys) != 2 {
+		t.Fatalf("Equity buys: got %d, want 2", len(equity.Buys))
+	}
+	if equity.Buys[0].Ticker != "VTI" || !equity.Buys[0].Amount.Equal(decimal.NewFromFloat(11428.57)) {
+		t.Errorf("VTI buy: got %s %s, want 11428.57", equity.Buys[0].Ticker, equity.Buys[0].Amount)
+	}
+	if equity.Buys[0].NewPosition {
+		t.Error("VTI is already held")
+	}
+}
+
+func TestService_SuggestCashDeployment_Target(t *testing.T) {
+	svc := NewService()
+
+	target := models.NewScenario(uuid.New(), "Growth")
+	target.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(60))
+	target.SetAllocation(models.AssetClassFixedIncome, decimal.NewFromInt(30))
+	target.SetAllocation(models.AssetClassAlternative, decimal.NewFromInt(5))
+	target.SetAllocation(models.AssetClassCash, decimal.NewFromInt(5))
+
+	d := svc.SuggestCashDeployment(createCashHeavyPortfolio(), target, models.DefaultThresholds())
+	if d.Target != "Growth" {
+		t.Errorf("Target: got %s, want Growth", d.Target)
+	}
+	// Down to the target's 5% cash
+	if !d.Deployable.Equal(decimal.NewFromInt(25000)) {
+		t.Errorf("Deployable: got %s, want 25000", d.Deployable)
+	}
+
+	wants := map[models.AssetClass]int64{
+		models.AssetClassEquity:      10000,
+		models.AssetClassFixedIncome: 10000,
+		models.AssetClassAlternative: 5000,
+	}
+	for class, want := range wants {
+		if got := findClassDeployment(t, d, class).Amount; !got.Equal(decimal.NewFromInt(want)) {
+			t.Errorf("%s amount: got %s, want %d", class, got, want)
+		}
+	}
+
+	// No alternatives are held, so a broad fund is suggested
+	alt := findClassDeployment(t, d, models.AssetClassAlternative)
+	if len(alt.Buys) != 1 || alt.Buys[0].Ticker != "VNQ" || !alt.Buys[0].NewPosition {
+		t.Errorf("Alternative buys: got %+v, want a new VNQ position", alt.Buys)
+	}
+}
+
+func TestService_SuggestCashDeployment_BelowThreshold(t *testing.T) {
+	svc := NewService()
+
+	portfolio := createTestPortfolio() // 20% cash
+	portfolio.CalculateTotals()
+	thresholds := models.DefaultThresholds()
+	thresholds.CashDragPercent = decimal.NewFromInt(25)
+
+	d := svc.SuggestCashDeployment(portfolio, nil, thresholds)
+	if d.Triggered {
+		t.Error("Expected no deployment below the cash-drag threshold")
+	}
+	if len(d.ByAssetClass) != 0 {
+		t.Errorf("Expected no buys, got %d", len(d.ByAssetClass))
+	}
+
+	if svc.SuggestCashDeployment(nil, nil, nil) != nil {
+		t.Error("Expected nil for nil portfolio")
+	}
+}
@@ -0,0 +1,72 @@
+package analytics
+
+import (
+	"math"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// downsideHistoryPeriod is how much of the value series downside deviation
+// is measured over
+const downsideHistoryPeriod = models.Period1Year
+
+// minDownsideObservations is the fewest daily returns needed before a
+// measured downside deviation is trusted over the estimate
+const minDownsideObservations = 30
+
+// estimatedDownsideFactor scales volatility into a downside deviation when
+// there's no return series to measure it from
+var estimatedDownsideFactor = decimal.NewFromFloat(0.7)
+
+// SetMinAcceptableReturn sets the annual return, as a fraction, below which
+// returns count as downside. It defaults to the risk-free rate.
+func (s *Service) SetMinAcceptableReturn(mar decimal.Decimal) {
+	s.minAcceptableReturn = mar
+}
+
+// DownsideDeviation is the root-mean-square shortfall of periodic returns
+// below a minimum acceptable return for the same period. Returns at or above
+// it count as no shortfall.
+func DownsideDeviation(returns []float64, mar float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, r := range returns {
+		if shortfall := r - mar; shortfall < 0 {
+			sum += shortfall * shortfall
+		}
+	}
+	return math.Sqrt(sum / float64(len(returns)))
+}
+
+// portfolioDownsideDeviation returns the annualized downside deviation, in
+// percent, of the portfolio's daily returns over the past year. ok is false
+// when there isn't enough history to measure it.
+func (s *Service) portfolioDownsideDeviation(portfolio *models.Portfolio) (decimal.Decimal, bool) {
+	if s.history == nil {
+		return decimal.Zero, false
+	}
+	series, ok := s.historicalTimeSeries(portfolio, downsideHistoryPeriod)
+	if !ok {
+		return decimal.Zero, false
+	}
+
+	returns := make([]float64, 0, len(series))
+	for i := 1; i < len(series); i++ {
+		prev := series[i-1].Value.InexactFloat64()
+		if prev <= 0 {
+			continue
+		}
+		returns = append(returns, series[i].Value.InexactFloat64()/prev-1)
+	}
+	if len(returns) < minDownsideObservations {
+		return decimal.Zero, false
+	}
+
+	dailyMAR := math.Pow(1+s.minAcceptableReturn.InexactFloat64(), 1.0/tradingDaysPerYear) - 1
+	annualized := DownsideDeviation(returns, dailyMAR) * math.Sqrt(tradingDaysPerYear) * 100
+	return decimal.NewFromFloat(annualized), true
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ExpenseRatioSource looks up funds' published expense ratios, as a
+// percentage like models.KnownExpenseRatios, keyed by ticker. Tickers it
+// has no ratio for are left out.
+type ExpenseRatioSource interface {
+	GetExpenseRatios(tickers []string) map[string]decimal.Decimal
+}
+
+// SetExpenseRatioSource prices expenses from a provider's expense ratios,
+// falling back to the curated list and asset class defaults for funds it
+// doesn't know
+func (s *Service) SetExpenseRatioSource(src ExpenseRatioSource) {
+	s.expenseRatios = src
+}
+
+// sourceExpenseRatios looks up the holdings' expense ratios from the source
+// in one batch. Options never have one, so they aren't looked up.
+func (s *Service) sourceExpenseRatios(holdings []models.Holding) map[string]decimal.Decimal {
+	if s.expenseRatios == nil {
+		return nil
+	}
+	var tickers []string
+	for _, h := range holdings {
+		if h.AssetClass != models.AssetClassDerivative {
+			tickers = append(tickers, h.Ticker)
+		}
+	}
+	if len(tickers) == 0 {
+		return nil
+	}
+	return s.expenseRatios.GetExpenseRatios(tickers)
+}
+
+// expenseRatio returns the holding's expense ratio, preferring the source's
+func expenseRatio(ticker string, assetClass models.AssetClass, fromSource map[string]decimal.Decimal) decimal.Decimal {
+	if ratio, ok := fromSource[ticker]; ok && assetClass != models.AssetClassDerivative {
+		return ratio
+	}
+	return models.GetExpenseRatio(ticker, assetClass)
+}
@@ -0,0 +1,188 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// frontierSteps are the weight increments, in percent, allocations are
+// searched in, finest first. The finest that keeps the search within
+// maxFrontierAllocations is used: 5% for up to six asset classes, 10% for
+// all eight.
+var frontierSteps = []int{5, 10, 20, 25, 50}
+
+// maxFrontierAllocations caps how many allocations one frontier evaluates
+const maxFrontierAllocations = 60000
+
+// frontierStep returns the finest step that splits 100% across n classes in
+// no more than maxFrontierAllocations ways
+func frontierStep(n int) int {
+	for _, step := range frontierSteps {
+		if allocationCount(n, 100/step) <= maxFrontierAllocations {
+			return step
+		}
+	}
+	return frontierSteps[len(frontierSteps)-1]
+}
+
+// allocationCount is how many ways total units split across n buckets,
+// the binomial coefficient C(total+n-1, n-1)
+func allocationCount(n, total int) int {
+	if n <= 0 {
+		return 0
+	}
+	count := 1
+	for k := 1; k < n; k++ {
+		count = count * (total + k) / k
+	}
+	return count
+}
+
+// CalculateEfficientFrontier searches long-only allocations across the
+// asset classes the portfolio holds and returns those on the efficient
+// frontier, using the asset class return assumptions and correlations. The
+// more classes, the coarser the search, so its cost is bounded.
+func (s *Service) CalculateEfficientFrontier(portfolio *models.Portfolio) *models.EfficientFrontier {
+	if portfolio == nil || portfolio.TotalValue.IsZero() || len(portfolio.Holdings) == 0 {
+		return nil
+	}
+
+	current := make(map[models.AssetClass]float64)
+	for _, h := range portfolio.Holdings {
+		current[h.AssetClass] += h.MarketValue.Div(portfolio.TotalValue).InexactFloat64()
+	}
+
+	var classes []models.AssetClass
+	for _, class := range models.AllAssetClasses() {
+		if _, ok := current[class]; ok {
+			classes = append(classes, class)
+		}
+	}
+
+	currentWeights := make([]float64, len(classes))
+	for i, class := range classes {
+		currentWeights[i] = current[class]
+	}
+
+	type candidate struct {
+		weights []float64
+		vol     float64
+		ret     float64
+	}
+	model := newRiskModel(classes)
+	step := frontierStep(len(classes))
+	candidates := make([]candidate, 0, allocationCount(len(classes), 100/step))
+	forEachAllocation(len(classes), 100/step, func(units []int) {
+		weights := make([]float64, len(units))
+		for i, u := range units {
+			weights[i] = float64(u*step) / 100
+		}
+		vol, ret := model.riskReturn(weights)
+		candidates = append(candidates, candidate{weights, vol, ret})
+	})
+
+	// Walk from lowest to highest risk, keeping each allocation that beats
+	// the return of everything less risky at the precision it's reported in
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].vol != candidates[j].vol {
+			return candidates[i].vol < candidates[j].vol
+		}
+		return candidates[i].ret > candidates[j].ret
+	})
+
+	frontier := &models.EfficientFrontier{
+		PortfolioID:  portfolio.ID.String(),
+		AssetClasses: classes,
+	}
+	for _, c := range candidates {
+		n := len(frontier.Points)
+		if n == 0 || decimal.NewFromFloat(c.ret).Round(2).GreaterThan(frontier.Points[n-1].ExpectedReturn) {
+			frontier.Points = append(frontier.Points, frontierPoint(classes, c.weights, c.vol, c.ret))
+		}
+	}
+
+	vol, ret := model.riskReturn(currentWeights)
+	frontier.Current = frontierPoint(classes, currentWeights, vol, ret)
+
+	return frontier
+}
+
+// allocationRiskReturn returns the expected volatility and return, both in
+// percent, of a set of asset class weights
+func allocationRiskReturn(classes []models.AssetClass, weights []float64) (volatility, expectedReturn float64) {
+	return newRiskModel(classes).riskReturn(weights)
+}
+
+// riskModel holds the asset class assumptions an allocation is measured
+// with, read once so searching many allocations doesn't repeat it
+type riskModel struct {
+	returns    []float64
+	covariance [][]float64
+}
+
+func newRiskModel(classes []models.AssetClass) *riskModel {
+	m := &riskModel{
+		returns:    make([]float64, len(classes)),
+		covariance: make([][]float64, len(classes)),
+	}
+	for i, a := range classes {
+		statsA := models.ReturnStats(a)
+		m.returns[i] = statsA.Average.InexactFloat64()
+		m.covariance[i] = make([]float64, len(classes))
+		for j, b := range classes {
+			statsB := models.ReturnStats(b)
+			m.covariance[i][j] = statsA.Volatility.InexactFloat64() * statsB.Volatility.InexactFloat64() *
+				models.AssetClassCorrelation(a, b)
+		}
+	}
+	return m
+}
+
+// riskReturn returns the expected volatility and return, both in percent,
+// of weights for the model's classes
+func (m *riskModel) riskReturn(weights []float64) (volatility, expectedReturn float64) {
+	var variance float64
+	for i, w := range weights {
+		expectedReturn += w * m.returns[i]
+		for j, v := range weights {
+			variance += w * v * m.covariance[i][j]
+		}
+	}
+	return math.Sqrt(variance), expectedReturn
+}
+
+// forEachAllocation calls fn with every way of splitting total units
+// across n buckets. The slice passed to fn is reused between calls.
+func forEachAllocation(n, total int, fn func(units []int)) {
+	units := make([]int, n)
+	var fill func(i, remaining int)
+	fill = func(i, remaining int) {
+		if i == n-1 {
+			units[i] = remaining
+			fn(units)
+			return
+		}
+		for u := 0; u <= remaining; u++ {
+			units[i] = u
+			fill(i+1, remaining-u)
+		}
+	}
+	if n > 0 {
+		fill(0, total)
+	}
+}
+
+func frontierPoint(classes []models.AssetClass, weights []float64, vol, ret float64) models.FrontierPoint {
+	point := models.FrontierPoint{
+		Volatility:     decimal.NewFromFloat(vol).Round(2),
+		ExpectedReturn: decimal.NewFromFloat(ret).Round(2),
+		Weights:        make(map[models.AssetClass]decimal.Decimal, len(classes)),
+	}
+	for i, class := range classes {
+		point.Weights[class] = decimal.NewFromFloat(weights[i] * 100).Round(2)
+	}
+	return point
+}
@@ -0,0 +1,135 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestService_CalculateEfficientFrontier(t *testing.T) {
+	svc := NewService()
+
+	portfolio := createTestPortfolio()
+	portfolio.CalculateTotals()
+	frontier := svc.CalculateEfficientFrontier(portfolio)
+
+	if frontier == nil {
+		t.Fatal("Expected frontier to be calculated")
+	}
+	if len(frontier.Points) < 2 {
+		t.Fatalf("Expected several frontier points, got %d", len(frontier.Points))
+	}
+
+	hundred := decimal.NewFromInt(100)
+	for i, p := range frontier.Points {
+		total := decimal.Zero
+		for class, w := range p.Weights {
+			if w.IsNegative() {
+				t.Errorf("Point %d: negative weight %s for %s", i, w, class)
+			}
+			total = total.Add(w)
+		}
+		if !total.Equal(hundred) {
+			t.Errorf("Point %d: weights sum to %s, want 100", i, total)
+		}
+
+		if i > 0 {
+			prev := frontier.Points[i-1]
+			if p.Volatility.LessThan(prev.Volatility) || !p.ExpectedReturn.GreaterThan(prev.ExpectedReturn) {
+				t.Errorf("Point %d should add both risk and return over point %d", i, i-1)
+			}
+		}
+	}
+
+	// The current allocation can't beat the frontier: some efficient point
+	// offers at least its return for no more risk
+	dominated := false
+	for _, p := range frontier.Points {
+		if p.Volatility.LessThanOrEqual(frontier.Current.Volatility) && p.ExpectedReturn.GreaterThanOrEqual(frontier.Current.ExpectedReturn) {
+			dominated = true
+			break
+		}
+	}
+	if !dominated {
+		t.Errorf("Current allocation (vol %s, return %s) lies above the frontier",
+			frontier.Current.Volatility, frontier.Current.ExpectedReturn)
+	}
+}
+
+func TestService_CalculateEfficientFrontier_SingleClass(t *testing.T) {
+	svc := NewService()
+
+	portfolio := &models.Portfolio{
+		Holdings: []models.Holding{
+			{Ticker: "VOO", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(1000)},
+		},
+	}
+	portfolio.CalculateTotals()
+
+	frontier := svc.CalculateEfficientFrontier(portfolio)
+	if frontier == nil || len(frontier.Points) != 1 {
+		t.Fatalf("Expected a single frontier point, got %v", frontier)
+	}
+	if !frontier.Points[0].Volatility.Equal(frontier.Current.Volatility) {
+		t.Errorf("Single class frontier should be the current allocation")
+	}
+
+	if svc.CalculateEfficientFrontier(nil) != nil {
+		t.Error("Expected nil for nil portfolio")
+	}
+}
+
+func TestService_CalculateEfficientFrontier_AllClasses(t *testing.T) {
+	svc := NewService()
+
+	portfolio := &models.Portfolio{}
+	for _, class := range models.AllAssetClasses() {
+		portfolio.Holdings = append(portfolio.Holdings, models.Holding{AssetClass: class, MarketValue: decimal.NewFromInt(1000)})
+	}
+	portfolio.CalculateTotals()
+
+	frontier := svc.CalculateEfficientFrontier(portfolio)
+	if frontier == nil || len(frontier.Points) < 2 || len(frontier.AssetClasses) != len(models.AllAssetClasses()) {
+		t.Fatalf("Expected a frontier across every class, got %v", frontier)
+	}
+}
+
+func TestFrontierStep(t *testing.T) {
+	tests := []struct {
+		classes, step int
+	}{
+		{1, 5},
+		{6, 5},
+		{7, 10},
+		{8, 10},
+	}
+	for _, tt := range tests {
+		step := frontierStep(tt.classes)
+		if step != tt.step {
+			t.Errorf("%d classes: got step %d, want %d", tt.classes, step, tt.step)
+		}
+		if count := allocationCount(tt.classes, 100/step); count > maxFrontierAllocations {
+			t.Errorf("%d classes: %d allocations, want at most %d", tt.classes, count, maxFrontierAllocations)
+		}
+	}
+
+	// C(25, 5): 20 units of 5% across six classes
+	if got := allocationCount(6, 20); got != 53130 {
+		t.Errorf("allocationCount(6, 20) = %d, want 53130", got)
+	}
+}
+
+func TestAllocationRiskReturn_Diversifies(t *testing.T) {
+	classes := []models.AssetClass{models.AssetClassEquity, models.AssetClassFixedIncome}
+
+	vol, ret := allocationRiskReturn(classes, []float64{0.5, 0.5})
+
+	// Correlation below 1 means less risk than the weighted average (10.5)
+	if vol >= 10.5 {
+		t.Errorf("Volatility: got %v, want below 10.5", vol)
+	}
+	if ret != 7.75 {
+		t.Errorf("Expected return: got %v, want 7.75", ret)
+	}
+}
@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// simulationTrials is how many market paths projections are simulated over
+	simulationTrials = 2000
+
+	// simulationSeed fixes the simulated paths so a projection doesn't
+	// change between requests
+	simulationSeed = 1
+)
+
+// ProjectGlidePath simulates following the glide path for years, with the
+// goal reached at the end. Each year's return is drawn from a normal
+// distribution with that year's allocation's expected return and
+// volatility, using the asset class correlations.
+func (s *Service) ProjectGlidePath(path *models.GlidePath, currentValue decimal.Decimal, years int) *models.GlidePathProjection {
+	if path == nil || len(path.Schedule) == 0 || years < 1 {
+		return nil
+	}
+
+	classes := models.AllAssetClasses()
+	allocations := make([]map[models.AssetClass]decimal.Decimal, years+1)
+	vols := make([]float64, years)
+	means := make([]float64, years)
+	for year := 0; year <= years; year++ {
+		allocations[year] = path.AllocationAt(float64(years - year))
+		if year == years {
+			break
+		}
+
+		weights := make([]float64, len(classes))
+		for i, class := range classes {
+			weights[i] = allocations[year][class].InexactFloat64() / 100
+		}
+		vols[year], means[year] = allocationRiskReturn(classes, weights)
+	}
+
+	// values[year][trial] is a trial's value at the start of that year
+	start := currentValue.InexactFloat64()
+	values := make([][]float64, years+1)
+	for year := range values {
+		values[year] = make([]float64, simulationTrials)
+	}
+	rng := rand.New(rand.NewSource(simulationSeed))
+	for trial := 0; trial < simulationTrials; trial++ {
+		value := start
+		values[0][trial] = value
+		for year := 0; year < years; year++ {
+			ret := means[year] + vols[year]*rng.NormFloat64()
+			value = math.Max(0, value*(1+ret/100))
+			values[year+1][trial] = value
+		}
+	}
+	for year := range values {
+		sort.Float64s(values[year])
+	}
+
+	terminal := values[years]
+	var sum float64
+	for _, v := range terminal {
+		sum += v
+	}
+
+	projection := &models.GlidePathProjection{
+		Years:        years,
+		Trials:       simulationTrials,
+		StartValue:   currentValue.Round(2),
+		MeanValue:    decimal.NewFromFloat(sum / float64(len(terminal))).Round(2),
+		Percentile10: percentileOf(terminal, 10),
+		Percentile25: percentileOf(terminal, 25),
+		MedianValue:  percentileOf(terminal, 50),
+		Percentile75: percentileOf(terminal, 75),
+		Percentile90: percentileOf(terminal, 90),
+	}
+
+	for _, year := range glidePathMilestones(path, years) {
+		rounded := make(map[models.AssetClass]decimal.Decimal)
+		for class, pct := range allocations[year] {
+			rounded[class] = pct.Round(2)
+		}
+		projection.Milestones = append(projection.Milestones, models.GlidePathMilestone{
+			Year:        year,
+			YearsOut:    years - year,
+			Allocations: rounded,
+			MedianValue: percentileOf(values[year], 50),
+		})
+	}
+
+	return projection
+}
+
+// glidePathMilestones returns the start, the end, and each year within the
+// horizon where the schedule has a step, in order
+func glidePathMilestones(path *models.GlidePath, years int) []int {
+	marked := map[int]bool{0: true, years: true}
+	for _, step := range path.Schedule {
+		if year := years - step.YearsOut; year > 0 && year < years {
+			marked[year] = true
+		}
+	}
+
+	milestones := make([]int, 0, len(marked))
+	for year := range marked {
+		milestones = append(milestones, year)
+	}
+	sort.Ints(milestones)
+	return milestones
+}
+
+// percentileOf returns the pth percentile of sorted values, interpolating
+// between neighbours
+func percentileOf(sorted []float64, p float64) decimal.Decimal {
+	if len(sorted) == 0 {
+		return decimal.Zero
+	}
+	pos := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	v := sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+	return decimal.NewFromFloat(v).Round(2)
+}
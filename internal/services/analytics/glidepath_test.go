@@ -0,0 +1,66 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestService_ProjectGlidePath(t *testing.T) {
+	svc := NewService()
+	path := &models.GlidePath{
+		Schedule: []models.GlidePathStep{
+			{YearsOut: 0, Allocations: map[models.AssetClass]decimal.Decimal{
+				models.AssetClassEquity:      decimal.NewFromInt(30),
+				models.AssetClassFixedIncome: decimal.NewFromInt(70),
+			}},
+			{YearsOut: 10, Allocations: map[models.AssetClass]decimal.Decimal{
+				models.AssetClassEquity:      decimal.NewFromInt(90),
+				models.AssetClassFixedIncome: decimal.NewFromInt(10),
+			}},
+		},
+	}
+
+	start := decimal.NewFromInt(100000)
+	projection := svc.ProjectGlidePath(path, start, 25)
+	if projection == nil {
+		t.Fatal("Expected a projection")
+	}
+
+	// Percentiles are ordered and growth is expected over 25 years
+	ordered := []decimal.Decimal{projection.Percentile10, projection.Percentile25, projection.MedianValue,
+		projection.Percentile75, projection.Percentile90}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i].LessThan(ordered[i-1]) {
+			t.Errorf("Percentiles out of order: %v", ordered)
+		}
+	}
+	if !projection.MedianValue.GreaterThan(start) {
+		t.Errorf("Expected median above the starting value, got %s", projection.MedianValue)
+	}
+
+	// Start, the step 10 years out, and the goal
+	years := []int{0, 15, 25}
+	if len(projection.Milestones) != len(years) {
+		t.Fatalf("Expected %d milestones, got %+v", len(years), projection.Milestones)
+	}
+	for i, m := range projection.Milestones {
+		if m.Year != years[i] || m.YearsOut != 25-years[i] {
+			t.Errorf("Milestone %d: got year %d (%d out), want year %d", i, m.Year, m.YearsOut, years[i])
+		}
+	}
+	if equity := projection.Milestones[2].Allocations[models.AssetClassEquity]; !equity.Equal(decimal.NewFromInt(30)) {
+		t.Errorf("Expected 30%% equity at the goal, got %s", equity)
+	}
+
+	// The same inputs give the same projection
+	again := svc.ProjectGlidePath(path, start, 25)
+	if !again.MedianValue.Equal(projection.MedianValue) {
+		t.Errorf("Expected a repeatable projection, got %s then %s", projection.MedianValue, again.MedianValue)
+	}
+
+	if svc.ProjectGlidePath(&models.GlidePath{}, start, 25) != nil {
+		t.Error("Expected nil for an empty schedule")
+	}
+}
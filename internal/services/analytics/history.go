@@ -0,0 +1,119 @@
+package analytics
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// HistorySource supplies daily price history for many tickers at once
+type HistorySource interface {
+	GetHistoricalPricesBatch(tickers []string, period string) (map[string][]models.PriceHistory, error)
+}
+
+// SetHistorySource values time series from holdings' price history instead
+// of estimating them from asset class returns
+func (s *Service) SetHistorySource(src HistorySource) {
+	s.history = src
+}
+
+// historicalTimeSeries values the portfolio on every trading day in the
+// period at today's quantities. Tickers trade on different calendars, so the
+// days are the union of every ticker's and a ticker's last close carries
+// over days it didn't trade; before its first close, that close is used.
+// Holdings without a ticker, price or history count at their current value
+// throughout, whether or not they were classified by hand. ok is
+// false when no holding has history.
+func (s *Service) historicalTimeSeries(portfolio *models.Portfolio, period string) ([]models.TimeSeriesPoint, bool) {
+	units := make(map[string]decimal.Decimal)
+	fixed := make(map[string]decimal.Decimal) // Current value of each ticker, for when its history is missing
+	var tickers []string
+	var other decimal.Decimal
+	for _, h := range portfolio.Holdings {
+		if h.Ticker == "" || !h.CurrentPrice.IsPositive() {
+			other = other.Add(h.MarketValue)
+			continue
+		}
+		ticker := strings.ToUpper(h.Ticker)
+		if _, ok := units[ticker]; !ok {
+			tickers = append(tickers, ticker)
+		}
+		// Units priced at the quote, so option multipliers and currency
+		// conversion carry over from the current market value
+		units[ticker] = units[ticker].Add(h.MarketValue.Div(h.CurrentPrice))
+		fixed[ticker] = fixed[ticker].Add(h.MarketValue)
+	}
+	if len(tickers) == 0 {
+		return nil, false
+	}
+
+	histories, err := s.history.GetHistoricalPricesBatch(tickers, period)
+	if err != nil {
+		log.Printf("analytics: loading price history: %v", err)
+		return nil, false
+	}
+
+	closes := make(map[string]map[time.Time]decimal.Decimal)
+	last := make(map[string]decimal.Decimal)
+	days := make(map[time.Time]bool)
+	for _, ticker := range tickers {
+		prices := histories[ticker]
+		if len(prices) == 0 {
+			other = other.Add(fixed[ticker])
+			continue
+		}
+
+		byDay := make(map[time.Time]decimal.Decimal, len(prices))
+		for _, p := range prices {
+			day := tradingDay(p.Date)
+			byDay[day] = p.Close
+			days[day] = true
+		}
+		closes[ticker] = byDay
+		last[ticker] = earliestClose(prices)
+	}
+	if len(days) == 0 {
+		return nil, false
+	}
+
+	sorted := make([]time.Time, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	points := make([]models.TimeSeriesPoint, 0, len(sorted))
+	for _, day := range sorted {
+		value := other
+		for ticker, byDay := range closes {
+			if price, ok := byDay[day]; ok {
+				last[ticker] = price
+			}
+			value = value.Add(units[ticker].Mul(last[ticker]))
+		}
+		points = append(points, models.TimeSeriesPoint{Date: day, Value: value.Round(2)})
+	}
+
+	return points, true
+}
+
+// tradingDay drops the time of day so closes from different sources line up
+func tradingDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// earliestClose returns the close on the first day of a price history
+func earliestClose(prices []models.PriceHistory) decimal.Decimal {
+	first := prices[0]
+	for _, p := range prices[1:] {
+		if p.Date.Before(first.Date) {
+			first = p
+		}
+	}
+	return first.Close
+}
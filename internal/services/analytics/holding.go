@@ -0,0 +1,140 @@
+package analytics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidPeriod is returned for a holding performance period that isn't
+// supported
+var ErrInvalidPeriod = errors.New("invalid performance period")
+
+// ErrNoPriceHistory is returned when a holding has too little price history
+// to measure
+var ErrNoPriceHistory = errors.New("no price history")
+
+// holdingPeriods are the periods a holding's performance can be measured
+// over. A day has too few daily closes, and a holding has no start date
+// for all.
+var holdingPeriods = map[string]bool{
+	models.Period1Week:  true,
+	models.Period1Month: true,
+	models.Period3Month: true,
+	models.Period6Month: true,
+	models.Period1Year:  true,
+	models.Period3Year:  true,
+	models.Period5Year:  true,
+	models.PeriodYTD:    true,
+}
+
+// CalculateHoldingPerformance measures a holding's return and risk over a
+// period from its ticker's price history. Beta is regressed against the
+// benchmark when its history is loaded, and read from the table otherwise.
+func (s *Service) CalculateHoldingPerformance(holding *models.Holding, period string) (*models.PerformanceMetrics, error) {
+	if !holdingPeriods[period] {
+		return nil, ErrInvalidPeriod
+	}
+	// Any holding with a ticker is looked up, including ones whose
+	// classification was edited; those without history answer below
+	ticker := strings.ToUpper(strings.TrimSpace(holding.Ticker))
+	if s.history == nil || ticker == "" {
+		return nil, ErrNoPriceHistory
+	}
+
+	histories, err := s.history.GetHistoricalPricesBatch([]string{ticker}, period)
+	if err != nil {
+		return nil, err
+	}
+	metrics, ok := PriceMetrics(histories[ticker])
+	if !ok {
+		return nil, ErrNoPriceHistory
+	}
+
+	metrics.Ticker = ticker
+	metrics.Period = period
+	if est, ok := s.estimateBeta(ticker, s.betaHistories([]string{ticker})); ok {
+		metrics.Beta = decimal.NewFromFloat(est.Beta).Round(2)
+	}
+	return &metrics, nil
+}
+
+// PriceMetrics measures a price history from its first close to its last:
+// total and annualized return, annualized volatility of daily returns, the
+// worst peak-to-trough fall and the Sharpe ratio, all but the last in
+// percent. Returns use adjusted closes so dividends and splits count. ok is
+// false when there are fewer than two closes.
+func PriceMetrics(history []models.PriceHistory) (models.PerformanceMetrics, bool) {
+	if len(history) < 2 {
+		return models.PerformanceMetrics{}, false
+	}
+	sorted := make([]models.PriceHistory, len(history))
+	copy(sorted, history)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	first, last := sorted[0], sorted[len(sorted)-1]
+	start, end := closePrice(first), closePrice(last)
+	if start <= 0 {
+		return models.PerformanceMetrics{}, false
+	}
+
+	var returns []float64
+	peak, drawdown := start, 0.0
+	for i := 1; i < len(sorted); i++ {
+		prev, curr := closePrice(sorted[i-1]), closePrice(sorted[i])
+		if prev > 0 {
+			returns = append(returns, curr/prev-1)
+		}
+		if curr > peak {
+			peak = curr
+		} else if fall := curr/peak - 1; fall < drawdown {
+			drawdown = fall
+		}
+	}
+
+	years := last.Date.Sub(first.Date).Hours() / 24 / 365
+	annualized := annualizedReturn(decimal.NewFromFloat(start), decimal.NewFromFloat(end), years)
+	volatility := sampleStdDev(returns) * math.Sqrt(tradingDaysPerYear) * 100
+
+	sharpe := decimal.Zero
+	if volatility > 0 {
+		excess := annualized.Sub(models.RiskFreeRate.Mul(decimal.NewFromInt(100)))
+		sharpe = excess.Div(decimal.NewFromFloat(volatility)).Round(2)
+	}
+
+	return models.PerformanceMetrics{
+		StartDate:        first.Date,
+		EndDate:          last.Date,
+		StartPrice:       first.Close,
+		EndPrice:         last.Close,
+		TotalReturn:      decimal.NewFromFloat((end/start - 1) * 100).Round(2),
+		AnnualizedReturn: annualized.Round(2),
+		Volatility:       decimal.NewFromFloat(volatility).Round(2),
+		MaxDrawdown:      decimal.NewFromFloat(drawdown * 100).Round(2),
+		SharpeRatio:      sharpe,
+	}, true
+}
+
+// sampleStdDev is the sample standard deviation of values
+func sampleStdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
@@ -0,0 +1,93 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func holdingHistory() []models.PriceHistory {
+	closeOn := func(date string, price float64) models.PriceHistory {
+		d, _ := time.Parse("2006-01-02", date)
+		return models.PriceHistory{Ticker: "VOO", Date: d, Close: decimal.NewFromFloat(price)}
+	}
+	// Out of order, rising to a peak and falling a quarter before recovering
+	return []models.PriceHistory{
+		closeOn("2023-06-01", 120),
+		closeOn("2023-01-01", 100),
+		closeOn("2024-01-01", 110),
+		closeOn("2023-09-01", 90),
+	}
+}
+
+func TestPriceMetrics(t *testing.T) {
+	metrics, ok := PriceMetrics(holdingHistory())
+	if !ok {
+		t.Fatal("Expected metrics")
+	}
+
+	if !metrics.StartPrice.Equal(decimal.NewFromInt(100)) || !metrics.EndPrice.Equal(decimal.NewFromInt(110)) {
+		t.Errorf("Prices: got %s to %s, want 100 to 110", metrics.StartPrice, metrics.EndPrice)
+	}
+	if !metrics.TotalReturn.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Total return: got %s, want 10", metrics.TotalReturn)
+	}
+	// The period is exactly a year, so the annualized return is the same
+	if !metrics.AnnualizedReturn.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Annualized return: got %s, want 10", metrics.AnnualizedReturn)
+	}
+	if !metrics.MaxDrawdown.Equal(decimal.NewFromInt(-25)) {
+		t.Errorf("Max drawdown: got %s, want -25", metrics.MaxDrawdown)
+	}
+	if !metrics.Volatility.IsPositive() || !metrics.SharpeRatio.IsPositive() {
+		t.Errorf("Volatility %s and Sharpe %s should be positive", metrics.Volatility, metrics.SharpeRatio)
+	}
+
+	if _, ok := PriceMetrics(holdingHistory()[:1]); ok {
+		t.Error("One close shouldn't be measured")
+	}
+}
+
+func TestService_CalculateHoldingPerformance(t *testing.T) {
+	svc := NewService()
+	holding := models.NewHolding(uuid.New(), "voo", "Vanguard S&P 500 ETF", "IRA")
+
+	if _, err := svc.CalculateHoldingPerformance(holding, models.Period1Year); !errors.Is(err, ErrNoPriceHistory) {
+		t.Errorf("Without a history source: got %v, want ErrNoPriceHistory", err)
+	}
+
+	svc.SetHistorySource(staticHistory{"VOO": holdingHistory()})
+	metrics, err := svc.CalculateHoldingPerformance(holding, models.Period1Year)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if metrics.Ticker != "VOO" || metrics.Period != models.Period1Year || !metrics.TotalReturn.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Got %s over %s returning %s, want VOO over 1y returning 10", metrics.Ticker, metrics.Period, metrics.TotalReturn)
+	}
+	if !metrics.Beta.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Beta: got %s, want the table's 1", metrics.Beta)
+	}
+
+	if _, err := svc.CalculateHoldingPerformance(holding, models.Period1Day); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("1d: got %v, want ErrInvalidPeriod", err)
+	}
+	other := models.NewHolding(uuid.New(), "BND", "Vanguard Total Bond Market ETF", "IRA")
+	if _, err := svc.CalculateHoldingPerformance(other, models.Period1Year); !errors.Is(err, ErrNoPriceHistory) {
+		t.Errorf("No history: got %v, want ErrNoPriceHistory", err)
+	}
+	unlisted := models.NewHolding(uuid.New(), "", "Rental property", "Other")
+	if _, err := svc.CalculateHoldingPerformance(unlisted, models.Period1Year); !errors.Is(err, ErrNoPriceHistory) {
+		t.Errorf("No ticker: got %v, want ErrNoPriceHistory", err)
+	}
+
+	// Editing a holding's classification doesn't take away its prices
+	holding.IsManualEntry = true
+	holding.AssetClass = models.AssetClassAlternative
+	if metrics, err := svc.CalculateHoldingPerformance(holding, models.Period1Year); err != nil || !metrics.TotalReturn.Equal(decimal.NewFromInt(10)) {
+		t.Errorf("Edited holding: got %v, %v, want a 10%% return", metrics, err)
+	}
+}
@@ -0,0 +1,202 @@
+package analytics
+
+import (
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoIRR is returned when cash flows have no rate that zeroes their NPV,
+// such as when they're all contributions or all withdrawals
+var ErrNoIRR = errors.New("cash flows have no internal rate of return")
+
+const (
+	// irrTolerance is how close to zero the NPV must get, relative to the
+	// largest flow
+	irrTolerance = 1e-9
+
+	// newtonIterations caps Newton's method before falling back to bisection
+	newtonIterations = 50
+
+	// bisectionIterations is enough to narrow any bracket to float precision
+	bisectionIterations = 200
+
+	daysPerYear = 365.0
+)
+
+// XIRR returns the annualized rate (0.1 = 10%) at which the net present
+// value of dated cash flows is zero. It tries Newton's method first and
+// bisects when Newton fails to converge or wanders out of range.
+func XIRR(flows []models.CashFlow) (float64, error) {
+	if len(flows) < 2 {
+		return 0, ErrNoIRR
+	}
+
+	sorted := make([]models.CashFlow, len(flows))
+	copy(sorted, flows)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	// Years from the first flow, and amounts as floats
+	start := sorted[0].Date
+	years := make([]float64, len(sorted))
+	amounts := make([]float64, len(sorted))
+	var hasIn, hasOut bool
+	scale := 0.0
+	for i, f := range sorted {
+		years[i] = f.Date.Sub(start).Hours() / 24 / daysPerYear
+		amounts[i] = f.Amount.InexactFloat64()
+		hasIn = hasIn || amounts[i] < 0
+		hasOut = hasOut || amounts[i] > 0
+		scale = math.Max(scale, math.Abs(amounts[i]))
+	}
+	if !hasIn || !hasOut {
+		return 0, ErrNoIRR
+	}
+	tolerance := irrTolerance * scale
+
+	npv := func(rate float64) (value, derivative float64) {
+		for i, amount := range amounts {
+			discount := math.Pow(1+rate, years[i])
+			value += amount / discount
+			derivative -= years[i] * amount / (discount * (1 + rate))
+		}
+		return value, derivative
+	}
+
+	// Newton's method from a typical market return
+	rate := 0.1
+	for i := 0; i < newtonIterations; i++ {
+		value, derivative := npv(rate)
+		if math.Abs(value) < tolerance {
+			return rate, nil
+		}
+		if derivative == 0 || math.IsNaN(derivative) {
+			break
+		}
+		rate -= value / derivative
+		if rate <= -1 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+			break
+		}
+	}
+
+	return bisectIRR(npv, tolerance)
+}
+
+// bisectIRR finds the root of npv by first bracketing a sign change between
+// just above -100% and an upper bound that doubles until one is found
+func bisectIRR(npv func(float64) (float64, float64), tolerance float64) (float64, error) {
+	low, high := -0.999999, 1.0
+	lowValue, _ := npv(low)
+	highValue, _ := npv(high)
+	for lowValue*highValue > 0 {
+		high *= 2
+		if high > 1e6 {
+			return 0, ErrNoIRR
+		}
+		highValue, _ = npv(high)
+	}
+
+	for i := 0; i < bisectionIterations; i++ {
+		mid := (low + high) / 2
+		value, _ := npv(mid)
+		if math.Abs(value) < tolerance || high-low < 1e-12 {
+			return mid, nil
+		}
+		if (value < 0) == (lowValue < 0) {
+			low, lowValue = mid, value
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2, nil
+}
+
+// TransactionSource supplies a portfolio's recorded purchases and sales
+type TransactionSource interface {
+	GetByPortfolioID(portfolioID uuid.UUID) ([]models.Transaction, error)
+}
+
+// SetTransactionSource dates contributions from recorded transactions
+// instead of from when holdings were imported
+func (s *Service) SetTransactionSource(src TransactionSource) {
+	s.transactions = src
+}
+
+// portfolioCashFlows reconstructs a portfolio's contributions. Tickers with
+// recorded transactions contribute each purchase and sale on its date;
+// otherwise a holding's cost basis went in on the day it was imported. The
+// value of the holdings those flows bought comes out now; holdings with
+// neither transactions nor a cost basis are left out, since what they cost
+// isn't known. ok is false when no contribution is known or everything was
+// bought today, since a rate over a single day isn't meaningful.
+func portfolioCashFlows(portfolio *models.Portfolio, transactions []models.Transaction, now time.Time) ([]models.CashFlow, bool) {
+	var flows []models.CashFlow
+	earliest := now
+	add := func(date time.Time, amount decimal.Decimal) {
+		flows = append(flows, models.CashFlow{Date: date, Amount: amount})
+		if date.Before(earliest) {
+			earliest = date
+		}
+	}
+
+	traded := make(map[string]bool)
+	for _, t := range transactions {
+		traded[strings.ToUpper(t.Ticker)] = true
+		if t.Type == models.TransactionSell {
+			add(t.Date, t.Amount())
+		} else {
+			add(t.Date, t.Amount().Neg())
+		}
+	}
+
+	var ending decimal.Decimal
+	for _, h := range portfolio.Holdings {
+		if traded[strings.ToUpper(h.Ticker)] {
+			ending = ending.Add(h.MarketValue)
+			continue
+		}
+		if !h.CostBasis.IsPositive() || h.ImportedAt.IsZero() {
+			continue
+		}
+		add(h.ImportedAt, h.CostBasis.Neg())
+		ending = ending.Add(h.MarketValue)
+	}
+	if len(flows) == 0 || now.Sub(earliest) < 24*time.Hour || !ending.IsPositive() {
+		return nil, false
+	}
+
+	return append(flows, models.CashFlow{Date: now, Amount: ending}), true
+}
+
+// moneyWeightedReturn is the portfolio's XIRR as a percentage, or nil when
+// its cash flows aren't known
+func (s *Service) moneyWeightedReturn(portfolio *models.Portfolio) *decimal.Decimal {
+	var transactions []models.Transaction
+	if s.transactions != nil {
+		var err error
+		if transactions, err = s.transactions.GetByPortfolioID(portfolio.ID); err != nil {
+			log.Printf("analytics: loading transactions: %v", err)
+			return nil
+		}
+	}
+
+	flows, ok := portfolioCashFlows(portfolio, transactions, time.Now().UTC())
+	if !ok {
+		return nil
+	}
+
+	rate, err := XIRR(flows)
+	if err != nil {
+		return nil
+	}
+
+	mwr := decimal.NewFromFloat(rate * 100).Round(2)
+	return &mwr
+}
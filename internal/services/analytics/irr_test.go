@@ -0,0 +1,144 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestXIRR(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	flow := func(days int, amount float64) models.CashFlow {
+		return models.CashFlow{Date: start.AddDate(0, 0, days), Amount: decimal.NewFromFloat(amount)}
+	}
+
+	tests := []struct {
+		name  string
+		flows []models.CashFlow
+		want  float64
+	}{
+		{"One year at 10%", []models.CashFlow{flow(0, -1000), flow(365, 1100)}, 0.10},
+		{"Loss", []models.CashFlow{flow(0, -1000), flow(365, 800)}, -0.20},
+		{"Tenfold", []models.CashFlow{flow(0, -100), flow(365, 1000)}, 9.0},
+		{"Unsorted", []models.CashFlow{flow(365, 1100), flow(0, -1000)}, 0.10},
+	}
+
+	for _, tt := range tests {
+		got, err := XIRR(tt.flows)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("%s: got %.6f, want %.6f", tt.name, got, tt.want)
+		}
+	}
+
+	// A mid-year contribution: the rate must zero the NPV
+	flows := []models.CashFlow{flow(0, -10000), flow(182, -5000), flow(365, 16500)}
+	rate, err := XIRR(flows)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	npv := 0.0
+	for _, f := range flows {
+		years := f.Date.Sub(start).Hours() / 24 / daysPerYear
+		npv += f.Amount.InexactFloat64() / math.Pow(1+rate, years)
+	}
+	if math.Abs(npv) > 1e-3 {
+		t.Errorf("NPV at %.6f: got %.6f, want 0", rate, npv)
+	}
+
+	// Bisection alone lands on the same root Newton would
+	npvAt := func(rate float64) (float64, float64) { return -1000 + 1100/(1+rate), 0 }
+	if got, err := bisectIRR(npvAt, 1e-9); err != nil || math.Abs(got-0.10) > 1e-6 {
+		t.Errorf("Bisection: got %.6f, %v, want 0.100000", got, err)
+	}
+
+	// Only contributions have no rate
+	if _, err := XIRR([]models.CashFlow{flow(0, -100), flow(30, -100)}); err != ErrNoIRR {
+		t.Errorf("All contributions: got %v, want %v", err, ErrNoIRR)
+	}
+}
+
+func TestCalculatePortfolioPerformance_MoneyWeighted(t *testing.T) {
+	svc := NewService()
+
+	holding := models.Holding{
+		Ticker:      "VTI",
+		AssetClass:  models.AssetClassEquity,
+		CostBasis:   decimal.NewFromInt(1000),
+		MarketValue: decimal.NewFromInt(1100),
+		ImportedAt:  time.Now().UTC().AddDate(-1, 0, 0),
+	}
+	portfolio := &models.Portfolio{
+		ID:         uuid.New(),
+		Holdings:   []models.Holding{holding},
+		TotalValue: decimal.NewFromInt(1100),
+	}
+
+	perf := svc.CalculatePortfolioPerformance(portfolio, models.Period1Year)
+	if perf.MoneyWeightedReturn == nil {
+		t.Fatal("Expected a money-weighted return")
+	}
+	if got := perf.MoneyWeightedReturn.InexactFloat64(); math.Abs(got-10) > 0.1 {
+		t.Errorf("Money-weighted return: got %.2f, want about 10", got)
+	}
+	if perf.AnnualizedReturn.IsZero() {
+		t.Error("Expected the time-weighted return alongside")
+	}
+
+	// Just imported: contributions aren't known yet
+	portfolio.Holdings[0].ImportedAt = time.Now().UTC()
+	if perf := svc.CalculatePortfolioPerformance(portfolio, models.Period1Year); perf.MoneyWeightedReturn != nil {
+		t.Errorf("Fresh import: got %s, want no money-weighted return", perf.MoneyWeightedReturn)
+	}
+}
+
+type staticTransactions []models.Transaction
+
+func (s staticTransactions) GetByPortfolioID(uuid.UUID) ([]models.Transaction, error) {
+	return s, nil
+}
+
+func TestCalculatePortfolioPerformance_MoneyWeightedFromTransactions(t *testing.T) {
+	now := time.Now().UTC()
+	holding := func(ticker string, basis, value int64) models.Holding {
+		return models.Holding{
+			Ticker:      ticker,
+			AssetClass:  models.AssetClassEquity,
+			CostBasis:   decimal.NewFromInt(basis),
+			MarketValue: decimal.NewFromInt(value),
+			ImportedAt:  now.AddDate(0, -1, 0),
+		}
+	}
+	portfolio := &models.Portfolio{
+		ID:         uuid.New(),
+		Holdings:   []models.Holding{holding("VTI", 1000, 1210), holding("GIFT", 0, 5000)},
+		TotalValue: decimal.NewFromInt(6210),
+	}
+
+	// Bought two years ago, not when it was imported last month; the holding
+	// without a cost basis doesn't count as growth
+	svc := NewService()
+	buy := models.NewTransaction(portfolio.ID, "vti", models.TransactionBuy, decimal.NewFromInt(10), decimal.NewFromInt(100), now.AddDate(-2, 0, 0))
+	svc.SetTransactionSource(staticTransactions{*buy})
+	perf := svc.CalculatePortfolioPerformance(portfolio, models.Period1Year)
+	if perf.MoneyWeightedReturn == nil {
+		t.Fatal("Expected a money-weighted return")
+	}
+	if got := perf.MoneyWeightedReturn.InexactFloat64(); math.Abs(got-10) > 0.1 {
+		t.Errorf("Money-weighted return: got %.2f, want about 10", got)
+	}
+
+	// Without transactions or any cost basis there's no contribution to
+	// measure against
+	portfolio.Holdings = portfolio.Holdings[1:]
+	if perf := NewService().CalculatePortfolioPerformance(portfolio, models.Period1Year); perf.MoneyWeightedReturn != nil {
+		t.Errorf("No cost basis: got %s, want no money-weighted return", perf.MoneyWeightedReturn)
+	}
+}
@@ -0,0 +1,165 @@
+package analytics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrOversold is returned when a sale is for more shares than were bought
+// before it
+var ErrOversold = errors.New("sale exceeds shares held")
+
+// openLot is shares from one purchase that haven't been sold yet
+type openLot struct {
+	acquiredAt time.Time
+	quantity   decimal.Decimal
+	cost       decimal.Decimal // Of the remaining shares, including fees
+}
+
+// position is the open lots of one security in one account, oldest first
+type position struct {
+	lots []openLot
+}
+
+func (p *position) quantity() decimal.Decimal {
+	total := decimal.Zero
+	for _, lot := range p.lots {
+		total = total.Add(lot.quantity)
+	}
+	return total
+}
+
+func (p *position) cost() decimal.Decimal {
+	total := decimal.Zero
+	for _, lot := range p.lots {
+		total = total.Add(lot.cost)
+	}
+	return total
+}
+
+// RealizedGains matches each sale against earlier purchases of the same
+// security in the same account and returns the gain on every lot sold,
+// oldest sale first. Shares always leave in the order they were bought, so
+// holding periods are the same under either method; FIFO takes each lot's
+// own cost and average takes the average cost of everything held.
+func RealizedGains(transactions []models.Transaction, method models.CostBasisMethod) ([]models.RealizedGain, error) {
+	sorted := make([]models.Transaction, len(transactions))
+	copy(sorted, transactions)
+	// Same-day purchases come before sales, so a day trade has shares to sell
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Date.Equal(sorted[j].Date) {
+			return sorted[i].Date.Before(sorted[j].Date)
+		}
+		return sorted[i].Type == models.TransactionBuy && sorted[j].Type == models.TransactionSell
+	})
+
+	positions := make(map[string]*position)
+	var gains []models.RealizedGain
+	for _, t := range sorted {
+		key := t.AccountName + "|" + t.Ticker
+		pos, ok := positions[key]
+		if !ok {
+			pos = &position{}
+			positions[key] = pos
+		}
+
+		if t.Type == models.TransactionBuy {
+			pos.lots = append(pos.lots, openLot{acquiredAt: t.Date, quantity: t.Quantity, cost: t.Amount()})
+			continue
+		}
+
+		held := pos.quantity()
+		if t.Quantity.GreaterThan(held) {
+			return nil, fmt.Errorf("%w: %s sold %s on %s with %s held", ErrOversold,
+				t.Ticker, t.Quantity, t.Date.Format("2006-01-02"), held)
+		}
+		averageCost := decimal.Zero
+		if !held.IsZero() {
+			averageCost = pos.cost().Div(held)
+		}
+
+		// Proceeds are shared across the lots sold by quantity
+		proceedsPerShare := t.Amount().Div(t.Quantity)
+		remaining := t.Quantity
+		for remaining.IsPositive() {
+			lot := &pos.lots[0]
+			sold := decimal.Min(remaining, lot.quantity)
+
+			var cost decimal.Decimal
+			if sold.Equal(lot.quantity) {
+				cost = lot.cost
+			} else {
+				cost = lot.cost.Mul(sold).Div(lot.quantity)
+			}
+			lot.quantity = lot.quantity.Sub(sold)
+			lot.cost = lot.cost.Sub(cost)
+			if method == models.CostBasisAverage {
+				cost = averageCost.Mul(sold)
+			}
+
+			proceeds := proceedsPerShare.Mul(sold)
+			gains = append(gains, models.RealizedGain{
+				Ticker:      t.Ticker,
+				AccountName: t.AccountName,
+				AcquiredAt:  lot.acquiredAt,
+				SoldAt:      t.Date,
+				Quantity:    sold,
+				Proceeds:    proceeds.Round(2),
+				CostBasis:   cost.Round(2),
+				Gain:        proceeds.Sub(cost).Round(2),
+				LongTerm:    t.Date.After(lot.acquiredAt.AddDate(1, 0, 0)),
+			})
+
+			remaining = remaining.Sub(sold)
+			if lot.quantity.IsZero() {
+				pos.lots = pos.lots[1:]
+			}
+		}
+
+		// Under average cost, the shares left keep the average
+		if method == models.CostBasisAverage {
+			for i := range pos.lots {
+				pos.lots[i].cost = averageCost.Mul(pos.lots[i].quantity)
+			}
+		}
+	}
+
+	return gains, nil
+}
+
+// CalculateRealizedGains reports a portfolio's realized gains, totalling
+// those from sales this calendar year so far
+func (s *Service) CalculateRealizedGains(transactions []models.Transaction, method models.CostBasisMethod, now time.Time) (*models.RealizedGainsReport, error) {
+	gains, err := RealizedGains(transactions, method)
+	if err != nil {
+		return nil, err
+	}
+	if gains == nil {
+		gains = []models.RealizedGain{}
+	}
+
+	report := &models.RealizedGainsReport{
+		Method: method,
+		Year:   now.Year(),
+		Gains:  gains,
+	}
+	for _, g := range gains {
+		report.TotalGain = report.TotalGain.Add(g.Gain)
+		if g.SoldAt.Year() != now.Year() || g.SoldAt.After(now) {
+			continue
+		}
+		report.YTDGain = report.YTDGain.Add(g.Gain)
+		if g.LongTerm {
+			report.YTDLongTerm = report.YTDLongTerm.Add(g.Gain)
+		} else {
+			report.YTDShortTerm = report.YTDShortTerm.Add(g.Gain)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func realizedTransactions() []models.Transaction {
+	tx := func(ticker string, txType models.TransactionType, quantity, price, fees int64, date string) models.Transaction {
+		d, _ := time.Parse("2006-01-02", date)
+		t := models.NewTransaction(uuid.New(), ticker, txType, decimal.NewFromInt(quantity), decimal.NewFromInt(price), d)
+		t.Fees = decimal.NewFromInt(fees)
+		return *t
+	}
+	// Out of order, as they might be entered
+	return []models.Transaction{
+		tx("AAPL", models.TransactionSell, 5, 100, 0, "2024-06-01"),
+		tx("AAPL", models.TransactionBuy, 10, 100, 0, "2022-01-10"),
+		tx("AAPL", models.TransactionBuy, 10, 150, 0, "2023-06-01"),
+		tx("AAPL", models.TransactionSell, 15, 200, 0, "2024-03-01"),
+		tx("VTI", models.TransactionBuy, 5, 200, 5, "2023-01-01"),
+		tx("VTI", models.TransactionSell, 5, 220, 5, "2023-02-01"),
+	}
+}
+
+func TestRealizedGains(t *testing.T) {
+	tests := []struct {
+		method models.CostBasisMethod
+		gains  []string // Gain on each lot sold, oldest sale first
+	}{
+		{models.CostBasisFIFO, []string{"90", "1000", "250", "-250"}},
+		{models.CostBasisAverage, []string{"90", "750", "375", "-125"}},
+	}
+
+	for _, tt := range tests {
+		gains, err := RealizedGains(realizedTransactions(), tt.method)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.method, err)
+		}
+		if len(gains) != len(tt.gains) {
+			t.Fatalf("%s: got %d gains, want %d", tt.method, len(gains), len(tt.gains))
+		}
+		for i, want := range tt.gains {
+			if !gains[i].Gain.Equal(decimal.RequireFromString(want)) {
+				t.Errorf("%s gain %d (%s %s): got %s, want %s", tt.method, i, gains[i].Ticker, gains[i].Quantity, gains[i].Gain, want)
+			}
+		}
+
+		// The first AAPL lot was held over a year; the second wasn't
+		if !gains[1].LongTerm || gains[2].LongTerm {
+			t.Errorf("%s: got long-term %v and %v, want true and false", tt.method, gains[1].LongTerm, gains[2].LongTerm)
+		}
+		if !gains[2].Quantity.Equal(decimal.NewFromInt(5)) || gains[2].AcquiredAt.Year() != 2023 {
+			t.Errorf("%s: second lot sold: got %s acquired %s", tt.method, gains[2].Quantity, gains[2].AcquiredAt)
+		}
+	}
+}
+
+func TestRealizedGains_Oversold(t *testing.T) {
+	date := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	buy := models.NewTransaction(uuid.New(), "AAPL", models.TransactionBuy, decimal.NewFromInt(10), decimal.NewFromInt(100), date)
+	buy.AccountName = "IRA"
+	sell := models.NewTransaction(uuid.New(), "AAPL", models.TransactionSell, decimal.NewFromInt(5), decimal.NewFromInt(120), date.AddDate(0, 1, 0))
+	sell.AccountName = "Taxable"
+
+	// Shares in one account can't be sold from another
+	_, err := RealizedGains([]models.Transaction{*buy, *sell}, models.CostBasisFIFO)
+	if !errors.Is(err, ErrOversold) {
+		t.Errorf("got %v, want ErrOversold", err)
+	}
+}
+
+func TestService_CalculateRealizedGains(t *testing.T) {
+	svc := NewService()
+	now := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		method                          models.CostBasisMethod
+		ytd, shortTerm, longTerm, total string
+	}{
+		{models.CostBasisFIFO, "1000", "0", "1000", "1090"},
+		{models.CostBasisAverage, "1000", "250", "750", "1090"},
+	}
+
+	for _, tt := range tests {
+		report, err := svc.CalculateRealizedGains(realizedTransactions(), tt.method, now)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.method, err)
+		}
+		if report.Year != 2024 || report.Method != tt.method {
+			t.Errorf("%s: got year %d, method %s", tt.method, report.Year, report.Method)
+		}
+		for _, c := range []struct {
+			name      string
+			got, want decimal.Decimal
+		}{
+			{"YTD", report.YTDGain, decimal.RequireFromString(tt.ytd)},
+			{"YTD short-term", report.YTDShortTerm, decimal.RequireFromString(tt.shortTerm)},
+			{"YTD long-term", report.YTDLongTerm, decimal.RequireFromString(tt.longTerm)},
+			{"Total", report.TotalGain, decimal.RequireFromString(tt.total)},
+		} {
+			if !c.got.Equal(c.want) {
+				t.Errorf("%s %s: got %s, want %s", tt.method, c.name, c.got, c.want)
+			}
+		}
+	}
+}
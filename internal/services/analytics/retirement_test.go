@@ -0,0 +1,62 @@
+package analytics
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestService_ProjectRetirement(t *testing.T) {
+	svc := NewService()
+	portfolio := &models.Portfolio{
+		TotalValue: decimal.NewFromInt(1000000),
+		Holdings: []models.Holding{
+			{Ticker: "VTI", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(600000)},
+			{Ticker: "BND", AssetClass: models.AssetClassFixedIncome, MarketValue: decimal.NewFromInt(400000)},
+		},
+	}
+	plan := func(spending int64) models.RetirementPlan {
+		return models.RetirementPlan{
+			CurrentValue:   portfolio.TotalValue,
+			AnnualSpending: decimal.NewFromInt(spending),
+		}
+	}
+
+	if p := svc.ProjectRetirement(portfolio, plan(0)); !p.SuccessProbability.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("No spending: got %s%% success, want 100%%", p.SuccessProbability)
+	}
+	if p := svc.ProjectRetirement(portfolio, plan(500000)); p.SuccessProbability.GreaterThan(decimal.NewFromInt(1)) {
+		t.Errorf("Spending half the portfolio a year: got %s%% success, want about 0%%", p.SuccessProbability)
+	}
+
+	// Spending the safe withdrawal lasts in about RetirementConfidence percent of trials
+	projection := svc.ProjectRetirement(portfolio, plan(40000))
+	if projection.Plan.RetirementYears != models.DefaultRetirementYears {
+		t.Errorf("Expected default retirement of %d years, got %d", models.DefaultRetirementYears, projection.Plan.RetirementYears)
+	}
+	safe := svc.ProjectRetirement(portfolio, models.RetirementPlan{
+		CurrentValue:   portfolio.TotalValue,
+		AnnualSpending: projection.SafeWithdrawal,
+	})
+	if got := safe.SuccessProbability.InexactFloat64(); got < models.RetirementConfidence-1 || got > models.RetirementConfidence+1 {
+		t.Errorf("Spending the safe withdrawal of %s: got %.1f%% success, want about %d%%",
+			projection.SafeWithdrawal, got, models.RetirementConfidence)
+	}
+
+	// Saving for ten more years first helps
+	saving := plan(40000)
+	saving.YearsToRetirement = 10
+	saving.AnnualContribution = decimal.NewFromInt(20000)
+	later := svc.ProjectRetirement(portfolio, saving)
+	if !later.SuccessProbability.GreaterThan(projection.SuccessProbability) {
+		t.Errorf("Expected saving longer to raise success above %s%%, got %s%%", projection.SuccessProbability, later.SuccessProbability)
+	}
+	if !later.MedianAtRetirement.GreaterThan(portfolio.TotalValue) {
+		t.Errorf("Expected growth before retirement, got median %s", later.MedianAtRetirement)
+	}
+
+	if svc.ProjectRetirement(&models.Portfolio{}, plan(40000)) != nil {
+		t.Error("Expected nil for an empty portfolio")
+	}
+}
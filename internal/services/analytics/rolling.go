@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidWindow is returned for a rolling window that isn't supported
+var ErrInvalidWindow = errors.New("invalid rolling window")
+
+// rollingWindows are the supported rolling return windows
+var rollingWindows = map[string]bool{
+	models.Period1Month: true,
+	models.Period3Month: true,
+	models.Period6Month: true,
+	models.Period1Year:  true,
+	models.Period3Year:  true,
+	models.Period5Year:  true,
+}
+
+// rollingHistoryPeriod is how far back the value series goes when no
+// snapshots are available
+const rollingHistoryPeriod = models.Period5Year
+
+// GenerateRollingReturns returns the portfolio's rolling returns over the
+// given window. Recorded value snapshots are used when there are any;
+// otherwise the series is estimated the same way as GenerateTimeSeries.
+func (s *Service) GenerateRollingReturns(portfolio *models.Portfolio, snapshots []models.ValueSnapshot, window string) ([]models.RollingReturnPoint, error) {
+	if !rollingWindows[window] {
+		return nil, ErrInvalidWindow
+	}
+
+	var series []models.TimeSeriesPoint
+	if len(snapshots) > 0 {
+		series = make([]models.TimeSeriesPoint, len(snapshots))
+		for i, snap := range snapshots {
+			series[i] = models.TimeSeriesPoint{Date: snap.Date, Value: snap.TotalValue}
+		}
+	} else {
+		series = s.GenerateTimeSeries(portfolio, rollingHistoryPeriod)
+	}
+
+	return RollingReturns(series, models.GetPeriodDuration(window)), nil
+}
+
+// RollingReturns slides a window over a value series, emitting the return
+// from the last point at least one window earlier to each later point.
+// A window longer than the series yields an empty result.
+func RollingReturns(series []models.TimeSeriesPoint, window time.Duration) []models.RollingReturnPoint {
+	sorted := make([]models.TimeSeriesPoint, len(series))
+	copy(sorted, series)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	points := make([]models.RollingReturnPoint, 0)
+	start := -1 // Last point at or before the current window's start
+	for _, end := range sorted {
+		windowStart := end.Date.Add(-window)
+		for start+1 < len(sorted) && !sorted[start+1].Date.After(windowStart) {
+			start++
+		}
+		if start < 0 || sorted[start].Value.IsZero() {
+			continue
+		}
+
+		ret := end.Value.Sub(sorted[start].Value).Div(sorted[start].Value).Mul(decimal.NewFromInt(100))
+		points = append(points, models.RollingReturnPoint{
+			Date:          end.Date,
+			RollingReturn: ret.Round(2),
+		})
+	}
+
+	return points
+}
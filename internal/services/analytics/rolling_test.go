@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestRollingReturns(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Monthly values growing 1% a month for two years
+	var series []models.TimeSeriesPoint
+	value := decimal.NewFromInt(100)
+	for m := 0; m <= 24; m++ {
+		series = append(series, models.TimeSeriesPoint{Date: start.AddDate(0, m, 0), Value: value})
+		value = value.Mul(decimal.NewFromFloat(1.01))
+	}
+
+	rolling := RollingReturns(series, 365*24*time.Hour)
+	if len(rolling) != 13 {
+		t.Fatalf("Points: got %d, want 13", len(rolling))
+	}
+	if first := rolling[0].Date; !first.Equal(start.AddDate(1, 0, 0)) {
+		t.Errorf("First point: got %s, want %s", first, start.AddDate(1, 0, 0))
+	}
+	// Twelve months of 1% compounding, measured over the last full year
+	want := decimal.NewFromFloat(12.68)
+	for _, p := range rolling {
+		if !p.RollingReturn.Equal(want) {
+			t.Errorf("%s: got %s, want %s", p.Date.Format("2006-01-02"), p.RollingReturn, want)
+		}
+	}
+
+	// A window longer than the history is empty, not an error
+	if got := RollingReturns(series, 5*365*24*time.Hour); got == nil || len(got) != 0 {
+		t.Errorf("Long window: got %v, want empty series", got)
+	}
+}
+
+func TestGenerateRollingReturns(t *testing.T) {
+	svc := NewService()
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{Ticker: "VTI", AssetClass: models.AssetClassEquity, MarketValue: decimal.NewFromInt(1000)},
+		},
+		TotalValue: decimal.NewFromInt(1000),
+	}
+
+	if _, err := svc.GenerateRollingReturns(portfolio, nil, "2w"); err != ErrInvalidWindow {
+		t.Errorf("Invalid window: got %v, want %v", err, ErrInvalidWindow)
+	}
+
+	rolling, err := svc.GenerateRollingReturns(portfolio, nil, models.Period1Year)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rolling) == 0 {
+		t.Error("Expected estimated rolling returns")
+	}
+
+	// Snapshots take precedence over the estimate
+	now := time.Now().UTC()
+	snapshots := []models.ValueSnapshot{
+		{Date: now.AddDate(0, -6, 0), TotalValue: decimal.NewFromInt(800)},
+		{Date: now.AddDate(0, -3, 0), TotalValue: decimal.NewFromInt(900)},
+		{Date: now, TotalValue: decimal.NewFromInt(1000)},
+	}
+	rolling, _ = svc.GenerateRollingReturns(portfolio, snapshots, models.Period3Month)
+	if len(rolling) != 2 || !rolling[1].RollingReturn.Equal(decimal.NewFromFloat(11.11)) {
+		t.Errorf("From snapshots: got %+v, want 2 points ending at 11.11", rolling)
+	}
+}
@@ -3,13 +3,18 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/findosh/truenorth/internal/config"
 	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/mail"
 	"github.com/findosh/truenorth/internal/storage"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
@@ -21,21 +26,29 @@ var (
 	ErrEmailExists        = errors.New("email already registered")
 	ErrSessionExpired     = errors.New("session expired")
 	ErrInvalidToken       = errors.New("invalid token")
+	ErrTokenExpired       = errors.New("token expired")
 )
 
+// verificationTTL is how long an email verification link works
+const verificationTTL = 48 * time.Hour
+
 // Service handles authentication operations
 type Service struct {
-	cfg         *config.Config
-	userRepo    *storage.UserRepository
-	sessionRepo *storage.SessionRepository
+	cfg              *config.Config
+	userRepo         *storage.UserRepository
+	sessionRepo      *storage.SessionRepository
+	verificationRepo *storage.EmailVerificationRepository
+	mailer           mail.Sender
 }
 
 // NewService creates a new auth service
-func NewService(cfg *config.Config, userRepo *storage.UserRepository, sessionRepo *storage.SessionRepository) *Service {
+func NewService(cfg *config.Config, userRepo *storage.UserRepository, sessionRepo *storage.SessionRepository, verificationRepo *storage.EmailVerificationRepository, mailer mail.Sender) *Service {
 	return &Service{
-		cfg:         cfg,
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
+		cfg:              cfg,
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		verificationRepo: verificationRepo,
+		mailer:           mailer,
 	}
 }
 
@@ -72,19 +85,84 @@ func (s *Service) Register(input RegisterInput) (*models.User, error) {
 	return user, nil
 }
 
+// SendVerification emails the user a link to confirm their address. Links
+// sent earlier stop working.
+func (s *Service) SendVerification(user *models.User) error {
+	if user.Verified {
+		return nil
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return fmt.Errorf("failed to create verification token: %w", err)
+	}
+	if err := s.verificationRepo.DeleteByUserID(user.ID); err != nil {
+		return fmt.Errorf("failed to replace verification token: %w", err)
+	}
+	if err := s.verificationRepo.Create(user.ID, hashToken(token), time.Now().UTC().Add(verificationTTL)); err != nil {
+		return fmt.Errorf("failed to store verification token: %w", err)
+	}
+
+	link := s.cfg.BaseURL + "/auth/verify?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nConfirm your email address for TrueNorth by opening this link:\n\n%s\n\nThe link expires in 48 hours. If you didn't create an account, you can ignore this email.\n", user.Name, link)
+	if err := s.mailer.Send(user.Email, "Confirm your TrueNorth email", body); err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
+
+// Verify confirms the email address a verification token was sent to
+func (s *Service) Verify(token string) (*models.User, error) {
+	if token == "" {
+		return nil, ErrInvalidToken
+	}
+
+	userID, expiresAt, err := s.verificationRepo.GetUserID(hashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find verification token: %w", err)
+	}
+	if userID == uuid.Nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().After(expiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil || user == nil {
+		return nil, ErrInvalidToken
+	}
+	if err := s.userRepo.MarkVerified(user.ID); err != nil {
+		return nil, fmt.Errorf("failed to verify user: %w", err)
+	}
+	user.Verified = true
+
+	if err := s.verificationRepo.DeleteByUserID(user.ID); err != nil {
+		return nil, fmt.Errorf("failed to clear verification tokens: %w", err)
+	}
+	return user, nil
+}
+
 // LoginInput contains login credentials
 type LoginInput struct {
 	Email    string
 	Password string
 }
 
-// LoginResult contains the result of a successful login
+// LoginResult contains the result of a successful login or refresh
 type LoginResult struct {
-	User    *models.User
-	Token   string
-	Expires time.Time
+	User           *models.User
+	Token          string // Short-lived access token (JWT)
+	Expires        time.Time
+	RefreshToken   string // Opaque, stored server-side so it can be revoked
+	RefreshExpires time.Time
 }
 
+// refreshGrace is how long a refresh token keeps working after it has been
+// rotated, so a request already in flight with it doesn't log the user out.
+// It covers a single reuse.
+const refreshGrace = 30 * time.Second
+
 // Login authenticates a user and creates a session
 func (s *Service) Login(input LoginInput) (*LoginResult, error) {
 	// Find user
@@ -92,7 +170,7 @@ func (s *Service) Login(input LoginInput) (*LoginResult, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
-	if user == nil {
+	if user == nil || user.PasswordHash == "" {
 		return nil, ErrInvalidCredentials
 	}
 
@@ -101,34 +179,139 @@ func (s *Service) Login(input LoginInput) (*LoginResult, error) {
 		return nil, ErrInvalidCredentials
 	}
 
-	// Create session token
-	token, err := s.createToken(user)
+	return s.startSession(user)
+}
+
+// LoginWithProvider signs in a user whose email an identity provider has
+// verified, creating an account without a password if there isn't one.
+// An existing unverified account may have been registered by someone else
+// who knew the email, so the provider's user takes it over: its password is
+// removed and its sessions and pending verification links revoked.
+func (s *Service) LoginWithProvider(provider, email, name string) (*LoginResult, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if user == nil {
+		if name = strings.TrimSpace(name); name == "" {
+			name = email
+		}
+		user = models.NewUser(email, name, "")
+		user.AuthProvider = provider
+		user.Verified = true
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+	} else if !user.Verified {
+		if err := s.userRepo.ClaimForProvider(user.ID, provider); err != nil {
+			return nil, fmt.Errorf("failed to verify user: %w", err)
+		}
+		if err := s.sessionRepo.DeleteByUserID(user.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		if err := s.verificationRepo.DeleteByUserID(user.ID); err != nil {
+			return nil, fmt.Errorf("failed to revoke verification links: %w", err)
+		}
+		user.PasswordHash = ""
+		user.AuthProvider = provider
+		user.Verified = true
+	}
+
+	return s.startSession(user)
+}
+
+// Refresh exchanges a refresh token for a new access token. The refresh
+// token is rotated: a new one is returned and the one presented can be used
+// once more within the grace period, then stops working.
+func (s *Service) Refresh(refreshToken string) (*LoginResult, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.sessionRepo.GetByToken(hashToken(refreshToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find session: %w", err)
+	}
+	if session == nil {
+		return nil, ErrInvalidToken
+	}
+	if session.IsExpired() {
+		return nil, ErrSessionExpired
+	}
+
+	user, err := s.userRepo.GetByID(session.UserID)
+	if err != nil || user == nil {
+		return nil, ErrInvalidToken
+	}
+
+	// A session already ending within the grace period was rotated (or is
+	// about to expire anyway), so this is its last use
+	rotated, err := s.sessionRepo.ExpireAt(session.ID, time.Now().UTC().Add(refreshGrace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate session: %w", err)
+	}
+	if !rotated {
+		consumed, err := s.sessionRepo.Consume(session.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate session: %w", err)
+		}
+		if !consumed {
+			return nil, ErrInvalidToken
+		}
+	}
+	return s.startSession(user)
+}
+
+// RevokeRefreshToken ends the session a refresh token belongs to
+func (s *Service) RevokeRefreshToken(refreshToken string) error {
+	session, err := s.sessionRepo.GetByToken(hashToken(refreshToken))
+	if err != nil || session == nil {
+		return err
+	}
+	return s.sessionRepo.Delete(session.ID)
+}
+
+// startSession issues an access token and stores a new refresh token
+func (s *Service) startSession(user *models.User) (*LoginResult, error) {
+	now := time.Now().UTC()
+
+	token, expires, err := s.createToken(user)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token: %w", err)
 	}
 
-	expires := time.Now().UTC().Add(s.cfg.SessionDuration)
+	refreshToken, err := generateToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create refresh token: %w", err)
+	}
 
-	// Store session
+	// Store only a hash of the refresh token
 	session := &models.Session{
 		ID:        uuid.New(),
 		UserID:    user.ID,
-		Token:     token,
-		ExpiresAt: expires,
-		CreatedAt: time.Now().UTC(),
+		Token:     hashToken(refreshToken),
+		ExpiresAt: now.Add(s.cfg.SessionDuration),
+		CreatedAt: now,
 	}
 	if err := s.sessionRepo.Create(session); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
 	return &LoginResult{
-		User:    user,
-		Token:   token,
-		Expires: expires,
+		User:           user,
+		Token:          token,
+		Expires:        expires,
+		RefreshToken:   refreshToken,
+		RefreshExpires: session.ExpiresAt,
 	}, nil
 }
 
-// ValidateToken verifies a JWT token and returns the user
+// ValidateToken verifies a JWT access token and returns the user
 func (s *Service) ValidateToken(tokenString string) (*models.User, error) {
 	// Parse token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
@@ -204,18 +387,37 @@ func (s *Service) ChangePassword(userID uuid.UUID, oldPassword, newPassword stri
 	return s.sessionRepo.DeleteByUserID(userID)
 }
 
-func (s *Service) createToken(user *models.User) (string, error) {
+func (s *Service) createToken(user *models.User) (string, time.Time, error) {
+	expires := time.Now().UTC().Add(s.cfg.AccessTokenDuration)
 	claims := jwt.MapClaims{
 		"sub":   user.ID.String(),
 		"email": user.Email,
 		"name":  user.Name,
-		"exp":   time.Now().Add(s.cfg.SessionDuration).Unix(),
+		"exp":   expires.Unix(),
 		"iat":   time.Now().Unix(),
 		"jti":   generateJTI(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.cfg.SecretKey))
+	signed, err := token.SignedString([]byte(s.cfg.SecretKey))
+	return signed, expires, err
+}
+
+// generateToken returns a random opaque token for refresh tokens and
+// verification links
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how opaque tokens are stored, so a database leak doesn't
+// hand out working sessions or verification links
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 func generateJTI() string {
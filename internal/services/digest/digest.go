@@ -0,0 +1,382 @@
+// Package digest emails subscribed users a weekly summary of their
+// portfolios: value change, top movers, new alerts and fees
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"net/url"
+	"sort"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/findosh/truenorth/internal/services/mail"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+//go:embed digest.html digest.txt
+var templates embed.FS
+
+// ErrInvalidToken is returned for unsubscribe links that weren't issued
+// for the user
+var ErrInvalidToken = errors.New("invalid unsubscribe link")
+
+// PriceSource refreshes holdings' prices and supplies their recent history
+type PriceSource interface {
+	UpdatePortfolioValues(portfolio *models.Portfolio) ([]string, error)
+	GetHistoricalPricesBatch(tickers []string, period string) (map[string][]models.PriceHistory, error)
+}
+
+// Config holds the digest service's dependencies
+type Config struct {
+	BaseURL string // Public URL, for the unsubscribe link
+	Secret  string // Signs unsubscribe links
+
+	Users       *storage.UserRepository
+	Portfolios  *storage.PortfolioRepository
+	Scenarios   *storage.ScenarioRepository
+	AlertStates *storage.AlertStateRepository
+	Digests     *storage.DigestRepository
+
+	Analytics *analytics.Service
+	Prices    PriceSource
+	Mailer    mail.HTMLSender
+}
+
+// Service assembles weekly digests and sends them to the users who are due
+// one
+type Service struct {
+	cfg  Config
+	html *htmltemplate.Template
+	text *texttemplate.Template
+	now  func() time.Time
+}
+
+// NewService creates a digest service, parsing its email templates
+func NewService(cfg Config) (*Service, error) {
+	funcs := map[string]interface{}{
+		"money":   formatMoney,
+		"percent": formatPercent,
+	}
+	html, err := htmltemplate.New("digest.html").Funcs(funcs).ParseFS(templates, "digest.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest template: %w", err)
+	}
+	text, err := texttemplate.New("digest.txt").Funcs(funcs).ParseFS(templates, "digest.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse digest template: %w", err)
+	}
+
+	return &Service{cfg: cfg, html: html, text: text, now: time.Now}, nil
+}
+
+// Subscription returns the user's digest subscription
+func (s *Service) Subscription(userID uuid.UUID) (*models.DigestSubscription, error) {
+	return s.cfg.Digests.GetSubscription(userID)
+}
+
+// SetEnabled opts the user in to or out of the weekly digest
+func (s *Service) SetEnabled(userID uuid.UUID, enabled bool) error {
+	return s.cfg.Digests.SetEnabled(userID, enabled)
+}
+
+// Unsubscribe opts the user out through the link in a digest email
+func (s *Service) Unsubscribe(userID uuid.UUID, token string) error {
+	if !hmac.Equal([]byte(token), []byte(s.unsubscribeToken(userID))) {
+		return ErrInvalidToken
+	}
+	return s.cfg.Digests.SetEnabled(userID, false)
+}
+
+// unsubscribeToken signs the user's ID so unsubscribe links work without
+// signing in but can't be forged for someone else
+func (s *Service) unsubscribeToken(userID uuid.UUID) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.Secret))
+	mac.Write([]byte("digest-unsubscribe:" + userID.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribeURL is the one-click opt-out link for the user's digests
+func (s *Service) unsubscribeURL(userID uuid.UUID) string {
+	return s.cfg.BaseURL + "/digest/unsubscribe?user=" + userID.String() +
+		"&token=" + url.QueryEscape(s.unsubscribeToken(userID))
+}
+
+// Run sends due digests now and then every interval until ctx is done
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := s.SendDue(ctx); err != nil {
+			log.Printf("digest: %v", err)
+		} else if sent > 0 {
+			log.Printf("digest: sent %d weekly digest(s)", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDue sends a digest to every subscribed user who hasn't had one in
+// the last week, returning how many were sent. A failure for one user is
+// logged and doesn't stop the rest; a done ctx does, between users.
+func (s *Service) SendDue(ctx context.Context) (int, error) {
+	now := s.now().UTC()
+	due, err := s.cfg.Digests.GetDue(now.Add(-models.DigestInterval))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find due digests: %w", err)
+	}
+
+	sent := 0
+	for _, userID := range due {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		if err := s.send(userID, now); err != nil {
+			log.Printf("digest for user %s: %v", userID, err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// send builds, mails and records one user's digest
+func (s *Service) send(userID uuid.UUID, now time.Time) error {
+	user, err := s.cfg.Users.GetByID(userID)
+	if err != nil || user == nil {
+		return fmt.Errorf("user not found: %v", err)
+	}
+
+	digest, alertKeys, err := s.build(user, now)
+	if err != nil {
+		return err
+	}
+
+	text, html, err := s.Render(digest)
+	if err != nil {
+		return err
+	}
+	subject := "Your TrueNorth week ending " + digest.WeekEnding.Format("Jan 2")
+	if err := s.cfg.Mailer.SendHTML(user.Email, subject, text, html); err != nil {
+		return fmt.Errorf("failed to send: %w", err)
+	}
+
+	// Alerts only stop being new once a digest has reported them
+	for portfolioID, keys := range alertKeys {
+		if err := s.cfg.Digests.SetAlertKeys(portfolioID, keys); err != nil {
+			log.Printf("digest for portfolio %s: %v", portfolioID, err)
+		}
+	}
+	return s.cfg.Digests.MarkSent(userID, now)
+}
+
+// Build assembles the user's digest as it would be sent now, without
+// sending it or marking its alerts as reported
+func (s *Service) Build(user *models.User) (*models.Digest, error) {
+	digest, _, err := s.build(user, s.now().UTC())
+	return digest, err
+}
+
+// build assembles the digest along with the keys of each portfolio's
+// active alerts, to remember once it's sent
+func (s *Service) build(user *models.User, now time.Time) (*models.Digest, map[uuid.UUID][]string, error) {
+	summaries, _, err := s.cfg.Portfolios.GetByUserID(user.ID, storage.Page{Limit: storage.MaxPageSize})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load portfolios: %w", err)
+	}
+
+	digest := &models.Digest{
+		Name:           user.Name,
+		Email:          user.Email,
+		WeekEnding:     now,
+		Portfolios:     []models.PortfolioDigest{},
+		UnsubscribeURL: s.unsubscribeURL(user.ID),
+	}
+	alertKeys := make(map[uuid.UUID][]string)
+
+	for _, summary := range summaries {
+		portfolio, err := s.cfg.Portfolios.GetByID(summary.ID)
+		if err != nil || portfolio == nil {
+			log.Printf("digest: loading portfolio %s: %v", summary.ID, err)
+			continue
+		}
+
+		pd, keys := s.portfolioDigest(portfolio)
+		digest.Portfolios = append(digest.Portfolios, pd)
+		alertKeys[portfolio.ID] = keys
+	}
+
+	return digest, alertKeys, nil
+}
+
+// portfolioDigest summarizes one portfolio's week at current prices
+func (s *Service) portfolioDigest(portfolio *models.Portfolio) (models.PortfolioDigest, []string) {
+	unpriced, err := s.cfg.Prices.UpdatePortfolioValues(portfolio)
+	if err != nil {
+		log.Printf("digest: pricing portfolio %s: %v", portfolio.ID, err)
+	}
+	portfolio.CalculateTotals()
+
+	pd := models.PortfolioDigest{
+		PortfolioID: portfolio.ID,
+		Name:        portfolio.Name,
+		Value:       portfolio.TotalValue,
+		TopMovers:   s.topMovers(portfolio),
+		NewAlerts:   []models.Alert{},
+		Unpriced:    unpriced,
+	}
+
+	if perf := s.cfg.Analytics.CalculatePortfolioPerformance(portfolio, models.Period1Week); perf != nil {
+		pd.Change = perf.EndValue.Sub(perf.StartValue).Round(2)
+		pd.ChangePct = perf.TotalReturn
+	}
+	if expenses := s.cfg.Analytics.CalculateExpenses(portfolio); expenses != nil {
+		pd.ExpenseRatio = expenses.WeightedExpenseRatio
+		pd.AnnualFees = expenses.TotalAnnualExpenses
+	}
+
+	alerts, keys := s.newAlerts(portfolio)
+	pd.NewAlerts = append(pd.NewAlerts, alerts...)
+	return pd, keys
+}
+
+// newAlerts returns the portfolio's alerts that the last digest didn't
+// report, leaving out dismissed ones, along with every active alert's key
+func (s *Service) newAlerts(portfolio *models.Portfolio) ([]models.Alert, []string) {
+	var target *models.Scenario
+	if portfolio.TargetScenarioID != nil {
+		target, _ = s.cfg.Scenarios.GetByID(*portfolio.TargetScenarioID)
+	}
+	alerts := models.NewAlertDetector().DetectAlertsWithTarget(portfolio, portfolio.CalculateLookThroughAllocation(), target)
+
+	reported, err := s.cfg.Digests.GetAlertKeys(portfolio.ID)
+	if err != nil {
+		log.Printf("digest: loading reported alerts for portfolio %s: %v", portfolio.ID, err)
+	}
+	states, err := s.cfg.AlertStates.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		log.Printf("digest: loading alert states for portfolio %s: %v", portfolio.ID, err)
+	}
+
+	var fresh []models.Alert
+	keys := make([]string, 0, len(alerts))
+	for _, alert := range alerts {
+		key := alert.Key()
+		keys = append(keys, key)
+		if reported[key] {
+			continue
+		}
+		if state, ok := states[key]; ok && state.Hides(alert) {
+			continue
+		}
+		fresh = append(fresh, alert)
+	}
+	return fresh, keys
+}
+
+// topMovers returns the holdings whose prices moved the most over the
+// week, in either direction
+func (s *Service) topMovers(portfolio *models.Portfolio) []models.DigestMover {
+	var tickers []string
+	for _, h := range portfolio.Holdings {
+		if h.Ticker != "" && !h.IsCash() {
+			tickers = append(tickers, h.Ticker)
+		}
+	}
+	if len(tickers) == 0 {
+		return []models.DigestMover{}
+	}
+
+	histories, err := s.cfg.Prices.GetHistoricalPricesBatch(tickers, models.Period1Week)
+	if err != nil {
+		log.Printf("digest: loading price history for portfolio %s: %v", portfolio.ID, err)
+	}
+
+	movers := []models.DigestMover{}
+	for _, h := range portfolio.Holdings {
+		history := histories[h.Ticker]
+		if len(history) < 2 {
+			continue
+		}
+		first, last := history[0].Close, history[len(history)-1].Close
+		if first.IsZero() || last.IsZero() {
+			continue
+		}
+
+		// The holding's value moved in proportion to its price
+		move := last.Sub(first).Div(first)
+		movers = append(movers, models.DigestMover{
+			Ticker:    h.Ticker,
+			Name:      h.Name,
+			ChangePct: move.Mul(decimal.NewFromInt(100)).Round(2),
+			Change:    h.MarketValue.Mul(last.Sub(first)).Div(last).Round(2),
+		})
+	}
+
+	sort.SliceStable(movers, func(i, j int) bool {
+		a, b := movers[i].ChangePct.Abs(), movers[j].ChangePct.Abs()
+		if !a.Equal(b) {
+			return a.GreaterThan(b)
+		}
+		return movers[i].Ticker < movers[j].Ticker
+	})
+	if len(movers) > models.DigestTopMovers {
+		movers = movers[:models.DigestTopMovers]
+	}
+	return movers
+}
+
+// Render returns the digest's plain-text and HTML email bodies
+func (s *Service) Render(digest *models.Digest) (string, string, error) {
+	var text, html bytes.Buffer
+	if err := s.text.Execute(&text, digest); err != nil {
+		return "", "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := s.html.Execute(&html, digest); err != nil {
+		return "", "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	return text.String(), html.String(), nil
+}
+
+// formatMoney formats an amount as dollars with cents and thousands
+// separators, keeping its sign
+func formatMoney(d decimal.Decimal) string {
+	sign := ""
+	if d.IsNegative() {
+		sign = "-"
+		d = d.Abs()
+	}
+
+	s := d.StringFixed(2)
+	whole, cents := s[:len(s)-3], s[len(s)-3:]
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return sign + "$" + whole + cents
+}
+
+// formatPercent formats a percentage with an explicit sign
+func formatPercent(d decimal.Decimal) string {
+	if d.IsPositive() {
+		return "+" + d.StringFixed(2) + "%"
+	}
+	return d.StringFixed(2) + "%"
+}
@@ -0,0 +1,204 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/services/analytics"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/shopspring/decimal"
+)
+
+// fakePrices keeps holdings at their stored prices and gives every ticker
+// a week that ends 10% above where it started, except MSFT which fell 2%
+type fakePrices struct{}
+
+func (fakePrices) UpdatePortfolioValues(*models.Portfolio) ([]string, error) {
+	return []string{}, nil
+}
+
+func (fakePrices) GetHistoricalPricesBatch(tickers []string, period string) (map[string][]models.PriceHistory, error) {
+	histories := make(map[string][]models.PriceHistory)
+	for _, ticker := range tickers {
+		end := decimal.NewFromInt(110)
+		if ticker == "MSFT" {
+			end = decimal.NewFromInt(98)
+		}
+		histories[ticker] = []models.PriceHistory{
+			{Ticker: ticker, Close: decimal.NewFromInt(100)},
+			{Ticker: ticker, Close: end},
+		}
+	}
+	return histories, nil
+}
+
+type sentMail struct {
+	to, subject, text, html string
+}
+
+type recordingMailer struct {
+	sent []sentMail
+}
+
+func (m *recordingMailer) Send(to, subject, body string) error {
+	return m.SendHTML(to, subject, body, "")
+}
+
+func (m *recordingMailer) SendHTML(to, subject, text, html string) error {
+	m.sent = append(m.sent, sentMail{to, subject, text, html})
+	return nil
+}
+
+func newTestService(t *testing.T) (*Service, *recordingMailer, *models.User) {
+	t.Helper()
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	users := storage.NewUserRepository(db)
+	user := models.NewUser("digest@example.com", "Dana", "hash")
+	if err := users.Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	portfolios := storage.NewPortfolioRepository(db)
+	portfolio := models.NewPortfolio(user.ID, "Retirement")
+	if err := portfolios.Create(portfolio); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+	// AAPL is concentrated enough to raise an alert
+	for _, h := range []struct {
+		ticker string
+		value  int64
+	}{{"AAPL", 9000}, {"MSFT", 1000}} {
+		holding := models.NewHolding(portfolio.ID, h.ticker, h.ticker+" Inc.", "Brokerage")
+		holding.AssetClass = models.AssetClassEquity
+		holding.Quantity = decimal.NewFromInt(10)
+		holding.MarketValue = decimal.NewFromInt(h.value)
+		if err := storage.NewHoldingRepository(db).Create(holding); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+	}
+
+	mailer := &recordingMailer{}
+	svc, err := NewService(Config{
+		BaseURL:     "https://truenorth.example",
+		Secret:      "test-secret",
+		Users:       users,
+		Portfolios:  portfolios,
+		Scenarios:   storage.NewScenarioRepository(db),
+		AlertStates: storage.NewAlertStateRepository(db),
+		Digests:     storage.NewDigestRepository(db),
+		Analytics:   analytics.NewService(),
+		Prices:      fakePrices{},
+		Mailer:      mailer,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create service: %v", err)
+	}
+	return svc, mailer, user
+}
+
+func TestService_SendDue(t *testing.T) {
+	svc, mailer, user := newTestService(t)
+	now := time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	// Nothing goes out until the user opts in
+	if sent, err := svc.SendDue(context.Background()); err != nil || sent != 0 {
+		t.Fatalf("Before opting in: sent %d, err %v", sent, err)
+	}
+	if err := svc.SetEnabled(user.ID, true); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if sent, err := svc.SendDue(cancelled); !errors.Is(err, context.Canceled) || sent != 0 || len(mailer.sent) != 0 {
+		t.Fatalf("After cancelling: sent %d, err %v", sent, err)
+	}
+
+	if sent, err := svc.SendDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("First digest: sent %d, err %v", sent, err)
+	}
+	if len(mailer.sent) != 1 {
+		t.Fatalf("Expected one email, got %d", len(mailer.sent))
+	}
+	first := mailer.sent[0]
+	if first.to != user.Email || first.subject != "Your TrueNorth week ending Mar 8" {
+		t.Errorf("Sent %q to %s", first.subject, first.to)
+	}
+	for _, want := range []string{"Retirement", "$10,000.00", "AAPL  +10.00%", "MSFT  -2.00%", "New alerts:", "/digest/unsubscribe?user=" + user.ID.String()} {
+		if !strings.Contains(first.text, want) {
+			t.Errorf("Text body missing %q:\n%s", want, first.text)
+		}
+	}
+	if !strings.Contains(first.html, "<strong>AAPL</strong>") || !strings.Contains(first.html, "Unsubscribe</a>") {
+		t.Errorf("HTML body missing movers or unsubscribe link:\n%s", first.html)
+	}
+
+	// Not due again until a week has passed
+	now = now.Add(24 * time.Hour)
+	if sent, _ := svc.SendDue(context.Background()); sent != 0 {
+		t.Errorf("Sent %d digests a day later, want 0", sent)
+	}
+
+	// The following week's alerts have all been reported already
+	now = now.Add(models.DigestInterval)
+	if sent, err := svc.SendDue(context.Background()); err != nil || sent != 1 {
+		t.Fatalf("Second digest: sent %d, err %v", sent, err)
+	}
+	if second := mailer.sent[1]; strings.Contains(second.text, "New alerts:") {
+		t.Errorf("Second digest repeated alerts:\n%s", second.text)
+	}
+}
+
+func TestService_Unsubscribe(t *testing.T) {
+	svc, _, user := newTestService(t)
+	if err := svc.SetEnabled(user.ID, true); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+
+	if err := svc.Unsubscribe(user.ID, "forged"); err != ErrInvalidToken {
+		t.Errorf("Forged token: got %v, want ErrInvalidToken", err)
+	}
+
+	link, err := url.Parse(svc.unsubscribeURL(user.ID))
+	if err != nil {
+		t.Fatalf("Invalid unsubscribe URL: %v", err)
+	}
+	if err := svc.Unsubscribe(user.ID, link.Query().Get("token")); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+
+	sub, err := svc.Subscription(user.ID)
+	if err != nil || sub.Enabled {
+		t.Errorf("Subscription after unsubscribing: %+v, %v", sub, err)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := map[string]string{
+		"0":           "$0.00",
+		"12.5":        "$12.50",
+		"1234567.891": "$1,234,567.89",
+		"-4200":       "-$4,200.00",
+	}
+	for input, want := range tests {
+		if got := formatMoney(decimal.RequireFromString(input)); got != want {
+			t.Errorf("formatMoney(%s) = %s, want %s", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// cryptoSource is the Source of holdings imported from a crypto exchange
+const cryptoSource = "crypto_csv"
+
+// cryptoAssetColumns name the coin in exchange exports, which have no
+// symbol column
+var cryptoAssetColumns = []string{"asset", "coin", "currency", "cryptocurrency"}
+
+// fiatCurrencies are cash balances on an exchange rather than coins
+var fiatCurrencies = map[string]bool{
+	"USD": true, "EUR": true, "GBP": true, "CAD": true, "AUD": true, "CHF": true, "JPY": true,
+}
+
+// CryptoParser handles balance exports from crypto exchanges such as
+// Coinbase, Kraken and Gemini
+type CryptoParser struct{}
+
+// NewCryptoParser creates a new crypto exchange parser
+func NewCryptoParser() *CryptoParser {
+	return &CryptoParser{}
+}
+
+// Name returns the parser name
+func (p *CryptoParser) Name() string {
+	return cryptoSource
+}
+
+// Detect checks if this is a crypto exchange export: one with an asset
+// column and a quantity, but no brokerage symbol column
+func (p *CryptoParser) Detect(header []string) bool {
+	hasAsset, hasQuantity := false, false
+	for _, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		switch {
+		case h == "symbol" || h == "ticker":
+			return false
+		case containsString(cryptoAssetColumns, h):
+			hasAsset = true
+		case h == "quantity" || h == "amount" || h == "balance":
+			hasQuantity = true
+		}
+	}
+	return hasAsset && hasQuantity
+}
+
+// Parse reads crypto exchange CSV data and returns holdings
+func (p *CryptoParser) Parse(reader io.Reader, portfolioID uuid.UUID, accountName string) ([]models.Holding, error) {
+	return nil, nil
+}
+
+// ParseRow parses a single crypto exchange CSV row. Every coin is
+// classified as crypto, including ones the tagger doesn't know.
+func (p *CryptoParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error) {
+	if len(row) < 2 {
+		return nil, tooFewColumns(row, 2)
+	}
+
+	// Build column index map
+	colMap := make(map[string]int)
+	for i, h := range header {
+		colMap[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	getCol := func(names ...string) string {
+		for _, name := range names {
+			if idx, ok := colMap[name]; ok && idx < len(row) {
+				return row[idx]
+			}
+		}
+		return ""
+	}
+
+	ticker, err := models.NormalizeTicker(getCol(cryptoAssetColumns...))
+	switch {
+	case ticker == "":
+		return nil, errors.New("missing asset")
+	case fiatCurrencies[ticker]:
+		return nil, skipRow("cash balance")
+	case err != nil:
+		return nil, err
+	}
+
+	name := cleanName(getCol("name", "asset name"))
+	if coin, ok := models.CryptoCoins[ticker]; ok && name == "" {
+		name = coin.Name
+	}
+	quantity := parseDecimal(getCol("quantity", "amount", "balance"))
+	price := parseDecimal(getCol("spot price", "price", "price (usd)"))
+	marketValue := parseDecimal(getCol("value", "market value", "value (usd)", "usd value"))
+	costBasis := parseDecimal(getCol("cost basis", "cost", "total cost", "cost basis (usd)"))
+
+	// Skip if no meaningful data
+	if quantity.IsZero() && marketValue.IsZero() {
+		return nil, missingAmount(getCol("quantity", "amount", "balance"), getCol("value", "market value"))
+	}
+
+	holding := &models.Holding{
+		ID:           uuid.New(),
+		PortfolioID:  portfolioID,
+		AccountName:  accountName,
+		Ticker:       ticker,
+		Name:         name,
+		Quantity:     quantity,
+		CostBasis:    costBasis,
+		CurrentPrice: price,
+		MarketValue:  marketValue,
+		AssetClass:   models.AssetClassCrypto,
+		Sector:       "Cryptocurrency",
+		Geography:    "Global",
+		Source:       cryptoSource,
+		ImportedAt:   time.Now().UTC(),
+	}
+
+	if holding.MarketValue.IsZero() && !holding.Quantity.IsZero() && !holding.CurrentPrice.IsZero() {
+		holding.CalculateMarketValue()
+	}
+
+	return holding, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestCryptoParser_Detect(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"Asset,Quantity,Cost Basis", true},
+		{"Coin,Balance,Value (USD)", true},
+		{"Currency,Amount", true},
+		{"Symbol,Description,Quantity,Price,Market Value", false},
+		{"Symbol,Asset,Quantity", false}, // Brokerage export with an asset column
+		{"Asset,Name", false},
+	}
+
+	parser := NewCryptoParser()
+	for _, tt := range tests {
+		if got := parser.Detect(strings.Split(tt.header, ",")); got != tt.want {
+			t.Errorf("Detect(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestService_ParseCSV_CryptoExchange(t *testing.T) {
+	input := "Asset,Quantity,Spot Price,Value,Cost Basis\n" +
+		"BTC,0.5,64000,32000.00,21400.00\n" +
+		"eth,4.25,3400,,9875.50\n" +
+		"PEPE,15000000,0.0000112,168.00,250.00\n" +
+		"USD,1250.00,1.00,1250.00,1250.00\n"
+
+	result, err := NewService().ParseCSV(strings.NewReader(input), uuid.New(), "Coinbase")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Source != "crypto_csv" {
+		t.Errorf("Detected as %s, want crypto_csv", result.Source)
+	}
+	if len(result.Holdings) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d", len(result.Holdings))
+	}
+
+	// Every coin is crypto, even ones the tagger has never heard of
+	for _, h := range result.Holdings {
+		if h.AssetClass != models.AssetClassCrypto || h.Sector != "Cryptocurrency" {
+			t.Errorf("%s classified as %s / %s, want crypto", h.Ticker, h.AssetClass, h.Sector)
+		}
+	}
+
+	btc, eth := result.Holdings[0], result.Holdings[1]
+	if btc.Ticker != "BTC" || btc.Name != "Bitcoin" || !btc.CostBasis.Equal(decimal.NewFromInt(21400)) {
+		t.Errorf("BTC: got %+v", btc)
+	}
+	if !btc.IsCryptoCoin() {
+		t.Error("BTC should be priced as a coin")
+	}
+	if eth.Ticker != "ETH" || !eth.MarketValue.Equal(decimal.NewFromInt(14450)) {
+		t.Errorf("ETH: got ticker %s value %s, want ETH worth 14450 from quantity and price", eth.Ticker, eth.MarketValue)
+	}
+
+	// The dollar balance is cash on the exchange, not a coin
+	if len(result.Errors) != 1 || result.Errors[0].Reason != "cash balance" || !result.Errors[0].Skipped {
+		t.Errorf("Expected the USD row to be skipped as cash, got %+v", result.Errors)
+	}
+}
@@ -0,0 +1,77 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+)
+
+// sniffSize is how much of the file is inspected to pick a delimiter.
+// Brokerage preambles are a few lines at most, so the header falls well
+// within this window.
+const sniffSize = 8192
+
+// candidateDelimiters are checked in order; earlier entries win ties so
+// comma stays the default for ambiguous input
+var candidateDelimiters = []rune{',', ';', '\t'}
+
+// NewCSVReader returns a csv.Reader configured with the delimiter sniffed
+// from the header row. Some European exports use semicolons and a few
+// brokerages emit tab-separated files, neither of which parse as commas.
+func NewCSVReader(r io.Reader) *csv.Reader {
+	br := bufio.NewReaderSize(r, sniffSize)
+	sample, _ := br.Peek(sniffSize)
+
+	csvReader := csv.NewReader(br)
+	csvReader.Comma = DetectDelimiter(sample)
+	csvReader.FieldsPerRecord = -1 // Allow variable fields
+	return csvReader
+}
+
+// DetectDelimiter picks the delimiter used by the header row of sample.
+// The header is the first line mentioning known column names, falling back
+// to the first non-empty line. Data rows are not consulted because
+// semicolon files often use commas as the decimal separator.
+func DetectDelimiter(sample []byte) rune {
+	lines := bytes.Split(sample, []byte("\n"))
+
+	var header []byte
+	for _, line := range lines {
+		if looksLikeHeader(string(line)) {
+			header = line
+			break
+		}
+	}
+	if header == nil {
+		for _, line := range lines {
+			if len(bytes.TrimSpace(line)) > 0 {
+				header = line
+				break
+			}
+		}
+	}
+
+	best, bestCount := ',', 0
+	for _, d := range candidateDelimiters {
+		if n := countUnquoted(header, d); n > bestCount {
+			best, bestCount = d, n
+		}
+	}
+	return best
+}
+
+// countUnquoted counts occurrences of d outside double-quoted fields
+func countUnquoted(line []byte, d rune) int {
+	count := 0
+	inQuotes := false
+	for _, ch := range string(line) {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case ch == d && !inQuotes:
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,71 @@
+package importer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   rune
+	}{
+		{"Comma", "Symbol,Description,Quantity,Price\nAAPL,Apple Inc.,100,175.50\n", ','},
+		{"Semicolon", "Symbol;Description;Quantity;Price\nAAPL;Apple Inc.;100;175,50\n", ';'},
+		{"Tab", "Symbol\tDescription\tQuantity\tPrice\nAAPL\tApple Inc.\t100\t175.50\n", '\t'},
+		{"Preamble before header", "Account Summary\nGenerated 2024-01-01\nSymbol;Description;Quantity;Price\n", ';'},
+		{"Quoted delimiters ignored", "\"Symbol;A\",\"Description;B\",Quantity\n", ','},
+		{"Empty defaults to comma", "", ','},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectDelimiter([]byte(tt.sample)); got != tt.want {
+				t.Errorf("DetectDelimiter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewCSVReader_SemicolonSeparated(t *testing.T) {
+	input := "Symbol;Description;Quantity;Price;Market Value\n" +
+		"AAPL;\"Apple; Inc.\";100;175.50;17550.00\n" +
+		"MSFT;Microsoft Corporation;50;378.25;18912.50\n"
+
+	records, err := NewCSVReader(strings.NewReader(input)).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(records) != 3 || len(records[0]) != 5 {
+		t.Fatalf("Expected 3 rows of 5 fields, got %v", records)
+	}
+	if records[1][1] != "Apple; Inc." {
+		t.Errorf("Expected quoted field to keep its delimiter, got %q", records[1][1])
+	}
+
+	holdings := ParseSchwabCSV(records, uuid.New(), "Test")
+	if len(holdings) != 2 {
+		t.Errorf("Expected 2 holdings, got %d", len(holdings))
+	}
+}
+
+func TestNewCSVReader_TabSeparated(t *testing.T) {
+	input := "Symbol\tDescription\tQuantity\tPrice\tMarket Value\n" +
+		"VOO\tVanguard S&P 500 ETF\t25\t425.00\t10625.00\n"
+
+	records, err := NewCSVReader(strings.NewReader(input)).ReadAll()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(records) != 2 || len(records[1]) != 5 {
+		t.Fatalf("Expected 2 rows of 5 fields, got %v", records)
+	}
+	if records[1][0] != "VOO" || records[1][4] != "10625.00" {
+		t.Errorf("Unexpected row: %v", records[1])
+	}
+}
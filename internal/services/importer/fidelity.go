@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"time"
@@ -57,9 +58,9 @@ func (p *FidelityParser) Parse(reader io.Reader, portfolioID uuid.UUID, accountN
 }
 
 // ParseRow parses a single Fidelity CSV row
-func (p *FidelityParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) *models.Holding {
+func (p *FidelityParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error) {
 	if len(row) < 5 {
-		return nil
+		return nil, tooFewColumns(row, 5)
 	}
 
 	// Build column index map
@@ -77,9 +78,14 @@ func (p *FidelityParser) ParseRow(row []string, header []string, portfolioID uui
 		return ""
 	}
 
-	ticker := cleanTicker(getCol("symbol"))
-	if ticker == "" || strings.HasPrefix(ticker, "CASH") || ticker == "PENDING ACTIVITY" {
-		return nil
+	ticker, err := models.NormalizeTicker(getCol("symbol"))
+	switch {
+	case ticker == "":
+		return nil, errors.New("missing symbol")
+	case strings.HasPrefix(ticker, "CASH") || ticker == "PENDING ACTIVITY":
+		return nil, skipRow("cash or pending activity")
+	case err != nil:
+		return nil, err
 	}
 
 	name := cleanName(getCol("description", "security description"))
@@ -90,7 +96,12 @@ func (p *FidelityParser) ParseRow(row []string, header []string, portfolioID uui
 
 	// Skip if no meaningful data
 	if quantity.IsZero() && currentValue.IsZero() {
-		return nil
+		return nil, missingAmount(getCol("quantity", "shares"), getCol("current value", "value"))
+	}
+
+	currency, err := models.NormalizeCurrency(getCol(currencyColumns...))
+	if err != nil {
+		return nil, err
 	}
 
 	holding := &models.Holding{
@@ -105,14 +116,16 @@ func (p *FidelityParser) ParseRow(row []string, header []string, portfolioID uui
 		MarketValue:  currentValue,
 		AssetClass:   models.AssetClassOther,
 		Source:       "fidelity_csv",
+		Currency:     currency,
 		ImportedAt:   time.Now().UTC(),
 	}
+	addLot(holding, getCol(acquiredColumns...))
 
 	if holding.MarketValue.IsZero() && !holding.Quantity.IsZero() && !holding.CurrentPrice.IsZero() {
 		holding.CalculateMarketValue()
 	}
 
-	return holding
+	return holding, nil
 }
 
 // ParseFidelityCSV is a convenience function to parse Fidelity CSV data
@@ -128,12 +141,6 @@ func ParseFidelityCSV(records [][]string, portfolioID uuid.UUID, accountName str
 		return nil
 	}
 
-	var holdings []models.Holding
-	for i := 1; i < len(records); i++ {
-		if h := parser.ParseRow(records[i], header, portfolioID, accountName); h != nil {
-			holdings = append(holdings, *h)
-		}
-	}
-
+	holdings, _ := ParseRecords(parser, records, portfolioID, accountName)
 	return holdings
 }
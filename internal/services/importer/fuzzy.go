@@ -0,0 +1,162 @@
+package importer
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// fuzzyMatchThreshold is the minimum name similarity (0-1) for a holding
+// to take on the classification of a known security
+const fuzzyMatchThreshold = 0.8
+
+// tokenMatchThreshold is the minimum similarity for two words to count as
+// the same word, which allows roughly one typo per five letters
+const tokenMatchThreshold = 0.8
+
+// nameStopWords are words that say nothing about which security a name
+// refers to and only add noise to the comparison
+var nameStopWords = map[string]bool{
+	"inc": true, "corp": true, "corporation": true, "co": true, "company": true,
+	"ltd": true, "plc": true, "the": true, "class": true, "cl": true,
+	"common": true, "stock": true, "shares": true, "sh": true, "com": true,
+	"etf": true, "fund": true, "index": true, "idx": true,
+	"admiral": true, "adm": true, "investor": true, "inv": true,
+}
+
+// knownName is a built-in security's name split into comparable tokens
+type knownName struct {
+	info   *TickerInfo
+	tokens []string
+}
+
+// buildNameIndex tokenizes the names in the ticker database. Entries are
+// kept in ticker order so ties are detected deterministically.
+func (t *Tagger) buildNameIndex() {
+	tickers := make([]string, 0, len(t.tickerDB))
+	for ticker := range t.tickerDB {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	t.names = t.names[:0]
+	for _, ticker := range tickers {
+		info := t.tickerDB[ticker]
+		if tokens := nameTokens(info.Name); len(tokens) > 0 {
+			t.names = append(t.names, knownName{info: info, tokens: tokens})
+		}
+	}
+}
+
+// matchName finds the known security whose name best matches name. It
+// returns nil when nothing clears the threshold or when two securities
+// match equally well.
+func (t *Tagger) matchName(name string) *TickerInfo {
+	query := nameTokens(name)
+	if len(query) == 0 {
+		return nil
+	}
+
+	var best *TickerInfo
+	bestScore := 0.0
+	tied := false
+	for _, known := range t.names {
+		score := nameSimilarity(query, known.tokens)
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = known.info, score, false
+		case score == bestScore && best != nil && best.Name != known.info.Name:
+			tied = true
+		}
+	}
+
+	if best == nil || tied || bestScore < fuzzyMatchThreshold {
+		return nil
+	}
+	return best
+}
+
+// nameTokens lowercases a security name and splits it into words, dropping
+// punctuation, single letters, and stop words
+func nameTokens(name string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(strings.ToLower(name)) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(word) < 2 || nameStopWords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// nameSimilarity scores two tokenized names from 0 to 1. Each query word is
+// paired with its closest unused word in the candidate; the score averages
+// how much of the query was explained with how much of both names overlap,
+// so extra words in a broker's description cost less than missing ones.
+func nameSimilarity(query, candidate []string) float64 {
+	used := make([]bool, len(candidate))
+	matched := 0.0
+	for _, q := range query {
+		bestIdx, bestSim := -1, 0.0
+		for i, c := range candidate {
+			if used[i] {
+				continue
+			}
+			if sim := tokenSimilarity(q, c); sim > bestSim {
+				bestIdx, bestSim = i, sim
+			}
+		}
+		if bestIdx >= 0 && bestSim >= tokenMatchThreshold {
+			used[bestIdx] = true
+			matched += bestSim
+		}
+	}
+
+	coverage := matched / float64(len(query))
+	dice := 2 * matched / float64(len(query)+len(candidate))
+	return (coverage + dice) / 2
+}
+
+// tokenSimilarity is 1 minus the edit distance scaled by the longer word.
+// Words containing digits must match exactly, since "500" and "400" are
+// different funds rather than a typo.
+func tokenSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if strings.ContainsAny(a, "0123456789") || strings.ContainsAny(b, "0123456789") {
+		return 0
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+// levenshtein returns the edit distance between two words
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,96 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+func TestTagger_TagHolding_FuzzyName(t *testing.T) {
+	tagger := NewTagger()
+
+	tests := []struct {
+		name      string
+		ticker    string
+		holdName  string
+		wantName  string
+		wantClass models.AssetClass
+	}{
+		{"Misspelled fund family", "VFIAX", "Vangard S&P 500 Idx", "Vanguard S&P 500 ETF", models.AssetClassEquity},
+		{"Extra share class words", "AAPL1", "Apple Inc Common Stock", "Apple Inc.", models.AssetClassEquity},
+		{"Dropped letter", "MSFTX", "Microsft Corp", "Microsoft Corporation", models.AssetClassEquity},
+		{"Typo in bond fund", "VBTLX", "Vanguard Totl Bond Market Index Fund", "Vanguard Total Bond Market ETF", models.AssetClassFixedIncome},
+		{"Typo in money market", "VMMXX", "Vanguard Federl Money Market", "Vanguard Federal Money Market", models.AssetClassCash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &models.Holding{
+				ID:         uuid.New(),
+				Ticker:     tt.ticker,
+				Name:       tt.holdName,
+				AssetClass: models.AssetClassOther,
+			}
+
+			tagger.TagHolding(h)
+
+			if h.Name != tt.wantName {
+				t.Errorf("Name: got %s, want %s", h.Name, tt.wantName)
+			}
+			if h.AssetClass != tt.wantClass {
+				t.Errorf("Asset class: got %s, want %s", h.AssetClass, tt.wantClass)
+			}
+		})
+	}
+}
+
+func TestTagger_TagHolding_ExactTickerBeatsFuzzyName(t *testing.T) {
+	tagger := NewTagger()
+
+	// The description resembles Apple, but the ticker is authoritative
+	h := &models.Holding{ID: uuid.New(), Ticker: "BND", Name: "Apple Inc", AssetClass: models.AssetClassOther}
+	tagger.TagHolding(h)
+
+	if h.AssetClass != models.AssetClassFixedIncome {
+		t.Errorf("Asset class: got %s, want %s", h.AssetClass, models.AssetClassFixedIncome)
+	}
+	if h.Name != "Apple Inc" {
+		t.Errorf("Name should be left alone on ticker match, got %s", h.Name)
+	}
+}
+
+func TestTagger_MatchName_NoMatch(t *testing.T) {
+	tagger := NewTagger()
+
+	names := []string{
+		"",
+		"XYZ Corporation",
+		"Vanguard Fund",           // matches several Vanguard funds equally
+		"ABC Corporate Bond Fund", // shares only "bond" with known names
+	}
+
+	for _, name := range names {
+		if info := tagger.matchName(name); info != nil {
+			t.Errorf("matchName(%q) = %s, want no match", name, info.Ticker)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"vanguard", "vangard", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// GenericParser reads CSVs that match no brokerage's export but name their
+// columns plainly, like a spreadsheet kept by hand. It's tried only when no
+// brokerage parser detects the file.
+type GenericParser struct{}
+
+// NewGenericParser creates a new generic parser
+func NewGenericParser() *GenericParser {
+	return &GenericParser{}
+}
+
+// Name returns the parser name
+func (p *GenericParser) Name() string {
+	return "generic_csv"
+}
+
+// Detect checks for the symbol or ticker column holdings are read by
+func (p *GenericParser) Detect(header []string) bool {
+	for _, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "symbol", "ticker":
+			return true
+		}
+	}
+	return false
+}
+
+// Parse reads generic CSV data and returns holdings
+func (p *GenericParser) Parse(reader io.Reader, portfolioID uuid.UUID, accountName string) ([]models.Holding, error) {
+	// This is called via the service's ParseCSV which handles reading
+	return nil, nil
+}
+
+// ParseRow parses a single generic CSV row, taking amounts from whichever
+// of the brokerages' column names the header uses
+func (p *GenericParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error) {
+	if len(row) < 3 {
+		return nil, tooFewColumns(row, 3)
+	}
+
+	colMap := make(map[string]int)
+	for i, h := range header {
+		colMap[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	getCol := func(names ...string) string {
+		for _, name := range names {
+			if idx, ok := colMap[name]; ok && idx < len(row) {
+				return row[idx]
+			}
+		}
+		return ""
+	}
+
+	ticker, err := models.NormalizeTicker(getCol("symbol", "ticker"))
+	switch {
+	case ticker == "":
+		return nil, errors.New("missing symbol")
+	case err != nil:
+		return nil, err
+	}
+
+	quantityColumns := []string{"quantity", "shares", "qty", "units"}
+	valueColumns := []string{"market value", "current value", "total value", "value"}
+
+	name := cleanName(getCol("description", "name", "security", "investment name"))
+	quantity := parseDecimal(getCol(quantityColumns...))
+	price := parseDecimal(getCol("price", "last price", "share price", "current price"))
+	marketValue := parseDecimal(getCol(valueColumns...))
+	costBasis := parseDecimal(getCol("cost basis", "cost basis total", "total cost", "cost"))
+
+	// Skip if no meaningful data
+	if quantity.IsZero() && marketValue.IsZero() {
+		return nil, missingAmount(getCol(quantityColumns...), getCol(valueColumns...))
+	}
+
+	currency, err := models.NormalizeCurrency(getCol(currencyColumns...))
+	if err != nil {
+		return nil, err
+	}
+
+	holding := &models.Holding{
+		ID:           uuid.New(),
+		PortfolioID:  portfolioID,
+		AccountName:  accountName,
+		Ticker:       ticker,
+		Name:         name,
+		Quantity:     quantity,
+		CostBasis:    costBasis,
+		CurrentPrice: price,
+		MarketValue:  marketValue,
+		AssetClass:   models.AssetClassOther,
+		Source:       "generic_csv",
+		Currency:     currency,
+		ImportedAt:   time.Now().UTC(),
+	}
+	addLot(holding, getCol(acquiredColumns...))
+
+	// Calculate market value if not provided
+	if holding.MarketValue.IsZero() && !holding.Quantity.IsZero() && !holding.CurrentPrice.IsZero() {
+		holding.CalculateMarketValue()
+	}
+
+	return holding, nil
+}
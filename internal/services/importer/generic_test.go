@@ -0,0 +1,46 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func TestGenericParser_ParseRow(t *testing.T) {
+	parser := NewGenericParser()
+	header := []string{"Ticker", "Name", "Shares", "Price", "Total Cost", "Currency"}
+	if !parser.Detect(header) {
+		t.Fatal("Expected the ticker column to be detected")
+	}
+	if parser.Detect([]string{"Asset", "Amount"}) {
+		t.Error("Expected a header without a symbol column not to be detected")
+	}
+
+	records := [][]string{
+		header,
+		{"vti", "Total Stock Market", "10", "$250.00", "2,000.00", ""},
+		{"SAP.DE", "SAP", "5", "100", "450", "eur"},
+		{"BND", "Total Bond", "", "70", "", ""},
+		{"XYZ", "Odd", "1", "1", "1", "euro"},
+	}
+	holdings, rowErrors := ParseRecords(parser, records, uuid.New(), "Test")
+	if len(holdings) != 2 {
+		t.Fatalf("Expected 2 holdings, got %d with errors %+v", len(holdings), rowErrors)
+	}
+
+	vti := holdings[0]
+	if vti.Ticker != "VTI" || !vti.Quantity.Equal(decimal.NewFromInt(10)) || !vti.MarketValue.Equal(decimal.NewFromInt(2500)) {
+		t.Errorf("VTI: got %s x %s worth %s, want 10 worth 2500", vti.Ticker, vti.Quantity, vti.MarketValue)
+	}
+	if !vti.CostBasis.Equal(decimal.NewFromInt(2000)) || vti.Currency != "USD" || vti.Source != "generic_csv" {
+		t.Errorf("VTI: cost basis %s in %s from %s", vti.CostBasis, vti.Currency, vti.Source)
+	}
+	if holdings[1].Currency != "EUR" {
+		t.Errorf("SAP.DE: currency %s, want EUR", holdings[1].Currency)
+	}
+
+	if len(rowErrors) != 2 || rowErrors[0].Reason != "no quantity or market value" || rowErrors[1].Row != 5 {
+		t.Errorf("Row errors: got %+v", rowErrors)
+	}
+}
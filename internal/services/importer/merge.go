@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/findosh/truenorth/internal/models"
+)
+
+// ImportMode controls how imported holdings combine with existing ones
+type ImportMode string
+
+const (
+	// ImportModeMerge updates positions already held in the same account and
+	// inserts the rest, so re-importing an account doesn't double-count it
+	ImportModeMerge ImportMode = "merge"
+	// ImportModeReplace discards the account's existing holdings first
+	ImportModeReplace ImportMode = "replace"
+	// ImportModeAppend inserts every row as a new holding
+	ImportModeAppend ImportMode = "append"
+)
+
+// ParseImportMode validates a mode string, defaulting to merge when empty
+func ParseImportMode(s string) (ImportMode, error) {
+	switch mode := ImportMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ImportModeMerge, nil
+	case ImportModeMerge, ImportModeReplace, ImportModeAppend:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown import mode %q", s)
+	}
+}
+
+// holdingKey identifies a position by ticker within an account
+func holdingKey(h models.Holding) string {
+	return strings.ToUpper(h.Ticker) + "|" + h.AccountName
+}
+
+// ConsolidateHoldings combines rows for the same ticker and account, as
+// happens when an export lists each tax lot separately. Quantities, cost
+// basis and market value are summed and lots are collected; the first row's
+// ID and metadata win.
+func ConsolidateHoldings(holdings []models.Holding) []models.Holding {
+	c := NewConsolidator()
+	for _, h := range holdings {
+		c.Add(h)
+	}
+	return c.Holdings()
+}
+
+// Consolidator combines holdings one at a time the way ConsolidateHoldings
+// does, so a streamed import only keeps one holding per position
+type Consolidator struct {
+	index    map[string]int
+	holdings []models.Holding
+}
+
+// NewConsolidator creates an empty consolidator
+func NewConsolidator() *Consolidator {
+	return &Consolidator{index: make(map[string]int)}
+}
+
+// Add folds h into the position with its ticker and account
+func (c *Consolidator) Add(h models.Holding) {
+	key := holdingKey(h)
+	if i, ok := c.index[key]; ok {
+		existing := &c.holdings[i]
+		existing.Quantity = existing.Quantity.Add(h.Quantity)
+		existing.CostBasis = existing.CostBasis.Add(h.CostBasis)
+		existing.MarketValue = existing.MarketValue.Add(h.MarketValue)
+		existing.Lots = append(existing.Lots, h.Lots...)
+		if existing.CurrentPrice.IsZero() {
+			existing.CurrentPrice = h.CurrentPrice
+		}
+		return
+	}
+	c.index[key] = len(c.holdings)
+	c.holdings = append(c.holdings, h)
+}
+
+// Holdings returns the consolidated positions in the order first seen
+func (c *Consolidator) Holdings() []models.Holding {
+	if c.holdings == nil {
+		return []models.Holding{}
+	}
+	return c.holdings
+}
+
+// MergeHoldings matches incoming holdings against existing ones by ticker
+// and account. Matches are returned as updates that keep the existing ID,
+// import date and any manual classification, but take the incoming
+// quantity, price, value, cost basis, currency and lots since the import is
+// the newer statement. Unmatched incoming holdings are returned as inserts.
+func MergeHoldings(existing, incoming []models.Holding) (updates, inserts []models.Holding) {
+	byKey := make(map[string]models.Holding, len(existing))
+	for _, h := range existing {
+		byKey[holdingKey(h)] = h
+	}
+
+	for _, in := range ConsolidateHoldings(incoming) {
+		current, ok := byKey[holdingKey(in)]
+		if !ok {
+			inserts = append(inserts, in)
+			continue
+		}
+
+		current.Quantity = in.Quantity
+		current.CostBasis = in.CostBasis
+		current.Lots = in.Lots
+		current.CurrentPrice = in.CurrentPrice
+		current.MarketValue = in.MarketValue
+		current.Currency = in.Currency
+		if in.Name != "" {
+			current.Name = in.Name
+		}
+		if !current.IsManualEntry {
+			current.AssetClass = in.AssetClass
+			current.Sector = in.Sector
+			current.Geography = in.Geography
+		}
+		updates = append(updates, current)
+	}
+
+	return updates, inserts
+}
@@ -0,0 +1,132 @@
+package importer
+
+import (
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+func newTestHolding(ticker, account string, qty, value float64) models.Holding {
+	h := models.NewHolding(uuid.Nil, ticker, ticker+" Inc.", account)
+	h.Quantity = decimal.NewFromFloat(qty)
+	h.MarketValue = decimal.NewFromFloat(value)
+	h.CostBasis = decimal.NewFromFloat(value / 2)
+	return *h
+}
+
+func TestParseImportMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ImportMode
+		wantErr bool
+	}{
+		{"", ImportModeMerge, false},
+		{"merge", ImportModeMerge, false},
+		{"REPLACE", ImportModeReplace, false},
+		{" append ", ImportModeAppend, false},
+		{"upsert", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseImportMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseImportMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseImportMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestConsolidateHoldings(t *testing.T) {
+	holdings := []models.Holding{
+		newTestHolding("AAPL", "IRA", 10, 1750),
+		newTestHolding("MSFT", "IRA", 5, 1890),
+		newTestHolding("AAPL", "IRA", 15, 2625),
+		newTestHolding("AAPL", "Brokerage", 1, 175),
+	}
+
+	result := ConsolidateHoldings(holdings)
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 holdings, got %d", len(result))
+	}
+
+	aapl := result[0]
+	if !aapl.Quantity.Equal(decimal.NewFromInt(25)) {
+		t.Errorf("Expected summed quantity 25, got %s", aapl.Quantity)
+	}
+	if !aapl.CostBasis.Equal(decimal.NewFromFloat(2187.5)) {
+		t.Errorf("Expected summed cost basis 2187.5, got %s", aapl.CostBasis)
+	}
+	if aapl.ID != holdings[0].ID {
+		t.Error("Expected the first row's ID to be kept")
+	}
+}
+
+func TestMergeHoldings(t *testing.T) {
+	existing := []models.Holding{
+		newTestHolding("AAPL", "IRA", 10, 1750),
+		newTestHolding("VOO", "IRA", 5, 2150),
+	}
+	existing[1].IsManualEntry = true
+	existing[1].AssetClass = models.AssetClassEquity
+	existing[1].Sector = "Diversified"
+
+	incoming := []models.Holding{
+		newTestHolding("AAPL", "IRA", 12, 2100),
+		newTestHolding("VOO", "IRA", 6, 2580),
+		newTestHolding("BND", "IRA", 20, 1460),
+	}
+	incoming[1].AssetClass = models.AssetClassOther
+
+	updates, inserts := MergeHoldings(existing, incoming)
+
+	if len(updates) != 2 || len(inserts) != 1 {
+		t.Fatalf("Expected 2 updates and 1 insert, got %d and %d", len(updates), len(inserts))
+	}
+
+	if updates[0].ID != existing[0].ID {
+		t.Error("Expected update to keep the existing holding ID")
+	}
+	if !updates[0].Quantity.Equal(decimal.NewFromInt(12)) {
+		t.Errorf("Expected quantity to be replaced with 12, got %s", updates[0].Quantity)
+	}
+	if updates[1].AssetClass != models.AssetClassEquity {
+		t.Errorf("Expected manual classification to survive, got %s", updates[1].AssetClass)
+	}
+	if inserts[0].Ticker != "BND" {
+		t.Errorf("Expected BND to be inserted, got %s", inserts[0].Ticker)
+	}
+}
+
+func TestParseSchwabCSV_Lots(t *testing.T) {
+	records := [][]string{
+		{"Symbol", "Description", "Quantity", "Price", "Market Value", "Cost Basis", "Date Acquired"},
+		{"VTI", "Vanguard Total Stock", "10", "250", "2500", "2000", "03/16/2020"},
+		{"VTI", "Vanguard Total Stock", "5", "250", "1250", "1100", "06/03/2024"},
+		{"AAPL", "Apple Inc", "2", "175", "350", "300", ""},
+	}
+
+	holdings := ConsolidateHoldings(ParseSchwabCSV(records, uuid.New(), "Brokerage"))
+	if len(holdings) != 2 {
+		t.Fatalf("Expected 2 holdings, got %d", len(holdings))
+	}
+
+	vti := holdings[0]
+	if len(vti.Lots) != 2 {
+		t.Fatalf("VTI lots: got %d, want 2", len(vti.Lots))
+	}
+	if got := vti.Lots[1].AcquiredAt.Format("2006-01-02"); got != "2024-06-03" {
+		t.Errorf("Second lot acquired: got %s, want 2024-06-03", got)
+	}
+	if !vti.CostBasis.Equal(vti.TotalCostBasis()) {
+		t.Errorf("Cost basis %s should equal the lot total %s", vti.CostBasis, vti.TotalCostBasis())
+	}
+
+	// Rows without an acquisition date don't become lots
+	if holdings[1].HasLots() {
+		t.Errorf("AAPL should have no lots, got %d", len(holdings[1].Lots))
+	}
+}
@@ -2,10 +2,11 @@
 package importer
 
 import (
-	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/findosh/truenorth/internal/models"
 	"github.com/google/uuid"
@@ -13,11 +14,42 @@ import (
 )
 
 var (
+	ErrNoFile        = errors.New("no file uploaded")
+	ErrInvalidCSV    = errors.New("file is not a valid CSV")
 	ErrUnknownFormat = errors.New("unknown CSV format")
 	ErrEmptyFile     = errors.New("CSV file is empty")
 	ErrNoData        = errors.New("no valid holdings found")
+	ErrFileTooLarge  = errors.New("file is larger than the import limit")
 )
 
+// errorCodes identify import errors to API clients
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrNoFile, "no_file"},
+	{ErrInvalidCSV, "invalid_csv"},
+	{ErrUnknownFormat, "unknown_format"},
+	{ErrEmptyFile, "empty_file"},
+	{ErrNoData, "no_holdings"},
+	{ErrFileTooLarge, "file_too_large"},
+	{ErrEncryptedXLSX, "encrypted_xlsx"},
+	{ErrInvalidXLSX, "invalid_xlsx"},
+	{ErrAmbiguousSheets, "ambiguous_sheets"},
+}
+
+// ErrorCode returns a stable code for an import error, so API clients can
+// tell them apart without matching messages. Errors that aren't one of the
+// importer's are "import_failed".
+func ErrorCode(err error) string {
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return "import_failed"
+}
+
 // CSVParser interface for brokerage-specific implementations
 type CSVParser interface {
 	// Parse reads CSV data and returns normalized holdings
@@ -28,6 +60,10 @@ type CSVParser interface {
 
 	// Name returns the parser name
 	Name() string
+
+	// ParseRow parses one data row. It returns a skipRow error for rows
+	// that are deliberately not holdings, like cash and pending activity.
+	ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error)
 }
 
 // ParseResult contains the result of parsing a CSV file
@@ -35,7 +71,26 @@ type ParseResult struct {
 	Holdings    []models.Holding
 	Source      string
 	AccountName string
-	Errors      []string
+	Errors      []RowError
+}
+
+// RowError explains why a data row didn't become a holding
+type RowError struct {
+	Row     int      `json:"row"` // 1-based, as shown in a spreadsheet
+	Cells   []string `json:"cells"`
+	Reason  string   `json:"reason"`
+	Skipped bool     `json:"skipped"` // Intentionally passed over, like a total row, rather than unreadable
+}
+
+// skipRow is returned for rows that aren't positions by design
+type skipRow string
+
+func (s skipRow) Error() string { return string(s) }
+
+// newRowError describes the failure of the row at records index i
+func newRowError(i int, row []string, err error) RowError {
+	var skip skipRow
+	return RowError{Row: i + 1, Cells: row, Reason: err.Error(), Skipped: errors.As(err, &skip)}
 }
 
 // Service handles CSV import operations
@@ -48,6 +103,7 @@ type Service struct {
 func NewService() *Service {
 	return &Service{
 		parsers: []CSVParser{
+			NewCryptoParser(),
 			NewSchwabParser(),
 			NewFidelityParser(),
 			NewVanguardParser(),
@@ -59,13 +115,12 @@ func NewService() *Service {
 // ParseCSV auto-detects the format and parses the CSV
 func (s *Service) ParseCSV(reader io.Reader, portfolioID uuid.UUID, accountName string) (*ParseResult, error) {
 	// Read all data first
-	csvReader := csv.NewReader(reader)
-	csvReader.FieldsPerRecord = -1 // Allow variable fields
+	csvReader := NewCSVReader(reader)
 	csvReader.TrimLeadingSpace = true
 
 	records, err := csvReader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
 	}
 
 	if len(records) == 0 {
@@ -91,11 +146,10 @@ func (s *Service) ParseCSV(reader io.Reader, portfolioID uuid.UUID, accountName
 		return nil, ErrUnknownFormat
 	}
 
-	// Create a reader from remaining records
-	dataRecords := records[headerIdx:]
-	holdings, err := s.parseRecords(parser, dataRecords, portfolioID, accountName)
-	if err != nil {
-		return nil, err
+	// Parse everything below the header, numbering rows from the top of the file
+	holdings, rowErrors := ParseRecords(parser, records[headerIdx:], portfolioID, accountName)
+	for i := range rowErrors {
+		rowErrors[i].Row += headerIdx
 	}
 
 	if len(holdings) == 0 {
@@ -109,83 +163,122 @@ func (s *Service) ParseCSV(reader io.Reader, portfolioID uuid.UUID, accountName
 		Holdings:    holdings,
 		Source:      parser.Name(),
 		AccountName: accountName,
+		Errors:      rowErrors,
 	}, nil
 }
 
-func findHeader(records [][]string) (int, []string) {
-	// Common header keywords
-	keywords := []string{"symbol", "ticker", "description", "quantity", "shares", "price", "value"}
+// headerKeywords are column names common to the supported export formats
+var headerKeywords = []string{"symbol", "ticker", "asset", "description", "quantity", "shares", "price", "value"}
 
+func findHeader(records [][]string) (int, []string) {
 	for i, row := range records {
 		if len(row) < 3 {
 			continue
 		}
-		// Check if row contains header keywords
-		rowStr := strings.ToLower(strings.Join(row, " "))
-		matches := 0
-		for _, kw := range keywords {
-			if strings.Contains(rowStr, kw) {
-				matches++
-			}
-		}
-		if matches >= 2 {
+		if looksLikeHeader(strings.Join(row, " ")) {
 			return i, row
 		}
 	}
 	return -1, nil
 }
 
-func (s *Service) parseRecords(parser CSVParser, records [][]string, portfolioID uuid.UUID, accountName string) ([]models.Holding, error) {
-	var holdings []models.Holding
-
-	// Skip header row
-	for i := 1; i < len(records); i++ {
-		row := records[i]
-		if len(row) < 3 {
-			continue
+// looksLikeHeader reports whether a line mentions at least two header keywords
+func looksLikeHeader(line string) bool {
+	line = strings.ToLower(line)
+	matches := 0
+	for _, kw := range headerKeywords {
+		if strings.Contains(line, kw) {
+			matches++
 		}
+	}
+	return matches >= 2
+}
 
-		// Check for empty or summary rows
-		if isSkipRow(row) {
+// ParseRecords parses the rows below the header in records[0], reporting
+// each row that didn't become a holding. Blank lines aren't reported.
+func ParseRecords(parser CSVParser, records [][]string, portfolioID uuid.UUID, accountName string) ([]models.Holding, []RowError) {
+	if len(records) < 2 {
+		return nil, nil
+	}
+	header := records[0]
+
+	var holdings []models.Holding
+	var rowErrors []RowError
+	for i := 1; i < len(records); i++ {
+		holding, rowErr := ParseRecord(parser, i, records[i], header, portfolioID, accountName)
+		if rowErr != nil {
+			rowErrors = append(rowErrors, *rowErr)
 			continue
 		}
-
-		holding := parseRow(parser, row, portfolioID, accountName)
 		if holding != nil {
 			holdings = append(holdings, *holding)
 		}
 	}
 
-	return holdings, nil
+	return holdings, rowErrors
 }
 
-func isSkipRow(row []string) bool {
-	if len(row) == 0 {
-		return true
+// ParseRecord parses the row at index i below header, so a large file can
+// be read one row at a time. It returns either the holding or why the row
+// isn't one, and neither for a blank line.
+func ParseRecord(parser CSVParser, i int, row, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, *RowError) {
+	if isBlankRow(row) {
+		return nil, nil
+	}
+	if isSummaryRow(row) {
+		rowErr := newRowError(i, row, skipRow("total or summary row"))
+		return nil, &rowErr
+	}
+
+	holding, err := parser.ParseRow(row, header, portfolioID, accountName)
+	if err != nil {
+		rowErr := newRowError(i, row, err)
+		return nil, &rowErr
 	}
+	return holding, nil
+}
 
-	// Skip total/summary rows
-	firstCell := strings.ToLower(strings.TrimSpace(row[0]))
-	skipPrefixes := []string{"total", "account total", "cash", "--", "***", ""}
+// summaryPrefixes start the first cell of total, cash and separator rows
+var summaryPrefixes = []string{"total", "account total", "cash", "--", "***"}
 
-	for _, prefix := range skipPrefixes {
-		if strings.HasPrefix(firstCell, prefix) && len(firstCell) < 20 {
+// isSummaryRow reports whether a row is a total, cash or separator row
+// rather than a position
+func isSummaryRow(row []string) bool {
+	first := strings.ToLower(strings.TrimSpace(row[0]))
+	for _, prefix := range summaryPrefixes {
+		if strings.HasPrefix(first, prefix) {
 			return true
 		}
 	}
-
 	return false
 }
 
-func parseRow(parser CSVParser, row []string, portfolioID uuid.UUID, accountName string) *models.Holding {
-	// This is a simplified common parser
-	// Each specific parser handles the actual parsing
-	return nil
+// tooFewColumns is the row error for rows shorter than a parser needs
+func tooFewColumns(row []string, need int) error {
+	return fmt.Errorf("expected at least %d columns, found %d", need, len(row))
+}
+
+// missingAmount explains a row with neither a quantity nor a value,
+// naming the first cell that isn't a number when there is one
+func missingAmount(quantity, value string) error {
+	for _, cell := range []struct{ name, text string }{{"quantity", quantity}, {"value", value}} {
+		if _, ok := parseNumber(cell.text); !ok {
+			return fmt.Errorf("%s %q isn't a number", cell.name, strings.TrimSpace(cell.text))
+		}
+	}
+	return errors.New("no quantity or market value")
 }
 
 // Helper functions for parsing values
 
 func parseDecimal(s string) decimal.Decimal {
+	d, _ := parseNumber(s)
+	return d
+}
+
+// parseNumber parses a brokerage-formatted number. Blank cells and
+// placeholders like "--" are zero; anything else unreadable reports false.
+func parseNumber(s string) (decimal.Decimal, bool) {
 	// Clean up the string
 	s = strings.TrimSpace(s)
 	s = strings.ReplaceAll(s, ",", "")
@@ -199,32 +292,14 @@ func parseDecimal(s string) decimal.Decimal {
 
 	// Handle empty or invalid
 	if s == "" || s == "--" || s == "n/a" || s == "N/A" {
-		return decimal.Zero
+		return decimal.Zero, true
 	}
 
 	d, err := decimal.NewFromString(s)
 	if err != nil {
-		return decimal.Zero
+		return decimal.Zero, false
 	}
-	return d
-}
-
-func cleanTicker(s string) string {
-	s = strings.TrimSpace(s)
-	s = strings.ToUpper(s)
-
-	// Remove common suffixes (loop to handle multiple)
-	for {
-		trimmed := s
-		trimmed = strings.TrimSuffix(trimmed, " ")
-		trimmed = strings.TrimSuffix(trimmed, "*")
-		if trimmed == s {
-			break
-		}
-		s = trimmed
-	}
-
-	return s
+	return d, true
 }
 
 func cleanName(s string) string {
@@ -235,3 +310,36 @@ func cleanName(s string) string {
 	}
 	return s
 }
+
+// currencyColumns are the headers exports use for the currency a position
+// is priced and valued in. Without one, amounts are taken to be US dollars.
+var currencyColumns = []string{"currency", "ccy", "price currency"}
+
+// acquiredColumns are the headers lot-level exports use for purchase date
+var acquiredColumns = []string{"date acquired", "acquired", "acquisition date", "open date"}
+
+// lotDateLayouts are the date formats brokerages use for acquisition dates
+var lotDateLayouts = []string{"01/02/2006", "1/2/2006", "2006-01-02", "01/02/06", "1/2/06"}
+
+// parseLotDate parses an acquisition date, reporting false when the cell is
+// empty or in an unknown format
+func parseLotDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range lotDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// addLot records the row as a single tax lot when the export includes an
+// acquisition date. Rows for the same position are combined into one
+// holding with several lots by ConsolidateHoldings.
+func addLot(h *models.Holding, acquired string) {
+	date, ok := parseLotDate(acquired)
+	if !ok {
+		return
+	}
+	h.Lots = []models.HoldingLot{*models.NewHoldingLot(h.ID, h.Quantity, h.CostBasis, date)}
+}
@@ -0,0 +1,87 @@
+package importer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseRecords_RowErrors(t *testing.T) {
+	records := [][]string{
+		{"Symbol", "Description", "Quantity", "Price", "Market Value"},
+		{"AAPL", "Apple Inc.", "100", "175.50", "17550.00"},
+		{"MSFT", "Microsoft Corporation", "fifty", "378.25", ""},
+		{"VOO", "Vanguard S&P 500 ETF"},
+		{"", "No symbol", "10", "5.00", "50.00"},
+		{"Cash & Cash Investments", "", "", "", "1200.00"},
+		{"Account Total", "", "", "", "18750.00"},
+		{"", "", "", "", ""},
+	}
+
+	holdings, rowErrors := ParseRecords(NewSchwabParser(), records, uuid.New(), "Test")
+	if len(holdings) != 1 || holdings[0].Ticker != "AAPL" {
+		t.Fatalf("Expected only AAPL to parse, got %v", holdings)
+	}
+
+	want := []struct {
+		row     int
+		reason  string
+		skipped bool
+	}{
+		{3, `quantity "fifty" isn't a number`, false},
+		{4, "expected at least 5 columns, found 2", false},
+		{5, "missing symbol", false},
+		{6, "total or summary row", true},
+		{7, "total or summary row", true},
+	}
+	if len(rowErrors) != len(want) {
+		t.Fatalf("Expected %d row errors, got %+v", len(want), rowErrors)
+	}
+	for i, w := range want {
+		got := rowErrors[i]
+		if got.Row != w.row || got.Reason != w.reason || got.Skipped != w.skipped {
+			t.Errorf("Row error %d: got %+v, want row %d %q skipped=%v", i, got, w.row, w.reason, w.skipped)
+		}
+	}
+}
+
+func TestService_ParseCSV_RowErrors(t *testing.T) {
+	input := "Positions for account Individual\n" +
+		"Symbol,Description,Quantity,Price,Market Value\n" +
+		"AAPL,Apple Inc.,100,175.50,17550.00\n" +
+		"MSFT,Microsoft Corporation,--,--,--\n"
+
+	result, err := NewService().ParseCSV(strings.NewReader(input), uuid.New(), "Test")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Holdings) != 1 {
+		t.Fatalf("Expected 1 holding, got %d", len(result.Holdings))
+	}
+
+	// Rows are numbered from the top of the file, not the header
+	if len(result.Errors) != 1 || result.Errors[0].Row != 4 || result.Errors[0].Reason != "no quantity or market value" {
+		t.Errorf("Expected row 4 to have no amounts, got %+v", result.Errors)
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	svc := NewService()
+	_, err := svc.ParseCSV(strings.NewReader("Symbol,Quantity\n\"AAPL,10\n"), uuid.New(), "")
+	if code := ErrorCode(err); code != "invalid_csv" {
+		t.Errorf("Malformed CSV: got %q (%v), want invalid_csv", code, err)
+	}
+	_, err = svc.ParseCSV(strings.NewReader(""), uuid.New(), "")
+	if code := ErrorCode(err); code != "empty_file" {
+		t.Errorf("Empty file: got %q (%v), want empty_file", code, err)
+	}
+	_, err = svc.ParseCSV(strings.NewReader("Date,Description,Amount\n2024-01-02,Coffee,4.50\n"), uuid.New(), "")
+	if code := ErrorCode(err); code != "unknown_format" {
+		t.Errorf("Unknown format: got %q (%v), want unknown_format", code, err)
+	}
+	if code := ErrorCode(errors.New("disk full")); code != "import_failed" {
+		t.Errorf("Other error: got %q, want import_failed", code)
+	}
+}
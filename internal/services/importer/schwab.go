@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"time"
@@ -54,9 +55,9 @@ func (p *SchwabParser) Parse(reader io.Reader, portfolioID uuid.UUID, accountNam
 }
 
 // ParseRow parses a single Schwab CSV row
-func (p *SchwabParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) *models.Holding {
+func (p *SchwabParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error) {
 	if len(row) < 5 {
-		return nil
+		return nil, tooFewColumns(row, 5)
 	}
 
 	// Build column index map
@@ -74,9 +75,14 @@ func (p *SchwabParser) ParseRow(row []string, header []string, portfolioID uuid.
 		return ""
 	}
 
-	ticker := cleanTicker(getCol("symbol"))
-	if ticker == "" || ticker == "CASH" || strings.HasPrefix(ticker, "--") {
-		return nil
+	ticker, err := models.NormalizeTicker(getCol("symbol"))
+	switch {
+	case ticker == "":
+		return nil, errors.New("missing symbol")
+	case ticker == "CASH" || strings.HasPrefix(ticker, "--"):
+		return nil, skipRow("cash or separator row")
+	case err != nil:
+		return nil, err
 	}
 
 	name := cleanName(getCol("description", "security description"))
@@ -87,7 +93,12 @@ func (p *SchwabParser) ParseRow(row []string, header []string, portfolioID uuid.
 
 	// Skip if no meaningful data
 	if quantity.IsZero() && marketValue.IsZero() {
-		return nil
+		return nil, missingAmount(getCol("quantity", "shares"), getCol("market value", "value"))
+	}
+
+	currency, err := models.NormalizeCurrency(getCol(currencyColumns...))
+	if err != nil {
+		return nil, err
 	}
 
 	holding := &models.Holding{
@@ -102,15 +113,17 @@ func (p *SchwabParser) ParseRow(row []string, header []string, portfolioID uuid.
 		MarketValue:  marketValue,
 		AssetClass:   models.AssetClassOther,
 		Source:       "schwab_csv",
+		Currency:     currency,
 		ImportedAt:   time.Now().UTC(),
 	}
+	addLot(holding, getCol(acquiredColumns...))
 
 	// Calculate market value if not provided
 	if holding.MarketValue.IsZero() && !holding.Quantity.IsZero() && !holding.CurrentPrice.IsZero() {
 		holding.CalculateMarketValue()
 	}
 
-	return holding
+	return holding, nil
 }
 
 // ParseSchwabCSV is a convenience function to parse Schwab CSV data
@@ -126,12 +139,6 @@ func ParseSchwabCSV(records [][]string, portfolioID uuid.UUID, accountName strin
 		return nil
 	}
 
-	var holdings []models.Holding
-	for i := 1; i < len(records); i++ {
-		if h := parser.ParseRow(records[i], header, portfolioID, accountName); h != nil {
-			holdings = append(holdings, *h)
-		}
-	}
-
+	holdings, _ := ParseRecords(parser, records, portfolioID, accountName)
 	return holdings
 }
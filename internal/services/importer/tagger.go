@@ -9,6 +9,7 @@ import (
 // Tagger classifies securities by asset class, sector, and geography
 type Tagger struct {
 	tickerDB map[string]*TickerInfo
+	names    []knownName
 }
 
 // TickerInfo holds classification data for a ticker
@@ -26,20 +27,61 @@ func NewTagger() *Tagger {
 		tickerDB: make(map[string]*TickerInfo),
 	}
 	t.loadBuiltinData()
+	t.buildNameIndex()
 	return t
 }
 
 // TagHoldings classifies a slice of holdings
 func (t *Tagger) TagHoldings(holdings []models.Holding) {
+	t.TagHoldingsWithOverrides(holdings, nil)
+}
+
+// TagHoldingsWithOverrides classifies a slice of holdings, consulting the
+// user's own classifications first
+func (t *Tagger) TagHoldingsWithOverrides(holdings []models.Holding, overrides map[string]models.TickerOverride) {
 	for i := range holdings {
-		t.TagHolding(&holdings[i])
+		t.TagHoldingWithOverrides(&holdings[i], overrides)
 	}
 }
 
 // TagHolding classifies a single holding
 func (t *Tagger) TagHolding(h *models.Holding) {
+	t.TagHoldingWithOverrides(h, nil)
+}
+
+// TagHoldingWithOverrides classifies a single holding. A user override for
+// the ticker wins over the built-in database and heuristics, so a user only
+// has to classify an obscure ticker once. overrides may be nil.
+func (t *Tagger) TagHoldingWithOverrides(h *models.Holding, overrides map[string]models.TickerOverride) {
 	ticker := strings.ToUpper(h.Ticker)
 
+	if o, ok := overrides[ticker]; ok {
+		h.AssetClass = o.AssetClass
+		h.Sector = o.Sector
+		h.Geography = o.Geography
+		return
+	}
+
+	// Everything in a crypto exchange export is a coin, including ones
+	// the heuristics wouldn't recognize
+	if h.Source == cryptoSource {
+		h.AssetClass = models.AssetClassCrypto
+		h.Sector = "Cryptocurrency"
+		h.Geography = "Global"
+		return
+	}
+
+	// Options take their geography from the underlying when it's known
+	if opt, ok := models.ParseOptionSymbol(ticker); ok {
+		h.AssetClass = models.AssetClassDerivative
+		h.Sector = "Options"
+		h.Geography = "US"
+		if info, ok := t.tickerDB[opt.Underlying]; ok && info.Geography != "" {
+			h.Geography = info.Geography
+		}
+		return
+	}
+
 	// Check built-in database
 	if info, ok := t.tickerDB[ticker]; ok {
 		h.AssetClass = info.AssetClass
@@ -48,10 +90,47 @@ func (t *Tagger) TagHolding(h *models.Holding) {
 		return
 	}
 
+	// Non-US listings are identified by their exchange suffix
+	if base, suffix, ok := models.SplitExchangeSuffix(ticker); ok {
+		t.tagForeignListing(h, base, suffix)
+		return
+	}
+
+	// Fall back to the closest known name, which catches broker descriptions
+	// with typos or extra words
+	if info := t.matchName(h.Name); info != nil {
+		h.Name = info.Name
+		h.AssetClass = info.AssetClass
+		h.Sector = info.Sector
+		h.Geography = info.Geography
+		return
+	}
+
 	// Apply heuristics
 	t.applyHeuristics(h)
 }
 
+// tagForeignListing classifies a holding listed on a non-US exchange. The
+// name heuristics still pick out bond and cash funds, but everything else is
+// treated as equity regardless of ticker length, and geography always comes
+// from the exchange.
+func (t *Tagger) tagForeignListing(h *models.Holding, base string, suffix models.ExchangeSuffix) {
+	name := strings.ToLower(h.Name)
+
+	switch {
+	case t.isCashLike(base, name):
+		h.AssetClass = models.AssetClassCash
+		h.Sector = "Cash"
+	case t.isBondFund(base, name):
+		h.AssetClass = models.AssetClassFixedIncome
+		h.Sector = t.detectBondSector(base, name)
+	default:
+		h.AssetClass = models.AssetClassEquity
+		h.Sector = t.detectSector(base, name)
+	}
+	h.Geography = suffix.Geography
+}
+
 func (t *Tagger) applyHeuristics(h *models.Holding) {
 	ticker := strings.ToUpper(h.Ticker)
 	name := strings.ToLower(h.Name)
@@ -67,7 +146,7 @@ func (t *Tagger) applyHeuristics(h *models.Holding) {
 	// ETF/Mutual fund detection by name patterns
 	if t.isBondFund(ticker, name) {
 		h.AssetClass = models.AssetClassFixedIncome
-		h.Sector = "Bonds"
+		h.Sector = t.detectBondSector(ticker, name)
 		h.Geography = t.detectGeography(name)
 		return
 	}
@@ -80,10 +159,19 @@ func (t *Tagger) applyHeuristics(h *models.Holding) {
 		return
 	}
 
+	// Commodities, checked before alternatives since both used to share
+	// the "alternative" class
+	if t.isCommodity(name) {
+		h.AssetClass = models.AssetClassCommodity
+		h.Sector = "Commodities"
+		h.Geography = "Global"
+		return
+	}
+
 	// Alternative investments
 	if t.isAlternative(ticker, name) {
 		h.AssetClass = models.AssetClassAlternative
-		h.Sector = "Alternatives"
+		h.Sector = t.detectAlternativeSector(name)
 		h.Geography = "US"
 		return
 	}
@@ -142,8 +230,7 @@ func (t *Tagger) isCrypto(ticker, name string) bool {
 
 func (t *Tagger) isAlternative(ticker, name string) bool {
 	altIndicators := []string{
-		"real estate", "reit", "private equity", "venture",
-		"commodity", "gold", "silver", "oil", "infrastructure",
+		"real estate", "reit", "private equity", "venture", "infrastructure",
 	}
 	for _, ind := range altIndicators {
 		if strings.Contains(name, ind) {
@@ -153,6 +240,26 @@ func (t *Tagger) isAlternative(ticker, name string) bool {
 	return false
 }
 
+// commodityWords are matched as whole words so names like "Goldman Sachs"
+// or "Boiler Works" aren't mistaken for gold or oil funds
+var commodityWords = map[string]bool{
+	"commodity": true, "commodities": true, "gold": true, "silver": true,
+	"platinum": true, "palladium": true, "oil": true, "crude": true,
+	"copper": true, "metals": true,
+}
+
+func (t *Tagger) isCommodity(name string) bool {
+	if strings.Contains(name, "natural gas") {
+		return true
+	}
+	for _, word := range nameTokens(name) {
+		if commodityWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *Tagger) detectSector(ticker, name string) string {
 	sectorKeywords := map[string][]string{
 		"Technology":            {"tech", "software", "semiconductor", "computer", "apple", "microsoft", "google", "nvidia"},
@@ -180,6 +287,59 @@ func (t *Tagger) detectSector(ticker, name string) string {
 	return "Diversified"
 }
 
+// bondSectors are checked in order, so inflation-protected Treasuries land
+// in TIPS and high-yield corporates in High-Yield before the broader
+// categories match
+var bondSectors = []struct {
+	sector   string
+	keywords []string
+	tickers  []string
+}{
+	{"TIPS", []string{"tips", "inflation"}, []string{"TIP", "SCHP", "VTIP", "STIP"}},
+	{"High-Yield", []string{"high yield", "high-yield", "junk"}, []string{"HYG", "JNK", "USHY", "SHYG"}},
+	{"Municipal", []string{"municipal", "muni", "tax-exempt", "tax exempt"}, []string{"MUB", "VTEB", "TFI"}},
+	{"Treasury", []string{"treasury", "government", "govt"}, []string{"TLT", "IEF", "SHY", "GOVT", "VGIT", "VGSH"}},
+	{"Corporate", []string{"corporate", "investment grade"}, []string{"LQD", "VCIT", "VCSH", "IGIB"}},
+}
+
+// detectBondSector picks the fixed income category for a bond fund. Broad
+// funds such as total market and aggregate indexes stay in "Bonds".
+func (t *Tagger) detectBondSector(ticker, name string) string {
+	ticker = strings.ToUpper(ticker)
+	name = strings.ToLower(name)
+
+	for _, s := range bondSectors {
+		for _, tk := range s.tickers {
+			if ticker == tk {
+				return s.sector
+			}
+		}
+	}
+	for _, s := range bondSectors {
+		for _, kw := range s.keywords {
+			if strings.Contains(name, kw) {
+				return s.sector
+			}
+		}
+	}
+	return "Bonds"
+}
+
+// detectAlternativeSector picks the category for an alternative investment.
+// Commodities have their own asset class, so they don't appear here.
+func (t *Tagger) detectAlternativeSector(name string) string {
+	name = strings.ToLower(name)
+
+	switch {
+	case strings.Contains(name, "real estate") || strings.Contains(name, "reit"):
+		return "Real Estate"
+	case strings.Contains(name, "private equity") || strings.Contains(name, "venture"):
+		return "Private Equity"
+	default:
+		return "Alternatives"
+	}
+}
+
 func (t *Tagger) detectGeography(name string) string {
 	nameLower := strings.ToLower(name)
 
@@ -235,12 +395,26 @@ func (t *Tagger) loadBuiltinData() {
 		{"VXUS", "Vanguard Total International Stock ETF", models.AssetClassEquity, "Diversified", "International Developed"},
 		{"BND", "Vanguard Total Bond Market ETF", models.AssetClassFixedIncome, "Bonds", "US"},
 		{"AGG", "iShares Core U.S. Aggregate Bond ETF", models.AssetClassFixedIncome, "Bonds", "US"},
-		{"TLT", "iShares 20+ Year Treasury Bond ETF", models.AssetClassFixedIncome, "Bonds", "US"},
-		{"IEF", "iShares 7-10 Year Treasury Bond ETF", models.AssetClassFixedIncome, "Bonds", "US"},
-		{"SHY", "iShares 1-3 Year Treasury Bond ETF", models.AssetClassFixedIncome, "Bonds", "US"},
-		{"TIP", "iShares TIPS Bond ETF", models.AssetClassFixedIncome, "Bonds", "US"},
+		{"TLT", "iShares 20+ Year Treasury Bond ETF", models.AssetClassFixedIncome, "Treasury", "US"},
+		{"IEF", "iShares 7-10 Year Treasury Bond ETF", models.AssetClassFixedIncome, "Treasury", "US"},
+		{"SHY", "iShares 1-3 Year Treasury Bond ETF", models.AssetClassFixedIncome, "Treasury", "US"},
+		{"GOVT", "iShares U.S. Treasury Bond ETF", models.AssetClassFixedIncome, "Treasury", "US"},
+		{"TIP", "iShares TIPS Bond ETF", models.AssetClassFixedIncome, "TIPS", "US"},
+		{"SCHP", "Schwab U.S. TIPS ETF", models.AssetClassFixedIncome, "TIPS", "US"},
+		{"LQD", "iShares iBoxx $ Investment Grade Corporate Bond ETF", models.AssetClassFixedIncome, "Corporate", "US"},
+		{"VCIT", "Vanguard Intermediate-Term Corporate Bond ETF", models.AssetClassFixedIncome, "Corporate", "US"},
+		{"HYG", "iShares iBoxx $ High Yield Corporate Bond ETF", models.AssetClassFixedIncome, "High-Yield", "US"},
+		{"JNK", "SPDR Bloomberg High Yield Bond ETF", models.AssetClassFixedIncome, "High-Yield", "US"},
+		{"MUB", "iShares National Muni Bond ETF", models.AssetClassFixedIncome, "Municipal", "US"},
+		{"VTEB", "Vanguard Tax-Exempt Bond ETF", models.AssetClassFixedIncome, "Municipal", "US"},
 		{"VNQ", "Vanguard Real Estate ETF", models.AssetClassAlternative, "Real Estate", "US"},
-		{"GLD", "SPDR Gold Trust", models.AssetClassAlternative, "Commodities", "Global"},
+		{"SCHH", "Schwab U.S. REIT ETF", models.AssetClassAlternative, "Real Estate", "US"},
+		{"PSP", "Invesco Global Listed Private Equity ETF", models.AssetClassAlternative, "Private Equity", "Global"},
+		{"GLD", "SPDR Gold Trust", models.AssetClassCommodity, "Commodities", "Global"},
+		{"IAU", "iShares Gold Trust", models.AssetClassCommodity, "Commodities", "Global"},
+		{"SLV", "iShares Silver Trust", models.AssetClassCommodity, "Commodities", "Global"},
+		{"USO", "United States Oil Fund", models.AssetClassCommodity, "Commodities", "Global"},
+		{"DBC", "Invesco DB Commodity Index Tracking Fund", models.AssetClassCommodity, "Commodities", "Global"},
 		{"GBTC", "Grayscale Bitcoin Trust", models.AssetClassCrypto, "Cryptocurrency", "Global"},
 	}
 
@@ -32,7 +32,11 @@ func TestTagger_TagHolding_KnownTicker(t *testing.T) {
 		{"JPM", models.AssetClassEquity, "Financial Services", "US"},
 		{"VOO", models.AssetClassEquity, "Diversified", "US"},
 		{"BND", models.AssetClassFixedIncome, "Bonds", "US"},
+		{"TLT", models.AssetClassFixedIncome, "Treasury", "US"},
+		{"HYG", models.AssetClassFixedIncome, "High-Yield", "US"},
 		{"VNQ", models.AssetClassAlternative, "Real Estate", "US"},
+		{"PSP", models.AssetClassAlternative, "Private Equity", "Global"},
+		{"GLD", models.AssetClassCommodity, "Commodities", "Global"},
 		{"SPAXX", models.AssetClassCash, "Cash", "US"},
 		{"VWO", models.AssetClassEquity, "Diversified", "Emerging Markets"},
 	}
@@ -93,6 +97,24 @@ func TestTagger_TagHolding_Heuristics(t *testing.T) {
 			holdName:  "ABC Real Estate Investment Trust",
 			wantClass: models.AssetClassAlternative,
 		},
+		{
+			name:      "Gold fund",
+			ticker:    "AUXX",
+			holdName:  "Aberdeen Physical Gold Shares",
+			wantClass: models.AssetClassCommodity,
+		},
+		{
+			name:      "Oil fund",
+			ticker:    "OILX",
+			holdName:  "Crude Oil Strategy Fund",
+			wantClass: models.AssetClassCommodity,
+		},
+		{
+			name:      "Gold in a company name is not a commodity",
+			ticker:    "GSX",
+			holdName:  "Goldman Sachs Group",
+			wantClass: models.AssetClassEquity,
+		},
 		{
 			name:      "Unknown short ticker - assume equity",
 			ticker:    "XYZ",
@@ -141,6 +163,131 @@ func TestTagger_TagHoldings_Batch(t *testing.T) {
 	}
 }
 
+func TestTagger_TagHoldingsWithOverrides(t *testing.T) {
+	tagger := NewTagger()
+
+	overrides := map[string]models.TickerOverride{
+		"AAPL": {Ticker: "AAPL", AssetClass: models.AssetClassAlternative, Sector: "Collectibles", Geography: "Global"},
+		"ZZZQ": {Ticker: "ZZZQ", AssetClass: models.AssetClassFixedIncome, Sector: "Bonds", Geography: "US"},
+	}
+
+	holdings := []models.Holding{
+		{ID: uuid.New(), Ticker: "aapl", Name: "Apple Inc.", AssetClass: models.AssetClassOther},
+		{ID: uuid.New(), Ticker: "ZZZQ", Name: "Private Note", AssetClass: models.AssetClassOther},
+		{ID: uuid.New(), Ticker: "MSFT", Name: "Microsoft Corp", AssetClass: models.AssetClassOther},
+	}
+
+	tagger.TagHoldingsWithOverrides(holdings, overrides)
+
+	if holdings[0].AssetClass != models.AssetClassAlternative || holdings[0].Sector != "Collectibles" {
+		t.Errorf("AAPL: override should beat built-in data, got %s/%s", holdings[0].AssetClass, holdings[0].Sector)
+	}
+	if holdings[1].AssetClass != models.AssetClassFixedIncome || holdings[1].Geography != "US" {
+		t.Errorf("ZZZQ: expected override classification, got %s/%s", holdings[1].AssetClass, holdings[1].Geography)
+	}
+	if holdings[2].AssetClass != models.AssetClassEquity || holdings[2].Sector != "Technology" {
+		t.Errorf("MSFT: expected built-in classification, got %s/%s", holdings[2].AssetClass, holdings[2].Sector)
+	}
+}
+
+func TestTagger_TagHolding_ExchangeSuffix(t *testing.T) {
+	tagger := NewTagger()
+
+	tests := []struct {
+		ticker    string
+		holdName  string
+		wantClass models.AssetClass
+		wantGeo   string
+	}{
+		{"SHOP.TO", "Shopify Inc", models.AssetClassEquity, "Canada"},
+		{"BP.L", "BP PLC", models.AssetClassEquity, "International Developed"},
+		{"7203.T", "Toyota Motor Corp", models.AssetClassEquity, "Japan"},
+		{"XBB.TO", "iShares Core Canadian Universe Bond Index ETF", models.AssetClassFixedIncome, "Canada"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ticker, func(t *testing.T) {
+			h := &models.Holding{
+				ID:         uuid.New(),
+				Ticker:     tt.ticker,
+				Name:       tt.holdName,
+				AssetClass: models.AssetClassOther,
+			}
+
+			tagger.TagHolding(h)
+
+			if h.AssetClass != tt.wantClass {
+				t.Errorf("Asset class: got %s, want %s", h.AssetClass, tt.wantClass)
+			}
+			if h.Geography != tt.wantGeo {
+				t.Errorf("Geography: got %s, want %s", h.Geography, tt.wantGeo)
+			}
+			if h.Ticker != tt.ticker {
+				t.Errorf("Ticker should keep its suffix, got %s", h.Ticker)
+			}
+		})
+	}
+}
+
+func TestTagger_TagHolding_BondAndAlternativeSectors(t *testing.T) {
+	tagger := NewTagger()
+
+	tests := []struct {
+		ticker     string
+		holdName   string
+		wantSector string
+	}{
+		{"ABCD", "ABC Corporate Bond Fund", "Corporate"},
+		{"ABCE", "ABC High Yield Corporate Bond Fund", "High-Yield"},
+		{"ABCF", "ABC Intermediate Municipal Bond Fund", "Municipal"},
+		{"ABCG", "ABC Inflation-Protected Treasury Bond Fund", "TIPS"},
+		{"ABCH", "ABC Long-Term Treasury Bond Fund", "Treasury"},
+		{"ABCI", "ABC Total Bond Market Index Fund", "Bonds"},
+		{"ABCJ", "ABC Real Estate Investment Trust", "Real Estate"},
+		{"ABCK", "ABC Private Equity Partners", "Private Equity"},
+		{"ABCL", "ABC Global Infrastructure Fund", "Alternatives"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.holdName, func(t *testing.T) {
+			h := &models.Holding{
+				ID:         uuid.New(),
+				Ticker:     tt.ticker,
+				Name:       tt.holdName,
+				AssetClass: models.AssetClassOther,
+			}
+
+			tagger.TagHolding(h)
+
+			if h.Sector != tt.wantSector {
+				t.Errorf("Sector: got %s, want %s", h.Sector, tt.wantSector)
+			}
+		})
+	}
+}
+
+func TestTagger_TagHolding_Option(t *testing.T) {
+	tagger := NewTagger()
+
+	h := &models.Holding{
+		ID:         uuid.New(),
+		Ticker:     "AAPL240119C00150000",
+		Name:       "CALL APPLE INC $150 EXP 01/19/24",
+		AssetClass: models.AssetClassOther,
+	}
+	tagger.TagHolding(h)
+
+	if h.AssetClass != models.AssetClassDerivative {
+		t.Errorf("Asset class: got %s, want %s", h.AssetClass, models.AssetClassDerivative)
+	}
+	if h.Sector != "Options" {
+		t.Errorf("Sector: got %s, want Options", h.Sector)
+	}
+	if h.Geography != "US" {
+		t.Errorf("Geography: got %s, want US", h.Geography)
+	}
+}
+
 func TestTagger_DetectSector(t *testing.T) {
 	tagger := NewTagger()
 
@@ -214,24 +361,3 @@ func TestParseDecimal(t *testing.T) {
 		})
 	}
 }
-
-func TestCleanTicker(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"AAPL", "AAPL"},
-		{"  aapl  ", "AAPL"},
-		{"MSFT*", "MSFT"},
-		{"googl**", "GOOGL"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			result := cleanTicker(tt.input)
-			if result != tt.expected {
-				t.Errorf("cleanTicker(%q) = %q, want %q", tt.input, result, tt.expected)
-			}
-		})
-	}
-}
@@ -1,6 +1,7 @@
 package importer
 
 import (
+	"errors"
 	"io"
 	"strings"
 	"time"
@@ -57,9 +58,9 @@ func (p *VanguardParser) Parse(reader io.Reader, portfolioID uuid.UUID, accountN
 }
 
 // ParseRow parses a single Vanguard CSV row
-func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) *models.Holding {
+func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uuid.UUID, accountName string) (*models.Holding, error) {
 	if len(row) < 4 {
-		return nil
+		return nil, tooFewColumns(row, 4)
 	}
 
 	// Build column index map
@@ -77,9 +78,14 @@ func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uui
 		return ""
 	}
 
-	ticker := cleanTicker(getCol("symbol", "ticker"))
-	if ticker == "" || strings.Contains(strings.ToLower(ticker), "settlement") {
-		return nil
+	ticker, err := models.NormalizeTicker(getCol("symbol", "ticker"))
+	switch {
+	case ticker == "":
+		return nil, errors.New("missing symbol")
+	case strings.Contains(strings.ToLower(ticker), "settlement"):
+		return nil, skipRow("settlement fund")
+	case err != nil:
+		return nil, err
 	}
 
 	name := cleanName(getCol("investment name", "name", "description"))
@@ -89,7 +95,12 @@ func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uui
 
 	// Skip if no meaningful data
 	if shares.IsZero() && totalValue.IsZero() {
-		return nil
+		return nil, missingAmount(getCol("shares", "quantity"), getCol("total value", "value", "market value"))
+	}
+
+	currency, err := models.NormalizeCurrency(getCol(currencyColumns...))
+	if err != nil {
+		return nil, err
 	}
 
 	holding := &models.Holding{
@@ -103,6 +114,7 @@ func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uui
 		MarketValue:  totalValue,
 		AssetClass:   models.AssetClassOther,
 		Source:       "vanguard_csv",
+		Currency:     currency,
 		ImportedAt:   time.Now().UTC(),
 	}
 
@@ -110,7 +122,7 @@ func (p *VanguardParser) ParseRow(row []string, header []string, portfolioID uui
 		holding.CalculateMarketValue()
 	}
 
-	return holding
+	return holding, nil
 }
 
 // ParseVanguardCSV is a convenience function to parse Vanguard CSV data
@@ -126,12 +138,6 @@ func ParseVanguardCSV(records [][]string, portfolioID uuid.UUID, accountName str
 		return nil
 	}
 
-	var holdings []models.Holding
-	for i := 1; i < len(records); i++ {
-		if h := parser.ParseRow(records[i], header, portfolioID, accountName); h != nil {
-			holdings = append(holdings, *h)
-		}
-	}
-
+	holdings, _ := ParseRecords(parser, records, portfolioID, accountName)
 	return holdings
 }
@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEncryptedXLSX   = errors.New("XLSX file is password-protected; remove the password and re-export")
+	ErrInvalidXLSX     = errors.New("file is not a valid XLSX workbook")
+	ErrAmbiguousSheets = errors.New("XLSX workbook has more than one sheet with holdings; export a single sheet")
+)
+
+// XLSXContentType is the MIME type browsers send for .xlsx uploads
+const XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// oleSignature marks an OLE compound file. Excel wraps password-protected
+// workbooks in this container instead of a plain zip archive.
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// IsXLSX reports whether an upload looks like an Excel workbook, judged by
+// file extension or content type
+func IsXLSX(filename, contentType string) bool {
+	if strings.EqualFold(path.Ext(filename), ".xlsx") {
+		return true
+	}
+	return strings.HasPrefix(contentType, XLSXContentType)
+}
+
+// ReadXLSX reads the holdings sheet of an XLSX workbook into rows of cells,
+// matching what csv.Reader.ReadAll would return for the same data.
+//
+// Workbooks with a single populated sheet are read directly. When several
+// sheets are populated, the one with a recognizable holdings header is used;
+// if more than one qualifies the choice is ambiguous and ErrAmbiguousSheets
+// is returned rather than guessing.
+func ReadXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	sig := make([]byte, len(oleSignature))
+	if _, err := r.ReadAt(sig, 0); err == nil && bytes.Equal(sig, oleSignature) {
+		return nil, ErrEncryptedXLSX
+	}
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, ErrInvalidXLSX
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	sheetPaths, err := readSheetPaths(files)
+	if err != nil {
+		return nil, err
+	}
+
+	sharedStrings, err := readSharedStrings(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var populated [][][]string
+	for _, p := range sheetPaths {
+		f, ok := files[p]
+		if !ok {
+			continue
+		}
+		rows, err := readSheet(f, sharedStrings)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) > 0 {
+			populated = append(populated, rows)
+		}
+	}
+
+	switch len(populated) {
+	case 0:
+		return nil, ErrEmptyFile
+	case 1:
+		return populated[0], nil
+	}
+
+	var match [][]string
+	for _, rows := range populated {
+		if idx, _ := findHeader(rows); idx >= 0 {
+			if match != nil {
+				return nil, ErrAmbiguousSheets
+			}
+			match = rows
+		}
+	}
+	if match == nil {
+		return populated[0], nil
+	}
+	return match, nil
+}
+
+// readSheetPaths returns worksheet part names in workbook order
+func readSheetPaths(files map[string]*zip.File) ([]string, error) {
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := decodeXMLPart(files, "xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeXMLPart(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string, len(rels.Relationships))
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+	}
+
+	paths := make([]string, 0, len(workbook.Sheets))
+	for _, s := range workbook.Sheets {
+		if target, ok := targets[s.RID]; ok {
+			paths = append(paths, target)
+		}
+	}
+	return paths, nil
+}
+
+// readSharedStrings loads the workbook string table. It is optional; sheets
+// containing only numbers or inline strings omit it.
+func readSharedStrings(files map[string]*zip.File) ([]string, error) {
+	if _, ok := files["xl/sharedStrings.xml"]; !ok {
+		return nil, nil
+	}
+
+	var sst struct {
+		Items []xlsxRichString `xml:"si"`
+	}
+	if err := decodeXMLPart(files, "xl/sharedStrings.xml", &sst); err != nil {
+		return nil, err
+	}
+
+	strs := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		strs[i] = item.String()
+	}
+	return strs, nil
+}
+
+// xlsxRichString is either a plain <t> or a sequence of formatted <r><t> runs
+type xlsxRichString struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (s xlsxRichString) String() string {
+	if len(s.Runs) == 0 {
+		return s.Text
+	}
+	var b strings.Builder
+	for _, run := range s.Runs {
+		b.WriteString(run.Text)
+	}
+	return b.String()
+}
+
+func readSheet(f *zip.File, sharedStrings []string) ([][]string, error) {
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string         `xml:"r,attr"`
+				Type   string         `xml:"t,attr"`
+				Value  string         `xml:"v"`
+				Inline xlsxRichString `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeXMLFile(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for _, row := range sheet.Rows {
+		var cells []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			for len(cells) < col {
+				cells = append(cells, "")
+			}
+
+			value := c.Value
+			switch c.Type {
+			case "s":
+				idx, err := strconv.Atoi(c.Value)
+				if err != nil || idx < 0 || idx >= len(sharedStrings) {
+					return nil, fmt.Errorf("invalid shared string reference in cell %s: %w", c.Ref, ErrInvalidXLSX)
+				}
+				value = sharedStrings[idx]
+			case "inlineStr":
+				value = c.Inline.String()
+			case "b":
+				if value == "1" {
+					value = "TRUE"
+				} else {
+					value = "FALSE"
+				}
+			}
+			cells = append(cells, value)
+		}
+
+		if !isBlankRow(cells) {
+			rows = append(rows, cells)
+		}
+	}
+	return rows, nil
+}
+
+// columnIndex converts a cell reference such as "C12" to a zero-based column
+func columnIndex(ref string) int {
+	col := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		col = col*26 + int(ch-'A'+1)
+	}
+	return col - 1
+}
+
+func isBlankRow(cells []string) bool {
+	for _, c := range cells {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeXMLPart(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("missing %s: %w", name, ErrInvalidXLSX)
+	}
+	return decodeXMLFile(f, v)
+}
+
+func decodeXMLFile(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", f.Name, ErrInvalidXLSX)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// buildXLSX assembles a minimal workbook where each sheet is given as raw
+// <sheetData> rows. Shared strings are referenced by index from the rows.
+func buildXLSX(t *testing.T, sharedStrings []string, sheets ...string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	write := func(name, body string) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		w.Write([]byte(body))
+	}
+
+	var sheetList, rels strings.Builder
+	for i, data := range sheets {
+		n := i + 1
+		fmt.Fprintf(&sheetList, `<sheet name="Sheet%d" sheetId="%d" r:id="rId%d"/>`, n, n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, n, n)
+		write(fmt.Sprintf("xl/worksheets/sheet%d.xml", n),
+			`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`+data+`</sheetData></worksheet>`)
+	}
+
+	write("xl/workbook.xml",
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`+
+			sheetList.String()+`</sheets></workbook>`)
+	write("xl/_rels/workbook.xml.rels",
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`+rels.String()+`</Relationships>`)
+
+	if len(sharedStrings) > 0 {
+		var sst strings.Builder
+		for _, s := range sharedStrings {
+			fmt.Fprintf(&sst, "<si><t>%s</t></si>", s)
+		}
+		write("xl/sharedStrings.xml",
+			`<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`+sst.String()+`</sst>`)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("Failed to close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+const holdingsSheet = `
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c><c r="E1" t="s"><v>4</v></c></row>
+<row r="2"><c r="A2" t="s"><v>5</v></c><c r="B2" t="inlineStr"><is><t>Apple Inc.</t></is></c><c r="C2"><v>100</v></c><c r="D2"><v>175.5</v></c><c r="E2"><v>17550</v></c></row>
+<row r="3"><c r="A3" t="s"><v>6</v></c><c r="C3"><v>50</v></c><c r="E3"><v>18912.5</v></c></row>`
+
+var holdingsStrings = []string{"Symbol", "Description", "Quantity", "Price", "Market Value", "AAPL", "MSFT"}
+
+func TestReadXLSX(t *testing.T) {
+	data := buildXLSX(t, holdingsStrings, holdingsSheet)
+
+	rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("Expected 3 rows, got %d", len(rows))
+	}
+	if rows[0][0] != "Symbol" || rows[0][4] != "Market Value" {
+		t.Errorf("Unexpected header: %v", rows[0])
+	}
+	if rows[1][1] != "Apple Inc." {
+		t.Errorf("Expected inline string to be read, got %q", rows[1][1])
+	}
+
+	// Sparse row: B3 and D3 are missing and must be padded to keep columns aligned
+	if len(rows[2]) != 5 || rows[2][1] != "" || rows[2][4] != "18912.5" {
+		t.Errorf("Expected sparse row to keep column positions, got %v", rows[2])
+	}
+
+	holdings := ParseSchwabCSV(rows, uuid.New(), "Test")
+	if len(holdings) != 2 {
+		t.Errorf("Expected 2 holdings from XLSX rows, got %d", len(holdings))
+	}
+}
+
+func TestReadXLSX_MultipleSheets(t *testing.T) {
+	notes := `<row r="1"><c r="A1" t="inlineStr"><is><t>Exported from brokerage</t></is></c></row>`
+
+	data := buildXLSX(t, holdingsStrings, notes, holdingsSheet)
+	rows, err := ReadXLSX(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if rows[0][0] != "Symbol" {
+		t.Errorf("Expected the holdings sheet to be chosen, got header %v", rows[0])
+	}
+
+	data = buildXLSX(t, holdingsStrings, holdingsSheet, holdingsSheet)
+	if _, err := ReadXLSX(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrAmbiguousSheets) {
+		t.Errorf("Expected ErrAmbiguousSheets, got %v", err)
+	}
+}
+
+func TestReadXLSX_Errors(t *testing.T) {
+	encrypted := append([]byte{}, oleSignature...)
+	encrypted = append(encrypted, make([]byte, 512)...)
+	if _, err := ReadXLSX(bytes.NewReader(encrypted), int64(len(encrypted))); !errors.Is(err, ErrEncryptedXLSX) {
+		t.Errorf("Expected ErrEncryptedXLSX, got %v", err)
+	}
+
+	garbage := []byte("Symbol,Quantity\nAAPL,10\n")
+	if _, err := ReadXLSX(bytes.NewReader(garbage), int64(len(garbage))); !errors.Is(err, ErrInvalidXLSX) {
+		t.Errorf("Expected ErrInvalidXLSX, got %v", err)
+	}
+
+	empty := buildXLSX(t, nil, "")
+	if _, err := ReadXLSX(bytes.NewReader(empty), int64(len(empty))); !errors.Is(err, ErrEmptyFile) {
+		t.Errorf("Expected ErrEmptyFile, got %v", err)
+	}
+}
+
+func TestIsXLSX(t *testing.T) {
+	tests := []struct {
+		filename    string
+		contentType string
+		want        bool
+	}{
+		{"positions.xlsx", "", true},
+		{"POSITIONS.XLSX", "application/octet-stream", true},
+		{"upload", XLSXContentType, true},
+		{"positions.csv", "text/csv", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsXLSX(tt.filename, tt.contentType); got != tt.want {
+			t.Errorf("IsXLSX(%q, %q) = %v, want %v", tt.filename, tt.contentType, got, tt.want)
+		}
+	}
+}
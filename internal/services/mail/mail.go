@@ -0,0 +1,100 @@
+// Package mail sends transactional email
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// Sender delivers a plain-text email
+type Sender interface {
+	Send(to, subject, body string) error
+}
+
+// HTMLSender delivers an email with plain-text and HTML versions of the
+// body, for clients to pick between
+type HTMLSender interface {
+	Sender
+	SendHTML(to, subject, text, html string) error
+}
+
+// SMTPSender sends email through an SMTP server
+type SMTPSender struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPSender creates a sender for the server at addr (host:port). The
+// username and password may be empty for servers that don't need them.
+func NewSMTPSender(addr, from, username, password string) *SMTPSender {
+	s := &SMTPSender{addr: addr, from: from}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// Send delivers one message
+func (s *SMTPSender) Send(to, subject, body string) error {
+	return s.send(to, subject, "text/plain; charset=UTF-8", body)
+}
+
+// SendHTML delivers one message as multipart/alternative, plain text first
+func (s *SMTPSender) SendHTML(to, subject, text, html string) error {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return err
+	}
+
+	return s.send(to, subject, "multipart/alternative; boundary="+parts.Boundary(), body.String())
+}
+
+func (s *SMTPSender) send(to, subject, contentType, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("invalid recipient or subject")
+	}
+
+	msg := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: " + contentType + "\r\n" +
+		"\r\n" + body
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg))
+}
+
+// LogSender writes emails to the log instead of sending them, for
+// development without a mail server
+type LogSender struct{}
+
+// Send logs the message
+func (LogSender) Send(to, subject, body string) error {
+	log.Printf("mail to %s: %s\n%s", to, subject, body)
+	return nil
+}
+
+// SendHTML logs the plain-text version of the message
+func (l LogSender) SendHTML(to, subject, text, html string) error {
+	return l.Send(to, subject, text)
+}
@@ -0,0 +1,154 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/metrics"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// coinGeckoURL is CoinGecko's spot price endpoint, which takes many coins
+// per request
+const coinGeckoURL = "https://api.coingecko.com/api/v3/simple/price"
+
+// mockCryptoPrices are approximate US dollar prices for the mock provider
+var mockCryptoPrices = map[string]float64{
+	"BTC":  65000.00,
+	"ETH":  3500.00,
+	"SOL":  150.00,
+	"USDC": 1.00,
+	"USDT": 1.00,
+}
+
+// GetCryptoQuotes returns US dollar spot quotes for coins such as BTC,
+// keyed by symbol. Coins without a price are left out. They're cached like
+// stock quotes but apart from them, since some coin symbols are also stock
+// tickers. The mock provider prices coins itself; the others use CoinGecko.
+func (s *Service) GetCryptoQuotes(symbols []string) (map[string]*Quote, error) {
+	quotes := make(map[string]*Quote, len(symbols))
+	var missing []string
+
+	s.mu.RLock()
+	for _, symbol := range symbols {
+		if cached, ok := s.crypto[symbol]; ok && time.Since(cached.LastUpdated) < s.cacheTTL {
+			quotes[symbol] = cached
+		} else {
+			missing = append(missing, symbol)
+		}
+	}
+	s.mu.RUnlock()
+	metrics.QuoteCache.WithLabelValues("hit").Add(float64(len(quotes)))
+	metrics.QuoteCache.WithLabelValues("miss").Add(float64(len(missing)))
+
+	if len(missing) == 0 {
+		return quotes, nil
+	}
+
+	var fetched map[string]*Quote
+	var err error
+	switch s.provider {
+	case ProviderYahoo, ProviderAlpha:
+		fetched, err = s.fetchCoinGeckoQuotes(missing)
+	default:
+		fetched = getMockCryptoQuotes(missing)
+	}
+
+	if err != nil {
+		metrics.QuoteProviderErrors.WithLabelValues("coingecko").Inc()
+		if len(quotes) == 0 {
+			return nil, err
+		}
+		return quotes, nil
+	}
+
+	s.mu.Lock()
+	for symbol, quote := range fetched {
+		s.crypto[symbol] = quote
+		quotes[symbol] = quote
+	}
+	s.mu.Unlock()
+
+	return quotes, nil
+}
+
+func getMockCryptoQuotes(symbols []string) map[string]*Quote {
+	quotes := make(map[string]*Quote, len(symbols))
+	for _, symbol := range symbols {
+		price, ok := mockCryptoPrices[symbol]
+		if !ok {
+			continue
+		}
+		quotes[symbol] = &Quote{
+			Ticker:       symbol,
+			Price:        decimal.NewFromFloat(price),
+			LastUpdated:  time.Now(),
+			IsMarketOpen: true,
+		}
+	}
+	return quotes
+}
+
+// fetchCoinGeckoQuotes prices every coin CoinGecko lists in one request
+func (s *Service) fetchCoinGeckoQuotes(symbols []string) (map[string]*Quote, error) {
+	ids := make([]string, 0, len(symbols))
+	byID := make(map[string]string, len(symbols))
+	for _, symbol := range symbols {
+		if coin, ok := models.CryptoCoins[symbol]; ok {
+			ids = append(ids, coin.CoinGeckoID)
+			byID[coin.CoinGeckoID] = symbol
+		}
+	}
+	if len(ids) == 0 {
+		return map[string]*Quote{}, nil
+	}
+
+	query := url.Values{
+		"ids":                 {strings.Join(ids, ",")},
+		"vs_currencies":       {"usd"},
+		"include_24hr_change": {"true"},
+	}
+	resp, err := s.httpClient.Get(coinGeckoURL + "?" + query.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch crypto prices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch crypto prices: %s", resp.Status)
+	}
+
+	var result map[string]struct {
+		USD       decimal.Decimal `json:"usd"`
+		USDChange decimal.Decimal `json:"usd_24h_change"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	quotes := make(map[string]*Quote, len(result))
+	for id, price := range result {
+		symbol, ok := byID[id]
+		if !ok || !price.USD.IsPositive() {
+			continue
+		}
+
+		// The change is reported as a percentage of yesterday's price
+		hundred := decimal.NewFromInt(100)
+		change := price.USD.Mul(price.USDChange).Div(hundred.Add(price.USDChange))
+		quotes[symbol] = &Quote{
+			Ticker:        symbol,
+			Price:         price.USD,
+			Change:        change.Round(2),
+			ChangePercent: price.USDChange.Round(2),
+			LastUpdated:   time.Now(),
+			IsMarketOpen:  true, // Coins trade around the clock
+		}
+	}
+	return quotes, nil
+}
@@ -0,0 +1,230 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/findosh/truenorth/internal/metrics"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNoExpenseRatio is returned for a ticker the provider has no expense
+// ratio for, such as an individual stock
+var ErrNoExpenseRatio = errors.New("no expense ratio")
+
+// expenseRatioTTL is how long expense ratios are cached. Funds publish them
+// at most a few times a year, so they're kept far longer than quotes.
+const expenseRatioTTL = 24 * time.Hour
+
+// expenseErrorTTL is how long a failed lookup is remembered, so an outage
+// costs one request per ticker every few minutes rather than one per page
+const expenseErrorTTL = 5 * time.Minute
+
+// expenseConcurrency caps how many tickers' expense ratios are fetched at
+// once, and expenseBatchTimeout is how long a batch waits for them
+const (
+	expenseConcurrency  = 8
+	expenseBatchTimeout = 5 * time.Second
+)
+
+// expenseEntry is a cached expense ratio. Misses are cached too, so tickers
+// without one aren't looked up on every request, and failures briefly.
+type expenseEntry struct {
+	ratio   decimal.Decimal
+	found   bool
+	err     error
+	fetched time.Time
+}
+
+// fresh reports whether the entry can still be served
+func (e *expenseEntry) fresh() bool {
+	if e.err != nil {
+		return time.Since(e.fetched) < expenseErrorTTL
+	}
+	return time.Since(e.fetched) < expenseRatioTTL
+}
+
+// GetExpenseRatio returns a fund's net expense ratio as a percentage
+// (0.03 = 0.03%), or ErrNoExpenseRatio when the provider doesn't list one.
+// The mock provider never does.
+func (s *Service) GetExpenseRatio(ticker string) (decimal.Decimal, error) {
+	ticker, err := models.NormalizeTicker(ticker)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	s.mu.RLock()
+	if cached, ok := s.expense[ticker]; ok && cached.fresh() {
+		s.mu.RUnlock()
+		metrics.QuoteCache.WithLabelValues("hit").Inc()
+		if cached.err != nil {
+			return decimal.Zero, cached.err
+		}
+		if !cached.found {
+			return decimal.Zero, ErrNoExpenseRatio
+		}
+		return cached.ratio, nil
+	}
+	s.mu.RUnlock()
+	metrics.QuoteCache.WithLabelValues("miss").Inc()
+
+	var ratio decimal.Decimal
+	switch s.provider {
+	case ProviderYahoo:
+		ratio, err = s.fetchYahooExpenseRatio(ticker)
+	case ProviderAlpha:
+		ratio, err = s.fetchAlphaVantageExpenseRatio(ticker)
+	default:
+		err = ErrNoExpenseRatio
+	}
+
+	entry := &expenseEntry{ratio: ratio, found: err == nil, fetched: time.Now()}
+	if err != nil && err != ErrNoExpenseRatio {
+		metrics.QuoteProviderErrors.WithLabelValues(string(s.provider)).Inc()
+		entry.err = err
+	}
+
+	s.mu.Lock()
+	s.expense[ticker] = entry
+	s.mu.Unlock()
+
+	return ratio, err
+}
+
+// GetExpenseRatios looks up several tickers' expense ratios at once, keyed
+// by ticker as given. Tickers without one, or not answered within
+// expenseBatchTimeout, are left out; lookups still running then finish in
+// the background and fill the cache for the next batch.
+func (s *Service) GetExpenseRatios(tickers []string) map[string]decimal.Decimal {
+	ratios := make(map[string]decimal.Decimal)
+	seen := make(map[string]bool)
+	deadline := time.Now().Add(expenseBatchTimeout)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, expenseConcurrency)
+
+	for _, ticker := range tickers {
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if time.Now().After(deadline) {
+				return
+			}
+
+			ratio, err := s.GetExpenseRatio(t)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			ratios[t] = ratio
+			mu.Unlock()
+		}(ticker)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Until(deadline)):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	found := make(map[string]decimal.Decimal, len(ratios))
+	for t, ratio := range ratios {
+		found[t] = ratio
+	}
+	return found
+}
+
+// Yahoo Finance reports a fund's expense ratio as a fraction in its profile
+func (s *Service) fetchYahooExpenseRatio(ticker string) (decimal.Decimal, error) {
+	url := fmt.Sprintf("https://query2.finance.yahoo.com/v10/finance/quoteSummary/%s?modules=fundProfile", ticker)
+
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to fetch expense ratio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return decimal.Zero, ErrNoExpenseRatio
+	}
+	if resp.StatusCode != http.StatusOK {
+		return decimal.Zero, fmt.Errorf("expense ratio request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		QuoteSummary struct {
+			Result []struct {
+				FundProfile struct {
+					Fees struct {
+						ExpenseRatio struct {
+							Raw *decimal.Decimal `json:"raw"`
+						} `json:"annualReportExpenseRatio"`
+					} `json:"feesExpensesInvestment"`
+				} `json:"fundProfile"`
+			} `json:"result"`
+		} `json:"quoteSummary"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.QuoteSummary.Result) == 0 {
+		return decimal.Zero, ErrNoExpenseRatio
+	}
+	raw := result.QuoteSummary.Result[0].FundProfile.Fees.ExpenseRatio.Raw
+	if raw == nil || raw.IsNegative() {
+		return decimal.Zero, ErrNoExpenseRatio
+	}
+
+	return raw.Mul(decimal.NewFromInt(100)), nil
+}
+
+// Alpha Vantage reports an ETF's net expense ratio as a fraction
+func (s *Service) fetchAlphaVantageExpenseRatio(ticker string) (decimal.Decimal, error) {
+	if s.apiKey == "" {
+		return decimal.Zero, ErrNoExpenseRatio
+	}
+
+	url := fmt.Sprintf("https://www.alphavantage.co/query?function=ETF_PROFILE&symbol=%s&apikey=%s",
+		ticker, s.apiKey)
+
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to fetch expense ratio: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		NetExpenseRatio string `json:"net_expense_ratio"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	ratio, err := decimal.NewFromString(result.NetExpenseRatio)
+	if err != nil || ratio.IsNegative() {
+		return decimal.Zero, ErrNoExpenseRatio
+	}
+
+	return ratio.Mul(decimal.NewFromInt(100)), nil
+}
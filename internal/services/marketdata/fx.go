@@ -0,0 +1,171 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/metrics"
+	"github.com/shopspring/decimal"
+)
+
+// ErrUnknownCurrency is returned for a currency the provider has no rate for
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// fxEntry is a cached exchange rate
+type fxEntry struct {
+	rate    decimal.Decimal
+	fetched time.Time
+}
+
+// mockUSDRates are approximate US dollars per unit of each currency
+var mockUSDRates = map[string]float64{
+	"USD": 1.00,
+	"EUR": 1.08,
+	"GBP": 1.27,
+	"CAD": 0.74,
+	"CHF": 1.12,
+	"JPY": 0.0067,
+	"AUD": 0.66,
+}
+
+// GetFXRate returns how many units of the quote currency one unit of the
+// base currency buys, so an amount in base converts to quote by multiplying.
+// Rates are cached like quotes.
+func (s *Service) GetFXRate(base, quote string) (decimal.Decimal, error) {
+	base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+	if base == quote {
+		return decimal.NewFromInt(1), nil
+	}
+
+	pair := base + quote
+	s.mu.RLock()
+	if cached, ok := s.fx[pair]; ok && time.Since(cached.fetched) < s.cacheTTL {
+		s.mu.RUnlock()
+		metrics.QuoteCache.WithLabelValues("hit").Inc()
+		return cached.rate, nil
+	}
+	s.mu.RUnlock()
+	metrics.QuoteCache.WithLabelValues("miss").Inc()
+
+	var rate decimal.Decimal
+	var err error
+
+	switch s.provider {
+	case ProviderYahoo:
+		rate, err = s.fetchYahooFXRate(base, quote)
+	case ProviderAlpha:
+		rate, err = s.fetchAlphaVantageFXRate(base, quote)
+	default:
+		rate, err = getMockFXRate(base, quote)
+	}
+
+	if err != nil {
+		if err != ErrUnknownCurrency {
+			metrics.QuoteProviderErrors.WithLabelValues(string(s.provider)).Inc()
+		}
+		return decimal.Zero, err
+	}
+
+	s.mu.Lock()
+	s.fx[pair] = &fxEntry{rate: rate, fetched: time.Now()}
+	s.mu.Unlock()
+
+	return rate, nil
+}
+
+// getFXRates fetches the rate from each currency into base, skipping
+// currencies that fail so one bad rate doesn't block a refresh
+func (s *Service) getFXRates(currencies []string, base string) map[string]decimal.Decimal {
+	rates := make(map[string]decimal.Decimal, len(currencies))
+	for _, c := range currencies {
+		if rate, err := s.GetFXRate(c, base); err == nil {
+			rates[c] = rate
+		}
+	}
+	return rates
+}
+
+func getMockFXRate(base, quote string) (decimal.Decimal, error) {
+	baseUSD, ok := mockUSDRates[base]
+	if !ok {
+		return decimal.Zero, ErrUnknownCurrency
+	}
+	quoteUSD, ok := mockUSDRates[quote]
+	if !ok {
+		return decimal.Zero, ErrUnknownCurrency
+	}
+	return decimal.NewFromFloat(baseUSD).Div(decimal.NewFromFloat(quoteUSD)), nil
+}
+
+// Yahoo Finance lists currency pairs as tickers like EURUSD=X
+func (s *Service) fetchYahooFXRate(base, quote string) (decimal.Decimal, error) {
+	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s%s=X", base, quote)
+
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to fetch FX rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Fall back to mock data
+		return getMockFXRate(base, quote)
+	}
+
+	var result struct {
+		Chart struct {
+			Result []struct {
+				Meta struct {
+					RegularMarketPrice decimal.Decimal `json:"regularMarketPrice"`
+				} `json:"meta"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.Chart.Result) == 0 || !result.Chart.Result[0].Meta.RegularMarketPrice.IsPositive() {
+		return getMockFXRate(base, quote)
+	}
+
+	return result.Chart.Result[0].Meta.RegularMarketPrice, nil
+}
+
+// Alpha Vantage integration (simplified)
+func (s *Service) fetchAlphaVantageFXRate(base, quote string) (decimal.Decimal, error) {
+	if s.apiKey == "" {
+		return getMockFXRate(base, quote)
+	}
+
+	url := fmt.Sprintf("https://www.alphavantage.co/query?function=CURRENCY_EXCHANGE_RATE&from_currency=%s&to_currency=%s&apikey=%s",
+		base, quote, s.apiKey)
+
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to fetch FX rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ExchangeRate struct {
+			Rate string `json:"5. Exchange Rate"`
+		} `json:"Realtime Currency Exchange Rate"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	rate, err := decimal.NewFromString(result.ExchangeRate.Rate)
+	if err != nil || !rate.IsPositive() {
+		return decimal.Zero, ErrUnknownCurrency
+	}
+
+	return rate, nil
+}
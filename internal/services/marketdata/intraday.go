@@ -0,0 +1,224 @@
+package marketdata
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/findosh/truenorth/internal/metrics"
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// Intraday candle intervals, named as Yahoo Finance names them
+const (
+	Interval1m  = "1m"
+	Interval5m  = "5m"
+	Interval15m = "15m"
+)
+
+// intervalDurations are the supported intraday intervals
+var intervalDurations = map[string]time.Duration{
+	Interval1m:  time.Minute,
+	Interval5m:  5 * time.Minute,
+	Interval15m: 15 * time.Minute,
+}
+
+// ErrInvalidInterval is returned for intervals other than 1m, 5m, and 15m
+var ErrInvalidInterval = errors.New("interval must be 1m, 5m, or 15m")
+
+// intradayEntry is a cached set of candles for one ticker and interval
+type intradayEntry struct {
+	candles    []models.PriceHistory
+	fetchedAt  time.Time
+	marketOpen bool // Whether the market was open when fetched
+}
+
+// fresh reports whether the entry can still be served. Candles fetched
+// while the market is closed can't change until it reopens, so they're
+// kept past the usual TTL.
+func (e *intradayEntry) fresh(ttl time.Duration, marketOpen bool) bool {
+	if !e.marketOpen && !marketOpen {
+		return true
+	}
+	return time.Since(e.fetchedAt) < ttl
+}
+
+// GetIntraday returns candles at the given interval for the current trading
+// session, or the most recent one while the market is closed
+func (s *Service) GetIntraday(ticker, interval string) ([]models.PriceHistory, error) {
+	step, ok := intervalDurations[interval]
+	if !ok {
+		return nil, ErrInvalidInterval
+	}
+	ticker, err := models.NormalizeTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+	key := ticker + "|" + interval
+	marketOpen := s.IsMarketOpen()
+
+	s.mu.RLock()
+	if cached, ok := s.intraday[key]; ok && cached.fresh(s.cacheTTL, marketOpen) {
+		s.mu.RUnlock()
+		metrics.QuoteCache.WithLabelValues("hit").Inc()
+		return cached.candles, nil
+	}
+	s.mu.RUnlock()
+	metrics.QuoteCache.WithLabelValues("miss").Inc()
+
+	var candles []models.PriceHistory
+	switch s.provider {
+	case ProviderYahoo:
+		candles, err = s.fetchYahooIntraday(ticker, interval, step)
+	default:
+		candles, err = s.getMockIntraday(ticker, step, time.Now())
+	}
+
+	if err != nil {
+		metrics.QuoteProviderErrors.WithLabelValues(string(s.provider)).Inc()
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.intraday[key] = &intradayEntry{candles: candles, fetchedAt: time.Now(), marketOpen: marketOpen}
+	s.mu.Unlock()
+
+	return candles, nil
+}
+
+// sessionBounds returns the open and close of the trading session in
+// progress at now, or of the most recent one if the market is closed
+func sessionBounds(now time.Time) (time.Time, time.Time) {
+	now = now.In(marketZone)
+	day := now
+	if now.Hour() < 9 || (now.Hour() == 9 && now.Minute() < 30) {
+		day = day.AddDate(0, 0, -1)
+	}
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	sessionOpen := time.Date(day.Year(), day.Month(), day.Day(), 9, 30, 0, 0, marketZone)
+	sessionClose := time.Date(day.Year(), day.Month(), day.Day(), 16, 0, 0, 0, marketZone)
+	return sessionOpen, sessionClose
+}
+
+// getMockIntraday simulates the session as a random walk that ends at the
+// current mock quote
+func (s *Service) getMockIntraday(ticker string, step time.Duration, now time.Time) ([]models.PriceHistory, error) {
+	quote, err := s.GetQuote(ticker, false)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionOpen, sessionClose := sessionBounds(now)
+	end := sessionClose
+	if now.Before(sessionClose) {
+		end = now
+	}
+
+	var times []time.Time
+	for t := sessionOpen; !t.After(end); t = t.Add(step) {
+		times = append(times, t)
+	}
+
+	// Walk backward from the quote so the last close matches it
+	hash := 0
+	for _, c := range ticker {
+		hash += int(c)
+	}
+	candles := make([]models.PriceHistory, len(times))
+	price := quote.Price
+	for i := len(times) - 1; i >= 0; i-- {
+		// Small deterministic move of up to ±0.2% per candle
+		move := decimal.NewFromFloat(float64((hash+i*7)%9-4) / 2000)
+		prev := price.Div(decimal.NewFromInt(1).Add(move))
+
+		high, low := price, prev
+		if prev.GreaterThan(price) {
+			high, low = prev, price
+		}
+		candles[i] = models.PriceHistory{
+			Ticker:   ticker,
+			Date:     times[i].UTC(),
+			Open:     prev.Round(2),
+			High:     high.Mul(decimal.NewFromFloat(1.0005)).Round(2),
+			Low:      low.Mul(decimal.NewFromFloat(0.9995)).Round(2),
+			Close:    price.Round(2),
+			AdjClose: price.Round(2),
+			Volume:   10000 + int64((hash+i*13)%50)*1000,
+		}
+		price = prev
+	}
+
+	return candles, nil
+}
+
+// fetchYahooIntraday loads the latest session's candles from Yahoo Finance
+func (s *Service) fetchYahooIntraday(ticker, interval string, step time.Duration) ([]models.PriceHistory, error) {
+	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?interval=%s&range=1d", ticker, interval)
+
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch intraday prices: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Fall back to mock data
+		return s.getMockIntraday(ticker, step, time.Now())
+	}
+
+	var result struct {
+		Chart struct {
+			Result []struct {
+				Timestamp  []int64 `json:"timestamp"`
+				Indicators struct {
+					Quote []struct {
+						Open   []*float64 `json:"open"`
+						High   []*float64 `json:"high"`
+						Low    []*float64 `json:"low"`
+						Close  []*float64 `json:"close"`
+						Volume []*int64   `json:"volume"`
+					} `json:"quote"`
+				} `json:"indicators"`
+			} `json:"result"`
+		} `json:"chart"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(result.Chart.Result) == 0 || len(result.Chart.Result[0].Indicators.Quote) == 0 {
+		return s.getMockIntraday(ticker, step, time.Now())
+	}
+
+	chart := result.Chart.Result[0]
+	q := chart.Indicators.Quote[0]
+	candles := make([]models.PriceHistory, 0, len(chart.Timestamp))
+	for i, ts := range chart.Timestamp {
+		// Yahoo leaves gaps as nulls when nothing traded in the interval
+		if i >= len(q.Close) || q.Open[i] == nil || q.High[i] == nil || q.Low[i] == nil || q.Close[i] == nil {
+			continue
+		}
+		candle := models.PriceHistory{
+			Ticker:   ticker,
+			Date:     time.Unix(ts, 0).UTC(),
+			Open:     decimal.NewFromFloat(*q.Open[i]).Round(2),
+			High:     decimal.NewFromFloat(*q.High[i]).Round(2),
+			Low:      decimal.NewFromFloat(*q.Low[i]).Round(2),
+			Close:    decimal.NewFromFloat(*q.Close[i]).Round(2),
+			AdjClose: decimal.NewFromFloat(*q.Close[i]).Round(2),
+		}
+		if i < len(q.Volume) && q.Volume[i] != nil {
+			candle.Volume = *q.Volume[i]
+		}
+		candles = append(candles, candle)
+	}
+
+	return candles, nil
+}
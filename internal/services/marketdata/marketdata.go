@@ -3,10 +3,13 @@ package marketdata
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/findosh/truenorth/internal/metrics"
 	"github.com/findosh/truenorth/internal/models"
 	"github.com/shopspring/decimal"
 )
@@ -33,8 +36,9 @@ type Quote struct {
 	MarketCap     decimal.Decimal `json:"market_cap,omitempty"`
 	PE            decimal.Decimal `json:"pe,omitempty"`
 	Dividend      decimal.Decimal `json:"dividend,omitempty"`
-	LastUpdated   time.Time       `json:"last_updated"`
+	LastUpdated   time.Time       `json:"last_updated"` // When it was fetched from the provider
 	IsMarketOpen  bool            `json:"is_market_open"`
+	Cached        bool            `json:"cached"` // Served from the cache rather than fetched for this call
 }
 
 // Service provides market data functionality
@@ -42,7 +46,13 @@ type Service struct {
 	provider   Provider
 	apiKey     string
 	cache      map[string]*Quote
+	intraday   map[string]*intradayEntry
+	fx         map[string]*fxEntry
+	expense    map[string]*expenseEntry
+	crypto     map[string]*Quote // Coin spot quotes, keyed by symbol
+	inflight   map[string]*quoteCall // Quote fetches in progress, by ticker
 	cacheTTL   time.Duration
+	mockSeed   int64
 	mu         sync.RWMutex
 	httpClient *http.Client
 }
@@ -52,6 +62,9 @@ type Config struct {
 	Provider Provider
 	APIKey   string
 	CacheTTL time.Duration
+	// MockSeed makes the mock provider's quotes and history the same on
+	// every run when non-zero. Unseeded, they vary from day to day.
+	MockSeed int64
 }
 
 // NewService creates a new market data service
@@ -64,29 +77,76 @@ func NewService(cfg Config) *Service {
 		provider: cfg.Provider,
 		apiKey:   cfg.APIKey,
 		cache:    make(map[string]*Quote),
+		intraday: make(map[string]*intradayEntry),
+		fx:       make(map[string]*fxEntry),
+		expense:  make(map[string]*expenseEntry),
+		crypto:   make(map[string]*Quote),
+		inflight: make(map[string]*quoteCall),
 		cacheTTL: cfg.CacheTTL,
+		mockSeed: cfg.MockSeed,
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
 	}
 }
 
-// GetQuote fetches a quote for a single ticker
-func (s *Service) GetQuote(ticker string) (*Quote, error) {
+// GetQuote fetches a quote for a single ticker. Quotes fetched within the
+// cache TTL are reused unless forceRefresh is set; a reused quote is marked
+// Cached and keeps the LastUpdated of its fetch.
+func (s *Service) GetQuote(ticker string, forceRefresh bool) (*Quote, error) {
+	ticker, err := models.NormalizeTicker(ticker)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check cache first
-	s.mu.RLock()
-	if cached, ok := s.cache[ticker]; ok {
-		if time.Since(cached.LastUpdated) < s.cacheTTL {
-			s.mu.RUnlock()
-			return cached, nil
+	if !forceRefresh {
+		s.mu.RLock()
+		if cached, ok := s.cache[ticker]; ok {
+			if time.Since(cached.LastUpdated) < s.cacheTTL {
+				quote := *cached
+				s.mu.RUnlock()
+				metrics.QuoteCache.WithLabelValues("hit").Inc()
+				quote.Cached = true
+				return &quote, nil
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	return s.fetchQuoteShared(ticker)
+}
+
+// quoteCall is a quote fetch in progress, which concurrent lookups of the
+// same ticker wait on instead of fetching it again
+type quoteCall struct {
+	done  chan struct{}
+	quote *Quote
+	err   error
+}
+
+// fetchQuoteShared fetches a quote from the provider, joining a fetch of
+// the same ticker that's already in flight rather than starting another
+func (s *Service) fetchQuoteShared(ticker string) (*Quote, error) {
+	s.mu.Lock()
+	if call, ok := s.inflight[ticker]; ok {
+		s.mu.Unlock()
+		metrics.QuoteCache.WithLabelValues("shared").Inc()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
 		}
+		quote := *call.quote
+		return &quote, nil
 	}
-	s.mu.RUnlock()
+	call := &quoteCall{done: make(chan struct{})}
+	s.inflight[ticker] = call
+	s.mu.Unlock()
+	metrics.QuoteCache.WithLabelValues("miss").Inc()
 
 	// Fetch from provider
 	var quote *Quote
 	var err error
-
 	switch s.provider {
 	case ProviderYahoo:
 		quote, err = s.fetchYahooQuote(ticker)
@@ -95,21 +155,31 @@ func (s *Service) GetQuote(ticker string) (*Quote, error) {
 	default:
 		quote = s.getMockQuote(ticker)
 	}
-
 	if err != nil {
-		return nil, err
+		metrics.QuoteProviderErrors.WithLabelValues(string(s.provider)).Inc()
 	}
 
-	// Update cache
+	// Update cache with a copy, so callers can't change it
 	s.mu.Lock()
-	s.cache[ticker] = quote
+	if err == nil {
+		cached := *quote
+		s.cache[ticker] = &cached
+	}
+	delete(s.inflight, ticker)
 	s.mu.Unlock()
 
-	return quote, nil
+	call.quote, call.err = quote, err
+	close(call.done)
+	if err != nil {
+		return nil, err
+	}
+	result := *quote
+	return &result, nil
 }
 
-// GetQuotes fetches quotes for multiple tickers
-func (s *Service) GetQuotes(tickers []string) (map[string]*Quote, error) {
+// GetQuotes fetches quotes for multiple tickers, bypassing the cache when
+// forceRefresh is set
+func (s *Service) GetQuotes(tickers []string, forceRefresh bool) (map[string]*Quote, error) {
 	quotes := make(map[string]*Quote)
 	var wg sync.WaitGroup
 	var mu sync.Mutex
@@ -120,7 +190,7 @@ func (s *Service) GetQuotes(tickers []string) (map[string]*Quote, error) {
 		go func(t string) {
 			defer wg.Done()
 
-			quote, err := s.GetQuote(t)
+			quote, err := s.GetQuote(t, forceRefresh)
 			mu.Lock()
 			if err != nil {
 				errors = append(errors, fmt.Errorf("%s: %w", t, err))
@@ -140,46 +210,120 @@ func (s *Service) GetQuotes(tickers []string) (map[string]*Quote, error) {
 	return quotes, nil
 }
 
-// UpdatePortfolioValues updates market values for portfolio holdings
-func (s *Service) UpdatePortfolioValues(portfolio *models.Portfolio) error {
+// UpdatePortfolioValues updates market values for portfolio holdings,
+// returning the tickers that couldn't be priced
+func (s *Service) UpdatePortfolioValues(portfolio *models.Portfolio) ([]string, error) {
 	if portfolio == nil || len(portfolio.Holdings) == 0 {
-		return nil
+		return []string{}, nil
 	}
+	return s.UpdatePortfoliosValues([]*models.Portfolio{portfolio})
+}
 
-	// Collect tickers
-	tickers := make([]string, 0, len(portfolio.Holdings))
-	for _, h := range portfolio.Holdings {
-		if h.Ticker != "" {
-			tickers = append(tickers, h.Ticker)
+// UpdatePortfoliosValues updates several portfolios at once, fetching each
+// ticker's quote only once even when it's held in more than one portfolio.
+// Coins held directly are priced at their spot price instead of a stock
+// quote, and never from a stock quote when they have none. It returns the tickers, in order, that kept their previous values
+// because there was no quote or exchange rate for them.
+func (s *Service) UpdatePortfoliosValues(portfolios []*models.Portfolio) ([]string, error) {
+	// Collect the distinct tickers and coins across every portfolio
+	seen := make(map[string]bool)
+	seenCoins := make(map[string]bool)
+	var tickers, coins []string
+	for _, p := range portfolios {
+		if p == nil {
+			continue
 		}
+		for _, h := range p.Holdings {
+			switch {
+			case h.Ticker == "":
+			case h.IsCryptoCoin():
+				if !seenCoins[h.Ticker] {
+					seenCoins[h.Ticker] = true
+					coins = append(coins, h.Ticker)
+				}
+			case !seen[h.Ticker]:
+				seen[h.Ticker] = true
+				tickers = append(tickers, h.Ticker)
+			}
+		}
+	}
+	if len(tickers) == 0 && len(coins) == 0 {
+		return []string{}, nil
 	}
 
 	// Fetch quotes
-	quotes, err := s.GetQuotes(tickers)
+	quotes, err := s.GetQuotes(tickers, false)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	coinQuotes := map[string]*Quote{}
+	if len(coins) > 0 {
+		if coinQuotes, err = s.GetCryptoQuotes(coins); err != nil && len(tickers) == 0 {
+			return nil, err
+		}
 	}
 
-	// Update holdings
+	missed := make(map[string]bool)
+	for _, p := range portfolios {
+		if p == nil {
+			continue
+		}
+		base := p.CurrencyCode()
+		var currencies []string
+		for _, h := range p.Holdings {
+			currencies = append(currencies, h.CurrencyCode())
+		}
+		for _, ticker := range applyQuotes(p, quotes, coinQuotes, s.getFXRates(currencies, base)) {
+			missed[ticker] = true
+		}
+	}
+
+	unpriced := make([]string, 0, len(missed))
+	for ticker := range missed {
+		unpriced = append(unpriced, ticker)
+	}
+	sort.Strings(unpriced)
+	return unpriced, nil
+}
+
+// applyQuotes reprices a portfolio's holdings and recomputes its total.
+// Coins take their quote from coinQuotes and everything else from quotes.
+// Prices stay in each holding's currency while market values are converted
+// into the portfolio's base currency using rates, keyed by holding currency.
+// Holdings without both a quote and a rate keep their previous values, and
+// their tickers are returned.
+func applyQuotes(portfolio *models.Portfolio, quotes, coinQuotes map[string]*Quote, rates map[string]decimal.Decimal) []string {
+	now := time.Now()
+	var unpriced []string
 	totalValue := decimal.Zero
 	for i := range portfolio.Holdings {
 		h := &portfolio.Holdings[i]
-		if quote, ok := quotes[h.Ticker]; ok {
+		quote, ok := quotes[h.Ticker]
+		if h.IsCryptoCoin() {
+			quote, ok = coinQuotes[h.Ticker]
+		}
+		rate, hasRate := rates[h.CurrencyCode()]
+		if ok && hasRate {
 			h.CurrentPrice = quote.Price
-			h.MarketValue = h.Quantity.Mul(quote.Price)
+			h.MarketValue = h.Quantity.Mul(quote.Price).Mul(h.ContractMultiplier()).Mul(rate)
+			h.PricedAt = now
+		} else if h.Ticker != "" {
+			unpriced = append(unpriced, h.Ticker)
 		}
 		totalValue = totalValue.Add(h.MarketValue)
 	}
 
 	portfolio.TotalValue = totalValue
-	portfolio.LastUpdated = time.Now()
-
-	return nil
+	portfolio.LastUpdated = now
+	return unpriced
 }
 
+// marketZone is the US market's time zone
+var marketZone = time.FixedZone("EST", -5*3600)
+
 // IsMarketOpen checks if the US stock market is currently open
 func (s *Service) IsMarketOpen() bool {
-	now := time.Now().In(time.FixedZone("EST", -5*3600))
+	now := time.Now().In(marketZone)
 
 	// Check if weekday
 	if now.Weekday() == time.Saturday || now.Weekday() == time.Sunday {
@@ -257,17 +401,32 @@ func (s *Service) mockBasePrice(ticker string) decimal.Decimal {
 }
 
 func (s *Service) mockChange(ticker string) decimal.Decimal {
-	// Generate small random-ish change based on ticker and time
+	// Generate small random-ish change based on ticker and time, or the
+	// seed when there is one
 	hash := 0
 	for _, c := range ticker {
 		hash += int(c)
 	}
-	hash += time.Now().Day()
+	if s.mockSeed != 0 {
+		hash += s.mockRand(ticker).Intn(300)
+	} else {
+		hash += time.Now().Day()
+	}
 
 	change := float64(hash%300-150) / 100.0 // -1.5% to +1.5%
 	return decimal.NewFromFloat(change)
 }
 
+// mockRand returns a random source for a ticker that repeats for a given
+// seed
+func (s *Service) mockRand(ticker string) *rand.Rand {
+	seed := s.mockSeed
+	for _, c := range ticker {
+		seed = seed*31 + int64(c)
+	}
+	return rand.New(rand.NewSource(seed))
+}
+
 // Yahoo Finance integration (simplified)
 func (s *Service) fetchYahooQuote(ticker string) (*Quote, error) {
 	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s", ticker)
@@ -376,14 +535,23 @@ func (s *Service) fetchAlphaVantageQuote(ticker string) (*Quote, error) {
 	}, nil
 }
 
+// mockEndDate is the last day of seeded mock history
+var mockEndDate = time.Date(2024, time.June, 28, 0, 0, 0, 0, time.UTC)
+
 // GetHistoricalPrices fetches historical price data
 func (s *Service) GetHistoricalPrices(ticker string, period string) ([]models.PriceHistory, error) {
-	// For MVP, return simulated historical data
-	startDate := models.GetPeriodStartDate(period)
+	// For MVP, return simulated historical data. Seeded mock data ends on a
+	// fixed date so the whole series repeats.
 	endDate := time.Now().UTC()
+	var walk *rand.Rand
+	if s.mockSeed != 0 {
+		endDate = mockEndDate
+		walk = s.mockRand(ticker)
+	}
+	startDate := models.PeriodStartDate(period, endDate, time.Time{})
 
 	// Get current price
-	quote, err := s.GetQuote(ticker)
+	quote, err := s.GetQuote(ticker, false)
 	if err != nil {
 		return nil, err
 	}
@@ -398,7 +566,11 @@ func (s *Service) GetHistoricalPrices(ticker string, period string) ([]models.Pr
 
 	for current.After(startDate) {
 		// Random walk backward
-		change := dailyVol.Mul(decimal.NewFromFloat(float64(current.Day()%10-5) / 5))
+		step := float64(current.Day()%10-5) / 5
+		if walk != nil {
+			step = walk.Float64()*2 - 1
+		}
+		change := dailyVol.Mul(decimal.NewFromFloat(step))
 		prevPrice := currentPrice.Div(decimal.NewFromInt(1).Add(change))
 
 		prices = append([]models.PriceHistory{{
@@ -426,6 +598,54 @@ func (s *Service) GetHistoricalPrices(ticker string, period string) ([]models.Pr
 	return prices, nil
 }
 
+// historyConcurrency caps how many tickers' history is fetched at once
+const historyConcurrency = 8
+
+// GetHistoricalPricesBatch fetches history for several tickers at once,
+// keyed by ticker. Tickers that fail are left out; it only errors when
+// none succeed.
+func (s *Service) GetHistoricalPricesBatch(tickers []string, period string) (map[string][]models.PriceHistory, error) {
+	histories := make(map[string][]models.PriceHistory)
+	seen := make(map[string]bool)
+	var firstErr error
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sem := make(chan struct{}, historyConcurrency)
+
+	for _, ticker := range tickers {
+		if seen[ticker] {
+			continue
+		}
+		seen[ticker] = true
+
+		wg.Add(1)
+		go func(t string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			prices, err := s.GetHistoricalPrices(t, period)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("%s: %w", t, err)
+				}
+				return
+			}
+			histories[t] = prices
+		}(ticker)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil && len(histories) == 0 {
+		return nil, firstErr
+	}
+
+	return histories, nil
+}
+
 // MarketStatus represents overall market status
 type MarketStatus struct {
 	IsOpen       bool      `json:"is_open"`
@@ -1,6 +1,13 @@
 package marketdata
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -19,7 +26,7 @@ func TestNewService(t *testing.T) {
 func TestService_GetQuote(t *testing.T) {
 	svc := NewService(Config{Provider: ProviderMock})
 
-	quote, err := svc.GetQuote("AAPL")
+	quote, err := svc.GetQuote("AAPL", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -44,13 +51,13 @@ func TestService_GetQuote_Cached(t *testing.T) {
 	})
 
 	// First call
-	quote1, err := svc.GetQuote("AAPL")
+	quote1, err := svc.GetQuote("AAPL", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
 	// Second call should return cached
-	quote2, err := svc.GetQuote("AAPL")
+	quote2, err := svc.GetQuote("AAPL", false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -60,11 +67,103 @@ func TestService_GetQuote_Cached(t *testing.T) {
 	}
 }
 
+func TestService_GetQuote_ForceRefresh(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderYahoo, CacheTTL: time.Hour})
+	fetches := 0
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		fetches++
+		body := fmt.Sprintf(`{"chart": {"result": [{"meta": {"regularMarketPrice": %d, "previousClose": 100}}]}}`, 100+fetches)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+
+	first, err := svc.GetQuote("AAPL", false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Cached {
+		t.Error("First quote should be fetched, not cached")
+	}
+
+	// A cache hit keeps the time it was fetched
+	cached, _ := svc.GetQuote("AAPL", false)
+	if fetches != 1 || !cached.Cached || !cached.LastUpdated.Equal(first.LastUpdated) {
+		t.Errorf("Cache hit: got %d fetches, cached %v, updated %v; want 1, true, %v",
+			fetches, cached.Cached, cached.LastUpdated, first.LastUpdated)
+	}
+
+	fresh, err := svc.GetQuote("AAPL", true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fetches != 2 || fresh.Cached || !fresh.Price.Equal(decimal.NewFromInt(102)) || fresh.LastUpdated.Before(first.LastUpdated) {
+		t.Errorf("Forced refresh: got %d fetches, cached %v, price %s; want 2, false, 102", fetches, fresh.Cached, fresh.Price)
+	}
+
+	// The refreshed quote replaces the cached one
+	quotes, _ := svc.GetQuotes([]string{"AAPL"}, false)
+	if fetches != 2 || !quotes["AAPL"].Price.Equal(decimal.NewFromInt(102)) {
+		t.Errorf("After refresh: got %d fetches, price %s; want 2, 102", fetches, quotes["AAPL"].Price)
+	}
+	if quotes, _ = svc.GetQuotes([]string{"AAPL"}, true); fetches != 3 || quotes["AAPL"].Cached {
+		t.Errorf("GetQuotes forced refresh: got %d fetches, want 3", fetches)
+	}
+}
+
+func TestService_GetQuote_Coalesced(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderYahoo, CacheTTL: time.Hour})
+	var fetches int32
+	release := make(chan struct{})
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		body := `{"chart": {"result": [{"meta": {"regularMarketPrice": 190, "previousClose": 185}}]}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header)}, nil
+	})}
+
+	const lookups = 20
+	var started, wg sync.WaitGroup
+	quotes := make([]*Quote, lookups)
+	for i := 0; i < lookups; i++ {
+		started.Add(1)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			quotes[i], _ = svc.GetQuote("AAPL", false)
+		}(i)
+	}
+
+	// Hold the fetch while the other lookups arrive; any that fetched
+	// instead of waiting on it would block here too
+	started.Wait()
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("Expected 1 upstream fetch, got %d", n)
+	}
+	for i, q := range quotes {
+		if q == nil || !q.Price.Equal(decimal.NewFromInt(190)) {
+			t.Fatalf("Lookup %d: got %+v, want price 190", i, q)
+		}
+	}
+	if quotes[0] == quotes[1] {
+		t.Error("Each lookup should get its own copy of the quote")
+	}
+	if len(svc.inflight) != 0 {
+		t.Errorf("Expected no fetches in flight, got %d", len(svc.inflight))
+	}
+}
+
 func TestService_GetQuotes(t *testing.T) {
 	svc := NewService(Config{Provider: ProviderMock})
 
 	tickers := []string{"AAPL", "MSFT", "GOOGL"}
-	quotes, err := svc.GetQuotes(tickers)
+	quotes, err := svc.GetQuotes(tickers, false)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -120,10 +219,13 @@ func TestService_UpdatePortfolioValues(t *testing.T) {
 		},
 	}
 
-	err := svc.UpdatePortfolioValues(portfolio)
+	unpriced, err := svc.UpdatePortfolioValues(portfolio)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
+	if len(unpriced) != 0 {
+		t.Errorf("Expected every holding priced, got unpriced %v", unpriced)
+	}
 
 	// Check holdings have updated prices
 	for _, h := range portfolio.Holdings {
@@ -133,6 +235,9 @@ func TestService_UpdatePortfolioValues(t *testing.T) {
 		if h.MarketValue.IsZero() {
 			t.Errorf("Holding %s should have market value", h.Ticker)
 		}
+		if h.PricedAt.IsZero() {
+			t.Errorf("Holding %s should record when it was priced", h.Ticker)
+		}
 	}
 
 	// Check portfolio total value
@@ -145,18 +250,210 @@ func TestService_UpdatePortfolioValues_Nil(t *testing.T) {
 	svc := NewService(Config{Provider: ProviderMock})
 
 	// Should not error on nil
-	err := svc.UpdatePortfolioValues(nil)
+	_, err := svc.UpdatePortfolioValues(nil)
 	if err != nil {
 		t.Errorf("Expected no error for nil portfolio, got: %v", err)
 	}
 
 	// Should not error on empty holdings
-	err = svc.UpdatePortfolioValues(&models.Portfolio{})
+	_, err = svc.UpdatePortfolioValues(&models.Portfolio{})
 	if err != nil {
 		t.Errorf("Expected no error for empty portfolio, got: %v", err)
 	}
 }
 
+func TestService_UpdatePortfoliosValues(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock})
+
+	holding := func(ticker string, qty int64) models.Holding {
+		return models.Holding{ID: uuid.New(), Ticker: ticker, Quantity: decimal.NewFromInt(qty)}
+	}
+	taxable := &models.Portfolio{ID: uuid.New(), Holdings: []models.Holding{holding("AAPL", 10), holding("VOO", 2)}}
+	ira := &models.Portfolio{ID: uuid.New(), Holdings: []models.Holding{holding("AAPL", 4)}}
+
+	if _, err := svc.UpdatePortfoliosValues([]*models.Portfolio{taxable, ira, nil}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Both portfolios are priced from the same AAPL quote
+	if !taxable.Holdings[0].CurrentPrice.Equal(ira.Holdings[0].CurrentPrice) {
+		t.Errorf("AAPL priced differently: %s vs %s", taxable.Holdings[0].CurrentPrice, ira.Holdings[0].CurrentPrice)
+	}
+
+	for _, p := range []*models.Portfolio{taxable, ira} {
+		sum := decimal.Zero
+		for _, h := range p.Holdings {
+			sum = sum.Add(h.MarketValue)
+		}
+		if sum.IsZero() || !p.TotalValue.Equal(sum) {
+			t.Errorf("Portfolio total: got %s, want %s", p.TotalValue, sum)
+		}
+	}
+}
+
+func TestService_GetFXRate(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock})
+
+	if rate, err := svc.GetFXRate("usd", "USD"); err != nil || !rate.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Same currency: got %s, %v, want 1", rate, err)
+	}
+
+	eurUSD, err := svc.GetFXRate("EUR", "USD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	usdEUR, _ := svc.GetFXRate("USD", "EUR")
+	if product := eurUSD.Mul(usdEUR).Round(6); !product.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("EURUSD x USDEUR: got %s, want 1", product)
+	}
+
+	if _, err := svc.GetFXRate("XYZ", "USD"); err != ErrUnknownCurrency {
+		t.Errorf("Unknown currency: got %v, want %v", err, ErrUnknownCurrency)
+	}
+}
+
+// roundTripFunc serves HTTP requests without a network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestService_GetExpenseRatio(t *testing.T) {
+	if _, err := NewService(Config{Provider: ProviderMock}).GetExpenseRatio("VOO"); err != ErrNoExpenseRatio {
+		t.Errorf("Mock provider: got %v, want %v", err, ErrNoExpenseRatio)
+	}
+
+	calls := 0
+	svc := NewService(Config{Provider: ProviderAlpha, APIKey: "test"})
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		body := `{}`
+		if r.URL.Query().Get("symbol") == "VOO" {
+			body = `{"net_expense_ratio": "0.0003"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	ratio, err := svc.GetExpenseRatio("voo")
+	if err != nil || !ratio.Equal(decimal.NewFromFloat(0.03)) {
+		t.Errorf("VOO: got %s, %v, want 0.03", ratio, err)
+	}
+	if _, err := svc.GetExpenseRatio("AAPL"); err != ErrNoExpenseRatio {
+		t.Errorf("Stock: got %v, want %v", err, ErrNoExpenseRatio)
+	}
+
+	// Both answers, including the miss, are cached
+	svc.GetExpenseRatio("VOO")
+	svc.GetExpenseRatio("AAPL")
+	if calls != 2 {
+		t.Errorf("Expected 2 provider calls, got %d", calls)
+	}
+}
+
+func TestService_GetExpenseRatio_CachesFailures(t *testing.T) {
+	calls := 0
+	svc := NewService(Config{Provider: ProviderAlpha, APIKey: "test"})
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	})}
+
+	for i := 0; i < 3; i++ {
+		if _, err := svc.GetExpenseRatio("VOO"); err == nil || err == ErrNoExpenseRatio {
+			t.Errorf("Lookup %d: got %v, want the outage", i, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 provider call during the outage, got %d", calls)
+	}
+
+	// Once the failure is old enough, the provider is asked again
+	svc.mu.Lock()
+	svc.expense["VOO"].fetched = time.Now().Add(-expenseErrorTTL)
+	svc.mu.Unlock()
+	svc.GetExpenseRatio("VOO")
+	if calls != 2 {
+		t.Errorf("Expected a retry after %s, got %d calls", expenseErrorTTL, calls)
+	}
+}
+
+func TestService_GetExpenseRatios(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, peak := 0, 0
+	svc := NewService(Config{Provider: ProviderAlpha, APIKey: "test"})
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		body := `{}`
+		if strings.HasPrefix(r.URL.Query().Get("symbol"), "FUND") {
+			body = `{"net_expense_ratio": "0.001"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	tickers := []string{"AAPL", "AAPL"}
+	for i := 0; i < 20; i++ {
+		tickers = append(tickers, fmt.Sprintf("FUND%d", i))
+	}
+	ratios := svc.GetExpenseRatios(tickers)
+	if len(ratios) != 20 {
+		t.Errorf("Got %d ratios, want one for each of the 20 funds", len(ratios))
+	}
+	if ratio := ratios["FUND3"]; !ratio.Equal(decimal.NewFromFloat(0.1)) {
+		t.Errorf("FUND3: got %s, want 0.1", ratio)
+	}
+	if peak > expenseConcurrency {
+		t.Errorf("Fetched %d at once, want at most %d", peak, expenseConcurrency)
+	}
+}
+
+func TestService_UpdatePortfolioValues_Currency(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock})
+
+	portfolio := &models.Portfolio{
+		ID:       uuid.New(),
+		Currency: "USD",
+		Holdings: []models.Holding{
+			{ID: uuid.New(), Ticker: "SAP.DE", Currency: "EUR", Quantity: decimal.NewFromInt(10)},
+			{ID: uuid.New(), Ticker: "AAPL", Quantity: decimal.NewFromInt(10)},
+			{ID: uuid.New(), Ticker: "ODD", Currency: "XYZ", Quantity: decimal.NewFromInt(10), MarketValue: decimal.NewFromInt(7)},
+		},
+	}
+	unpriced, err := svc.UpdatePortfolioValues(portfolio)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Prices stay in the listing currency; market value is in dollars
+	sap := portfolio.Holdings[0]
+	eurUSD, _ := svc.GetFXRate("EUR", "USD")
+	if want := sap.Quantity.Mul(sap.CurrentPrice).Mul(eurUSD); !sap.MarketValue.Equal(want) {
+		t.Errorf("EUR holding value: got %s, want %s", sap.MarketValue, want)
+	}
+
+	// Untracked currencies keep their previous value rather than guessing
+	odd := portfolio.Holdings[2]
+	if !odd.CurrentPrice.IsZero() || !odd.MarketValue.Equal(decimal.NewFromInt(7)) {
+		t.Errorf("Unknown currency holding: got price %s value %s, want untouched", odd.CurrentPrice, odd.MarketValue)
+	}
+	if len(unpriced) != 1 || unpriced[0] != "ODD" || !odd.PricedAt.IsZero() {
+		t.Errorf("Unknown currency holding should be reported unpriced, got %v", unpriced)
+	}
+
+	want := sap.MarketValue.Add(portfolio.Holdings[1].MarketValue).Add(odd.MarketValue)
+	if !portfolio.TotalValue.Equal(want) {
+		t.Errorf("Total: got %s, want %s", portfolio.TotalValue, want)
+	}
+}
+
 func TestService_GetHistoricalPrices(t *testing.T) {
 	svc := NewService(Config{Provider: ProviderMock})
 
@@ -179,6 +476,60 @@ func TestService_GetHistoricalPrices(t *testing.T) {
 	}
 }
 
+func TestService_GetHistoricalPricesBatch(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock, MockSeed: 1})
+
+	histories, err := svc.GetHistoricalPricesBatch([]string{"AAPL", "MSFT", "AAPL", "VTI"}, models.Period1Month)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(histories) != 3 {
+		t.Fatalf("Tickers: got %d, want 3", len(histories))
+	}
+
+	want, _ := svc.GetHistoricalPrices("MSFT", models.Period1Month)
+	got := histories["MSFT"]
+	if len(got) != len(want) || !got[len(got)-1].Close.Equal(want[len(want)-1].Close) {
+		t.Errorf("MSFT history doesn't match a single fetch")
+	}
+}
+
+func TestService_MockSeed(t *testing.T) {
+	a := NewService(Config{Provider: ProviderMock, MockSeed: 42})
+	b := NewService(Config{Provider: ProviderMock, MockSeed: 42})
+
+	quoteA, _ := a.GetQuote("AAPL", false)
+	quoteB, _ := b.GetQuote("AAPL", false)
+	if !quoteA.ChangePercent.Equal(quoteB.ChangePercent) {
+		t.Errorf("Change: got %s and %s for the same seed", quoteA.ChangePercent, quoteB.ChangePercent)
+	}
+
+	historyA, err := a.GetHistoricalPrices("AAPL", models.Period3Month)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	historyB, _ := b.GetHistoricalPrices("AAPL", models.Period3Month)
+	if len(historyA) == 0 || len(historyA) != len(historyB) {
+		t.Fatalf("History length: got %d and %d", len(historyA), len(historyB))
+	}
+	for i := range historyA {
+		if !historyA[i].Date.Equal(historyB[i].Date) || !historyA[i].Close.Equal(historyB[i].Close) {
+			t.Fatalf("Day %d: got %s %s and %s %s", i,
+				historyA[i].Date, historyA[i].Close, historyB[i].Date, historyB[i].Close)
+		}
+	}
+	if last := historyA[len(historyA)-1].Date; !last.Equal(mockEndDate) {
+		t.Errorf("Last day: got %s, want %s", last, mockEndDate)
+	}
+
+	// Another seed walks a different path
+	other := NewService(Config{Provider: ProviderMock, MockSeed: 7})
+	historyC, _ := other.GetHistoricalPrices("AAPL", models.Period3Month)
+	if historyC[0].Close.Equal(historyA[0].Close) {
+		t.Error("Different seeds should give different history")
+	}
+}
+
 func TestMockBasePrice(t *testing.T) {
 	svc := NewService(Config{Provider: ProviderMock})
 
@@ -202,3 +553,156 @@ func TestMockBasePrice(t *testing.T) {
 		t.Error("Unknown ticker should have positive price")
 	}
 }
+
+func TestSessionBounds(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.March, day, hour, minute, 0, 0, marketZone)
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		wantDay int
+	}{
+		{"During session", at(13, 11, 0), 13},   // Wednesday
+		{"After close", at(13, 18, 0), 13},      // Wednesday evening
+		{"Before open", at(13, 8, 0), 12},       // Previous day
+		{"Monday before open", at(11, 9, 0), 8}, // Back to Friday
+		{"Saturday", at(16, 12, 0), 15},         // Friday
+	}
+
+	for _, tt := range tests {
+		open, close := sessionBounds(tt.now)
+		if open != at(tt.wantDay, 9, 30) || close != at(tt.wantDay, 16, 0) {
+			t.Errorf("%s: got %s to %s, want March %d 9:30 to 16:00", tt.name, open, close, tt.wantDay)
+		}
+	}
+}
+
+func TestService_GetIntraday(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock})
+
+	if _, err := svc.GetIntraday("AAPL", "2h"); err != ErrInvalidInterval {
+		t.Errorf("Invalid interval: got %v, want %v", err, ErrInvalidInterval)
+	}
+
+	// A full session at 15 minutes is 9:30 through 16:00 inclusive
+	now := time.Date(2024, time.March, 13, 18, 0, 0, 0, marketZone)
+	candles, err := svc.getMockIntraday("AAPL", 15*time.Minute, now)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candles) != 27 {
+		t.Fatalf("Candles: got %d, want 27", len(candles))
+	}
+	for i := 1; i < len(candles); i++ {
+		if gap := candles[i].Date.Sub(candles[i-1].Date); gap != 15*time.Minute {
+			t.Fatalf("Candle %d: got %s after previous, want 15m", i, gap)
+		}
+	}
+	for i, c := range candles {
+		if c.High.LessThan(c.Close) || c.High.LessThan(c.Open) || c.Low.GreaterThan(c.Close) || c.Low.GreaterThan(c.Open) {
+			t.Errorf("Candle %d: high %s / low %s don't bound open %s and close %s", i, c.High, c.Low, c.Open, c.Close)
+		}
+	}
+
+	quote, _ := svc.GetQuote("AAPL", false)
+	if last := candles[len(candles)-1].Close; !last.Equal(quote.Price.Round(2)) {
+		t.Errorf("Last close: got %s, want quote price %s", last, quote.Price)
+	}
+
+	// Midway through a session, candles stop at the current time
+	now = time.Date(2024, time.March, 13, 10, 0, 0, 0, marketZone)
+	candles, _ = svc.getMockIntraday("AAPL", 5*time.Minute, now)
+	if len(candles) != 7 {
+		t.Errorf("Partial session: got %d candles, want 7", len(candles))
+	}
+}
+
+func TestService_GetIntraday_Cached(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock, CacheTTL: time.Hour})
+
+	first, err := svc.GetIntraday("aapl", Interval5m)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, _ := svc.GetIntraday("AAPL", Interval5m)
+
+	if len(first) == 0 || &first[0] != &second[0] {
+		t.Error("Expected second call to be served from cache")
+	}
+}
+
+func TestService_GetCryptoQuotes(t *testing.T) {
+	calls := 0
+	svc := NewService(Config{Provider: ProviderYahoo})
+	svc.httpClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+		if ids := r.URL.Query().Get("ids"); ids != "bitcoin,ethereum" {
+			t.Errorf("Requested ids %q, want bitcoin,ethereum", ids)
+		}
+		body := `{"bitcoin": {"usd": 64000, "usd_24h_change": 2.5}, "ethereum": {"usd": 3400.5, "usd_24h_change": -1.2}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})}
+
+	quotes, err := svc.GetCryptoQuotes([]string{"BTC", "ETH", "PEPE"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("Expected BTC and ETH quotes, got %d", len(quotes))
+	}
+	btc := quotes["BTC"]
+	if !btc.Price.Equal(decimal.NewFromInt(64000)) || !btc.ChangePercent.Equal(decimal.NewFromFloat(2.5)) {
+		t.Errorf("BTC: got price %s change %s%%", btc.Price, btc.ChangePercent)
+	}
+	if !btc.Change.Equal(decimal.RequireFromString("1560.98")) {
+		t.Errorf("BTC change: got %s, want 1560.98", btc.Change)
+	}
+
+	// Cached coins aren't fetched again
+	svc.GetCryptoQuotes([]string{"BTC", "ETH"})
+	if calls != 1 {
+		t.Errorf("Expected 1 provider call, got %d", calls)
+	}
+}
+
+func TestService_UpdatePortfolioValues_Crypto(t *testing.T) {
+	svc := NewService(Config{Provider: ProviderMock})
+
+	portfolio := &models.Portfolio{
+		ID: uuid.New(),
+		Holdings: []models.Holding{
+			{ID: uuid.New(), Ticker: "BTC", AssetClass: models.AssetClassCrypto, Quantity: decimal.NewFromFloat(0.5)},
+			{ID: uuid.New(), Ticker: "GBTC", AssetClass: models.AssetClassCrypto, Quantity: decimal.NewFromInt(10)},
+			{ID: uuid.New(), Ticker: "ETH", AssetClass: models.AssetClassEquity, Quantity: decimal.NewFromInt(10)}, // Ethan Allen
+			// An exchange coin with no spot price, whose symbol is also a stock's
+			{ID: uuid.New(), Ticker: "ONE", AssetClass: models.AssetClassCrypto, Source: "crypto_csv", Quantity: decimal.NewFromInt(100), MarketValue: decimal.NewFromInt(2)},
+			// A stock the tagger took for crypto, from a brokerage account
+			{ID: uuid.New(), Ticker: "COIN", AssetClass: models.AssetClassCrypto, Source: "schwab_csv", Quantity: decimal.NewFromInt(1)},
+		},
+	}
+	unpriced, err := svc.UpdatePortfolioValues(portfolio)
+	if err != nil || len(unpriced) != 1 || unpriced[0] != "ONE" {
+		t.Fatalf("Unexpected result: unpriced %v, err %v", unpriced, err)
+	}
+	if one := portfolio.Holdings[3]; !one.MarketValue.Equal(decimal.NewFromInt(2)) || !one.CurrentPrice.IsZero() {
+		t.Errorf("ONE: got price %s value %s, want it left unpriced", one.CurrentPrice, one.MarketValue)
+	}
+	if want := svc.mockBasePrice("COIN"); !portfolio.Holdings[4].CurrentPrice.Equal(want) {
+		t.Errorf("COIN: got price %s, want stock quote %s", portfolio.Holdings[4].CurrentPrice, want)
+	}
+
+	// Coins take their spot price; the crypto fund and the stock that
+	// shares a coin's symbol are priced as stocks
+	btc, gbtc, eth := portfolio.Holdings[0], portfolio.Holdings[1], portfolio.Holdings[2]
+	if !btc.MarketValue.Equal(decimal.NewFromInt(32500)) {
+		t.Errorf("BTC: got value %s, want 32500", btc.MarketValue)
+	}
+	if want := svc.mockBasePrice("GBTC"); !gbtc.CurrentPrice.Equal(want) {
+		t.Errorf("GBTC: got price %s, want stock quote %s", gbtc.CurrentPrice, want)
+	}
+	if want := svc.mockBasePrice("ETH"); !eth.CurrentPrice.Equal(want) {
+		t.Errorf("ETH stock: got price %s, want stock quote %s", eth.CurrentPrice, want)
+	}
+}
@@ -0,0 +1,69 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func newTestGoogle(t *testing.T, verified bool) *Google {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" || r.FormValue("client_secret") != "secret" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(Profile{Email: "ada@example.com", EmailVerified: verified, Name: "Ada"})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	g := NewGoogle("client", "secret", "http://localhost/auth/google/callback")
+	g.tokenURL = server.URL + "/token"
+	g.userInfoURL = server.URL + "/userinfo"
+	return g
+}
+
+func TestGoogle_AuthCodeURL(t *testing.T) {
+	g := NewGoogle("client", "secret", "http://localhost/auth/google/callback")
+
+	u, err := url.Parse(g.AuthCodeURL("xyz"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	q := u.Query()
+	if q.Get("state") != "xyz" || q.Get("client_id") != "client" || q.Get("response_type") != "code" {
+		t.Errorf("Unexpected query: %v", q)
+	}
+}
+
+func TestGoogle_Exchange(t *testing.T) {
+	profile, err := newTestGoogle(t, true).Exchange(context.Background(), "good-code")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if profile.Email != "ada@example.com" || profile.Name != "Ada" {
+		t.Errorf("Profile: got %+v", profile)
+	}
+
+	if _, err := newTestGoogle(t, true).Exchange(context.Background(), "bad-code"); err == nil {
+		t.Error("Exchange with a bad code should fail")
+	}
+
+	if _, err := newTestGoogle(t, false).Exchange(context.Background(), "good-code"); !errors.Is(err, ErrUnverifiedEmail) {
+		t.Errorf("Unverified email: got %v, want ErrUnverifiedEmail", err)
+	}
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+)
+
+// ErrPrivateAddress is returned for webhook URLs that point at, or resolve
+// to, an address that isn't on the public internet
+var ErrPrivateAddress = errors.New("webhook URL must point to a public address")
+
+// reservedPrefixes are ranges that aren't publicly routable beyond those
+// netip already classifies: carrier-grade NAT, "this network", IETF
+// protocol assignments, benchmarking and NAT64
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddr reports whether a webhook may be delivered to addr. Loopback,
+// private, link-local (which includes cloud metadata endpoints), multicast
+// and reserved addresses are refused.
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDialAddress refuses connections to non-public addresses. It runs
+// after the host is resolved, for every address tried, so a name that
+// resolves differently at delivery than at registration is still caught.
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isPublicAddr(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// newDeliveryClient returns a client that only connects to public
+// addresses, including when following redirects. Proxies are ignored, since
+// the check would otherwise apply to the proxy rather than the webhook.
+func newDeliveryClient() *http.Client {
+	dialer := &net.Dialer{Timeout: deliveryTimeout, Control: checkDialAddress}
+	return &http.Client{
+		Timeout: deliveryTimeout,
+		Transport: &http.Transport{
+			Proxy: nil,
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			TLSHandshakeTimeout: deliveryTimeout,
+		},
+	}
+}
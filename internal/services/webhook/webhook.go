@@ -0,0 +1,251 @@
+// Package webhook delivers portfolio alerts to user-registered endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/storage"
+	"github.com/google/uuid"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the request body, keyed with
+// the webhook's secret, as "sha256=<hex>"
+const SignatureHeader = "X-TrueNorth-Signature"
+
+// EventHeader names the kind of delivery so receivers can tell a test
+// ping from real alerts
+const EventHeader = "X-TrueNorth-Event"
+
+// NotifyAlertsInBackground runs NotifyAlerts without blocking the caller,
+// logging any failure. Wait blocks until these have finished.
+func (s *Service) NotifyAlertsInBackground(userID, portfolioID uuid.UUID, alerts []models.Alert) {
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		if err := s.NotifyAlerts(userID, portfolioID, alerts); err != nil {
+			log.Printf("webhook notify for portfolio %s: %v", portfolioID, err)
+		}
+	}()
+}
+
+// Wait blocks until background notifications finish or ctx is done
+func (s *Service) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Delivery defaults
+const (
+	defaultMaxAttempts = 4
+	defaultBackoff     = 2 * time.Second
+	deliveryTimeout    = 10 * time.Second
+)
+
+var (
+	// ErrInvalidURL is returned for webhook URLs that aren't absolute
+	// http(s) URLs
+	ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+)
+
+// Service sends new alerts to webhooks, retrying failed deliveries and
+// dead-lettering the ones that never succeed
+type Service struct {
+	repo        *storage.WebhookRepository
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+
+	// mu keeps concurrent alert checks for the same portfolio from both
+	// seeing an alert as new and sending it twice
+	mu sync.Mutex
+
+	// pending tracks background notifications so shutdown can wait for them
+	pending sync.WaitGroup
+}
+
+// NewService creates a new webhook service
+func NewService(repo *storage.WebhookRepository) *Service {
+	return &Service{
+		repo:        repo,
+		client:      newDeliveryClient(),
+		maxAttempts: defaultMaxAttempts,
+		backoff:     defaultBackoff,
+	}
+}
+
+// GenerateSecret returns a random signing secret for a new webhook
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateURL checks that rawURL is something we can POST to. Hosts given
+// as a non-public IP or as localhost are refused up front; names are
+// checked again when a delivery connects.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidURL
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !isPublicAddr(addr) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// NotifyAlerts sends the user's webhooks any of the portfolio's alerts that
+// haven't been sent before. Only new alerts are delivered; ones that have
+// cleared are forgotten so they're sent again if they return.
+func (s *Service) NotifyAlerts(userID, portfolioID uuid.UUID, alerts []models.Alert) error {
+	hooks, err := s.repo.GetByUserID(userID)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+
+	s.mu.Lock()
+	notified, err := s.repo.GetNotifiedAlertKeys(portfolioID)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	var fresh []models.Alert
+	keys := make([]string, 0, len(alerts))
+	seen := make(map[string]bool)
+	for _, alert := range alerts {
+		key := alert.Key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		keys = append(keys, key)
+		if !notified[key] {
+			fresh = append(fresh, alert)
+		}
+	}
+
+	err = s.repo.SetNotifiedAlertKeys(portfolioID, keys)
+	s.mu.Unlock()
+	if err != nil || len(fresh) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(fresh)
+	if err != nil {
+		return err
+	}
+
+	for _, hook := range hooks {
+		if err := s.Deliver(hook, "alerts", payload); err != nil {
+			log.Printf("webhook %s: %v", hook.ID, err)
+		}
+	}
+	return nil
+}
+
+// Deliver POSTs payload to the webhook, backing off exponentially between
+// attempts. A delivery that fails every attempt goes to the dead-letter log.
+func (s *Service) Deliver(hook *models.Webhook, event string, payload []byte) error {
+	backoff := s.backoff
+	var err error
+	for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+		if err = s.send(hook, event, payload); err == nil {
+			return nil
+		}
+		if attempt < s.maxAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+
+	failure := &models.WebhookFailure{
+		WebhookID: hook.ID,
+		Payload:   string(payload),
+		Error:     err.Error(),
+		Attempts:  s.maxAttempts,
+	}
+	if recordErr := s.repo.RecordFailure(failure); recordErr != nil {
+		log.Printf("webhook %s: failed to record dead letter: %v", hook.ID, recordErr)
+	}
+	return fmt.Errorf("delivery failed after %d attempts: %w", s.maxAttempts, err)
+}
+
+// SendTest makes a single delivery of a sample alert so the user can check
+// their endpoint and signature verification. Failures aren't retried or
+// dead-lettered.
+func (s *Service) SendTest(hook *models.Webhook) error {
+	payload, err := json.Marshal([]models.Alert{{
+		Type:       models.AlertConcentration,
+		Severity:   models.SeverityInfo,
+		Title:      "Test Alert",
+		Message:    "This is a test delivery from TrueNorth",
+		Suggestion: "No action needed",
+	}})
+	if err != nil {
+		return err
+	}
+	return s.send(hook, "test", payload)
+}
+
+// send makes one signed POST, treating any non-2xx response as a failure
+func (s *Service) send(hook *models.Webhook, event string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, payload))
+	req.Header.Set(EventHeader, event)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/findosh/truenorth/internal/storage"
+)
+
+// newTestService returns a service without retry delays and a user with a
+// portfolio to send alerts for
+func newTestService(t *testing.T) (*Service, *storage.WebhookRepository, *models.Portfolio) {
+	t.Helper()
+
+	db, err := storage.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	user := models.NewUser("hooks@example.com", "Hook User", "hash")
+	if err := storage.NewUserRepository(db).Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	portfolio := models.NewPortfolio(user.ID, "Test Portfolio")
+	if err := storage.NewPortfolioRepository(db).Create(portfolio); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+
+	repo := storage.NewWebhookRepository(db)
+	svc := NewService(repo)
+	svc.backoff = 0
+	// Test receivers listen on loopback, which deliveries otherwise refuse
+	svc.client = &http.Client{Timeout: deliveryTimeout}
+	return svc, repo, portfolio
+}
+
+// receiver records the alerts posted to it and answers with status
+type receiver struct {
+	mu         sync.Mutex
+	status     int
+	deliveries [][]models.Alert
+	signedOK   []bool
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var alerts []models.Alert
+	json.Unmarshal(body, &alerts)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.deliveries = append(rc.deliveries, alerts)
+	rc.signedOK = append(rc.signedOK, r.Header.Get(SignatureHeader) == Sign("secret", body))
+	w.WriteHeader(rc.status)
+}
+
+func TestNotifyAlerts_SendsOnlyNewAlerts(t *testing.T) {
+	svc, repo, portfolio := newTestService(t)
+	rc := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	if err := repo.Create(models.NewWebhook(portfolio.UserID, server.URL, "secret")); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	cash := models.Alert{Type: models.AlertCashDrag, Title: "Cash Drag", Message: "Cash at 12%"}
+	overlap := models.Alert{Type: models.AlertOverlap, Title: "Ticker Overlap", Holdings: []string{"VTI"}}
+
+	runs := [][]models.Alert{
+		{cash},          // new: cash
+		{cash, overlap}, // new: overlap only
+		{overlap},       // cash clears, nothing new
+		{cash, overlap}, // cash returns and is new again
+		{{Type: models.AlertCashDrag, Message: "Cash at 15%"}, overlap}, // same condition, new numbers
+	}
+	for _, alerts := range runs {
+		if err := svc.NotifyAlerts(portfolio.UserID, portfolio.ID, alerts); err != nil {
+			t.Fatalf("NotifyAlerts: %v", err)
+		}
+	}
+
+	want := []models.AlertType{models.AlertCashDrag, models.AlertOverlap, models.AlertCashDrag}
+	if len(rc.deliveries) != len(want) {
+		t.Fatalf("deliveries: got %d, want %d", len(rc.deliveries), len(want))
+	}
+	for i, delivery := range rc.deliveries {
+		if len(delivery) != 1 || delivery[0].Type != want[i] {
+			t.Errorf("delivery %d: got %v, want one %s alert", i, delivery, want[i])
+		}
+	}
+
+	for i, ok := range rc.signedOK {
+		if !ok {
+			t.Errorf("delivery %d: signature does not match body", i)
+		}
+	}
+}
+
+func TestDeliver_DeadLettersAfterRetries(t *testing.T) {
+	svc, repo, portfolio := newTestService(t)
+	rc := &receiver{status: http.StatusBadGateway}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	hook := models.NewWebhook(portfolio.UserID, server.URL, "secret")
+	if err := repo.Create(hook); err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+
+	if err := svc.Deliver(hook, "alerts", []byte(`[]`)); err == nil {
+		t.Fatal("Deliver: got nil error, want failure")
+	}
+
+	if len(rc.deliveries) != defaultMaxAttempts {
+		t.Errorf("attempts: got %d, want %d", len(rc.deliveries), defaultMaxAttempts)
+	}
+
+	failures, err := repo.GetFailures(hook.ID)
+	if err != nil {
+		t.Fatalf("GetFailures: %v", err)
+	}
+	if len(failures) != 1 || failures[0].Attempts != defaultMaxAttempts || failures[0].Payload != "[]" {
+		t.Errorf("dead letters: got %+v, want one with %d attempts", failures, defaultMaxAttempts)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://hooks.slack.com/services/T/B/X", true},
+		{"http://203.0.113.7:9000/hook", true},
+		{"http://localhost:9000/hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[::ffff:192.168.1.1]/hook", false},
+		{"ftp://example.com/hook", false},
+		{"/relative/path", false},
+		{"https://", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidateURL(tt.url) == nil; got != tt.valid {
+			t.Errorf("ValidateURL(%q): got valid=%v, want %v", tt.url, got, tt.valid)
+		}
+	}
+}
+
+func TestSendTest_RefusesPrivateAddresses(t *testing.T) {
+	svc, _, portfolio := newTestService(t)
+	svc.client = newDeliveryClient()
+	rc := &receiver{status: http.StatusOK}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	// A name can resolve to loopback even though the URL passed validation
+	hook := models.NewWebhook(portfolio.UserID, server.URL, "secret")
+	if err := svc.SendTest(hook); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("SendTest: got %v, want ErrPrivateAddress", err)
+	}
+	if len(rc.deliveries) != 0 {
+		t.Errorf("deliveries: got %d, want none", len(rc.deliveries))
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// AlertStateRepository provides access to users' decisions about alerts
+type AlertStateRepository struct {
+	db *DB
+}
+
+// NewAlertStateRepository creates a new alert state repository
+func NewAlertStateRepository(db *DB) *AlertStateRepository {
+	return &AlertStateRepository{db: db}
+}
+
+// Upsert records a decision about an alert, replacing any earlier one
+func (r *AlertStateRepository) Upsert(s *models.AlertState) error {
+	s.UpdatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO alert_states (portfolio_id, alert_key, status, value, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (portfolio_id, alert_key) DO UPDATE SET
+			status = excluded.status, value = excluded.value, updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query,
+		s.PortfolioID.String(),
+		s.AlertKey,
+		string(s.Status),
+		s.Value.String(),
+		s.UpdatedAt,
+	)
+	return err
+}
+
+// GetByPortfolioID returns the portfolio's alert states keyed by alert key
+func (r *AlertStateRepository) GetByPortfolioID(portfolioID uuid.UUID) (map[string]*models.AlertState, error) {
+	rows, err := r.db.Query(
+		"SELECT alert_key, status, value, updated_at FROM alert_states WHERE portfolio_id = ?",
+		portfolioID.String(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]*models.AlertState)
+	for rows.Next() {
+		s := &models.AlertState{PortfolioID: portfolioID}
+		var status, value string
+		if err := rows.Scan(&s.AlertKey, &status, &value, &s.UpdatedAt); err != nil {
+			return nil, err
+		}
+		s.Status = models.AlertStatus(status)
+		s.Value, _ = decimal.NewFromString(value)
+		states[s.AlertKey] = s
+	}
+
+	return states, rows.Err()
+}
+
+// Delete clears the decision about an alert, so it's shown as new again
+func (r *AlertStateRepository) Delete(portfolioID uuid.UUID, alertKey string) error {
+	_, err := r.db.Exec(
+		"DELETE FROM alert_states WHERE portfolio_id = ? AND alert_key = ?",
+		portfolioID.String(), alertKey,
+	)
+	return err
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// encryptedPrefix marks a column value written by fieldCipher, so values
+// stored before encryption was enabled can still be read
+const encryptedPrefix = "enc:v1:"
+
+// fieldCipher encrypts individual column values with AES-256-GCM
+type fieldCipher struct {
+	aead cipher.AEAD
+}
+
+// newFieldCipher derives an AES-256 key from the configured key string
+func newFieldCipher(key string) (*fieldCipher, error) {
+	if key == "" {
+		return nil, errors.New("encryption key is empty")
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldCipher{aead: aead}, nil
+}
+
+// encrypt seals a value with a random nonce. Empty values stay empty.
+func (c *fieldCipher) encrypt(plaintext string) (string, error) {
+	if plaintext == "" || isEncrypted(plaintext) {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decrypt opens a value written by encrypt, passing through plaintext
+// values stored before encryption was enabled
+func (c *fieldCipher) decrypt(value string) (string, error) {
+	if !isEncrypted(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted value: %w", err)
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return "", errors.New("encrypted value is too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
@@ -4,17 +4,35 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// DB wraps the database connection
+// Dialect identifies the SQL flavor of the connected database
+type Dialect string
+
+const (
+	DialectSQLite   Dialect = "sqlite3"
+	DialectPostgres Dialect = "postgres"
+)
+
+// DB wraps the database connection. Queries are written with SQLite-style
+// "?" placeholders and rewritten for the connected dialect.
 type DB struct {
 	*sql.DB
+	Dialect Dialect
 }
 
-// New creates a new database connection
+// New creates a new database connection. URLs starting with postgres:// or
+// postgresql:// connect to PostgreSQL; anything else is a SQLite file path.
 func New(databaseURL string) (*DB, error) {
+	if dialect := detectDialect(databaseURL); dialect == DialectPostgres {
+		return newPostgres(databaseURL)
+	}
+
 	db, err := sql.Open("sqlite3", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -30,28 +48,83 @@ func New(databaseURL string) (*DB, error) {
 		return nil, fmt.Errorf("failed to enable WAL mode: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, Dialect: DialectSQLite}, nil
 }
 
-// Migrate runs database migrations
-func (db *DB) Migrate() error {
-	migrations := []string{
-		createUsersTable,
-		createPortfoliosTable,
-		createHoldingsTable,
-		createScenariosTable,
-		createSessionsTable,
+func newPostgres(databaseURL string) (*DB, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
-			return fmt.Errorf("migration failed: %w", err)
-		}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	return &DB{DB: db, Dialect: DialectPostgres}, nil
+}
+
+func detectDialect(databaseURL string) Dialect {
+	lower := strings.ToLower(databaseURL)
+	if strings.HasPrefix(lower, "postgres://") || strings.HasPrefix(lower, "postgresql://") {
+		return DialectPostgres
+	}
+	return DialectSQLite
+}
+
+// Rebind rewrites "?" placeholders into the connected dialect's style
+func (db *DB) Rebind(query string) string {
+	if db.Dialect != DialectPostgres {
+		return query
 	}
+	return rebindDollar(query)
+}
+
+// Exec executes a query written with "?" placeholders
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.Rebind(query), args...)
+}
+
+// Query runs a query written with "?" placeholders
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.Rebind(query), args...)
+}
 
-	return nil
+// QueryRow runs a single-row query written with "?" placeholders
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.Rebind(query), args...)
 }
 
+// rebindDollar numbers "?" placeholders as $1, $2, ... skipping any inside
+// quoted string literals
+func rebindDollar(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+
+	n := 0
+	inString := false
+	for _, ch := range query {
+		switch {
+		case ch == '\'':
+			inString = !inString
+		case ch == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	return b.String()
+}
+
+// postgresDDL adapts the SQLite migrations to PostgreSQL column types
+var postgresDDL = strings.NewReplacer(
+	"DATETIME", "TIMESTAMPTZ",
+	"INTEGER DEFAULT 0", "BOOLEAN DEFAULT FALSE",
+)
+
 const createUsersTable = `
 CREATE TABLE IF NOT EXISTS users (
 	id TEXT PRIMARY KEY,
@@ -106,6 +179,58 @@ CREATE INDEX IF NOT EXISTS idx_holdings_portfolio_id ON holdings(portfolio_id);
 CREATE INDEX IF NOT EXISTS idx_holdings_ticker ON holdings(ticker);
 `
 
+const createHoldingLotsTable = `
+CREATE TABLE IF NOT EXISTS holding_lots (
+	id TEXT PRIMARY KEY,
+	holding_id TEXT NOT NULL,
+	quantity TEXT NOT NULL,
+	cost_basis TEXT DEFAULT '0',
+	acquired_at DATETIME NOT NULL,
+	FOREIGN KEY (holding_id) REFERENCES holdings(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_holding_lots_holding_id ON holding_lots(holding_id);
+`
+
+const createHoldingHistoryTable = `
+CREATE TABLE IF NOT EXISTS holding_history (
+	id TEXT PRIMARY KEY,
+	holding_id TEXT NOT NULL,
+	before_state TEXT NOT NULL,
+	after_state TEXT NOT NULL,
+	changed_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (holding_id) REFERENCES holdings(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_holding_history_holding_id ON holding_history(holding_id);
+`
+
+const createEmailVerificationsTable = `
+CREATE TABLE IF NOT EXISTS email_verifications (
+	token_hash TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	expires_at DATETIME NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user_id ON email_verifications(user_id);
+`
+
+const createPortfolioSharesTable = `
+CREATE TABLE IF NOT EXISTS portfolio_shares (
+	portfolio_id TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (portfolio_id, user_id),
+	FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_shares_user_id ON portfolio_shares(user_id);
+`
+
 const createScenariosTable = `
 CREATE TABLE IF NOT EXISTS scenarios (
 	id TEXT PRIMARY KEY,
@@ -133,3 +258,97 @@ CREATE TABLE IF NOT EXISTS sessions (
 CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
 CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token);
 `
+
+const createTickerOverridesTable = `
+CREATE TABLE IF NOT EXISTS ticker_overrides (
+	user_id TEXT NOT NULL,
+	ticker TEXT NOT NULL,
+	asset_class TEXT NOT NULL,
+	sector TEXT,
+	geography TEXT,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (user_id, ticker),
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+`
+
+const createWebhooksTable = `
+CREATE TABLE IF NOT EXISTS webhooks (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL,
+	url TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_user_id ON webhooks(user_id);
+
+CREATE TABLE IF NOT EXISTS webhook_failures (
+	id TEXT PRIMARY KEY,
+	webhook_id TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	error TEXT NOT NULL,
+	attempts INTEGER NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_failures_webhook_id ON webhook_failures(webhook_id);
+
+CREATE TABLE IF NOT EXISTS notified_alerts (
+	portfolio_id TEXT NOT NULL,
+	alert_key TEXT NOT NULL,
+	notified_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (portfolio_id, alert_key),
+	FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+`
+
+const createAlertStatesTable = `
+CREATE TABLE IF NOT EXISTS alert_states (
+	portfolio_id TEXT NOT NULL,
+	alert_key TEXT NOT NULL,
+	status TEXT NOT NULL,
+	value TEXT DEFAULT '0',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (portfolio_id, alert_key),
+	FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+`
+
+const createDigestTables = `
+CREATE TABLE IF NOT EXISTS digest_subscriptions (
+	user_id TEXT PRIMARY KEY,
+	enabled INTEGER DEFAULT 0,
+	last_sent_at DATETIME,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS digest_alerts (
+	portfolio_id TEXT NOT NULL,
+	alert_key TEXT NOT NULL,
+	sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (portfolio_id, alert_key),
+	FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+`
+
+const createTransactionsTable = `
+CREATE TABLE IF NOT EXISTS transactions (
+	id TEXT PRIMARY KEY,
+	portfolio_id TEXT NOT NULL,
+	ticker TEXT NOT NULL,
+	account_name TEXT DEFAULT '',
+	tx_type TEXT NOT NULL,
+	quantity TEXT NOT NULL,
+	price TEXT NOT NULL,
+	fees TEXT DEFAULT '0',
+	traded_at DATETIME NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (portfolio_id) REFERENCES portfolios(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transactions_portfolio_id ON transactions(portfolio_id);
+`
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// DigestRepository provides access to weekly digest subscriptions and the
+// alerts each portfolio's last digest reported
+type DigestRepository struct {
+	db *DB
+}
+
+// NewDigestRepository creates a new digest repository
+func NewDigestRepository(db *DB) *DigestRepository {
+	return &DigestRepository{db: db}
+}
+
+// GetSubscription returns the user's digest subscription, disabled if they
+// have never set one
+func (r *DigestRepository) GetSubscription(userID uuid.UUID) (*models.DigestSubscription, error) {
+	sub := &models.DigestSubscription{UserID: userID}
+	var lastSent sql.NullTime
+
+	err := r.db.QueryRow(
+		"SELECT enabled, last_sent_at, updated_at FROM digest_subscriptions WHERE user_id = ?",
+		userID.String(),
+	).Scan(&sub.Enabled, &lastSent, &sub.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return sub, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if lastSent.Valid {
+		sub.LastSentAt = &lastSent.Time
+	}
+	return sub, nil
+}
+
+// SetEnabled opts the user in to or out of the weekly digest
+func (r *DigestRepository) SetEnabled(userID uuid.UUID, enabled bool) error {
+	query := `
+		INSERT INTO digest_subscriptions (user_id, enabled, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = excluded.enabled, updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query, userID.String(), enabled, time.Now().UTC())
+	return err
+}
+
+// GetDue returns the subscribed users who haven't been sent a digest since
+// before
+func (r *DigestRepository) GetDue(before time.Time) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id FROM digest_subscriptions
+		WHERE enabled = ? AND (last_sent_at IS NULL OR last_sent_at <= ?)
+		ORDER BY user_id
+	`, true, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []uuid.UUID
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if userID, err := uuid.Parse(id); err == nil {
+			due = append(due, userID)
+		}
+	}
+
+	return due, rows.Err()
+}
+
+// MarkSent records when the user's digest was sent
+func (r *DigestRepository) MarkSent(userID uuid.UUID, at time.Time) error {
+	_, err := r.db.Exec("UPDATE digest_subscriptions SET last_sent_at = ? WHERE user_id = ?", at, userID.String())
+	return err
+}
+
+// GetAlertKeys returns the keys of the alerts the portfolio's last digest
+// reported
+func (r *DigestRepository) GetAlertKeys(portfolioID uuid.UUID) (map[string]bool, error) {
+	rows, err := r.db.Query("SELECT alert_key FROM digest_alerts WHERE portfolio_id = ?", portfolioID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+
+	return keys, rows.Err()
+}
+
+// SetAlertKeys replaces the portfolio's reported alert keys with the
+// currently active ones. Alerts that have cleared are forgotten, so they
+// count as new again if they come back.
+func (r *DigestRepository) SetAlertKeys(portfolioID uuid.UUID, keys []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.db.Rebind("DELETE FROM digest_alerts WHERE portfolio_id = ?"), portfolioID.String()); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(r.db.Rebind("INSERT INTO digest_alerts (portfolio_id, alert_key, sent_at) VALUES (?, ?, ?)"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, key := range keys {
+		if _, err := stmt.Exec(portfolioID.String(), key, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
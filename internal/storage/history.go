@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// EnableHistory makes Update record a before/after snapshot of each change.
+// It's off by default since every update becomes two writes.
+func (r *HoldingRepository) EnableHistory() {
+	r.history = true
+}
+
+// GetHistory returns a holding's recorded changes, newest first
+func (r *HoldingRepository) GetHistory(holdingID uuid.UUID) ([]models.HoldingChange, error) {
+	query := `
+		SELECT id, holding_id, before_state, after_state, changed_at
+		FROM holding_history WHERE holding_id = ? ORDER BY changed_at DESC, id
+	`
+	rows, err := r.db.Query(query, holdingID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.HoldingChange
+	for rows.Next() {
+		var c models.HoldingChange
+		var id, hid, before, after string
+
+		if err := rows.Scan(&id, &hid, &before, &after, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		c.ID, _ = uuid.Parse(id)
+		c.HoldingID, _ = uuid.Parse(hid)
+		if err := json.Unmarshal([]byte(before), &c.Before); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(after), &c.After); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+
+	return changes, rows.Err()
+}
+
+// recordChange stores a snapshot pair, skipping updates that changed nothing
+// such as a price refresh at an unchanged price
+func (r *HoldingRepository) recordChange(tx *sql.Tx, before, after *models.Holding) error {
+	beforeJSON, err := holdingSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := holdingSnapshot(after)
+	if err != nil {
+		return err
+	}
+	if beforeJSON == afterJSON {
+		return nil
+	}
+
+	query := `
+		INSERT INTO holding_history (id, holding_id, before_state, after_state, changed_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(r.db.Rebind(query),
+		uuid.New().String(),
+		after.ID.String(),
+		beforeJSON,
+		afterJSON,
+		time.Now().UTC(),
+	)
+	return err
+}
+
+// holdingSnapshot serializes a holding as read from the holdings table.
+// PricedAt is left out, since every refresh moves it even when the price
+// doesn't change.
+func holdingSnapshot(h *models.Holding) (string, error) {
+	snapshot := *h
+	snapshot.PricedAt = time.Time{}
+	data, err := json.Marshal(snapshot)
+	return string(data), err
+}
+
+// getByIDTx reads a holding inside a transaction, or nil if it doesn't exist
+func (r *HoldingRepository) getByIDTx(tx *sql.Tx, id uuid.UUID) (*models.Holding, error) {
+	query := `
+		SELECT id, portfolio_id, account_name, ticker, name, quantity,
+			cost_basis, current_price, market_value, asset_class,
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		FROM holdings WHERE id = ?
+	`
+	rows, err := tx.Query(r.db.Rebind(query), id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanHoldingRow(rows)
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// HoldingLotRepository provides access to holdings' tax lots
+type HoldingLotRepository struct {
+	db *DB
+}
+
+// NewHoldingLotRepository creates a new holding lot repository
+func NewHoldingLotRepository(db *DB) *HoldingLotRepository {
+	return &HoldingLotRepository{db: db}
+}
+
+const insertLotQuery = `
+	INSERT INTO holding_lots (id, holding_id, quantity, cost_basis, acquired_at)
+	VALUES (?, ?, ?, ?, ?)
+`
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// insertLots writes lots for a holding, pointing each at holdingID
+func insertLots(db *DB, ex execer, holdingID uuid.UUID, lots []models.HoldingLot) error {
+	for i := range lots {
+		lot := &lots[i]
+		if lot.ID == uuid.Nil {
+			lot.ID = uuid.New()
+		}
+		lot.HoldingID = holdingID
+
+		_, err := ex.Exec(db.Rebind(insertLotQuery),
+			lot.ID.String(),
+			lot.HoldingID.String(),
+			lot.Quantity.String(),
+			lot.CostBasis.String(),
+			lot.AcquiredAt,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create inserts a new lot
+func (r *HoldingLotRepository) Create(lot *models.HoldingLot) error {
+	lots := []models.HoldingLot{*lot}
+	if err := insertLots(r.db, r.db.DB, lot.HoldingID, lots); err != nil {
+		return err
+	}
+	*lot = lots[0]
+	return nil
+}
+
+// GetByHoldingID retrieves a holding's lots, oldest first
+func (r *HoldingLotRepository) GetByHoldingID(holdingID uuid.UUID) ([]models.HoldingLot, error) {
+	query := `
+		SELECT id, holding_id, quantity, cost_basis, acquired_at
+		FROM holding_lots WHERE holding_id = ? ORDER BY acquired_at, id
+	`
+	rows, err := r.db.Query(query, holdingID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.HoldingLot
+	for rows.Next() {
+		lot, err := scanLotRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, *lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// GetByPortfolioID retrieves the lots of every holding in a portfolio, keyed
+// by holding ID. Deleted holdings are left out.
+func (r *HoldingLotRepository) GetByPortfolioID(portfolioID uuid.UUID) (map[uuid.UUID][]models.HoldingLot, error) {
+	return lotsByPortfolio(r.db, portfolioID)
+}
+
+// ReplaceForHolding swaps a holding's lots for a new set, as when a newer
+// statement is imported. An empty set removes them.
+func (r *HoldingLotRepository) ReplaceForHolding(holdingID uuid.UUID, lots []models.HoldingLot) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.db.Rebind("DELETE FROM holding_lots WHERE holding_id = ?"), holdingID.String()); err != nil {
+		return err
+	}
+	if err := insertLots(r.db, tx, holdingID, lots); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes a lot
+func (r *HoldingLotRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM holding_lots WHERE id = ?", id.String())
+	return err
+}
+
+// lotsByPortfolio loads the lots of every holding in a portfolio, oldest
+// first, keyed by holding ID
+func lotsByPortfolio(db *DB, portfolioID uuid.UUID) (map[uuid.UUID][]models.HoldingLot, error) {
+	query := `
+		SELECT l.id, l.holding_id, l.quantity, l.cost_basis, l.acquired_at
+		FROM holding_lots l
+		JOIN holdings h ON h.id = l.holding_id
+		WHERE h.portfolio_id = ? AND h.deleted_at IS NULL
+		ORDER BY l.acquired_at, l.id
+	`
+	rows, err := db.Query(query, portfolioID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := make(map[uuid.UUID][]models.HoldingLot)
+	for rows.Next() {
+		lot, err := scanLotRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots[lot.HoldingID] = append(lots[lot.HoldingID], *lot)
+	}
+
+	return lots, rows.Err()
+}
+
+// attachLots fills in each holding's lots from a portfolio-wide lookup
+func attachLots(db *DB, portfolioID uuid.UUID, holdings []models.Holding) error {
+	if len(holdings) == 0 {
+		return nil
+	}
+	lots, err := lotsByPortfolio(db, portfolioID)
+	if err != nil {
+		return err
+	}
+	for i := range holdings {
+		holdings[i].Lots = lots[holdings[i].ID]
+	}
+	return nil
+}
+
+func scanLotRow(rows *sql.Rows) (*models.HoldingLot, error) {
+	var lot models.HoldingLot
+	var id, holdingID, quantity, costBasis string
+
+	if err := rows.Scan(&id, &holdingID, &quantity, &costBasis, &lot.AcquiredAt); err != nil {
+		return nil, err
+	}
+
+	lot.ID, _ = uuid.Parse(id)
+	lot.HoldingID, _ = uuid.Parse(holdingID)
+	lot.Quantity, _ = decimal.NewFromString(quantity)
+	lot.CostBasis, _ = decimal.NewFromString(costBasis)
+
+	return &lot, nil
+}
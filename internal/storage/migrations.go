@@ -0,0 +1,337 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSchemaAhead is returned when the database has migrations applied that
+// this binary doesn't know about, as after rolling back a deployment
+var ErrSchemaAhead = errors.New("database schema is newer than this binary")
+
+// migration is one numbered schema change. Up steps are written to be safe
+// to re-run, since databases created before versioning have some of them
+// applied already without a record of it.
+type migration struct {
+	version int
+	name    string
+	up      func(db *DB) error
+	down    func(db *DB) error
+}
+
+// migrations are applied in order. Append new ones; never renumber or edit
+// one that has shipped.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		up: execAll(
+			createUsersTable,
+			createPortfoliosTable,
+			createHoldingsTable,
+			createScenariosTable,
+			createSessionsTable,
+			createTickerOverridesTable,
+			createWebhooksTable,
+		),
+		down: dropTables(
+			"notified_alerts", "webhook_failures", "webhooks", "ticker_overrides",
+			"sessions", "scenarios", "holdings", "portfolios", "users",
+		),
+	},
+	{
+		version: 2,
+		name:    "portfolio target scenario",
+		up:      addColumn("portfolios", "target_scenario_id", "TEXT REFERENCES scenarios(id) ON DELETE SET NULL"),
+		down:    dropColumn("portfolios", "target_scenario_id"),
+	},
+	{
+		version: 3,
+		name:    "currencies",
+		up: steps(
+			addColumn("portfolios", "currency", "TEXT DEFAULT 'USD'"),
+			addColumn("holdings", "currency", "TEXT DEFAULT 'USD'"),
+		),
+		down: steps(
+			dropColumn("holdings", "currency"),
+			dropColumn("portfolios", "currency"),
+		),
+	},
+	{
+		version: 4,
+		name:    "holding lots",
+		up:      execAll(createHoldingLotsTable),
+		down:    dropTables("holding_lots"),
+	},
+	{
+		version: 5,
+		name:    "holding history",
+		up:      execAll(createHoldingHistoryTable),
+		down:    dropTables("holding_history"),
+	},
+	{
+		version: 6,
+		name:    "soft delete",
+		up: steps(
+			addColumn("portfolios", "deleted_at", "DATETIME"),
+			addColumn("holdings", "deleted_at", "DATETIME"),
+			addColumn("scenarios", "deleted_at", "DATETIME"),
+		),
+		down: steps(
+			dropColumn("scenarios", "deleted_at"),
+			dropColumn("holdings", "deleted_at"),
+			dropColumn("portfolios", "deleted_at"),
+		),
+	},
+	{
+		version: 7,
+		name:    "portfolio shares",
+		up:      execAll(createPortfolioSharesTable),
+		down:    dropTables("portfolio_shares"),
+	},
+	{
+		version: 8,
+		name:    "user auth provider",
+		up:      addColumn("users", "auth_provider", "TEXT DEFAULT 'password'"),
+		down:    dropColumn("users", "auth_provider"),
+	},
+	{
+		version: 9,
+		name:    "email verification",
+		up: steps(
+			execAll(createEmailVerificationsTable),
+			verifyExistingUsers,
+		),
+		down: steps(
+			dropTables("email_verifications"),
+			dropColumn("users", "verified"),
+		),
+	},
+	{
+		version: 10,
+		name:    "alert states",
+		up:      execAll(createAlertStatesTable),
+		down:    dropTables("alert_states"),
+	},
+	{
+		version: 11,
+		name:    "holding priced at",
+		up:      addColumn("holdings", "priced_at", "DATETIME"),
+		down:    dropColumn("holdings", "priced_at"),
+	},
+	{
+		version: 12,
+		name:    "weekly digest",
+		up:      execAll(createDigestTables),
+		down:    dropTables("digest_alerts", "digest_subscriptions"),
+	},
+	{
+		version: 13,
+		name:    "transactions",
+		up:      execAll(createTransactionsTable),
+		down:    dropTables("transactions"),
+	},
+	{
+		version: 14,
+		name:    "scenario sector and geography targets",
+		up: steps(
+			addColumn("scenarios", "sector_targets", "TEXT"),
+			addColumn("scenarios", "geo_targets", "TEXT"),
+		),
+		down: steps(
+			dropColumn("scenarios", "geo_targets"),
+			dropColumn("scenarios", "sector_targets"),
+		),
+	},
+}
+
+// verifyExistingUsers adds the verified flag, treating accounts created
+// before verification existed as verified so they keep working. Rolling
+// back drops the column, so a re-run never sees a user's own answer.
+func verifyExistingUsers(db *DB) error {
+	if err := db.addColumnIfMissing("users", "verified", "INTEGER DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := db.Exec("UPDATE users SET verified = ?", true)
+	return err
+}
+
+const createSchemaMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// LatestSchemaVersion is the newest migration this binary knows about
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Migrate applies every migration newer than the database's schema version.
+// It fails with ErrSchemaAhead if the database is newer than this binary.
+func (db *DB) Migrate() error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if latest := LatestSchemaVersion(); current > latest {
+		return fmt.Errorf("%w: database is at version %d, this binary knows up to %d", ErrSchemaAhead, current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := m.up(db); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		if _, err := db.Exec("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)", m.version, m.name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateDown rolls back the most recent n migrations
+func (db *DB) MigrateDown(n int) error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("%w: database is at version %d, this binary knows up to %d", ErrSchemaAhead, current, LatestSchemaVersion())
+	}
+
+	for i := len(migrations) - 1; i >= 0 && n > 0; i-- {
+		m := migrations[i]
+		if m.version > current {
+			continue
+		}
+		if err := m.down(db); err != nil {
+			return fmt.Errorf("rollback of migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		if _, err := db.Exec("DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+			return fmt.Errorf("rollback of migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		n--
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the newest migration applied to the database, or 0
+// for a new database
+func (db *DB) SchemaVersion() (int, error) {
+	if _, err := db.Exec(createSchemaMigrationsTable); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// steps runs several migration steps in order
+func steps(fns ...func(db *DB) error) func(db *DB) error {
+	return func(db *DB) error {
+		for _, fn := range fns {
+			if err := fn(db); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// execAll runs DDL statements, adapting them for Postgres
+func execAll(statements ...string) func(db *DB) error {
+	return func(db *DB) error {
+		for _, statement := range statements {
+			if db.Dialect == DialectPostgres {
+				statement = postgresDDL.Replace(statement)
+			}
+			if _, err := db.Exec(statement); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func dropTables(tables ...string) func(db *DB) error {
+	return func(db *DB) error {
+		for _, table := range tables {
+			if _, err := db.Exec("DROP TABLE IF EXISTS " + table); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func addColumn(table, column, definition string) func(db *DB) error {
+	return func(db *DB) error {
+		return db.addColumnIfMissing(table, column, definition)
+	}
+}
+
+func dropColumn(table, column string) func(db *DB) error {
+	return func(db *DB) error {
+		if db.Dialect == DialectPostgres {
+			_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", table, column))
+			return err
+		}
+
+		exists, err := db.hasColumn(table, column)
+		if err != nil || !exists {
+			return err
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+		return err
+	}
+}
+
+// addColumnIfMissing adds a column to an existing table, doing nothing if a
+// previous run already added it
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	if db.Dialect == DialectPostgres {
+		_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, postgresDDL.Replace(definition)))
+		return err
+	}
+
+	// SQLite has no IF NOT EXISTS for columns, so check the schema first
+	exists, err := db.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// hasColumn reports whether a SQLite table has a column
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// TickerOverrideRepository provides access to user-defined classifications
+type TickerOverrideRepository struct {
+	db *DB
+}
+
+// NewTickerOverrideRepository creates a new ticker override repository
+func NewTickerOverrideRepository(db *DB) *TickerOverrideRepository {
+	return &TickerOverrideRepository{db: db}
+}
+
+// Upsert creates or replaces the user's classification for a ticker
+func (r *TickerOverrideRepository) Upsert(o *models.TickerOverride) error {
+	o.Ticker = strings.ToUpper(strings.TrimSpace(o.Ticker))
+	o.UpdatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO ticker_overrides (user_id, ticker, asset_class, sector, geography, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, ticker) DO UPDATE SET
+			asset_class = excluded.asset_class,
+			sector = excluded.sector,
+			geography = excluded.geography,
+			updated_at = excluded.updated_at
+	`
+	_, err := r.db.Exec(query,
+		o.UserID.String(),
+		o.Ticker,
+		string(o.AssetClass),
+		o.Sector,
+		o.Geography,
+		o.UpdatedAt,
+	)
+	return err
+}
+
+// GetByUserID returns all of a user's overrides keyed by ticker
+func (r *TickerOverrideRepository) GetByUserID(userID uuid.UUID) (map[string]models.TickerOverride, error) {
+	query := `
+		SELECT ticker, asset_class, sector, geography, updated_at
+		FROM ticker_overrides WHERE user_id = ?
+	`
+	rows, err := r.db.Query(query, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := make(map[string]models.TickerOverride)
+	for rows.Next() {
+		o := models.TickerOverride{UserID: userID}
+		var assetClass string
+		var sector, geography sql.NullString
+
+		if err := rows.Scan(&o.Ticker, &assetClass, &sector, &geography, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		o.AssetClass = models.AssetClass(assetClass)
+		o.Sector = sector.String
+		o.Geography = geography.String
+		overrides[o.Ticker] = o
+	}
+
+	return overrides, rows.Err()
+}
+
+// Delete removes the user's override for a ticker
+func (r *TickerOverrideRepository) Delete(userID uuid.UUID, ticker string) error {
+	_, err := r.db.Exec(
+		"DELETE FROM ticker_overrides WHERE user_id = ? AND ticker = ?",
+		userID.String(), strings.ToUpper(strings.TrimSpace(ticker)),
+	)
+	return err
+}
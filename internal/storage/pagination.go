@@ -0,0 +1,28 @@
+package storage
+
+// Page size bounds for list queries
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Page selects a window of rows from a list query
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// NewPage builds a page from user-supplied values, falling back to the
+// default size and clamping to MaxPageSize
+func NewPage(limit, offset int) Page {
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return Page{Limit: limit, Offset: offset}
+}
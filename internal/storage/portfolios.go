@@ -24,8 +24,8 @@ func NewPortfolioRepository(db *DB) *PortfolioRepository {
 // Create inserts a new portfolio
 func (r *PortfolioRepository) Create(p *models.Portfolio) error {
 	query := `
-		INSERT INTO portfolios (id, user_id, name, total_value, free_cash, last_updated, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO portfolios (id, user_id, name, total_value, free_cash, currency, last_updated, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := r.db.Exec(query,
 		p.ID.String(),
@@ -33,6 +33,7 @@ func (r *PortfolioRepository) Create(p *models.Portfolio) error {
 		p.Name,
 		p.TotalValue.String(),
 		p.FreeCash.String(),
+		p.CurrencyCode(),
 		p.LastUpdated,
 		p.CreatedAt,
 	)
@@ -41,11 +42,7 @@ func (r *PortfolioRepository) Create(p *models.Portfolio) error {
 
 // GetByID retrieves a portfolio by ID with holdings
 func (r *PortfolioRepository) GetByID(id uuid.UUID) (*models.Portfolio, error) {
-	query := `
-		SELECT id, user_id, name, total_value, free_cash, last_updated, created_at
-		FROM portfolios WHERE id = ?
-	`
-	p, err := r.scanPortfolio(r.db.QueryRow(query, id.String()))
+	p, err := r.GetSummary(id)
 	if err != nil || p == nil {
 		return p, err
 	}
@@ -60,11 +57,59 @@ func (r *PortfolioRepository) GetByID(id uuid.UUID) (*models.Portfolio, error) {
 	return p, nil
 }
 
-// GetByUserID retrieves all portfolios for a user
-func (r *PortfolioRepository) GetByUserID(userID uuid.UUID) ([]*models.Portfolio, error) {
+// GetSummary retrieves a portfolio with its persisted totals but without
+// loading its holdings, for requests that only check access or need the
+// total value
+func (r *PortfolioRepository) GetSummary(id uuid.UUID) (*models.Portfolio, error) {
+	query := `
+		SELECT id, user_id, name, total_value, free_cash, last_updated, created_at, target_scenario_id, currency
+		FROM portfolios WHERE id = ? AND deleted_at IS NULL
+	`
+	return r.scanPortfolio(r.db.QueryRow(query, id.String()))
+}
+
+// GetByUserID retrieves a page of a user's portfolios, newest first, along
+// with the total number of portfolios the user has
+func (r *PortfolioRepository) GetByUserID(userID uuid.UUID, page Page) ([]*models.Portfolio, int, error) {
+	page = NewPage(page.Limit, page.Offset)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM portfolios WHERE user_id = ? AND deleted_at IS NULL", userID.String()).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, user_id, name, total_value, free_cash, last_updated, created_at, target_scenario_id, currency
+		FROM portfolios WHERE user_id = ? AND deleted_at IS NULL ORDER BY created_at DESC, id
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, userID.String(), page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var portfolios []*models.Portfolio
+	for rows.Next() {
+		p, err := r.scanPortfolioRow(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		portfolios = append(portfolios, p)
+	}
+
+	return portfolios, total, rows.Err()
+}
+
+// GetSharedWithUser retrieves the portfolios other users have shared with
+// a user, without holdings, newest first
+func (r *PortfolioRepository) GetSharedWithUser(userID uuid.UUID) ([]*models.Portfolio, error) {
 	query := `
-		SELECT id, user_id, name, total_value, free_cash, last_updated, created_at
-		FROM portfolios WHERE user_id = ? ORDER BY created_at DESC
+		SELECT p.id, p.user_id, p.name, p.total_value, p.free_cash, p.last_updated, p.created_at, p.target_scenario_id, p.currency
+		FROM portfolios p
+		JOIN portfolio_shares s ON s.portfolio_id = p.id
+		WHERE s.user_id = ? AND p.deleted_at IS NULL
+		ORDER BY p.created_at DESC, p.id
 	`
 	rows, err := r.db.Query(query, userID.String())
 	if err != nil {
@@ -88,21 +133,83 @@ func (r *PortfolioRepository) GetByUserID(userID uuid.UUID) ([]*models.Portfolio
 func (r *PortfolioRepository) Update(p *models.Portfolio) error {
 	p.LastUpdated = time.Now().UTC()
 	query := `
-		UPDATE portfolios SET name = ?, total_value = ?, free_cash = ?, last_updated = ?
+		UPDATE portfolios SET name = ?, total_value = ?, free_cash = ?, currency = ?, last_updated = ?
 		WHERE id = ?
 	`
 	_, err := r.db.Exec(query,
 		p.Name,
 		p.TotalValue.String(),
 		p.FreeCash.String(),
+		p.CurrencyCode(),
 		p.LastUpdated,
 		p.ID.String(),
 	)
 	return err
 }
 
-// Delete removes a portfolio and all its holdings
+// SetTargetScenario designates the saved scenario that drift is measured
+// against. Passing nil clears it.
+func (r *PortfolioRepository) SetTargetScenario(portfolioID uuid.UUID, scenarioID *uuid.UUID) error {
+	var target interface{}
+	if scenarioID != nil {
+		target = scenarioID.String()
+	}
+	_, err := r.db.Exec("UPDATE portfolios SET target_scenario_id = ? WHERE id = ?", target, portfolioID.String())
+	return err
+}
+
+// Delete soft-deletes a portfolio along with its holdings and scenarios.
+// They're hidden from every query but can be brought back with Restore.
 func (r *PortfolioRepository) Delete(id uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Children share the portfolio's timestamp so Restore can tell them
+	// apart from holdings that were deleted on their own earlier
+	now := time.Now().UTC()
+	for _, query := range []string{
+		"UPDATE portfolios SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		"UPDATE holdings SET deleted_at = ? WHERE portfolio_id = ? AND deleted_at IS NULL",
+		"UPDATE scenarios SET deleted_at = ? WHERE portfolio_id = ? AND deleted_at IS NULL",
+	} {
+		if _, err := tx.Exec(r.db.Rebind(query), now, id.String()); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Restore undoes Delete, bringing back the portfolio and the holdings and
+// scenarios deleted with it
+func (r *PortfolioRepository) Restore(id uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"UPDATE holdings SET deleted_at = NULL WHERE portfolio_id = ? AND deleted_at = (SELECT deleted_at FROM portfolios WHERE id = ?)",
+		"UPDATE scenarios SET deleted_at = NULL WHERE portfolio_id = ? AND deleted_at = (SELECT deleted_at FROM portfolios WHERE id = ?)",
+	} {
+		if _, err := tx.Exec(r.db.Rebind(query), id.String(), id.String()); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(r.db.Rebind("UPDATE portfolios SET deleted_at = NULL WHERE id = ?"), id.String()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purge permanently removes a portfolio and everything in it, deleted or
+// not, as for a data erasure request
+func (r *PortfolioRepository) Purge(id uuid.UUID) error {
 	_, err := r.db.Exec("DELETE FROM portfolios WHERE id = ?", id.String())
 	return err
 }
@@ -110,8 +217,9 @@ func (r *PortfolioRepository) Delete(id uuid.UUID) error {
 func (r *PortfolioRepository) scanPortfolio(row *sql.Row) (*models.Portfolio, error) {
 	var p models.Portfolio
 	var id, userID, totalValue, freeCash string
+	var targetID, currency sql.NullString
 
-	err := row.Scan(&id, &userID, &p.Name, &totalValue, &freeCash, &p.LastUpdated, &p.CreatedAt)
+	err := row.Scan(&id, &userID, &p.Name, &totalValue, &freeCash, &p.LastUpdated, &p.CreatedAt, &targetID, &currency)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -123,6 +231,8 @@ func (r *PortfolioRepository) scanPortfolio(row *sql.Row) (*models.Portfolio, er
 	p.UserID, _ = uuid.Parse(userID)
 	p.TotalValue, _ = decimal.NewFromString(totalValue)
 	p.FreeCash, _ = decimal.NewFromString(freeCash)
+	p.TargetScenarioID = parseNullUUID(targetID)
+	p.Currency = currencyOrDefault(currency)
 
 	return &p, nil
 }
@@ -130,8 +240,9 @@ func (r *PortfolioRepository) scanPortfolio(row *sql.Row) (*models.Portfolio, er
 func (r *PortfolioRepository) scanPortfolioRow(rows *sql.Rows) (*models.Portfolio, error) {
 	var p models.Portfolio
 	var id, userID, totalValue, freeCash string
+	var targetID, currency sql.NullString
 
-	err := rows.Scan(&id, &userID, &p.Name, &totalValue, &freeCash, &p.LastUpdated, &p.CreatedAt)
+	err := rows.Scan(&id, &userID, &p.Name, &totalValue, &freeCash, &p.LastUpdated, &p.CreatedAt, &targetID, &currency)
 	if err != nil {
 		return nil, err
 	}
@@ -140,13 +251,45 @@ func (r *PortfolioRepository) scanPortfolioRow(rows *sql.Rows) (*models.Portfoli
 	p.UserID, _ = uuid.Parse(userID)
 	p.TotalValue, _ = decimal.NewFromString(totalValue)
 	p.FreeCash, _ = decimal.NewFromString(freeCash)
+	p.TargetScenarioID = parseNullUUID(targetID)
+	p.Currency = currencyOrDefault(currency)
 
 	return &p, nil
 }
 
+// currencyOrDefault returns the stored currency, or USD for rows written
+// before currencies were tracked
+func currencyOrDefault(s sql.NullString) string {
+	if !s.Valid || s.String == "" {
+		return models.DefaultCurrency
+	}
+	return s.String
+}
+
+// parseNullUUID returns nil for NULL or malformed IDs
+func parseNullUUID(s sql.NullString) *uuid.UUID {
+	if !s.Valid {
+		return nil
+	}
+	id, err := uuid.Parse(s.String)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
+// nullTime stores a zero time as NULL
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
 // HoldingRepository provides holding data access
 type HoldingRepository struct {
-	db *DB
+	db      *DB
+	history bool // Record before/after snapshots on Update
 }
 
 // NewHoldingRepository creates a new holding repository
@@ -160,8 +303,8 @@ func (r *HoldingRepository) Create(h *models.Holding) error {
 		INSERT INTO holdings (
 			id, portfolio_id, account_name, ticker, name, quantity,
 			cost_basis, current_price, market_value, asset_class,
-			sector, geography, is_manual_entry, source, imported_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := r.db.Exec(query,
 		h.ID.String(),
@@ -179,8 +322,13 @@ func (r *HoldingRepository) Create(h *models.Holding) error {
 		h.IsManualEntry,
 		h.Source,
 		h.ImportedAt,
+		h.CurrencyCode(),
+		nullTime(h.PricedAt),
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return insertLots(r.db, r.db.DB, h.ID, h.Lots)
 }
 
 // CreateBatch inserts multiple holdings in a transaction
@@ -191,13 +339,43 @@ func (r *HoldingRepository) CreateBatch(holdings []models.Holding) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
+	if err := r.insertBatch(tx, holdings); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceAccount soft-deletes an account's holdings and inserts holdings in
+// their place in one transaction, so a failed insert leaves the account as
+// it was rather than empty
+func (r *HoldingRepository) ReplaceAccount(portfolioID uuid.UUID, accountName string, holdings []models.Holding) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		r.db.Rebind("UPDATE holdings SET deleted_at = ? WHERE portfolio_id = ? AND account_name = ? AND deleted_at IS NULL"),
+		time.Now().UTC(), portfolioID.String(), accountName,
+	); err != nil {
+		return err
+	}
+	if err := r.insertBatch(tx, holdings); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertBatch inserts holdings and their lots within tx
+func (r *HoldingRepository) insertBatch(tx *sql.Tx, holdings []models.Holding) error {
+	stmt, err := tx.Prepare(r.db.Rebind(`
 		INSERT INTO holdings (
 			id, portfolio_id, account_name, ticker, name, quantity,
 			cost_basis, current_price, market_value, asset_class,
-			sector, geography, is_manual_entry, source, imported_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`)
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`))
 	if err != nil {
 		return err
 	}
@@ -220,25 +398,136 @@ func (r *HoldingRepository) CreateBatch(holdings []models.Holding) error {
 			h.IsManualEntry,
 			h.Source,
 			h.ImportedAt,
+			h.CurrencyCode(),
+			nullTime(h.PricedAt),
 		)
 		if err != nil {
 			return err
 		}
+		if err := insertLots(r.db, tx, h.ID, h.Lots); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	return tx.Commit()
+// GetByPortfolioID retrieves a page of a portfolio's holdings, largest
+// first, along with the total number of holdings
+func (r *HoldingRepository) GetByPortfolioID(portfolioID uuid.UUID, page Page) ([]models.Holding, int, error) {
+	page = NewPage(page.Limit, page.Offset)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM holdings WHERE portfolio_id = ? AND deleted_at IS NULL", portfolioID.String()).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, portfolio_id, account_name, ticker, name, quantity,
+			cost_basis, current_price, market_value, asset_class,
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		FROM holdings WHERE portfolio_id = ? AND deleted_at IS NULL ORDER BY CAST(market_value AS REAL) DESC, id
+		LIMIT ? OFFSET ?
+	`
+	rows, err := r.db.Query(query, portfolioID.String(), page.Limit, page.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var holdings []models.Holding
+	for rows.Next() {
+		h, err := scanHoldingRow(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		holdings = append(holdings, *h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	if err := attachLots(r.db, portfolioID, holdings); err != nil {
+		return nil, 0, err
+	}
+	return holdings, total, nil
 }
 
-// Update modifies an existing holding
+// GetByID retrieves a single holding, or nil if it doesn't exist or has
+// been deleted
+func (r *HoldingRepository) GetByID(id uuid.UUID) (*models.Holding, error) {
+	query := `
+		SELECT id, portfolio_id, account_name, ticker, name, quantity,
+			cost_basis, current_price, market_value, asset_class,
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		FROM holdings WHERE id = ? AND deleted_at IS NULL
+	`
+	rows, err := r.db.Query(query, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	h, err := scanHoldingRow(rows)
+	if err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if h.Lots, err = NewHoldingLotRepository(r.db).GetByHoldingID(h.ID); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Update modifies an existing holding. Its lots are left alone; replace
+// them through HoldingLotRepository. With history enabled, the change is
+// recorded in the same transaction.
 func (r *HoldingRepository) Update(h *models.Holding) error {
+	if !r.history {
+		return r.update(r.db.DB, h)
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := r.getByIDTx(tx, h.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.update(tx, h); err != nil {
+		return err
+	}
+	if before != nil {
+		// Read back rather than trusting h, so both snapshots are as stored
+		after, err := r.getByIDTx(tx, h.ID)
+		if err != nil {
+			return err
+		}
+		if err := r.recordChange(tx, before, after); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *HoldingRepository) update(ex execer, h *models.Holding) error {
 	query := `
 		UPDATE holdings SET
 			account_name = ?, ticker = ?, name = ?, quantity = ?,
 			cost_basis = ?, current_price = ?, market_value = ?,
-			asset_class = ?, sector = ?, geography = ?, is_manual_entry = ?
+			asset_class = ?, sector = ?, geography = ?, is_manual_entry = ?, currency = ?,
+			priced_at = ?
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(query,
+	_, err := ex.Exec(r.db.Rebind(query),
 		h.AccountName,
 		h.Ticker,
 		h.Name,
@@ -250,20 +539,40 @@ func (r *HoldingRepository) Update(h *models.Holding) error {
 		h.Sector,
 		h.Geography,
 		h.IsManualEntry,
+		h.CurrencyCode(),
+		nullTime(h.PricedAt),
 		h.ID.String(),
 	)
 	return err
 }
 
-// Delete removes a holding
+// Delete soft-deletes a holding; Restore brings it back
 func (r *HoldingRepository) Delete(id uuid.UUID) error {
-	_, err := r.db.Exec("DELETE FROM holdings WHERE id = ?", id.String())
+	_, err := r.db.Exec(
+		"UPDATE holdings SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL",
+		time.Now().UTC(), id.String(),
+	)
 	return err
 }
 
-// DeleteByPortfolioID removes all holdings for a portfolio
+// DeleteByPortfolioID soft-deletes all holdings for a portfolio
 func (r *HoldingRepository) DeleteByPortfolioID(portfolioID uuid.UUID) error {
-	_, err := r.db.Exec("DELETE FROM holdings WHERE portfolio_id = ?", portfolioID.String())
+	_, err := r.db.Exec(
+		"UPDATE holdings SET deleted_at = ? WHERE portfolio_id = ? AND deleted_at IS NULL",
+		time.Now().UTC(), portfolioID.String(),
+	)
+	return err
+}
+
+// Restore undoes Delete for a single holding
+func (r *HoldingRepository) Restore(id uuid.UUID) error {
+	_, err := r.db.Exec("UPDATE holdings SET deleted_at = NULL WHERE id = ?", id.String())
+	return err
+}
+
+// Purge permanently removes a holding, deleted or not
+func (r *HoldingRepository) Purge(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM holdings WHERE id = ?", id.String())
 	return err
 }
 
@@ -272,8 +581,8 @@ func (r *PortfolioRepository) getHoldings(portfolioID uuid.UUID) ([]models.Holdi
 	query := `
 		SELECT id, portfolio_id, account_name, ticker, name, quantity,
 			cost_basis, current_price, market_value, asset_class,
-			sector, geography, is_manual_entry, source, imported_at
-		FROM holdings WHERE portfolio_id = ? ORDER BY market_value DESC
+			sector, geography, is_manual_entry, source, imported_at, currency, priced_at
+		FROM holdings WHERE portfolio_id = ? AND deleted_at IS NULL ORDER BY CAST(market_value AS REAL) DESC, id
 	`
 	rows, err := r.db.Query(query, portfolioID.String())
 	if err != nil {
@@ -289,8 +598,15 @@ func (r *PortfolioRepository) getHoldings(portfolioID uuid.UUID) ([]models.Holdi
 		}
 		holdings = append(holdings, *h)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-	return holdings, rows.Err()
+	if err := attachLots(r.db, portfolioID, holdings); err != nil {
+		return nil, err
+	}
+	return holdings, nil
 }
 
 func scanHoldingRow(rows *sql.Rows) (*models.Holding, error) {
@@ -298,12 +614,13 @@ func scanHoldingRow(rows *sql.Rows) (*models.Holding, error) {
 	var id, portfolioID string
 	var quantity, costBasis, currentPrice, marketValue string
 	var assetClass string
-	var sector, geography, source sql.NullString
+	var sector, geography, source, currency sql.NullString
+	var pricedAt sql.NullTime
 
 	err := rows.Scan(
 		&id, &portfolioID, &h.AccountName, &h.Ticker, &h.Name,
 		&quantity, &costBasis, &currentPrice, &marketValue,
-		&assetClass, &sector, &geography, &h.IsManualEntry, &source, &h.ImportedAt,
+		&assetClass, &sector, &geography, &h.IsManualEntry, &source, &h.ImportedAt, &currency, &pricedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -316,6 +633,10 @@ func scanHoldingRow(rows *sql.Rows) (*models.Holding, error) {
 	h.CurrentPrice, _ = decimal.NewFromString(currentPrice)
 	h.MarketValue, _ = decimal.NewFromString(marketValue)
 	h.AssetClass = models.AssetClass(assetClass)
+	h.Currency = currencyOrDefault(currency)
+	if pricedAt.Valid {
+		h.PricedAt = pricedAt.Time
+	}
 
 	if sector.Valid {
 		h.Sector = sector.String
@@ -346,14 +667,16 @@ func (r *ScenarioRepository) Create(s *models.Scenario) error {
 	projJSON, _ := json.Marshal(s.Projections)
 
 	query := `
-		INSERT INTO scenarios (id, portfolio_id, name, allocations, projections, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)
+		INSERT INTO scenarios (id, portfolio_id, name, allocations, sector_targets, geo_targets, projections, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	_, err := r.db.Exec(query,
 		s.ID.String(),
 		s.PortfolioID.String(),
 		s.Name,
 		string(allocJSON),
+		targetsJSON(s.SectorTargets),
+		targetsJSON(s.GeoTargets),
 		string(projJSON),
 		s.CreatedAt,
 	)
@@ -363,8 +686,8 @@ func (r *ScenarioRepository) Create(s *models.Scenario) error {
 // GetByPortfolioID retrieves all scenarios for a portfolio
 func (r *ScenarioRepository) GetByPortfolioID(portfolioID uuid.UUID) ([]*models.Scenario, error) {
 	query := `
-		SELECT id, portfolio_id, name, allocations, projections, created_at
-		FROM scenarios WHERE portfolio_id = ? ORDER BY created_at DESC
+		SELECT id, portfolio_id, name, allocations, sector_targets, geo_targets, projections, created_at
+		FROM scenarios WHERE portfolio_id = ? AND deleted_at IS NULL ORDER BY created_at DESC
 	`
 	rows, err := r.db.Query(query, portfolioID.String())
 	if err != nil {
@@ -384,6 +707,45 @@ func (r *ScenarioRepository) GetByPortfolioID(portfolioID uuid.UUID) ([]*models.
 	return scenarios, rows.Err()
 }
 
+// GetByID retrieves a scenario by ID
+func (r *ScenarioRepository) GetByID(id uuid.UUID) (*models.Scenario, error) {
+	query := `
+		SELECT id, portfolio_id, name, allocations, sector_targets, geo_targets, projections, created_at
+		FROM scenarios WHERE id = ? AND deleted_at IS NULL
+	`
+	rows, err := r.db.Query(query, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return r.scanScenarioRow(rows)
+}
+
+// Update saves a scenario's name, allocations, targets, and projections.
+// The ID, portfolio, and creation date are left unchanged.
+func (r *ScenarioRepository) Update(s *models.Scenario) error {
+	allocJSON, _ := json.Marshal(s.Allocations)
+	projJSON, _ := json.Marshal(s.Projections)
+
+	query := `
+		UPDATE scenarios SET name = ?, allocations = ?, sector_targets = ?, geo_targets = ?, projections = ?
+		WHERE id = ?
+	`
+	_, err := r.db.Exec(query,
+		s.Name,
+		string(allocJSON),
+		targetsJSON(s.SectorTargets),
+		targetsJSON(s.GeoTargets),
+		string(projJSON),
+		s.ID.String(),
+	)
+	return err
+}
+
 // Delete removes a scenario
 func (r *ScenarioRepository) Delete(id uuid.UUID) error {
 	_, err := r.db.Exec("DELETE FROM scenarios WHERE id = ?", id.String())
@@ -393,8 +755,9 @@ func (r *ScenarioRepository) Delete(id uuid.UUID) error {
 func (r *ScenarioRepository) scanScenarioRow(rows *sql.Rows) (*models.Scenario, error) {
 	var s models.Scenario
 	var id, portfolioID, allocJSON, projJSON string
+	var sectorJSON, geoJSON sql.NullString
 
-	err := rows.Scan(&id, &portfolioID, &s.Name, &allocJSON, &projJSON, &s.CreatedAt)
+	err := rows.Scan(&id, &portfolioID, &s.Name, &allocJSON, &sectorJSON, &geoJSON, &projJSON, &s.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -405,9 +768,25 @@ func (r *ScenarioRepository) scanScenarioRow(rows *sql.Rows) (*models.Scenario,
 	if allocJSON != "" {
 		json.Unmarshal([]byte(allocJSON), &s.Allocations)
 	}
+	if sectorJSON.String != "" {
+		json.Unmarshal([]byte(sectorJSON.String), &s.SectorTargets)
+	}
+	if geoJSON.String != "" {
+		json.Unmarshal([]byte(geoJSON.String), &s.GeoTargets)
+	}
 	if projJSON != "" {
 		json.Unmarshal([]byte(projJSON), &s.Projections)
 	}
 
 	return &s, nil
 }
+
+// targetsJSON encodes a scenario's sector or geography targets, storing
+// none as NULL
+func targetsJSON(targets map[string]decimal.Decimal) interface{} {
+	if len(targets) == 0 {
+		return nil
+	}
+	data, _ := json.Marshal(targets)
+	return string(data)
+}
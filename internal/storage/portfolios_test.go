@@ -0,0 +1,678 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// newTestDB opens a migrated database in a temporary directory
+func newTestDB(t testing.TB) *DB {
+	t.Helper()
+
+	db, err := New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+// createTestPortfolio inserts a user with an empty portfolio
+func createTestPortfolio(t testing.TB, db *DB, email string) *models.Portfolio {
+	t.Helper()
+
+	user := models.NewUser(email, "Test User", "hash")
+	if err := NewUserRepository(db).Create(user); err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	portfolio := models.NewPortfolio(user.ID, "Test Portfolio")
+	if err := NewPortfolioRepository(db).Create(portfolio); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+	return portfolio
+}
+
+func TestScenarioRepository_Update(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewScenarioRepository(db)
+	portfolio := createTestPortfolio(t, db, "update@example.com")
+
+	scenario := models.NewScenario(portfolio.ID, "Original")
+	scenario.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(100))
+	scenario.CalculateProjections(decimal.NewFromInt(10000))
+	if err := repo.Create(scenario); err != nil {
+		t.Fatalf("Failed to create scenario: %v", err)
+	}
+
+	saved, err := repo.GetByID(scenario.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to load scenario: %v", err)
+	}
+	if saved.SectorTargets != nil || saved.GeoTargets != nil {
+		t.Errorf("Expected no targets, got %v and %v", saved.SectorTargets, saved.GeoTargets)
+	}
+
+	saved.Name = "Balanced"
+	saved.Allocations = map[models.AssetClass]decimal.Decimal{
+		models.AssetClassEquity:      decimal.NewFromInt(60),
+		models.AssetClassFixedIncome: decimal.NewFromInt(40),
+	}
+	saved.GeoTargets = map[string]decimal.Decimal{"US": decimal.NewFromInt(70), "International": decimal.NewFromInt(30)}
+	saved.CalculateProjections(decimal.NewFromInt(10000))
+	if err := repo.Update(saved); err != nil {
+		t.Fatalf("Failed to update scenario: %v", err)
+	}
+
+	updated, err := repo.GetByID(scenario.ID)
+	if err != nil || updated == nil {
+		t.Fatalf("Failed to reload scenario: %v", err)
+	}
+
+	if updated.Name != "Balanced" {
+		t.Errorf("Name: got %s, want Balanced", updated.Name)
+	}
+	if !updated.Allocations[models.AssetClassFixedIncome].Equal(decimal.NewFromInt(40)) {
+		t.Errorf("Fixed income allocation: got %s, want 40", updated.Allocations[models.AssetClassFixedIncome])
+	}
+	if !updated.GeoTargets["International"].Equal(decimal.NewFromInt(30)) || updated.SectorTargets != nil {
+		t.Errorf("Targets: got sectors %v, geographies %v", updated.SectorTargets, updated.GeoTargets)
+	}
+	if !updated.Projections.AverageCase.Equal(saved.Projections.AverageCase) {
+		t.Errorf("Average case: got %s, want %s", updated.Projections.AverageCase, saved.Projections.AverageCase)
+	}
+	if !updated.CreatedAt.Equal(saved.CreatedAt) {
+		t.Errorf("Created at should be unchanged: got %v, want %v", updated.CreatedAt, saved.CreatedAt)
+	}
+
+	scenarios, err := repo.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		t.Fatalf("Failed to list scenarios: %v", err)
+	}
+	if len(scenarios) != 1 {
+		t.Errorf("Expected update in place, got %d scenarios", len(scenarios))
+	}
+}
+
+func TestScenarioRepository_GetByID_NotFound(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewScenarioRepository(db)
+
+	scenario, err := repo.GetByID(models.NewScenario(createTestPortfolio(t, db, "missing@example.com").ID, "Unsaved").ID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if scenario != nil {
+		t.Error("Expected nil for a scenario that was never saved")
+	}
+}
+
+func TestHoldingRepository_GetByPortfolioID_Pages(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHoldingRepository(db)
+	portfolio := createTestPortfolio(t, db, "pages@example.com")
+
+	// Values chosen so text ordering would differ from numeric ordering
+	values := []int64{900, 10000, 50, 2500, 100}
+	var holdings []models.Holding
+	for i, v := range values {
+		h := models.NewHolding(portfolio.ID, fmt.Sprintf("T%d", i), "Test", "IRA")
+		h.MarketValue = decimal.NewFromInt(v)
+		holdings = append(holdings, *h)
+	}
+	if err := repo.CreateBatch(holdings); err != nil {
+		t.Fatalf("Failed to create holdings: %v", err)
+	}
+
+	first, total, err := repo.GetByPortfolioID(portfolio.ID, Page{Limit: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 5 {
+		t.Errorf("Total: got %d, want 5", total)
+	}
+	if len(first) != 2 || !first[0].MarketValue.Equal(decimal.NewFromInt(10000)) || !first[1].MarketValue.Equal(decimal.NewFromInt(2500)) {
+		t.Errorf("First page should be the two largest holdings, got %v", first)
+	}
+
+	last, _, err := repo.GetByPortfolioID(portfolio.ID, Page{Limit: 2, Offset: 4})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(last) != 1 || !last[0].MarketValue.Equal(decimal.NewFromInt(50)) {
+		t.Errorf("Last page should hold only the smallest holding, got %v", last)
+	}
+}
+
+func TestHoldingRepository_ReplaceAccount(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHoldingRepository(db)
+	portfolio := createTestPortfolio(t, db, "replace@example.com")
+
+	ira := models.NewHolding(portfolio.ID, "VTI", "Test", "IRA")
+	brokerage := models.NewHolding(portfolio.ID, "BND", "Test", "Brokerage")
+	if err := repo.CreateBatch([]models.Holding{*ira, *brokerage}); err != nil {
+		t.Fatalf("Failed to create holdings: %v", err)
+	}
+	tickers := func() map[string]bool {
+		holdings, _, err := repo.GetByPortfolioID(portfolio.ID, Page{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		got := make(map[string]bool)
+		for _, h := range holdings {
+			got[h.Ticker] = true
+		}
+		return got
+	}
+
+	// An insert that fails, here on a repeated ID, leaves the account alone
+	dup := models.NewHolding(portfolio.ID, "VOO", "Test", "IRA")
+	if err := repo.ReplaceAccount(portfolio.ID, "IRA", []models.Holding{*dup, *dup}); err == nil {
+		t.Fatal("Expected the repeated ID to fail")
+	}
+	if got := tickers(); len(got) != 2 || !got["VTI"] || !got["BND"] {
+		t.Errorf("After a failed replace: got %v, want VTI and BND", got)
+	}
+
+	if err := repo.ReplaceAccount(portfolio.ID, "IRA", []models.Holding{*dup}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	if got := tickers(); len(got) != 2 || !got["VOO"] || !got["BND"] {
+		t.Errorf("After replacing the IRA: got %v, want VOO and BND", got)
+	}
+}
+
+func TestPortfolioRepository_GetByUserID_Pages(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewPortfolioRepository(db)
+	first := createTestPortfolio(t, db, "portfolios@example.com")
+
+	for i := 0; i < 2; i++ {
+		if err := repo.Create(models.NewPortfolio(first.UserID, fmt.Sprintf("Extra %d", i))); err != nil {
+			t.Fatalf("Failed to create portfolio: %v", err)
+		}
+	}
+
+	page, total, err := repo.GetByUserID(first.UserID, Page{Limit: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if total != 3 || len(page) != 2 {
+		t.Errorf("Got %d of %d portfolios, want 2 of 3", len(page), total)
+	}
+
+	rest, _, err := repo.GetByUserID(first.UserID, Page{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rest) != 1 {
+		t.Errorf("Second page: got %d portfolios, want 1", len(rest))
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	tests := []struct {
+		limit, offset int
+		want          Page
+	}{
+		{0, 0, Page{Limit: DefaultPageSize}},
+		{10, 20, Page{Limit: 10, Offset: 20}},
+		{10000, -5, Page{Limit: MaxPageSize}},
+	}
+
+	for _, tt := range tests {
+		if got := NewPage(tt.limit, tt.offset); got != tt.want {
+			t.Errorf("NewPage(%d, %d) = %+v, want %+v", tt.limit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestMigrate_AddsColumnsToExistingDatabase(t *testing.T) {
+	db := newTestDB(t)
+	portfolio := createTestPortfolio(t, db, "legacy@example.com")
+
+	// Simulate a database created before target scenarios existed, which
+	// also predates migration versioning
+	if _, err := db.Exec("ALTER TABLE portfolios DROP COLUMN target_scenario_id"); err != nil {
+		t.Fatalf("Failed to drop column: %v", err)
+	}
+	if _, err := db.Exec("DROP TABLE schema_migrations"); err != nil {
+		t.Fatalf("Failed to drop schema_migrations: %v", err)
+	}
+
+	// Migrating twice must add the column once and then leave it alone
+	for i := 0; i < 2; i++ {
+		if err := db.Migrate(); err != nil {
+			t.Fatalf("Migrate run %d: %v", i+1, err)
+		}
+	}
+
+	loaded, err := NewPortfolioRepository(db).GetByID(portfolio.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("Failed to load portfolio after migration: %v", err)
+	}
+	if loaded.TargetScenarioID != nil {
+		t.Errorf("TargetScenarioID: got %s, want none", loaded.TargetScenarioID)
+	}
+}
+
+func TestHoldingRepository_Currency(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHoldingRepository(db)
+	portfolio := createTestPortfolio(t, db, "fx@example.com")
+
+	euro := models.NewHolding(portfolio.ID, "SAP.DE", "SAP SE", "Brokerage")
+	euro.Currency = "EUR"
+	if err := repo.Create(euro); err != nil {
+		t.Fatalf("Failed to create holding: %v", err)
+	}
+
+	// Rows written before currencies were tracked read back as USD
+	legacy := models.NewHolding(portfolio.ID, "VTI", "Vanguard Total Stock", "Brokerage")
+	if err := repo.Create(legacy); err != nil {
+		t.Fatalf("Failed to create holding: %v", err)
+	}
+	if _, err := db.Exec("UPDATE holdings SET currency = NULL WHERE id = ?", legacy.ID.String()); err != nil {
+		t.Fatalf("Failed to clear currency: %v", err)
+	}
+
+	loaded, err := NewPortfolioRepository(db).GetByID(portfolio.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("Failed to load portfolio: %v", err)
+	}
+	if loaded.Currency != models.DefaultCurrency {
+		t.Errorf("Portfolio currency: got %q, want %q", loaded.Currency, models.DefaultCurrency)
+	}
+	want := map[string]string{"SAP.DE": "EUR", "VTI": "USD"}
+	for _, h := range loaded.Holdings {
+		if h.Currency != want[h.Ticker] {
+			t.Errorf("%s currency: got %q, want %q", h.Ticker, h.Currency, want[h.Ticker])
+		}
+	}
+}
+
+func TestHoldingLotRepository(t *testing.T) {
+	db := newTestDB(t)
+	holdings := NewHoldingRepository(db)
+	lots := NewHoldingLotRepository(db)
+	portfolio := createTestPortfolio(t, db, "lots@example.com")
+
+	older := time.Date(2020, 3, 16, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	h := models.NewHolding(portfolio.ID, "VTI", "Vanguard Total Stock", "Brokerage")
+	h.Quantity = decimal.NewFromInt(15)
+	h.CostBasis = decimal.NewFromInt(3500)
+	h.Lots = []models.HoldingLot{
+		*models.NewHoldingLot(uuid.Nil, decimal.NewFromInt(5), decimal.NewFromInt(1000), newer),
+		*models.NewHoldingLot(uuid.Nil, decimal.NewFromInt(10), decimal.NewFromInt(2500), older),
+	}
+	if err := holdings.CreateBatch([]models.Holding{*h}); err != nil {
+		t.Fatalf("Failed to create holding: %v", err)
+	}
+
+	loaded, err := NewPortfolioRepository(db).GetByID(portfolio.ID)
+	if err != nil || loaded == nil || len(loaded.Holdings) != 1 {
+		t.Fatalf("Failed to load portfolio: %v", err)
+	}
+	got := loaded.Holdings[0].Lots
+	if len(got) != 2 {
+		t.Fatalf("Lots: got %d, want 2", len(got))
+	}
+	if !got[0].AcquiredAt.Equal(older) || got[0].HoldingID != h.ID {
+		t.Errorf("First lot: got %s for %s, want the oldest lot for %s", got[0].AcquiredAt, got[0].HoldingID, h.ID)
+	}
+	if !loaded.Holdings[0].TotalCostBasis().Equal(decimal.NewFromInt(3500)) {
+		t.Errorf("Total cost basis: got %s, want 3500", loaded.Holdings[0].TotalCostBasis())
+	}
+
+	replacement := []models.HoldingLot{*models.NewHoldingLot(h.ID, decimal.NewFromInt(15), decimal.NewFromInt(3600), older)}
+	if err := lots.ReplaceForHolding(h.ID, replacement); err != nil {
+		t.Fatalf("Failed to replace lots: %v", err)
+	}
+	got, err = lots.GetByHoldingID(h.ID)
+	if err != nil || len(got) != 1 || !got[0].CostBasis.Equal(decimal.NewFromInt(3600)) {
+		t.Fatalf("After replace: got %+v, %v", got, err)
+	}
+
+	// Lots go with their holding
+	if err := holdings.Purge(h.ID); err != nil {
+		t.Fatalf("Failed to purge holding: %v", err)
+	}
+	byPortfolio, err := lots.GetByPortfolioID(portfolio.ID)
+	if err != nil || len(byPortfolio) != 0 {
+		t.Errorf("Expected no lots after deleting the holding, got %d", len(byPortfolio))
+	}
+}
+
+func TestHoldingRepository_History(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewHoldingRepository(db)
+	portfolio := createTestPortfolio(t, db, "history@example.com")
+
+	h := models.NewHolding(portfolio.ID, "VTI", "Vanguard Total Stock", "Brokerage")
+	h.CurrentPrice = decimal.NewFromInt(250)
+	if err := repo.Create(h); err != nil {
+		t.Fatalf("Failed to create holding: %v", err)
+	}
+
+	// Off by default
+	h.CurrentPrice = decimal.NewFromInt(255)
+	if err := repo.Update(h); err != nil {
+		t.Fatalf("Failed to update holding: %v", err)
+	}
+	if changes, _ := repo.GetHistory(h.ID); len(changes) != 0 {
+		t.Fatalf("Expected no history while disabled, got %d", len(changes))
+	}
+
+	repo.EnableHistory()
+	h.CurrentPrice = decimal.NewFromInt(260)
+	h.AssetClass = models.AssetClassEquity
+	if err := repo.Update(h); err != nil {
+		t.Fatalf("Failed to update holding: %v", err)
+	}
+	// A refresh at the same price only moves PricedAt, so nothing to record
+	h.PricedAt = time.Now().UTC().Truncate(time.Second)
+	if err := repo.Update(h); err != nil {
+		t.Fatalf("Failed to update holding: %v", err)
+	}
+	if loaded, _ := repo.GetByID(h.ID); loaded == nil || !loaded.PricedAt.Equal(h.PricedAt) {
+		t.Errorf("PricedAt should be stored, got %v", loaded)
+	}
+
+	changes, err := repo.GetHistory(h.ID)
+	if err != nil {
+		t.Fatalf("Failed to get history: %v", err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("History: got %d changes, want 1", len(changes))
+	}
+	c := changes[0]
+	if !c.Before.CurrentPrice.Equal(decimal.NewFromInt(255)) || !c.After.CurrentPrice.Equal(decimal.NewFromInt(260)) {
+		t.Errorf("Price: got %s -> %s, want 255 -> 260", c.Before.CurrentPrice, c.After.CurrentPrice)
+	}
+	if c.Before.AssetClass != models.AssetClassOther || c.After.AssetClass != models.AssetClassEquity {
+		t.Errorf("Asset class: got %s -> %s, want other -> equity", c.Before.AssetClass, c.After.AssetClass)
+	}
+}
+
+func TestPortfolioRepository_SoftDelete(t *testing.T) {
+	db := newTestDB(t)
+	portfolios := NewPortfolioRepository(db)
+	holdings := NewHoldingRepository(db)
+	scenarios := NewScenarioRepository(db)
+
+	portfolio := createTestPortfolio(t, db, "softdelete@example.com")
+	kept := models.NewPortfolio(portfolio.UserID, "Kept")
+	if err := portfolios.Create(kept); err != nil {
+		t.Fatalf("Failed to create portfolio: %v", err)
+	}
+
+	var holdingIDs []uuid.UUID
+	for _, ticker := range []string{"VTI", "BND"} {
+		h := models.NewHolding(portfolio.ID, ticker, ticker, "Brokerage")
+		if err := holdings.Create(h); err != nil {
+			t.Fatalf("Failed to create holding: %v", err)
+		}
+		holdingIDs = append(holdingIDs, h.ID)
+	}
+	scenario := models.NewScenario(portfolio.ID, "Target")
+	if err := scenarios.Create(scenario); err != nil {
+		t.Fatalf("Failed to create scenario: %v", err)
+	}
+
+	// A holding deleted on its own stays deleted when the portfolio is restored
+	if err := holdings.Delete(holdingIDs[1]); err != nil {
+		t.Fatalf("Failed to delete holding: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	if err := portfolios.Delete(portfolio.ID); err != nil {
+		t.Fatalf("Failed to delete portfolio: %v", err)
+	}
+
+	list, total, err := portfolios.GetByUserID(portfolio.UserID, Page{})
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if total != 1 || len(list) != 1 || list[0].ID != kept.ID {
+		t.Fatalf("GetByUserID: got %d of %d, want only the kept portfolio", len(list), total)
+	}
+	if p, _ := portfolios.GetByID(portfolio.ID); p != nil {
+		t.Error("Deleted portfolio should not be found by ID")
+	}
+	if h, _ := holdings.GetByID(holdingIDs[0]); h != nil {
+		t.Error("Holdings should be deleted with their portfolio")
+	}
+	if s, _ := scenarios.GetByID(scenario.ID); s != nil {
+		t.Error("Scenarios should be deleted with their portfolio")
+	}
+
+	if err := portfolios.Restore(portfolio.ID); err != nil {
+		t.Fatalf("Failed to restore portfolio: %v", err)
+	}
+	restored, err := portfolios.GetByID(portfolio.ID)
+	if err != nil || restored == nil {
+		t.Fatalf("Restored portfolio not found: %v", err)
+	}
+	if len(restored.Holdings) != 1 || restored.Holdings[0].ID != holdingIDs[0] {
+		t.Errorf("Restored holdings: got %d, want only the one deleted with the portfolio", len(restored.Holdings))
+	}
+	if s, _ := scenarios.GetByID(scenario.ID); s == nil {
+		t.Error("Scenario should be restored with its portfolio")
+	}
+
+	if err := portfolios.Purge(portfolio.ID); err != nil {
+		t.Fatalf("Failed to purge portfolio: %v", err)
+	}
+	var remaining int
+	if err := db.QueryRow("SELECT COUNT(*) FROM holdings WHERE portfolio_id = ?", portfolio.ID.String()).Scan(&remaining); err != nil {
+		t.Fatalf("Failed to count holdings: %v", err)
+	}
+	if remaining != 0 {
+		t.Errorf("Purge should remove every holding, %d left", remaining)
+	}
+}
+
+func TestPortfolioRepository_GetSummary(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewPortfolioRepository(db)
+	portfolio := createLargePortfolio(t, db, 3)
+
+	summary, err := repo.GetSummary(portfolio.ID)
+	if err != nil || summary == nil {
+		t.Fatalf("Failed to load summary: %v", err)
+	}
+	if len(summary.Holdings) != 0 {
+		t.Errorf("Summary should not load holdings, got %d", len(summary.Holdings))
+	}
+	if !summary.TotalValue.Equal(portfolio.TotalValue) || summary.UserID != portfolio.UserID {
+		t.Errorf("Summary: got total %s for user %s, want %s for %s", summary.TotalValue, summary.UserID, portfolio.TotalValue, portfolio.UserID)
+	}
+
+	if err := repo.Delete(portfolio.ID); err != nil {
+		t.Fatalf("Failed to delete portfolio: %v", err)
+	}
+	if p, _ := repo.GetSummary(portfolio.ID); p != nil {
+		t.Error("Deleted portfolio should not be found by GetSummary")
+	}
+}
+
+// createLargePortfolio inserts a portfolio with n holdings and persists
+// its totals
+func createLargePortfolio(t testing.TB, db *DB, n int) *models.Portfolio {
+	t.Helper()
+
+	portfolio := createTestPortfolio(t, db, fmt.Sprintf("large%d@example.com", n))
+	for i := 0; i < n; i++ {
+		h := models.NewHolding(portfolio.ID, fmt.Sprintf("T%d", i), "Test", "Brokerage")
+		h.Quantity = decimal.NewFromInt(10)
+		h.CurrentPrice = decimal.NewFromInt(int64(i + 1))
+		h.MarketValue = h.Quantity.Mul(h.CurrentPrice)
+		portfolio.Holdings = append(portfolio.Holdings, *h)
+	}
+	if err := NewHoldingRepository(db).CreateBatch(portfolio.Holdings); err != nil {
+		t.Fatalf("Failed to create holdings: %v", err)
+	}
+
+	portfolio.CalculateTotals()
+	if err := NewPortfolioRepository(db).Update(portfolio); err != nil {
+		t.Fatalf("Failed to update portfolio: %v", err)
+	}
+	return portfolio
+}
+
+// BenchmarkPortfolioRepository compares loading a 300-holding portfolio in
+// full with loading only its summary
+func BenchmarkPortfolioRepository(b *testing.B) {
+	db := newTestDB(b)
+	repo := NewPortfolioRepository(db)
+	portfolio := createLargePortfolio(b, db, 300)
+
+	b.Run("GetByID", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetByID(portfolio.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("GetSummary", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.GetSummary(portfolio.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestShareRepository(t *testing.T) {
+	db := newTestDB(t)
+	shares := NewShareRepository(db)
+	portfolios := NewPortfolioRepository(db)
+
+	portfolio := createTestPortfolio(t, db, "owner@example.com")
+	other := createTestPortfolio(t, db, "collaborator@example.com")
+	collaborator := other.UserID
+
+	if role, err := shares.GetRole(portfolio.ID, collaborator); err != nil || role != "" {
+		t.Fatalf("GetRole before sharing: got %q, %v", role, err)
+	}
+
+	share := &models.PortfolioShare{PortfolioID: portfolio.ID, UserID: collaborator, Role: models.ShareRoleViewer}
+	if err := shares.Upsert(share); err != nil {
+		t.Fatalf("Upsert: %v", err)
+	}
+	share.Role = models.ShareRoleEditor
+	if err := shares.Upsert(share); err != nil {
+		t.Fatalf("Upsert again: %v", err)
+	}
+
+	if role, err := shares.GetRole(portfolio.ID, collaborator); err != nil || role != models.ShareRoleEditor {
+		t.Errorf("GetRole: got %q, %v, want editor", role, err)
+	}
+
+	list, err := shares.GetByPortfolioID(portfolio.ID)
+	if err != nil {
+		t.Fatalf("GetByPortfolioID: %v", err)
+	}
+	if len(list) != 1 || list[0].Email != "collaborator@example.com" || list[0].Role != models.ShareRoleEditor {
+		t.Errorf("GetByPortfolioID: got %+v", list)
+	}
+
+	shared, err := portfolios.GetSharedWithUser(collaborator)
+	if err != nil {
+		t.Fatalf("GetSharedWithUser: %v", err)
+	}
+	if len(shared) != 1 || shared[0].ID != portfolio.ID {
+		t.Errorf("GetSharedWithUser: got %d portfolios, want the shared one", len(shared))
+	}
+
+	// Deleted portfolios drop out of the shared list
+	if err := portfolios.Delete(portfolio.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if shared, _ := portfolios.GetSharedWithUser(collaborator); len(shared) != 0 {
+		t.Errorf("GetSharedWithUser after delete: got %d portfolios, want 0", len(shared))
+	}
+	if err := portfolios.Restore(portfolio.ID); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if err := shares.Delete(portfolio.ID, collaborator); err != nil {
+		t.Fatalf("Delete share: %v", err)
+	}
+	if role, _ := shares.GetRole(portfolio.ID, collaborator); role != "" {
+		t.Errorf("GetRole after revoke: got %q, want none", role)
+	}
+}
+
+func TestUserRepository_Encryption(t *testing.T) {
+	db := newTestDB(t)
+
+	// A secret stored before encryption was turned on
+	legacy := models.NewUser("legacy@example.com", "Legacy", "hash")
+	legacy.MFASecret = "JBSWY3DPEHPK3PXP"
+	if err := NewUserRepository(db).Create(legacy); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	users := NewUserRepository(db)
+	if err := users.EnableEncryption("test-key"); err != nil {
+		t.Fatalf("EnableEncryption: %v", err)
+	}
+
+	user := models.NewUser("mfa@example.com", "MFA", "hash")
+	user.MFASecret = "KRSXG5CTMVRXEZLU"
+	if err := users.Create(user); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	stored := func(id uuid.UUID) string {
+		var secret string
+		if err := db.QueryRow("SELECT mfa_secret FROM users WHERE id = ?", id.String()).Scan(&secret); err != nil {
+			t.Fatalf("Failed to read column: %v", err)
+		}
+		return secret
+	}
+	if raw := stored(user.ID); !isEncrypted(raw) || raw == user.MFASecret {
+		t.Errorf("Stored secret should be encrypted, got %q", raw)
+	}
+	if got, err := users.GetByID(user.ID); err != nil || got.MFASecret != "KRSXG5CTMVRXEZLU" {
+		t.Errorf("GetByID: got %q, %v", got.MFASecret, err)
+	}
+
+	// Plaintext is still readable, then converted in place
+	if got, err := users.GetByEmail("legacy@example.com"); err != nil || got.MFASecret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Legacy GetByEmail: got %q, %v", got.MFASecret, err)
+	}
+	if n, err := users.EncryptExisting(); err != nil || n != 1 {
+		t.Fatalf("EncryptExisting: got %d, %v, want 1", n, err)
+	}
+	if n, _ := users.EncryptExisting(); n != 0 {
+		t.Errorf("EncryptExisting again: got %d, want 0", n)
+	}
+	if raw := stored(legacy.ID); !isEncrypted(raw) {
+		t.Errorf("Legacy secret should be encrypted, got %q", raw)
+	}
+	if got, _ := users.GetByID(legacy.ID); got.MFASecret != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("Legacy after encrypting: got %q", got.MFASecret)
+	}
+
+	// The wrong key can't read it
+	wrong := NewUserRepository(db)
+	wrong.EnableEncryption("other-key")
+	if _, err := wrong.GetByID(user.ID); err == nil {
+		t.Error("Reading with the wrong key should fail")
+	}
+}
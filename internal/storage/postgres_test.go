@@ -0,0 +1,164 @@
+//go:build postgres
+
+package storage
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Run with a disposable database:
+//
+//	TRUENORTH_TEST_POSTGRES_URL=postgres://localhost/truenorth_test?sslmode=disable \
+//		go test -tags postgres ./internal/storage/
+//
+// The tests drop and recreate every table.
+func newPostgresTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	url := os.Getenv("TRUENORTH_TEST_POSTGRES_URL")
+	if url == "" {
+		t.Skip("TRUENORTH_TEST_POSTGRES_URL not set")
+	}
+
+	db, err := New(url)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if db.Dialect != DialectPostgres {
+		t.Fatalf("Expected postgres dialect, got %s", db.Dialect)
+	}
+
+	for _, table := range []string{"schema_migrations", "transactions", "digest_alerts", "digest_subscriptions", "alert_states", "email_verifications", "portfolio_shares", "notified_alerts", "webhook_failures", "webhooks", "ticker_overrides", "sessions", "scenarios", "holding_history", "holding_lots", "holdings", "portfolios", "users"} {
+		if _, err := db.Exec("DROP TABLE IF EXISTS " + table + " CASCADE"); err != nil {
+			t.Fatalf("Failed to drop %s: %v", table, err)
+		}
+	}
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+func TestPostgres_Repositories(t *testing.T) {
+	db := newPostgresTestDB(t)
+
+	// Users
+	users := NewUserRepository(db)
+	user := models.NewUser("pg@example.com", "Postgres User", "hash")
+	if err := users.Create(user); err != nil {
+		t.Fatalf("Create user: %v", err)
+	}
+	if got, err := users.GetByEmail("pg@example.com"); err != nil || got == nil || got.ID != user.ID {
+		t.Fatalf("GetByEmail: got %v, %v", got, err)
+	}
+	if exists, err := users.EmailExists("pg@example.com"); err != nil || !exists {
+		t.Errorf("EmailExists: got %v, %v", exists, err)
+	}
+
+	// Sessions
+	sessions := NewSessionRepository(db)
+	session := &models.Session{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Token:     "token",
+		ExpiresAt: time.Now().UTC().Add(time.Hour),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := sessions.Create(session); err != nil {
+		t.Fatalf("Create session: %v", err)
+	}
+	if got, err := sessions.GetByToken("token"); err != nil || got == nil || got.UserID != user.ID {
+		t.Fatalf("GetByToken: got %v, %v", got, err)
+	}
+	if err := sessions.DeleteExpired(); err != nil {
+		t.Errorf("DeleteExpired: %v", err)
+	}
+
+	// Portfolios
+	portfolios := NewPortfolioRepository(db)
+	portfolio := models.NewPortfolio(user.ID, "Retirement")
+	if err := portfolios.Create(portfolio); err != nil {
+		t.Fatalf("Create portfolio: %v", err)
+	}
+
+	// Holdings
+	holdings := NewHoldingRepository(db)
+	batch := []models.Holding{
+		*models.NewHolding(portfolio.ID, "VOO", "Vanguard S&P 500 ETF", "IRA"),
+		*models.NewHolding(portfolio.ID, "BND", "Vanguard Total Bond Market ETF", "IRA"),
+	}
+	batch[0].MarketValue = decimal.NewFromInt(6000)
+	batch[1].MarketValue = decimal.NewFromInt(4000)
+	batch[1].IsManualEntry = true
+	if err := holdings.CreateBatch(batch); err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	loaded, err := portfolios.GetByID(portfolio.ID)
+	if err != nil || loaded == nil {
+		t.Fatalf("GetByID portfolio: %v", err)
+	}
+	if len(loaded.Holdings) != 2 {
+		t.Fatalf("Expected 2 holdings, got %d", len(loaded.Holdings))
+	}
+
+	held, err := holdings.GetByID(batch[1].ID)
+	if err != nil || held == nil || !held.IsManualEntry {
+		t.Fatalf("GetByID holding: got %v, %v", held, err)
+	}
+
+	// Scenarios
+	scenarios := NewScenarioRepository(db)
+	scenario := models.NewScenario(portfolio.ID, "60/40")
+	scenario.SetAllocation(models.AssetClassEquity, decimal.NewFromInt(60))
+	scenario.SetAllocation(models.AssetClassFixedIncome, decimal.NewFromInt(40))
+	if err := scenarios.Create(scenario); err != nil {
+		t.Fatalf("Create scenario: %v", err)
+	}
+	scenario.Name = "Balanced"
+	if err := scenarios.Update(scenario); err != nil {
+		t.Fatalf("Update scenario: %v", err)
+	}
+	if got, err := scenarios.GetByID(scenario.ID); err != nil || got == nil || got.Name != "Balanced" {
+		t.Fatalf("GetByID scenario: got %v, %v", got, err)
+	}
+
+	// Ticker overrides use ON CONFLICT, which both databases support
+	overrides := NewTickerOverrideRepository(db)
+	override := &models.TickerOverride{UserID: user.ID, Ticker: "ZZZ", AssetClass: models.AssetClassAlternative}
+	if err := overrides.Upsert(override); err != nil {
+		t.Fatalf("Upsert override: %v", err)
+	}
+	override.AssetClass = models.AssetClassCrypto
+	if err := overrides.Upsert(override); err != nil {
+		t.Fatalf("Upsert override again: %v", err)
+	}
+	if got, err := overrides.GetByUserID(user.ID); err != nil || got["ZZZ"].AssetClass != models.AssetClassCrypto {
+		t.Errorf("GetByUserID overrides: got %v, %v", got, err)
+	}
+
+	// Cascading soft delete, restore, then purge
+	if err := portfolios.Delete(portfolio.ID); err != nil {
+		t.Fatalf("Delete portfolio: %v", err)
+	}
+	if got, _ := holdings.GetByID(batch[0].ID); got != nil {
+		t.Error("Expected holdings to be deleted with their portfolio")
+	}
+	if err := portfolios.Restore(portfolio.ID); err != nil {
+		t.Fatalf("Restore portfolio: %v", err)
+	}
+	if got, _ := holdings.GetByID(batch[0].ID); got == nil {
+		t.Error("Expected holdings to be restored with their portfolio")
+	}
+	if err := portfolios.Purge(portfolio.ID); err != nil {
+		t.Fatalf("Purge portfolio: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// ShareRepository provides access to portfolios shared with other users
+type ShareRepository struct {
+	db *DB
+}
+
+// NewShareRepository creates a new share repository
+func NewShareRepository(db *DB) *ShareRepository {
+	return &ShareRepository{db: db}
+}
+
+// Upsert shares a portfolio with a user, or changes their role if it's
+// already shared with them
+func (r *ShareRepository) Upsert(s *models.PortfolioShare) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now().UTC()
+	}
+
+	query := `
+		INSERT INTO portfolio_shares (portfolio_id, user_id, role, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (portfolio_id, user_id) DO UPDATE SET role = excluded.role
+	`
+	_, err := r.db.Exec(query,
+		s.PortfolioID.String(),
+		s.UserID.String(),
+		string(s.Role),
+		s.CreatedAt,
+	)
+	return err
+}
+
+// GetRole returns the user's role on a portfolio, or "" if it isn't shared
+// with them
+func (r *ShareRepository) GetRole(portfolioID, userID uuid.UUID) (models.ShareRole, error) {
+	rows, err := r.db.Query(
+		"SELECT role FROM portfolio_shares WHERE portfolio_id = ? AND user_id = ?",
+		portfolioID.String(), userID.String(),
+	)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return "", rows.Err()
+	}
+	var role string
+	if err := rows.Scan(&role); err != nil {
+		return "", err
+	}
+	return models.ShareRole(role), nil
+}
+
+// GetByPortfolioID lists who a portfolio is shared with, oldest first
+func (r *ShareRepository) GetByPortfolioID(portfolioID uuid.UUID) ([]models.PortfolioShare, error) {
+	query := `
+		SELECT s.user_id, u.email, u.name, s.role, s.created_at
+		FROM portfolio_shares s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.portfolio_id = ?
+		ORDER BY s.created_at, u.email
+	`
+	rows, err := r.db.Query(query, portfolioID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []models.PortfolioShare
+	for rows.Next() {
+		s := models.PortfolioShare{PortfolioID: portfolioID}
+		var userID, role string
+		if err := rows.Scan(&userID, &s.Email, &s.Name, &role, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		s.UserID, _ = uuid.Parse(userID)
+		s.Role = models.ShareRole(role)
+		shares = append(shares, s)
+	}
+
+	return shares, rows.Err()
+}
+
+// Delete stops sharing a portfolio with a user
+func (r *ShareRepository) Delete(portfolioID, userID uuid.UUID) error {
+	_, err := r.db.Exec(
+		"DELETE FROM portfolio_shares WHERE portfolio_id = ? AND user_id = ?",
+		portfolioID.String(), userID.String(),
+	)
+	return err
+}
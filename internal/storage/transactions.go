@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// TransactionRepository provides access to portfolios' buys and sells
+type TransactionRepository struct {
+	db *DB
+}
+
+// NewTransactionRepository creates a new transaction repository
+func NewTransactionRepository(db *DB) *TransactionRepository {
+	return &TransactionRepository{db: db}
+}
+
+// Create inserts a new transaction
+func (r *TransactionRepository) Create(t *models.Transaction) error {
+	query := `
+		INSERT INTO transactions (id, portfolio_id, ticker, account_name, tx_type, quantity, price, fees, traded_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query,
+		t.ID.String(),
+		t.PortfolioID.String(),
+		t.Ticker,
+		t.AccountName,
+		string(t.Type),
+		t.Quantity.String(),
+		t.Price.String(),
+		t.Fees.String(),
+		t.Date,
+		t.CreatedAt,
+	)
+	return err
+}
+
+// GetByPortfolioID retrieves a portfolio's transactions, oldest first
+func (r *TransactionRepository) GetByPortfolioID(portfolioID uuid.UUID) ([]models.Transaction, error) {
+	query := `
+		SELECT id, portfolio_id, ticker, account_name, tx_type, quantity, price, fees, traded_at, created_at
+		FROM transactions WHERE portfolio_id = ? ORDER BY traded_at, created_at
+	`
+	rows, err := r.db.Query(query, portfolioID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		t, err := scanTransactionRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+
+	return transactions, rows.Err()
+}
+
+// Delete removes one of a portfolio's transactions
+func (r *TransactionRepository) Delete(portfolioID, id uuid.UUID) error {
+	_, err := r.db.Exec(
+		"DELETE FROM transactions WHERE id = ? AND portfolio_id = ?",
+		id.String(), portfolioID.String(),
+	)
+	return err
+}
+
+func scanTransactionRow(rows *sql.Rows) (*models.Transaction, error) {
+	var t models.Transaction
+	var id, portfolioID, txType, quantity, price, fees string
+
+	err := rows.Scan(&id, &portfolioID, &t.Ticker, &t.AccountName, &txType,
+		&quantity, &price, &fees, &t.Date, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	t.ID, _ = uuid.Parse(id)
+	t.PortfolioID, _ = uuid.Parse(portfolioID)
+	t.Type = models.TransactionType(txType)
+	t.Quantity, _ = decimal.NewFromString(quantity)
+	t.Price, _ = decimal.NewFromString(price)
+	t.Fees, _ = decimal.NewFromString(fees)
+
+	return &t, nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,7 +12,8 @@ import (
 
 // UserRepository provides user data access
 type UserRepository struct {
-	db *DB
+	db     *DB
+	cipher *fieldCipher // Encrypts sensitive columns when set
 }
 
 // NewUserRepository creates a new user repository
@@ -19,19 +21,86 @@ func NewUserRepository(db *DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+// EnableEncryption encrypts sensitive columns, currently the MFA secret,
+// with AES-GCM using a key derived from the given string. Values stored in
+// plaintext before it was enabled are still read; EncryptExisting converts
+// them.
+func (r *UserRepository) EnableEncryption(key string) error {
+	c, err := newFieldCipher(key)
+	if err != nil {
+		return err
+	}
+	r.cipher = c
+	return nil
+}
+
+// EncryptExisting encrypts sensitive values stored in plaintext, returning
+// how many users were updated. It's safe to run on every startup.
+func (r *UserRepository) EncryptExisting() (int, error) {
+	if r.cipher == nil {
+		return 0, errors.New("encryption is not enabled")
+	}
+
+	rows, err := r.db.Query("SELECT id, mfa_secret FROM users WHERE mfa_secret IS NOT NULL AND mfa_secret <> ''")
+	if err != nil {
+		return 0, err
+	}
+	plaintext := make(map[string]string)
+	for rows.Next() {
+		var id, secret string
+		if err := rows.Scan(&id, &secret); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !isEncrypted(secret) {
+			plaintext[id] = secret
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, secret := range plaintext {
+		sealed, err := r.cipher.encrypt(secret)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := r.db.Exec("UPDATE users SET mfa_secret = ? WHERE id = ?", sealed, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(plaintext), nil
+}
+
+// sealSecret encrypts a sensitive value for storage when encryption is on
+func (r *UserRepository) sealSecret(value string) (string, error) {
+	if r.cipher == nil {
+		return value, nil
+	}
+	return r.cipher.encrypt(value)
+}
+
 // Create inserts a new user
 func (r *UserRepository) Create(user *models.User) error {
+	mfaSecret, err := r.sealSecret(user.MFASecret)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
 	query := `
-		INSERT INTO users (id, email, password_hash, name, mfa_enabled, mfa_secret, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO users (id, email, password_hash, name, mfa_enabled, mfa_secret, auth_provider, verified, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.Exec(query,
+	_, err = r.db.Exec(query,
 		user.ID.String(),
 		user.Email,
 		user.PasswordHash,
 		user.Name,
 		user.MFAEnabled,
-		user.MFASecret,
+		mfaSecret,
+		user.AuthProvider,
+		user.Verified,
 		user.CreatedAt,
 		user.UpdatedAt,
 	)
@@ -44,7 +113,7 @@ func (r *UserRepository) Create(user *models.User) error {
 // GetByID retrieves a user by ID
 func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, mfa_enabled, mfa_secret, created_at, updated_at
+		SELECT id, email, password_hash, name, mfa_enabled, mfa_secret, auth_provider, verified, created_at, updated_at
 		FROM users WHERE id = ?
 	`
 	return r.scanUser(r.db.QueryRow(query, id.String()))
@@ -53,7 +122,7 @@ func (r *UserRepository) GetByID(id uuid.UUID) (*models.User, error) {
 // GetByEmail retrieves a user by email
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, mfa_enabled, mfa_secret, created_at, updated_at
+		SELECT id, email, password_hash, name, mfa_enabled, mfa_secret, auth_provider, verified, created_at, updated_at
 		FROM users WHERE email = ?
 	`
 	return r.scanUser(r.db.QueryRow(query, email))
@@ -61,22 +130,42 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 
 // Update modifies an existing user
 func (r *UserRepository) Update(user *models.User) error {
+	mfaSecret, err := r.sealSecret(user.MFASecret)
+	if err != nil {
+		return err
+	}
+
 	user.UpdatedAt = time.Now().UTC()
 	query := `
 		UPDATE users SET email = ?, name = ?, mfa_enabled = ?, mfa_secret = ?, updated_at = ?
 		WHERE id = ?
 	`
-	_, err := r.db.Exec(query,
+	_, err = r.db.Exec(query,
 		user.Email,
 		user.Name,
 		user.MFAEnabled,
-		user.MFASecret,
+		mfaSecret,
 		user.UpdatedAt,
 		user.ID.String(),
 	)
 	return err
 }
 
+// MarkVerified records that a user has confirmed their email address
+func (r *UserRepository) MarkVerified(id uuid.UUID) error {
+	_, err := r.db.Exec("UPDATE users SET verified = ?, updated_at = ? WHERE id = ?", true, time.Now().UTC(), id.String())
+	return err
+}
+
+// ClaimForProvider hands an unverified account to an identity provider
+// that has verified its email: the password is removed and the account
+// marked verified
+func (r *UserRepository) ClaimForProvider(id uuid.UUID, provider string) error {
+	_, err := r.db.Exec("UPDATE users SET password_hash = ?, auth_provider = ?, verified = ?, updated_at = ? WHERE id = ?",
+		"", provider, true, time.Now().UTC(), id.String())
+	return err
+}
+
 // Delete removes a user
 func (r *UserRepository) Delete(id uuid.UUID) error {
 	_, err := r.db.Exec("DELETE FROM users WHERE id = ?", id.String())
@@ -93,7 +182,7 @@ func (r *UserRepository) EmailExists(email string) (bool, error) {
 func (r *UserRepository) scanUser(row *sql.Row) (*models.User, error) {
 	var user models.User
 	var id string
-	var mfaSecret sql.NullString
+	var mfaSecret, authProvider sql.NullString
 
 	err := row.Scan(
 		&id,
@@ -102,6 +191,8 @@ func (r *UserRepository) scanUser(row *sql.Row) (*models.User, error) {
 		&user.Name,
 		&user.MFAEnabled,
 		&mfaSecret,
+		&authProvider,
+		&user.Verified,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -113,8 +204,17 @@ func (r *UserRepository) scanUser(row *sql.Row) (*models.User, error) {
 	}
 
 	user.ID, _ = uuid.Parse(id)
+	user.AuthProvider = models.AuthProviderPassword
+	if authProvider.Valid && authProvider.String != "" {
+		user.AuthProvider = authProvider.String
+	}
 	if mfaSecret.Valid {
 		user.MFASecret = mfaSecret.String
+		if r.cipher != nil {
+			if user.MFASecret, err = r.cipher.decrypt(mfaSecret.String); err != nil {
+				return nil, fmt.Errorf("failed to read user: %w", err)
+			}
+		}
 	}
 
 	return &user, nil
@@ -175,6 +275,34 @@ func (r *SessionRepository) GetByToken(token string) (*models.Session, error) {
 	return &session, nil
 }
 
+// ExpireAt shortens a session so it ends at the given time, reporting
+// whether it did; a session already ending by then is left alone
+func (r *SessionRepository) ExpireAt(id uuid.UUID, at time.Time) (bool, error) {
+	result, err := r.db.Exec("UPDATE sessions SET expires_at = ? WHERE id = ? AND expires_at > ?", at, id.String(), at)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Delete removes a session
+func (r *SessionRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM sessions WHERE id = ?", id.String())
+	return err
+}
+
+// Consume removes a session, reporting whether this call removed it rather
+// than a concurrent one
+func (r *SessionRepository) Consume(id uuid.UUID) (bool, error) {
+	result, err := r.db.Exec("DELETE FROM sessions WHERE id = ?", id.String())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
 // DeleteByUserID removes all sessions for a user
 func (r *SessionRepository) DeleteByUserID(userID uuid.UUID) error {
 	_, err := r.db.Exec("DELETE FROM sessions WHERE user_id = ?", userID.String())
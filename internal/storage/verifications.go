@@ -0,0 +1,53 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailVerificationRepository stores outstanding email verification tokens.
+// Only a hash of each token is kept.
+type EmailVerificationRepository struct {
+	db *DB
+}
+
+// NewEmailVerificationRepository creates a new email verification repository
+func NewEmailVerificationRepository(db *DB) *EmailVerificationRepository {
+	return &EmailVerificationRepository{db: db}
+}
+
+// Create stores a token for a user
+func (r *EmailVerificationRepository) Create(userID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.Exec(
+		"INSERT INTO email_verifications (token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)",
+		tokenHash, userID.String(), expiresAt, time.Now().UTC(),
+	)
+	return err
+}
+
+// GetUserID returns the user a token was issued to and when it expires.
+// The ID is uuid.Nil if the token doesn't exist.
+func (r *EmailVerificationRepository) GetUserID(tokenHash string) (uuid.UUID, time.Time, error) {
+	var userID string
+	var expiresAt time.Time
+	err := r.db.QueryRow(
+		"SELECT user_id, expires_at FROM email_verifications WHERE token_hash = ?", tokenHash,
+	).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, time.Time{}, nil
+	}
+	if err != nil {
+		return uuid.Nil, time.Time{}, err
+	}
+
+	id, err := uuid.Parse(userID)
+	return id, expiresAt, err
+}
+
+// DeleteByUserID removes all of a user's tokens
+func (r *EmailVerificationRepository) DeleteByUserID(userID uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM email_verifications WHERE user_id = ?", userID.String())
+	return err
+}
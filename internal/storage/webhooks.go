@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/findosh/truenorth/internal/models"
+	"github.com/google/uuid"
+)
+
+// WebhookRepository provides access to alert webhooks and their delivery
+// state
+type WebhookRepository struct {
+	db *DB
+}
+
+// NewWebhookRepository creates a new webhook repository
+func NewWebhookRepository(db *DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create inserts a new webhook
+func (r *WebhookRepository) Create(w *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (id, user_id, url, secret, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query, w.ID.String(), w.UserID.String(), w.URL, w.Secret, w.CreatedAt)
+	return err
+}
+
+// GetByID retrieves a webhook by ID. Returns nil if not found.
+func (r *WebhookRepository) GetByID(id uuid.UUID) (*models.Webhook, error) {
+	query := `SELECT id, user_id, url, secret, created_at FROM webhooks WHERE id = ?`
+	rows, err := r.db.Query(query, id.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	return scanWebhookRow(rows)
+}
+
+// GetByUserID retrieves all of a user's webhooks, oldest first
+func (r *WebhookRepository) GetByUserID(userID uuid.UUID) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, user_id, url, secret, created_at
+		FROM webhooks WHERE user_id = ? ORDER BY created_at, id
+	`
+	rows, err := r.db.Query(query, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		w, err := scanWebhookRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete removes a webhook
+func (r *WebhookRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec("DELETE FROM webhooks WHERE id = ?", id.String())
+	return err
+}
+
+// RecordFailure adds a delivery to the dead-letter log
+func (r *WebhookRepository) RecordFailure(f *models.WebhookFailure) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = time.Now().UTC()
+	}
+
+	query := `
+		INSERT INTO webhook_failures (id, webhook_id, payload, error, attempts, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.Exec(query, f.ID.String(), f.WebhookID.String(), f.Payload, f.Error, f.Attempts, f.CreatedAt)
+	return err
+}
+
+// GetFailures returns a webhook's dead-lettered deliveries, newest first
+func (r *WebhookRepository) GetFailures(webhookID uuid.UUID) ([]models.WebhookFailure, error) {
+	query := `
+		SELECT id, payload, error, attempts, created_at
+		FROM webhook_failures WHERE webhook_id = ? ORDER BY created_at DESC
+	`
+	rows, err := r.db.Query(query, webhookID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var failures []models.WebhookFailure
+	for rows.Next() {
+		f := models.WebhookFailure{WebhookID: webhookID}
+		var id string
+		if err := rows.Scan(&id, &f.Payload, &f.Error, &f.Attempts, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.ID, _ = uuid.Parse(id)
+		failures = append(failures, f)
+	}
+
+	return failures, rows.Err()
+}
+
+// GetNotifiedAlertKeys returns the keys of the portfolio's alerts that have
+// already been sent
+func (r *WebhookRepository) GetNotifiedAlertKeys(portfolioID uuid.UUID) (map[string]bool, error) {
+	rows, err := r.db.Query("SELECT alert_key FROM notified_alerts WHERE portfolio_id = ?", portfolioID.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys[key] = true
+	}
+
+	return keys, rows.Err()
+}
+
+// SetNotifiedAlertKeys replaces the portfolio's sent alert keys with the
+// currently active ones. Alerts that have cleared are forgotten, so they are
+// sent again if they come back.
+func (r *WebhookRepository) SetNotifiedAlertKeys(portfolioID uuid.UUID, keys []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.db.Rebind("DELETE FROM notified_alerts WHERE portfolio_id = ?"), portfolioID.String()); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(r.db.Rebind("INSERT INTO notified_alerts (portfolio_id, alert_key, notified_at) VALUES (?, ?, ?)"))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, key := range keys {
+		if _, err := stmt.Exec(portfolioID.String(), key, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func scanWebhookRow(rows *sql.Rows) (*models.Webhook, error) {
+	w := &models.Webhook{}
+	var id, userID string
+	if err := rows.Scan(&id, &userID, &w.URL, &w.Secret, &w.CreatedAt); err != nil {
+		return nil, err
+	}
+	w.ID, _ = uuid.Parse(id)
+	w.UserID, _ = uuid.Parse(userID)
+	return w, nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
@@ -9,6 +12,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,53 +27,151 @@ type Story struct {
 	Time  int64  `json:"time"`
 }
 
+const (
+	// requestTimeout bounds a single HN API request
+	requestTimeout = 10 * time.Second
+	// maxAttempts is how many times a transient failure is tried
+	maxAttempts = 3
+	// retryBackoff is the wait before the first retry; it doubles after
+	retryBackoff = 500 * time.Millisecond
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
 type PageData struct {
 	Stories template.HTML
 }
 
-func fetchTopStories(limit int) ([]Story, error) {
-	resp, err := http.Get("https://hacker-news.firebaseio.com/v0/topstories.json")
-	if err != nil {
-		return nil, err
+// feedEndpoints maps each -feed name to its Firebase story list
+var feedEndpoints = map[string]string{
+	"top":  "topstories",
+	"new":  "newstories",
+	"best": "beststories",
+	"ask":  "askstories",
+	"show": "showstories",
+	"job":  "jobstories",
+}
+
+// feedURL returns the story list URL for a feed name
+func feedURL(feed string) (string, error) {
+	endpoint, ok := feedEndpoints[feed]
+	if !ok {
+		names := make([]string, 0, len(feedEndpoints))
+		for name := range feedEndpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf("unknown feed %q (want one of %s)", feed, strings.Join(names, ", "))
 	}
-	defer resp.Body.Close()
+	return fmt.Sprintf("https://hacker-news.firebaseio.com/v0/%s.json", endpoint), nil
+}
+
+func fetchStories(listURL string, limit, concurrency int) ([]Story, []error, error) {
 	var ids []int
-	if err := json.NewDecoder(resp.Body).Decode(&ids); err != nil {
-		return nil, err
+	if err := getJSON(listURL, &ids); err != nil {
+		return nil, nil, err
 	}
-	stories := make([]Story, 0, limit)
-	for i, id := range ids {
-		if i >= limit {
-			break
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	// Each worker writes only to its own index, so rank order is kept
+	// without any locking
+	results := make([]Story, len(ids))
+	errs := make([]error, len(ids))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = fetchStory(ids[i])
+			}
+		}()
+	}
+	for i := range ids {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	stories := make([]Story, 0, len(ids))
+	var fetchErrs []error
+	for i, story := range results {
+		if errs[i] != nil {
+			fetchErrs = append(fetchErrs, fmt.Errorf("story %d: %w", ids[i], errs[i]))
+			continue
 		}
-		story, err := fetchStory(id)
-		if err == nil && story.Title != "" {
+		if story.Title != "" {
 			stories = append(stories, story)
 		}
 	}
-	return stories, nil
+	return stories, fetchErrs, nil
 }
 
 func fetchStory(id int) (Story, error) {
-	resp, err := http.Get(fmt.Sprintf("https://hacker-news.firebaseio.com/v0/item/%d.json", id))
-	if err != nil {
-		return Story{}, err
-	}
-	defer resp.Body.Close()
 	var story Story
-	if err := json.NewDecoder(resp.Body).Decode(&story); err != nil {
+	if err := getJSON(fmt.Sprintf("https://hacker-news.firebaseio.com/v0/item/%d.json", id), &story); err != nil {
 		return Story{}, err
 	}
 	return story, nil
 }
 
+// getJSON decodes the response from url into v, retrying network errors,
+// rate limiting, and server errors with exponential backoff
+func getJSON(url string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout*maxAttempts)
+	defer cancel()
+
+	backoff := retryBackoff
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		var retry bool
+		retry, err = getJSONOnce(ctx, url, v)
+		if err == nil || !retry || attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// getJSONOnce makes a single request and reports whether a failure is
+// worth retrying
+func getJSONOnce(ctx context.Context, url string, v interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, err
+	}
+	return false, nil
+}
+
 func renderStoriesHTML(stories []Story) template.HTML {
 	html := ""
 	for _, s := range stories {
-		storyURL := s.URL
-		if storyURL == "" {
-			storyURL = fmt.Sprintf("https://news.ycombinator.com/item?id=%d", s.ID)
-		}
+		storyURL := storyLink(s)
 		timeStr := time.Unix(s.Time, 0).Format("Jan 2, 2006 15:04")
 		html += fmt.Sprintf(
 			`<div class="story">
@@ -80,12 +184,100 @@ func renderStoriesHTML(stories []Story) template.HTML {
 	return template.HTML(html)
 }
 
+// storyLink returns the story's own URL, or its HN discussion page for
+// text posts such as Ask HN
+func storyLink(s Story) string {
+	if s.URL != "" {
+		return s.URL
+	}
+	return discussionLink(s)
+}
+
+func discussionLink(s Story) string {
+	return fmt.Sprintf("https://news.ycombinator.com/item?id=%d", s.ID)
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Comments    string `xml:"comments"`
+	Creator     string `xml:"dc:creator"`
+	Description string `xml:"description"`
+	PubDate     string `xml:"pubDate"`
+	GUID        string `xml:"guid"`
+}
+
+// writeRSS writes the stories as an RSS 2.0 feed. The author goes in
+// dc:creator because RSS's own author element must be an email address.
+func writeRSS(w io.Writer, feed string, stories []Story) error {
+	doc := rss{
+		Version: "2.0",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       fmt.Sprintf("Hacker News: %s stories", feed),
+			Link:        "https://news.ycombinator.com/",
+			Description: "Stories from the Hacker News API",
+		},
+	}
+	for _, s := range stories {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       s.Title,
+			Link:        storyLink(s),
+			Comments:    discussionLink(s),
+			Creator:     s.By,
+			Description: fmt.Sprintf("%d points by %s", s.Score, s.By),
+			PubDate:     time.Unix(s.Time, 0).UTC().Format(time.RFC1123Z),
+			GUID:        discussionLink(s),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
 func main() {
-	stories, err := fetchTopStories(20)
+	feed := flag.String("feed", "top", "story feed: top, new, best, ask, show, or job")
+	limit := flag.Int("limit", 20, "number of stories to include")
+	concurrency := flag.Int("concurrency", 8, "number of stories to fetch in parallel")
+	withRSS := flag.Bool("rss", false, "also write an RSS feed to public/feed.xml")
+	flag.Parse()
+
+	listURL, err := feedURL(*feed)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(2)
+	}
+	if *limit < 1 {
+		fmt.Println("Error: -limit must be at least 1")
+		os.Exit(2)
+	}
+
+	stories, fetchErrs, err := fetchStories(listURL, *limit, *concurrency)
 	if err != nil {
 		fmt.Println("Error fetching stories:", err)
 		return
 	}
+	for _, err := range fetchErrs {
+		fmt.Println("Skipping story:", err)
+	}
 
 	tmpl, err := template.ParseFiles("templates/index.html")
 	if err != nil {
@@ -110,6 +302,19 @@ func main() {
 		return
 	}
 
+	if *withRSS {
+		rssFile, err := os.Create(filepath.Join("public", "feed.xml"))
+		if err != nil {
+			fmt.Println("Error creating feed.xml:", err)
+			return
+		}
+		defer rssFile.Close()
+		if err := writeRSS(rssFile, *feed, stories); err != nil {
+			fmt.Println("Error writing feed.xml:", err)
+			return
+		}
+	}
+
 	// Copy static assets
 	copyStatic("static", "public/static")
 
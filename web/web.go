@@ -0,0 +1,30 @@
+// Package web holds the HTML templates and static assets, embedded so the
+// server runs from a single binary
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed templates static
+var files embed.FS
+
+// Templates returns the embedded templates, laid out as layouts/, pages/
+// and components/
+func Templates() fs.FS {
+	return sub("templates")
+}
+
+// Static returns the embedded static assets served under /static/
+func Static() fs.FS {
+	return sub("static")
+}
+
+func sub(dir string) fs.FS {
+	fsys, err := fs.Sub(files, dir)
+	if err != nil {
+		panic(err) // dir is one of the embedded directories
+	}
+	return fsys
+}